@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/imtanmoy/authn/internal/errorx"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/imtanmoy/authn/auth"
@@ -15,6 +18,7 @@ import (
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/user"
 	"github.com/imtanmoy/httpx"
+	"github.com/imtanmoy/logx"
 	"gopkg.in/thedevsaddam/govalidator.v1"
 )
 
@@ -26,7 +30,7 @@ type loginPayload struct {
 func (o *loginPayload) validate() url.Values {
 	rules := govalidator.MapData{
 		"email":    []string{"required", "min:4", "max:100", "email"},
-		"password": []string{"required", "min:8", "max:20"},
+		"password": []string{"required"},
 	}
 	opts := govalidator.Options{
 		Data:  o,
@@ -49,8 +53,8 @@ func (rp *registerPayload) validate() url.Values {
 	rules := govalidator.MapData{
 		"name":             []string{"required", "min:4", "max:100"},
 		"email":            []string{"required", "min:4", "max:100", "email"},
-		"password":         []string{"required", "min:8", "max:20"},
-		"confirm_password": []string{"required", "min:8", "max:20"},
+		"password":         []string{"required"},
+		"confirm_password": []string{"required"},
 	}
 	opts := govalidator.Options{
 		Data:  rp,
@@ -68,23 +72,99 @@ func (rp *registerPayload) validate() url.Values {
 	return e
 }
 
+type refreshPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (rp *refreshPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"refresh_token": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  rp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type changePasswordPayload struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+	// RevokeOtherSessions logs out every other device, the response then
+	// carries a new token pair for this one
+	RevokeOtherSessions bool `json:"revoke_other_sessions"`
+}
+
+func (cp *changePasswordPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"current_password": []string{"required"},
+		"password":         []string{"required"},
+		"confirm_password": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  cp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	if cp.Password != "" && cp.ConfirmPassword != "" && cp.Password != cp.ConfirmPassword {
+		e.Add("password", "password and confirmation password do not match")
+		e.Add("confirm_password", "password and confirmation password do not match")
+	}
+	if cp.Password != "" && cp.Password == cp.CurrentPassword {
+		e.Add("password", "must differ from the current password")
+	}
+	return e
+}
+
+type logoutPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type UserResponse struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 func NewUserResponse(u *models.User) *UserResponse {
 	resp := &UserResponse{
-		ID:    u.ID,
-		Name:  u.Name,
-		Email: u.Email,
+		ID:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.IsEmailVerified(),
 	}
 	return resp
 }
 
 type loginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func newLoginResponse(pair *authx.TokenPair) *loginResponse {
+	return &loginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}
+}
+
+// mfaRequiredResponse answers a correct password of a user with a second
+// factor, MFAToken is exchanged for the token pair at /login/mfa or
+// /login/webauthn. MFAMethods are the amr values of the factors the user has.
+type mfaRequiredResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	MFAMethods  []string `json:"mfa_methods"`
+	ExpiresIn   int      `json:"expires_in"`
 }
 
 // AuthHandler  represent the http handler for auth
@@ -120,9 +200,21 @@ func (handler *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := authx.ClientIP(r)
+	if err := handler.CheckLogin(ctx, data.Email, ip); err != nil {
+		var be *authx.LoginBlockedError
+		if !errors.As(err, &be) {
+			panic(err)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+		httpx.ResponseJSONError(w, r, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return
+	}
+
 	u, err := handler.useCase.FindByEmail(ctx, data.Email)
 	if err != nil {
 		if errors.Is(err, errorx.ErrorNotFound) {
+			handler.loginFailed(ctx, nil, data.Email, ip)
 			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid credentials", err)
 		} else {
 			panic(err)
@@ -130,22 +222,167 @@ func (handler *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !handler.VerifyPassword(u, data.Password) {
+		handler.loginFailed(ctx, u, data.Email, ip)
 		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid credentials", err)
 		return
 	}
+	if err := handler.LoginSucceeded(ctx, data.Email); err != nil {
+		panic(err)
+	}
+	if err := handler.CheckEmailVerified(u); err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "email address is not verified", err)
+		return
+	}
+	if handler.PasswordNeedsRehash(u) {
+		handler.rehashPassword(ctx, u, data.Password)
+	}
+
+	methods, err := handler.SecondFactorMethods(ctx, u)
+	if err != nil {
+		panic(err)
+	}
+	if len(methods) > 0 {
+		token, err := handler.GenerateMFAToken(u)
+		if err != nil {
+			panic(err)
+		}
+		httpx.ResponseJSON(w, http.StatusOK, &mfaRequiredResponse{
+			MFARequired: true,
+			MFAToken:    token,
+			MFAMethods:  methods,
+			ExpiresIn:   handler.MFATokenExpiresIn(),
+		})
+		return
+	}
+	pair, err := handler.GenerateTokenPair(ctx, u, authx.WithAuthMethods(authx.AuthMethodPassword))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, newLoginResponse(pair))
+	return
+}
 
-	token, err := handler.GenerateToken(u.Email)
+// loginFailed counts a failed login against the email, whether or not u exists,
+// and the source IP. Locking an existing account is announced on the bus.
+func (handler *AuthHandler) loginFailed(ctx context.Context, u *models.User, email, ip string) {
+	lockedUntil, err := handler.LoginFailed(ctx, email, ip)
 	if err != nil {
 		panic(err)
 	}
-	res := &loginResponse{Token: token}
-	httpx.ResponseJSON(w, http.StatusOK, res)
+	if u == nil || lockedUntil.IsZero() {
+		return
+	}
+	handler.event.Emit(ctx, events.UserLockedEvent, events.UserLocked{
+		UserID:      u.ID,
+		Email:       u.Email,
+		IP:          ip,
+		LockedUntil: lockedUntil,
+	})
+}
+
+// rehashPassword upgrades the stored hash of u to the preferred algorithm and
+// parameters. Failures are only logged, the login itself already succeeded.
+func (handler *AuthHandler) rehashPassword(ctx context.Context, u *models.User, password string) {
+	hash, err := handler.HashPassword(password)
+	if err != nil {
+		logx.Errorf("could not rehash password of user %d: %s", u.ID, err)
+		return
+	}
+	u.PutPassword(hash)
+	if err := handler.useCase.UpdatePassword(ctx, u); err != nil {
+		logx.Errorf("could not save rehashed password of user %d: %s", u.ID, err)
+	}
+}
+
+// Refresh Handler rotates a refresh token and issues a new token pair
+func (handler *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &refreshPayload{}
+
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+		} else {
+			httpx.ResponseJSONError(w, r, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	validationErrors := data.validate()
+
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	pair, err := handler.RefreshTokenPair(ctx, data.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, errorx.ErrInvalidToken),
+			errors.Is(err, errorx.ErrTokenExpired),
+			errors.Is(err, errorx.ErrTokenReused):
+			httpx.ResponseJSONError(w, r, http.StatusUnauthorized, "invalid refresh token", err)
+		default:
+			panic(err)
+		}
+		return
+	}
+	httpx.ResponseJSON(w, http.StatusOK, newLoginResponse(pair))
 	return
 }
 
-// Logout Handler
+// Logout Handler revokes the presented access token and, when given, the
+// refresh token family issued alongside it
 func (handler *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	//TODO need to work on meaning full logout
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &logoutPayload{}
+	if r.ContentLength != 0 {
+		if err := httpx.DecodeJSON(r, data); err != nil {
+			var mr *httpx.MalformedRequest
+			if errors.As(err, &mr) {
+				httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+				return
+			}
+			panic(err)
+		}
+	}
+	u, err := handler.GetCurrentUser(r)
+	if err != nil {
+		panic(err)
+	}
+	claims, err := handler.GetCurrentClaims(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := handler.RevokeToken(ctx, u, claims); err != nil {
+		panic(err)
+	}
+	if err := handler.RevokeRefreshToken(ctx, u, data.RefreshToken); err != nil {
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// LogoutAll Handler revokes every token issued to the current user
+func (handler *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	u, err := handler.GetCurrentUser(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := handler.RevokeAllTokens(ctx, u, time.Now()); err != nil {
+		panic(err)
+	}
 	httpx.NoContent(w)
 }
 
@@ -164,6 +401,113 @@ func (handler *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// ChangePassword handler sets a new password after checking the current one.
+// Wrong current passwords count as failed logins.
+func (handler *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &changePasswordPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	au, err := handler.GetCurrentUser(r)
+	if err != nil {
+		panic(err)
+	}
+	// the context user is loaded without the password hash
+	u, err := handler.useCase.FindByEmail(ctx, au.GetEmail())
+	if err != nil {
+		panic(err)
+	}
+	claims, err := handler.GetCurrentClaims(r)
+	if err != nil {
+		panic(err)
+	}
+
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	ip := authx.ClientIP(r)
+	if err := handler.CheckLogin(ctx, u.Email, ip); err != nil {
+		var be *authx.LoginBlockedError
+		if !errors.As(err, &be) {
+			panic(err)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+		httpx.ResponseJSONError(w, r, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return
+	}
+	if !handler.VerifyPassword(u, data.CurrentPassword) {
+		handler.loginFailed(ctx, u, u.Email, ip)
+		validationErrors.Add("current_password", "current password is incorrect")
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	if err := handler.LoginSucceeded(ctx, u.Email); err != nil {
+		panic(err)
+	}
+	handler.validatePassword(ctx, validationErrors, data.Password, claims.OrganizationID, u.Email, u.Name)
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	hash, err := handler.HashPassword(data.Password)
+	if err != nil {
+		panic(err)
+	}
+	u.PutPassword(hash)
+	if err := handler.useCase.UpdatePassword(ctx, u); err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	handler.event.Emit(ctx, events.UserPasswordChangedEvent, events.UserPasswordChanged{
+		UserID:          u.ID,
+		Email:           u.Email,
+		IP:              ip,
+		SessionsRevoked: data.RevokeOtherSessions,
+		ChangedAt:       now.UTC(),
+	})
+
+	if !data.RevokeOtherSessions {
+		httpx.NoContent(w)
+		return
+	}
+	if err := handler.RevokeAllTokens(ctx, u, now); err != nil {
+		panic(err)
+	}
+	pair, err := handler.GenerateTokenPair(ctx, u, authx.WithAuthMethods(claims.AuthMethods...))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, newLoginResponse(pair))
+}
+
+// validatePassword adds the password policy violations to validationErrors
+func (handler *AuthHandler) validatePassword(ctx context.Context, validationErrors url.Values, password string, organizationID int, userInputs ...string) {
+	err := handler.ValidatePassword(ctx, password, organizationID, userInputs...)
+	if err == nil {
+		return
+	}
+	var pe *authx.PolicyError
+	if !errors.As(err, &pe) {
+		panic(err)
+	}
+	for field, messages := range pe.Values("password") {
+		validationErrors[field] = append(validationErrors[field], messages...)
+	}
+}
+
 // Register handler
 func (handler *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -185,6 +529,9 @@ func (handler *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if handler.userUseCase.ExistsByEmail(ctx, data.Email) {
 		validationErrors.Add("email", "user with this email already exists")
 	}
+	if data.Password != "" {
+		handler.validatePassword(ctx, validationErrors, data.Password, 0, data.Email, data.Name)
+	}
 
 	if len(validationErrors) > 0 {
 		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
@@ -212,6 +559,17 @@ func (handler *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// JWKS handler publishes the public keys tokens are signed with
+func (handler *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := handler.Authx.JWKS()
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	httpx.ResponseJSON(w, http.StatusOK, set)
+	return
+}
+
 // NewHandler will initialize the user's resources endpoint
 func NewHandler(
 	r *chi.Mux,
@@ -229,10 +587,14 @@ func NewHandler(
 	r.Route("/", func(r chi.Router) {
 		r.Post("/login", handler.Login)
 		r.Post("/register", handler.Register)
+		r.Post("/token/refresh", handler.Refresh)
+		r.Get("/.well-known/jwks.json", handler.JWKS)
 		r.Group(func(r chi.Router) {
 			r.Use(handler.AuthMiddleware)
 			r.Post("/logout", handler.Logout)
+			r.Post("/logout/all", handler.LogoutAll)
 			r.Get("/me", handler.GetMe)
+			r.Post("/me/password", handler.ChangePassword)
 		})
 	})
 }
@@ -2,13 +2,16 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/go-chi/chi"
 	_authUseCase "github.com/imtanmoy/authn/auth/usecase"
 	"github.com/imtanmoy/authn/internal/authx"
+	_lockoutRepo "github.com/imtanmoy/authn/lockout/repository"
 	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
 	_userRepo "github.com/imtanmoy/authn/user/repository"
 	_userUseCase "github.com/imtanmoy/authn/user/usecase"
 	"github.com/jackc/pgx/v4"
@@ -45,13 +48,23 @@ func init() {
 func setup() {
 	timeoutContext := 30 * time.Millisecond * time.Second
 	userRepo := _userRepo.NewPgxRepository(conn)
+	tokenRepo := _tokenRepo.NewPgxRepository(conn)
+	lockoutRepo := _lockoutRepo.NewPgxRepository(conn)
 
 	authxConfig := authx.AuthxConfig{
-		SecretKey:             "test",
-		AccessTokenExpireTime: 1,
+		SecretKey:              "test",
+		AccessTokenExpireTime:  1,
+		RefreshTokenExpireTime: 5,
 	}
 
-	aux = authx.New(userRepo, &authxConfig)
+	aux = authx.New(userRepo, &authxConfig,
+		authx.WithRefreshTokenRepo(tokenRepo),
+		authx.WithRevocationRepo(tokenRepo),
+		authx.WithLoginLockout(lockoutRepo,
+			authx.LockoutPolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, LockAfter: 4, LockDuration: time.Hour, Window: time.Hour},
+			authx.LockoutPolicy{FreeAttempts: 100, BaseDelay: time.Second, Window: time.Hour},
+		),
+	)
 
 	evt := tests.NewMockEventEmitter()
 	userUseCase := _userUseCase.NewUseCase(userRepo, timeoutContext)
@@ -81,6 +94,26 @@ func TestAuthHandler_Login(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		var got *loginResponse
+		err := json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, got.Token)
+		assert.NotEmpty(t, got.RefreshToken)
+
+		var hash string
+		err = db.QueryRow("SELECT password FROM users WHERE email = $1", payload.Email).Scan(&hash)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$"), "the seeded bcrypt hash is upgraded")
+	})
+
+	t.Run("Login with wrong password", func(t *testing.T) {
+		bodyRequest, _ := json.Marshal(&loginPayload{Email: "test@test.com", Password: "wrong1234"})
+		req, _ := http.NewRequest("POST", ts.URL+"/login", bytes.NewReader(bodyRequest))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 
 	t.Run("Login with wrong credentials", func(t *testing.T) {
@@ -99,6 +132,74 @@ func TestAuthHandler_Login(t *testing.T) {
 		body := w.Body.Bytes()
 		assert.Contains(t, string(body), "invalid credentials")
 	})
+
+	t.Run("Login is throttled after repeated failures", func(t *testing.T) {
+		login := func(password string) *httptest.ResponseRecorder {
+			bodyRequest, _ := json.Marshal(&loginPayload{Email: "test@test.com", Password: password})
+			req, _ := http.NewRequest("POST", ts.URL+"/login", bytes.NewReader(bodyRequest))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+		// the wrong password above was the first failure
+		assert.Equal(t, http.StatusBadRequest, login("wrong1234").Code)
+		assert.Equal(t, http.StatusBadRequest, login("wrong1234").Code)
+
+		w := login("password")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, "even the right password has to wait")
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		assert.Nil(t, aux.UnlockLogin(context.Background(), "test@test.com"))
+		assert.Equal(t, http.StatusOK, login("password").Code)
+	})
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tests.SeedUser(db)
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		bodyRequest, _ := json.Marshal(&refreshPayload{RefreshToken: refreshToken})
+		req, _ := http.NewRequest("POST", ts.URL+"/token/refresh", bytes.NewReader(bodyRequest))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	bodyRequest, _ := json.Marshal(&loginPayload{Email: "test@test.com", Password: "password"})
+	req, _ := http.NewRequest("POST", ts.URL+"/login", bytes.NewReader(bodyRequest))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var login *loginResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &login)
+
+	var rotated *loginResponse
+	t.Run("Refresh with valid token", func(t *testing.T) {
+		w := refresh(login.RefreshToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		err := json.Unmarshal(w.Body.Bytes(), &rotated)
+		assert.Nil(t, err)
+		assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+	})
+
+	t.Run("Refresh with reused token revokes family", func(t *testing.T) {
+		w := refresh(login.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = refresh(rotated.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Refresh with invalid token", func(t *testing.T) {
+		w := refresh("invalid")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid refresh token")
+	})
 }
 
 func TestAuthHandler_Register(t *testing.T) {
@@ -113,8 +214,8 @@ func TestAuthHandler_Register(t *testing.T) {
 		payload := &registerPayload{
 			Name:            "Test",
 			Email:           "test@test.com",
-			Password:        "password",
-			ConfirmPassword: "password",
+			Password:        "correct horse battery staple",
+			ConfirmPassword: "correct horse battery staple",
 		}
 		bodyRequest, _ := json.Marshal(payload)
 		req, _ := http.NewRequest("POST", ts.URL+"/register", bytes.NewReader(bodyRequest))
@@ -169,6 +270,28 @@ func TestAuthHandler_Register(t *testing.T) {
 		assert.True(t, got)
 	})
 
+	t.Run("Register failed for password policy", func(t *testing.T) {
+		payload := &registerPayload{
+			Name:            "Test",
+			Email:           "policy@test.com",
+			Password:        "policy123",
+			ConfirmPassword: "policy123",
+		}
+		bodyRequest, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", ts.URL+"/register", bytes.NewReader(bodyRequest))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var got struct {
+			Errors map[string][]string `json:"errors"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Nil(t, err)
+		assert.Contains(t, got.Errors, "password.user_info")
+	})
+
 	t.Run("Register failed for email already exist", func(t *testing.T) {
 		// POST register
 		payload := &registerPayload{
@@ -211,6 +334,46 @@ func TestAuthHandler_Logout(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// the token is rejected once revoked
+	req, _ = http.NewRequest("GET", ts.URL+"/me", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthHandler_LogoutAll(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tests.SeedUser(db)
+
+	first, err := aux.GenerateToken("test@test.com")
+	if err != nil {
+		panic(err)
+	}
+	second, err := aux.GenerateToken("test@test.com")
+	if err != nil {
+		panic(err)
+	}
+
+	req, _ := http.NewRequest("POST", ts.URL+"/logout/all", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", first))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	for _, token := range []string{first, second} {
+		req, _ := http.NewRequest("GET", ts.URL+"/me", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
 }
 
 func TestAuthHandler_GetMe(t *testing.T) {
@@ -240,3 +403,96 @@ func TestAuthHandler_GetMe(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "test@test.com", got.Email)
 }
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tests.SeedUser(db)
+
+	token, err := aux.GenerateToken("test@test.com")
+	if err != nil {
+		panic(err)
+	}
+	changePassword := func(token string, payload *changePasswordPayload) *httptest.ResponseRecorder {
+		bodyRequest, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", ts.URL+"/me/password", bytes.NewReader(bodyRequest))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Change password with wrong current password", func(t *testing.T) {
+		w := changePassword(token, &changePasswordPayload{
+			CurrentPassword: "wrong-password",
+			Password:        "Vq8#mZt!kLw2",
+			ConfirmPassword: "Vq8#mZt!kLw2",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "current_password")
+	})
+
+	t.Run("Change password failed for password and confirm password doest not match", func(t *testing.T) {
+		w := changePassword(token, &changePasswordPayload{
+			CurrentPassword: "password",
+			Password:        "Vq8#mZt!kLw2",
+			ConfirmPassword: "Vq8#mZt!kLw3",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Change password with success", func(t *testing.T) {
+		w := changePassword(token, &changePasswordPayload{
+			CurrentPassword: "password",
+			Password:        "Vq8#mZt!kLw2",
+			ConfirmPassword: "Vq8#mZt!kLw2",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		bodyRequest, _ := json.Marshal(&loginPayload{Email: "test@test.com", Password: "Vq8#mZt!kLw2"})
+		req, _ := http.NewRequest("POST", ts.URL+"/login", bytes.NewReader(bodyRequest))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Change password revoking other sessions", func(t *testing.T) {
+		w := changePassword(token, &changePasswordPayload{
+			CurrentPassword:     "Vq8#mZt!kLw2",
+			Password:            "correct horse battery staple",
+			ConfirmPassword:     "correct horse battery staple",
+			RevokeOtherSessions: true,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var got *loginResponse
+		err := json.Unmarshal(w.Body.Bytes(), &got)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, got.Token)
+
+		for token, code := range map[string]int{token: http.StatusUnauthorized, got.Token: http.StatusOK} {
+			req, _ := http.NewRequest("GET", ts.URL+"/me", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, code, w.Code)
+		}
+	})
+}
+
+func TestAuthHandler_JWKS(t *testing.T) {
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got *authx.JSONWebKeySet
+	err := json.Unmarshal(w.Body.Bytes(), &got)
+	assert.Nil(t, err)
+	assert.NotNil(t, got.Keys)
+}
@@ -9,4 +9,5 @@ import (
 type UseCase interface {
 	ExistsByEmail(ctx context.Context, email string) bool
 	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdatePassword(ctx context.Context, u *models.User) error
 }
@@ -25,6 +25,10 @@ func (uc *useCase) FindByEmail(ctx context.Context, email string) (*models.User,
 	return uc.userRepo.FindByEmail(ctx, email)
 }
 
+func (uc *useCase) UpdatePassword(ctx context.Context, u *models.User) error {
+	return uc.userRepo.UpdatePassword(ctx, u)
+}
+
 func NewUseCase(userRepo user.Repository, contextTimeout time.Duration) auth.UseCase {
 	return &useCase{userRepo: userRepo, contextTimeout: contextTimeout}
 }
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/imtanmoy/authn/config"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/logx"
+	"github.com/spf13/cobra"
+)
+
+var (
+	breachIndexOut      string
+	breachIndexMinCount int
+	breachIndexFPRate   float64
+)
+
+func init() {
+	indexBreachCmd.Flags().StringVar(&breachIndexOut, "out", "", "path of the index, defaults to password.breach_index")
+	indexBreachCmd.Flags().IntVar(&breachIndexMinCount, "min-count", 0, "only index passwords seen at least this many times, defaults to password.breach_threshold")
+	indexBreachCmd.Flags().Float64Var(&breachIndexFPRate, "fp-rate", 0.001, "false positive rate of the index")
+	breachCmd.AddCommand(indexBreachCmd)
+	rootCmd.AddCommand(breachCmd)
+}
+
+var breachCmd = &cobra.Command{
+	Use:   "breach",
+	Short: "Manage the breached password corpus",
+}
+
+var indexBreachCmd = &cobra.Command{
+	Use:   "index [hibp-file]",
+	Short: "Build a bloom filter index of a Have I Been Pwned SHA-1 file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		out := breachIndexOut
+		if out == "" {
+			out = config.Conf.PASSWORD.BreachIndex
+		}
+		if out == "" {
+			logx.Fatal("--out or password.breach_index is required")
+		}
+		minCount := breachIndexMinCount
+		if minCount == 0 {
+			minCount = config.Conf.PASSWORD.BreachThreshold
+		}
+		if minCount < 1 {
+			minCount = 1
+		}
+
+		// the first pass sizes the filter for the hashes it will hold
+		n, err := countBreachedHashes(args[0], minCount)
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not read hash file", err)
+		}
+		in, err := os.Open(args[0])
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not read hash file", err)
+		}
+		defer in.Close()
+		f, err := os.Create(out)
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not create index", err)
+		}
+		w := bufio.NewWriter(f)
+		added, err := authx.BuildBloomIndex(bufio.NewReader(in), w, n, minCount, breachIndexFPRate)
+		if err == nil {
+			err = w.Flush()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not build index", err)
+		}
+		logx.Infof("indexed %d hashes seen at least %d times into %s", added, minCount, out)
+	},
+}
+
+func countBreachedHashes(path string, minCount int) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		if count, err := strconv.Atoi(strings.TrimSpace(line[i+1:])); err == nil && count >= minCount {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
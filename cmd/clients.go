@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imtanmoy/authn/config"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/oauth"
+	_oauthRepo "github.com/imtanmoy/authn/oauth/repository"
+	_oauthUseCase "github.com/imtanmoy/authn/oauth/usecase"
+	"github.com/imtanmoy/authn/registry"
+	"github.com/imtanmoy/logx"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientName         string
+	clientPublic       bool
+	clientRedirectURIs []string
+	clientScopes       []string
+)
+
+func init() {
+	createClientCmd.Flags().StringVar(&clientName, "name", "", "name of the client, e.g. the resource server using it")
+	createClientCmd.Flags().BoolVar(&clientPublic, "public", false, "register a public client, e.g. a browser or mobile app, which has no secret")
+	createClientCmd.Flags().StringSliceVar(&clientRedirectURIs, "redirect-uri", nil, "redirect URI of the authorization code flow, may be repeated")
+	createClientCmd.Flags().StringSliceVar(&clientScopes, "scope", nil, "scope the client may ask for, may be repeated")
+	_ = createClientCmd.MarkFlagRequired("name")
+	clientsCmd.AddCommand(createClientCmd)
+	rootCmd.AddCommand(clientsCmd)
+}
+
+var clientsCmd = &cobra.Command{
+	Use:   "clients",
+	Short: "Manage OAuth2 clients",
+}
+
+var createClientCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Register a client and print its credentials",
+	Run: func(cmd *cobra.Command, args []string) {
+		r := registry.NewRegistry(config.Conf)
+		defer r.Close()
+		conn, err := stdlib.AcquireConn(r.DB())
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not acquire connection", err)
+		}
+		useCase := _oauthUseCase.NewUseCase(_oauthRepo.NewPgxRepository(conn), &oauth.Config{
+			Scopes: config.Conf.OAUTH.Scopes,
+		}, 0)
+
+		c := &models.OAuthClient{
+			Name:         clientName,
+			Public:       clientPublic,
+			RedirectURIs: clientRedirectURIs,
+			Scopes:       clientScopes,
+		}
+		secret, err := useCase.CreateClient(context.Background(), c)
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not create client", err)
+		}
+		fmt.Printf("client_id: %s\n", c.ClientID)
+		if !c.Public {
+			fmt.Printf("client_secret: %s\n", secret)
+		}
+	},
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/imtanmoy/authn/config"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/logx"
+	"github.com/spf13/cobra"
+)
+
+var keyAlgorithm string
+
+func init() {
+	rotateKeysCmd.Flags().StringVar(&keyAlgorithm, "alg", "ES256", "signing algorithm of the new key (RS256, ES256, ES384, ES512, EdDSA)")
+	keysCmd.AddCommand(listKeysCmd, rotateKeysCmd, promoteKeyCmd, retireKeyCmd)
+	rootCmd.AddCommand(keysCmd)
+}
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the token signing keyring",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if config.Conf.JwtKeyringDir == "" {
+			return errors.New("jwt_keyring_dir is not configured")
+		}
+		return nil
+	},
+}
+
+var listKeysCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys of the keyring",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := authx.ListKeys(config.Conf.JwtKeyringDir)
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not read keyring", err)
+		}
+		for _, k := range keys {
+			fmt.Printf("%s\t%s\t%s\t%s\n", k.ID, k.Algorithm, k.State, k.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+	},
+}
+
+var rotateKeysCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Generate and publish a new key, promote it once cached JWKS expired",
+	Run: func(cmd *cobra.Command, args []string) {
+		entry, err := authx.RotateKeyring(config.Conf.JwtKeyringDir, keyAlgorithm)
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not rotate keyring", err)
+		}
+		logx.Infof("new %s key %s (%s)", entry.State, entry.ID, entry.Algorithm)
+	},
+}
+
+var promoteKeyCmd = &cobra.Command{
+	Use:   "promote [kid]",
+	Short: "Sign new tokens with a key, the current one keeps verifying",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authx.PromoteKey(config.Conf.JwtKeyringDir, args[0]); err != nil {
+			logx.Fatalf("%s : %s", "could not promote key", err)
+		}
+		logx.Infof("key %s is active", args[0])
+	},
+}
+
+var retireKeyCmd = &cobra.Command{
+	Use:   "retire [kid]",
+	Short: "Stop accepting tokens signed with a key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authx.RetireKey(config.Conf.JwtKeyringDir, args[0]); err != nil {
+			logx.Fatalf("%s : %s", "could not retire key", err)
+		}
+		logx.Infof("key %s retired", args[0])
+	},
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/imtanmoy/authn/config"
+	"github.com/imtanmoy/authn/internal/authx"
+	_lockoutRepo "github.com/imtanmoy/authn/lockout/repository"
+	"github.com/imtanmoy/authn/registry"
+	"github.com/imtanmoy/authn/user/importer"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	_userUseCase "github.com/imtanmoy/authn/user/usecase"
+	"github.com/imtanmoy/logx"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFormat  string
+	importOptions importer.Options
+	unlockIP      bool
+)
+
+func init() {
+	flags := importUsersCmd.Flags()
+	flags.StringVar(&importFormat, "format", "", "json or csv, guessed from the file extension when empty")
+	flags.StringVar(&importOptions.FirebaseSignerKey, "firebase-signer-key", "", "base64 signer key of the Firebase project")
+	flags.StringVar(&importOptions.FirebaseSaltSeparator, "firebase-salt-separator", "", "base64 salt separator of the Firebase project")
+	flags.IntVar(&importOptions.FirebaseRounds, "firebase-rounds", 8, "rounds of the Firebase project")
+	flags.IntVar(&importOptions.FirebaseMemCost, "firebase-mem-cost", 14, "memory cost of the Firebase project")
+	flags.BoolVar(&importOptions.SaltSuffix, "salt-suffix", false, "salted_sha256 hashes were computed over password+salt")
+	unlockUserCmd.Flags().BoolVar(&unlockIP, "ip", false, "the argument is a source IP instead of an email")
+	usersCmd.AddCommand(importUsersCmd, unlockUserCmd)
+	rootCmd.AddCommand(usersCmd)
+}
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage users",
+}
+
+var importUsersCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import users with their password hashes from a JSON or CSV file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not open import file", err)
+		}
+		defer f.Close()
+
+		format := importFormat
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(args[0])), ".")
+		}
+		var records []*importer.Record
+		switch format {
+		case "json":
+			records, err = importer.ParseJSON(f)
+		case "csv":
+			records, err = importer.ParseCSV(f)
+		default:
+			logx.Fatalf("unknown import format %q", format)
+		}
+		if err != nil {
+			logx.Fatal(err)
+		}
+
+		r := registry.NewRegistry(config.Conf)
+		defer r.Close()
+		conn, err := stdlib.AcquireConn(r.DB())
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not acquire connection", err)
+		}
+		useCase := _userUseCase.NewUseCase(_userRepo.NewPgxRepository(conn), 0)
+
+		result, err := useCase.Import(context.Background(), records, &importOptions)
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not import users", err)
+		}
+		for _, e := range result.Failed {
+			logx.Errorf("row %d (%s): %s", e.Row, e.Email, e.Error)
+		}
+		logx.Infof("imported %d of %d users", result.Imported, len(records))
+	},
+}
+
+var unlockUserCmd = &cobra.Command{
+	Use:   "unlock [email]",
+	Short: "Lift the lockout of an account, or of a source IP with --ip, after failed logins",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := authx.AccountLoginKey(args[0])
+		if unlockIP {
+			ip := net.ParseIP(args[0])
+			if ip == nil {
+				logx.Fatalf("invalid IP %q", args[0])
+			}
+			key = authx.IPLoginKey(ip.String())
+		}
+
+		r := registry.NewRegistry(config.Conf)
+		defer r.Close()
+		conn, err := stdlib.AcquireConn(r.DB())
+		if err != nil {
+			logx.Fatalf("%s : %s", "could not acquire connection", err)
+		}
+		if err := _lockoutRepo.NewPgxRepository(conn).DeleteLoginAttempt(context.Background(), key); err != nil {
+			logx.Fatalf("%s : %s", "could not unlock", err)
+		}
+		logx.Infof("unlocked %s", args[0])
+	},
+}
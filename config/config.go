@@ -10,12 +10,32 @@ import (
 
 // Config contains env variables
 type Config struct {
-	ENVIRONMENT           string `mapstructure:"environment"`
-	DEBUG                 bool   `mapstructure:"debug"`
-	JwtSecretKey          string `mapstructure:"jwt_secret_key"`
-	JwtAccessTokenExpires int    `mapstructure:"jwt_access_token_expires"`
-	SERVER                Server
-	DB                    DB
+	ENVIRONMENT            string   `mapstructure:"environment"`
+	DEBUG                  bool     `mapstructure:"debug"`
+	JwtSecretKey           string   `mapstructure:"jwt_secret_key"`
+	JwtAccessTokenExpires  int      `mapstructure:"jwt_access_token_expires"`
+	JwtRefreshTokenExpires int      `mapstructure:"jwt_refresh_token_expires"`
+	JwtSigningKeyFile      string   `mapstructure:"jwt_signing_key_file"`
+	JwtSigningKeyID        string   `mapstructure:"jwt_signing_key_id"`
+	JwtKeyringDir          string   `mapstructure:"jwt_keyring_dir"`
+	JwtKeyringReload       int      `mapstructure:"jwt_keyring_reload"`
+	JwtIssuer              string   `mapstructure:"jwt_issuer"`
+	JwtAudience            string   `mapstructure:"jwt_audience"`
+	AdminEmails            []string `mapstructure:"admin_emails"`
+	SERVER                 Server
+	DB                     DB
+	PASSWORD               Password
+	LOCKOUT                Lockout
+	RATELIMIT              RateLimit     `mapstructure:"rate_limit"`
+	PASSWORDRESET          PasswordReset `mapstructure:"password_reset"`
+	CONFIRMATION           Confirmation
+	INVITATION             Invitation
+	ORGANIZATION           Organization
+	MFA                    MFA
+	WEBAUTHN               WebAuthn
+	PASSWORDLESS           Passwordless
+	OAUTH                  OAuth
+	MAIL                   Mail
 }
 
 type Server struct {
@@ -31,6 +51,159 @@ type DB struct {
 	DBNAME   string `mapstructure:"db_name"`
 }
 
+type Password struct {
+	Hasher            string `mapstructure:"hasher"`
+	Argon2Memory      uint32 `mapstructure:"argon2_memory"`
+	Argon2Iterations  uint32 `mapstructure:"argon2_iterations"`
+	Argon2Parallelism uint8  `mapstructure:"argon2_parallelism"`
+	BcryptCost        int    `mapstructure:"bcrypt_cost"`
+	ScryptN           int    `mapstructure:"scrypt_n"`
+	ScryptR           int    `mapstructure:"scrypt_r"`
+	ScryptP           int    `mapstructure:"scrypt_p"`
+	MinLength         int    `mapstructure:"min_length"`
+	MaxLength         int    `mapstructure:"max_length"`
+	RequireUpper      bool   `mapstructure:"require_upper"`
+	RequireLower      bool   `mapstructure:"require_lower"`
+	RequireDigit      bool   `mapstructure:"require_digit"`
+	RequireSymbol     bool   `mapstructure:"require_symbol"`
+	CheckUserInfo     bool   `mapstructure:"check_user_info"`
+	MinScore          int    `mapstructure:"min_score"`
+	DenylistFile      string `mapstructure:"denylist_file"`
+	BreachFile        string `mapstructure:"breach_file"`
+	BreachIndex       string `mapstructure:"breach_index"`
+	BreachThreshold   int    `mapstructure:"breach_threshold"`
+}
+
+// Lockout throttles failed logins per account and per source IP, durations
+// are in seconds
+type Lockout struct {
+	Enabled             bool `mapstructure:"enabled"`
+	AccountFreeAttempts int  `mapstructure:"account_free_attempts"`
+	AccountLockAfter    int  `mapstructure:"account_lock_after"`
+	IPFreeAttempts      int  `mapstructure:"ip_free_attempts"`
+	IPLockAfter         int  `mapstructure:"ip_lock_after"`
+	BaseDelay           int  `mapstructure:"base_delay"`
+	MaxDelay            int  `mapstructure:"max_delay"`
+	LockDuration        int  `mapstructure:"lock_duration"`
+	Window              int  `mapstructure:"window"`
+}
+
+// RateLimit limits request rates, the first policy matching a request applies
+type RateLimit struct {
+	Enabled  bool              `mapstructure:"enabled"`
+	Backend  string            `mapstructure:"backend"`
+	Policies []RateLimitPolicy `mapstructure:"policies"`
+}
+
+// RateLimitPolicy allows Limit requests per Period seconds for each key
+type RateLimitPolicy struct {
+	Name   string `mapstructure:"name"`
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	Limit  int    `mapstructure:"limit"`
+	Period int    `mapstructure:"period"`
+	Key    string `mapstructure:"key"`
+}
+
+// PasswordReset configures the reset emails
+type PasswordReset struct {
+	URL      string `mapstructure:"url"`
+	TokenTTL int    `mapstructure:"token_ttl"`
+}
+
+// Confirmation configures the email confirmation emails
+type Confirmation struct {
+	URL      string `mapstructure:"url"`
+	TokenTTL int    `mapstructure:"token_ttl"`
+	// ResendInterval is the minimum number of seconds between two emails
+	ResendInterval int `mapstructure:"resend_interval"`
+	// RequireVerified refuses logins until the email address is confirmed
+	RequireVerified bool `mapstructure:"require_verified"`
+}
+
+// Invitation configures the organization invitation emails
+type Invitation struct {
+	URL      string `mapstructure:"url"`
+	TokenTTL int    `mapstructure:"token_ttl"`
+}
+
+// Organization configures the organization lifecycle
+type Organization struct {
+	// RetentionDays is how long deleted organizations can be restored
+	RetentionDays int `mapstructure:"retention_days"`
+}
+
+// MFA configures two-factor authentication
+type MFA struct {
+	// Issuer names the service in authenticator apps
+	Issuer string `mapstructure:"issuer"`
+	// EncryptionKey encrypts the TOTP secrets at rest
+	EncryptionKey string `mapstructure:"encryption_key"`
+	RecoveryCodes int    `mapstructure:"recovery_codes"`
+	// TokenTTL is the lifetime, in minutes, of the tokens exchanged for the
+	// second factor
+	TokenTTL int `mapstructure:"token_ttl"`
+}
+
+// WebAuthn configures the relying party of passkeys
+type WebAuthn struct {
+	// RPID is the domain passkeys are scoped to
+	RPID   string `mapstructure:"rp_id"`
+	RPName string `mapstructure:"rp_name"`
+	// Origins are the web origins allowed to run the ceremonies
+	Origins []string `mapstructure:"origins"`
+	// Timeout is the time, in seconds, to complete a ceremony
+	Timeout          int    `mapstructure:"timeout"`
+	UserVerification string `mapstructure:"user_verification"`
+}
+
+// Passwordless configures the logins by magic link and emailed code
+type Passwordless struct {
+	// MagicLinkURL is the page completing the login, the token is added as
+	// the token query parameter
+	MagicLinkURL string `mapstructure:"magic_link_url"`
+	// MagicLinkTTL and OTPTTL are in minutes
+	MagicLinkTTL int `mapstructure:"magic_link_ttl"`
+	OTPTTL       int `mapstructure:"otp_ttl"`
+	// OTPAttempts is the number of verifications a code allows
+	OTPAttempts int `mapstructure:"otp_attempts"`
+	// CreateUsers signs up unknown email addresses on their first login
+	CreateUsers bool `mapstructure:"create_users"`
+}
+
+// OAuth configures the authorization server for third party clients
+type OAuth struct {
+	// ConsentURL is the page logging the user in and asking for consent,
+	// valid authorization requests are passed on with their query
+	ConsentURL string `mapstructure:"consent_url"`
+	// CodeTTL is in seconds
+	CodeTTL int `mapstructure:"code_ttl"`
+	// Scopes are the scopes clients may be registered for
+	Scopes []string `mapstructure:"scopes"`
+}
+
+// Mail selects and configures the transport of outgoing emails
+type Mail struct {
+	// Transport is one of log, smtp, maildir or memory
+	Transport string `mapstructure:"transport"`
+	From      string `mapstructure:"from"`
+	// TemplatesDir overrides and adds to the built-in templates, laid out as
+	// <locale>/<name>.txt and <locale>/<name>.html
+	TemplatesDir  string `mapstructure:"templates_dir"`
+	DefaultLocale string `mapstructure:"default_locale"`
+	MaildirDir    string `mapstructure:"maildir_dir"`
+	SMTP          MailSMTP
+}
+
+// MailSMTP configures the smtp mail transport
+type MailSMTP struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	TLS      bool   `mapstructure:"tls"`
+}
+
 // Conf is global configuration file
 var Conf Config
 
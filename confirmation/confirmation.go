@@ -1,12 +1,41 @@
 package confirmation
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"net/url"
 	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/models"
 )
 
+// Config of the confirmation emails
+type Config struct {
+	// URL of the page which confirms the email address, the token is
+	// appended as the token query parameter
+	URL string
+	TTL time.Duration
+	// ResendInterval is the minimum time between two emails to a user
+	ResendInterval time.Duration
+}
+
+// UseCase represent the email confirmation's use cases
 type UseCase interface {
+	// Send emails a confirmation link to u
+	Send(ctx context.Context, u *models.User) error
+	// Resend emails a new confirmation link to the user with email, unless
+	// one was sent less than Config.ResendInterval ago. Unknown and already
+	// confirmed emails are ignored without an error, so callers can't tell
+	// them apart.
+	Resend(ctx context.Context, email string) error
+	// Confirm marks the email address of the token's user as verified. It
+	// returns errorx.ErrInvalidToken, errorx.ErrTokenExpired or
+	// errorx.ErrTokenReused for tokens which can't be redeemed.
+	Confirm(ctx context.Context, token string) (*models.User, error)
 }
 
 func GenerateConfirmationToken() string {
@@ -17,3 +46,22 @@ func GenerateConfirmationToken() string {
 	}
 	return strings.TrimRight(base64.URLEncoding.EncodeToString(bytes), "=")
 }
+
+// HashToken is what gets stored of an emailed token, the token itself only
+// ever appears in the email
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenLink sets token as the token query parameter of the base URL
+func TokenLink(base, token string) string {
+	link, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+	return link.String()
+}
@@ -1,18 +1,132 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
 	"github.com/go-chi/chi"
 	"github.com/imtanmoy/authn/confirmation"
-	"net/http"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/httpx"
+	"github.com/imtanmoy/logx"
+	"gopkg.in/thedevsaddam/govalidator.v1"
 )
 
+// resendTimeout bounds the lookup and the email sent after responding
+const resendTimeout = 30 * time.Second
+
+type confirmPayload struct {
+	Token string `json:"token"`
+}
+
+func (cp *confirmPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"token": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  cp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type resendPayload struct {
+	Email string `json:"email"`
+}
+
+func (rp *resendPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"email": []string{"required", "min:4", "max:100", "email"},
+	}
+	opts := govalidator.Options{
+		Data:  rp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
 // confirmationHandler  represent the http handler for Confirmation
 type confirmationHandler struct {
 	useCase confirmation.UseCase
 }
 
+// Confirm verifies the email address with the token of a confirmation email
 func (handler *confirmationHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &confirmPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
 
+	if _, err := handler.useCase.Confirm(ctx, data.Token); err != nil {
+		if errors.Is(err, errorx.ErrInvalidToken) ||
+			errors.Is(err, errorx.ErrTokenExpired) ||
+			errors.Is(err, errorx.ErrTokenReused) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return
+		}
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// Resend emails a new confirmation link. The response is the same whether or
+// not the email belongs to an unconfirmed account, the lookup and the email
+// happen after it.
+func (handler *confirmationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	data := &resendPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	go func(email, locale string) {
+		ctx, cancel := context.WithTimeout(mailer.WithLocale(context.Background(), locale), resendTimeout)
+		defer cancel()
+		if err := handler.useCase.Resend(ctx, email); err != nil {
+			logx.Errorf("could not resend confirmation: %s", err)
+		}
+	}(data.Email, mailer.RequestLocale(r))
+
+	httpx.ResponseJSON(w, http.StatusAccepted, &messageResponse{
+		Message: "if an unconfirmed account with this email exists, a new confirmation link has been sent",
+	})
 }
 
 func NewHandler(r *chi.Mux, useCase confirmation.UseCase) {
@@ -20,7 +134,8 @@ func NewHandler(r *chi.Mux, useCase confirmation.UseCase) {
 		useCase: useCase,
 	}
 
-	r.Route("/", func(r chi.Router) {
-		r.Post("/confirm", handler.Confirm)
+	r.Route("/confirm", func(r chi.Router) {
+		r.Post("/", handler.Confirm)
+		r.Post("/resend", handler.Resend)
 	})
 }
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/confirmation"
+	_confirmationRepo "github.com/imtanmoy/authn/confirmation/repository"
+	_confirmationUseCase "github.com/imtanmoy/authn/confirmation/usecase"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/tests"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	r       = chi.NewRouter()
+	db      *sql.DB
+	conn    *pgx.Conn
+	useCase confirmation.UseCase
+	mail    = mailer.NewMemoryMailer()
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err = stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setup()
+}
+
+func setup() {
+	timeoutContext := 30 * time.Millisecond * time.Second
+	useCase = _confirmationUseCase.NewUseCase(_confirmationRepo.NewPgxRepository(conn), _userRepo.NewPgxRepository(conn), mail, mailer.NewTemplates("en"),
+		&confirmation.Config{URL: "http://localhost:3000/confirm", TTL: time.Hour, ResendInterval: time.Hour}, timeoutContext)
+	NewHandler(r, useCase)
+}
+
+func post(t *testing.T, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// confirmationToken waits for the confirmation email to email and returns its token
+func confirmationToken(t *testing.T, email string, after int) string {
+	var msg *mailer.Message
+	require.Eventually(t, func() bool {
+		msg = mail.Last(email)
+		return msg != nil && len(mail.Messages()) > after
+	}, 5*time.Second, 10*time.Millisecond)
+	i := strings.Index(msg.Text, "http://localhost:3000/confirm?")
+	require.True(t, i >= 0, msg.Text)
+	link, err := url.Parse(strings.Fields(msg.Text[i:])[0])
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+func TestConfirmationHandler(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+
+	t.Run("Resend responds the same for unknown emails and is throttled", func(t *testing.T) {
+		known := post(t, "/confirm/resend", &resendPayload{Email: "test@test.com"})
+		unknown := post(t, "/confirm/resend", &resendPayload{Email: "nobody@test.com"})
+		assert.Equal(t, http.StatusAccepted, known.Code)
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+		confirmationToken(t, "test@test.com", 0)
+		sent := len(mail.Messages())
+		post(t, "/confirm/resend", &resendPayload{Email: "test@test.com"})
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, mail.Messages(), sent, "a link was sent less than the resend interval ago")
+		assert.Nil(t, mail.Last("nobody@test.com"))
+	})
+
+	t.Run("Confirm verifies the email once", func(t *testing.T) {
+		users := _userRepo.NewPgxRepository(conn)
+		u, err := users.FindByEmail(context.Background(), "test@test.com")
+		require.NoError(t, err)
+		assert.False(t, u.IsEmailVerified())
+
+		sent := len(mail.Messages())
+		require.NoError(t, useCase.Send(context.Background(), u))
+		token := confirmationToken(t, "test@test.com", sent)
+
+		w := post(t, "/confirm", &confirmPayload{Token: token})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		u, err = users.FindByEmail(context.Background(), "test@test.com")
+		require.NoError(t, err)
+		assert.True(t, u.IsEmailVerified())
+
+		w = post(t, "/confirm", &confirmPayload{Token: token})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "tokens are single use")
+		assert.Contains(t, w.Body.String(), "invalid or expired token")
+	})
+
+	t.Run("Confirm rejects unknown tokens", func(t *testing.T) {
+		w := post(t, "/confirm", &confirmPayload{Token: "unknown"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
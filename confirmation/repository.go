@@ -0,0 +1,22 @@
+package confirmation
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the email confirmation's repository contract
+type Repository interface {
+	Save(ctx context.Context, ec *models.EmailConfirmation) error
+	FindByTokenHash(ctx context.Context, hash string) (*models.EmailConfirmation, error)
+	// FindLatestByUserID returns the last confirmation sent to the user or
+	// errorx.ErrorNotFound
+	FindLatestByUserID(ctx context.Context, userID int) (*models.EmailConfirmation, error)
+	// MarkUsed redeems ec, it must return errorx.ErrTokenReused when ec was
+	// already used
+	MarkUsed(ctx context.Context, ec *models.EmailConfirmation) error
+	// InvalidateUserConfirmations marks every unused confirmation of the user
+	// as used
+	InvalidateUserConfirmations(ctx context.Context, userID int) error
+}
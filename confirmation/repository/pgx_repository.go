@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/confirmation"
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/jackc/pgconn"
+)
+
+const selectConfirmation = "SELECT id, user_id, email, token_hash, expires_at, used_at, created_at " +
+	"FROM email_confirmations "
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ confirmation.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the confirmation.Repository interface
+func NewPgxRepository(conn dbx.DB) confirmation.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) Save(ctx context.Context, ec *models.EmailConfirmation) error {
+	err := repo.conn.QueryRow(ctx, "INSERT INTO email_confirmations(user_id, email, token_hash, expires_at) "+
+		"VALUES ($1,$2,$3,$4) "+
+		"RETURNING id, created_at",
+		ec.UserID, ec.Email, ec.TokenHash, ec.ExpiresAt).
+		Scan(&ec.ID, &ec.CreatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindByTokenHash(ctx context.Context, hash string) (*models.EmailConfirmation, error) {
+	return repo.findOne(ctx, selectConfirmation+"WHERE token_hash = $1", hash)
+}
+
+func (repo *pgxRepository) FindLatestByUserID(ctx context.Context, userID int) (*models.EmailConfirmation, error) {
+	return repo.findOne(ctx, selectConfirmation+"WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1", userID)
+}
+
+func (repo *pgxRepository) findOne(ctx context.Context, query string, args ...interface{}) (*models.EmailConfirmation, error) {
+	var ec models.EmailConfirmation
+	var usedAt *time.Time
+	err := repo.conn.QueryRow(ctx, query, args...).
+		Scan(&ec.ID, &ec.UserID, &ec.Email, &ec.TokenHash, &ec.ExpiresAt, &usedAt, &ec.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	if usedAt != nil {
+		ec.UsedAt = *usedAt
+	}
+	return &ec, nil
+}
+
+func (repo *pgxRepository) MarkUsed(ctx context.Context, ec *models.EmailConfirmation) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE email_confirmations SET used_at = $1 "+
+		"WHERE id = $2 AND used_at IS NULL", now, ec.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrTokenReused
+	}
+	ec.UsedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) InvalidateUserConfirmations(ctx context.Context, userID int) error {
+	_, err := repo.conn.Exec(ctx, "UPDATE email_confirmations SET used_at = $1 "+
+		"WHERE user_id = $2 AND used_at IS NULL", time.Now().UTC(), userID)
+	return err
+}
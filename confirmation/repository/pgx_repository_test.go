@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/confirmation"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo confirmation.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func fakeConfirmation(hash string) *models.EmailConfirmation {
+	return &models.EmailConfirmation{
+		UserID:    1,
+		Email:     "test@test.com",
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}
+}
+
+func TestPgxRepository_EmailConfirmations(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	_, err := repo.FindLatestByUserID(ctx, 1)
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	first := fakeConfirmation("hash-1")
+	require.NoError(t, repo.Save(ctx, first))
+	assert.NotZero(t, first.ID)
+	second := fakeConfirmation("hash-2")
+	require.NoError(t, repo.Save(ctx, second))
+
+	found, err := repo.FindByTokenHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, found.ID)
+	assert.Equal(t, "test@test.com", found.Email)
+	assert.False(t, found.IsUsed())
+
+	latest, err := repo.FindLatestByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, latest.ID)
+
+	_, err = repo.FindByTokenHash(ctx, "unknown")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	t.Run("single use", func(t *testing.T) {
+		require.NoError(t, repo.MarkUsed(ctx, found))
+		assert.True(t, found.IsUsed())
+		assert.Equal(t, errorx.ErrTokenReused, repo.MarkUsed(ctx, first))
+	})
+
+	t.Run("invalidate the others", func(t *testing.T) {
+		require.NoError(t, repo.InvalidateUserConfirmations(ctx, 1))
+		found, err := repo.FindByTokenHash(ctx, "hash-2")
+		require.NoError(t, err)
+		assert.True(t, found.IsUsed())
+	})
+}
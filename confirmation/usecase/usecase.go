@@ -1,16 +1,117 @@
 package usecase
 
 import (
-	"github.com/imtanmoy/authn/confirmation"
+	"context"
+	"errors"
+	"strings"
 	"time"
+
+	"github.com/imtanmoy/authn/confirmation"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/user"
 )
 
 type useCase struct {
+	repo           confirmation.Repository
+	userRepo       user.Repository
+	mailer         mailer.Mailer
+	templates      *mailer.Templates
+	config         *confirmation.Config
 	contextTimeout time.Duration
 }
 
 var _ confirmation.UseCase = (*useCase)(nil)
 
-func NewUseCase(contextTimeout time.Duration) confirmation.UseCase {
-	return &useCase{contextTimeout: contextTimeout}
+// NewUseCase will create new an useCase object representation of confirmation.UseCase interface
+func NewUseCase(repo confirmation.Repository, userRepo user.Repository, m mailer.Mailer, t *mailer.Templates, config *confirmation.Config, timeout time.Duration) confirmation.UseCase {
+	return &useCase{
+		repo:           repo,
+		userRepo:       userRepo,
+		mailer:         m,
+		templates:      t,
+		config:         config,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) Send(ctx context.Context, us *models.User) error {
+	token := confirmation.GenerateConfirmationToken()
+	ec := &models.EmailConfirmation{
+		UserID:    us.ID,
+		Email:     us.Email,
+		TokenHash: confirmation.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(u.config.TTL),
+	}
+	if err := u.repo.Save(ctx, ec); err != nil {
+		return err
+	}
+	msg, err := u.templates.Render(ctx, "confirmation", struct {
+		Name string
+		Link string
+		TTL  time.Duration
+	}{us.Name, confirmation.TokenLink(u.config.URL, token), u.config.TTL})
+	if err != nil {
+		return err
+	}
+	msg.To = us.Email
+	return u.mailer.Send(ctx, msg)
+}
+
+func (u *useCase) Resend(ctx context.Context, email string) error {
+	us, err := u.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil
+		}
+		return err
+	}
+	if us.IsEmailVerified() {
+		return nil
+	}
+	last, err := u.repo.FindLatestByUserID(ctx, us.ID)
+	if err != nil && !errors.Is(err, errorx.ErrorNotFound) {
+		return err
+	}
+	if last != nil && time.Since(last.CreatedAt) < u.config.ResendInterval {
+		return nil
+	}
+	return u.Send(ctx, us)
+}
+
+func (u *useCase) Confirm(ctx context.Context, token string) (*models.User, error) {
+	ec, err := u.repo.FindByTokenHash(ctx, confirmation.HashToken(token))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, errorx.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if ec.IsUsed() {
+		return nil, errorx.ErrTokenReused
+	}
+	if ec.IsExpired() {
+		return nil, errorx.ErrTokenExpired
+	}
+	us, err := u.userRepo.FindByID(ctx, ec.UserID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, errorx.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(us.Email, ec.Email) {
+		return nil, errorx.ErrInvalidToken
+	}
+	if err := u.repo.MarkUsed(ctx, ec); err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.MarkEmailVerified(ctx, us); err != nil {
+		return nil, err
+	}
+	if err := u.repo.InvalidateUserConfirmations(ctx, us.ID); err != nil {
+		return nil, err
+	}
+	return us, nil
 }
@@ -1,12 +1,16 @@
 -- organizations start
 CREATE TABLE organizations
 (
-    id         BIGSERIAL PRIMARY KEY NOT NULL,
-    name       VARCHAR(100)          NOT NULL,
-    owner_id   BIGINT                NOT NULL,
-    created_at TIMESTAMP             NOT NULL DEFAULT NOW(),
-    updated_at TIMESTAMP             NOT NULL DEFAULT NOW(),
-    deleted_at TIMESTAMP             NULL
+    id                    BIGSERIAL PRIMARY KEY NOT NULL,
+    name                  VARCHAR(100)          NOT NULL,
+    owner_id              BIGINT                NOT NULL,
+    pending_owner_id      BIGINT                NULL,
+    transfer_requested_at TIMESTAMP             NULL,
+    password_policy       JSONB                 NULL,
+    require_mfa           BOOLEAN               NOT NULL DEFAULT FALSE,
+    created_at            TIMESTAMP             NOT NULL DEFAULT NOW(),
+    updated_at            TIMESTAMP             NOT NULL DEFAULT NOW(),
+    deleted_at            TIMESTAMP             NULL
 );
 -- organizations end
 
@@ -17,6 +21,7 @@ CREATE TABLE users
     name       VARCHAR(100)          NOT NULL,
     email      VARCHAR(255)          NOT NULL,
     password   VARCHAR(255)          NOT NULL,
+    email_verified_at TIMESTAMP      NULL,
     created_at TIMESTAMP             NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP             NOT NULL DEFAULT NOW(),
     deleted_at TIMESTAMP             NULL
@@ -36,10 +41,18 @@ ALTER TABLE organizations
 -- user_organization start
 CREATE TABLE users_organizations
 (
-    user_id         BIGINT NOT NULL,
-    organization_id BIGINT NOT NULL
+    user_id         BIGINT      NOT NULL,
+    organization_id BIGINT      NOT NULL,
+    role            VARCHAR(50) NOT NULL DEFAULT 'member',
+    created_at      TIMESTAMP   NOT NULL DEFAULT now()
 );
 
+ALTER TABLE users_organizations
+    ADD CONSTRAINT pk_users_organizations
+        PRIMARY KEY (user_id, organization_id);
+
+CREATE INDEX idx_users_organizations_organization ON users_organizations (organization_id);
+
 ALTER TABLE users_organizations
     ADD CONSTRAINT fk_users_organizations_users
         FOREIGN KEY (user_id)
@@ -52,17 +65,39 @@ ALTER TABLE users_organizations
 
 -- user_organization end
 
+-- organization_roles start
+CREATE TABLE organization_roles
+(
+    id              BIGSERIAL PRIMARY KEY NOT NULL,
+    organization_id BIGINT                NOT NULL,
+    name            VARCHAR(50)           NOT NULL,
+    permissions     TEXT[]                NOT NULL DEFAULT '{}',
+    created_at      TIMESTAMP             NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE organization_roles
+    ADD CONSTRAINT uk_organization_roles_organization_name
+        UNIQUE (organization_id, name);
+
+ALTER TABLE organization_roles
+    ADD CONSTRAINT fk_organization_roles_organizations
+        FOREIGN KEY (organization_id)
+            REFERENCES organizations (id);
+-- organization_roles end
+
 -- invitations start
 create type invitation_status as enum ('pending', 'successful','canceled');
 CREATE TABLE invitations
 (
     id              BIGSERIAL         NOT NULL,
     email           VARCHAR(255)      NOT NULL,
-    token           VARCHAR(32)       NOT NULL,
+    token_hash      VARCHAR(64)       NOT NULL,
     status          invitation_status NOT NULL DEFAULT 'pending',
     organization_id BIGINT            NOT NULL,
     user_id         BIGINT            NULL,
     invited_by      BIGINT            NOT NULL,
+    expires_at      TIMESTAMP         NOT NULL,
     accepted_at     TIMESTAMP         NULL,
     created_at      TIMESTAMP         NOT NULL DEFAULT NOW(),
     updated_at      TIMESTAMP         NOT NULL DEFAULT NOW()
@@ -83,15 +118,310 @@ ALTER TABLE invitations
             REFERENCES users (id);
 
 ALTER TABLE invitations
-    ADD CONSTRAINT uk_invitations_token
-        UNIQUE (token);
+    ADD CONSTRAINT uk_invitations_token_hash
+        UNIQUE (token_hash);
 
 ALTER TABLE invitations
     ADD CONSTRAINT fk_invitations_invited_by_users
         FOREIGN KEY (invited_by)
             REFERENCES users (id);
 
-ALTER TABLE invitations
-    ADD CONSTRAINT uk_invitations_email_organization_user
-        UNIQUE (email, organization_id, user_id);
--- invitations end
\ No newline at end of file
+CREATE UNIQUE INDEX uk_invitations_pending_email_organization
+    ON invitations (organization_id, LOWER(email))
+    WHERE status = 'pending';
+-- invitations end
+
+-- refresh_tokens start
+CREATE TABLE refresh_tokens
+(
+    id              BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id         BIGINT                NOT NULL,
+    family_id       VARCHAR(36)           NOT NULL,
+    token_hash      VARCHAR(64)           NOT NULL,
+    expires_at      TIMESTAMP             NOT NULL,
+    revoked_at      TIMESTAMP             NULL,
+    replaced_by     BIGINT                NULL,
+    auth_methods    TEXT[]                NOT NULL DEFAULT '{}',
+    scope           TEXT                  NOT NULL DEFAULT '',
+    client_id       VARCHAR(36)           NULL,
+    organization_id BIGINT                NULL,
+    created_at      TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT uk_refresh_tokens_token_hash
+        UNIQUE (token_hash);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+-- refresh_tokens end
+
+-- revoked_tokens start
+CREATE TABLE revoked_tokens
+(
+    jti        VARCHAR(36) PRIMARY KEY NOT NULL,
+    user_id    BIGINT                  NOT NULL,
+    expires_at TIMESTAMP               NOT NULL,
+    created_at TIMESTAMP               NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE user_token_revocations
+(
+    user_id        BIGINT PRIMARY KEY NOT NULL,
+    revoked_before TIMESTAMP          NOT NULL
+);
+
+ALTER TABLE user_token_revocations
+    ADD CONSTRAINT fk_user_token_revocations_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+-- revoked_tokens end
+
+-- oauth_clients start
+CREATE TABLE oauth_clients
+(
+    id            BIGSERIAL PRIMARY KEY NOT NULL,
+    client_id     VARCHAR(36)           NOT NULL,
+    secret_hash   VARCHAR(64)           NOT NULL,
+    name          VARCHAR(100)          NOT NULL,
+    public        BOOLEAN               NOT NULL DEFAULT FALSE,
+    redirect_uris TEXT[]                NOT NULL DEFAULT '{}',
+    scopes        TEXT[]                NOT NULL DEFAULT '{}',
+    created_at    TIMESTAMP             NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP             NOT NULL DEFAULT NOW(),
+    deleted_at    TIMESTAMP             NULL
+);
+
+ALTER TABLE oauth_clients
+    ADD CONSTRAINT uk_oauth_clients_client_id
+        UNIQUE (client_id);
+-- oauth_clients end
+
+-- login_attempts start
+CREATE TABLE login_attempts
+(
+    key            VARCHAR(320) PRIMARY KEY NOT NULL,
+    failures       INT                      NOT NULL,
+    last_failed_at TIMESTAMP                NOT NULL,
+    locked_until   TIMESTAMP                NULL
+);
+
+CREATE INDEX idx_login_attempts_last_failed_at ON login_attempts (last_failed_at);
+-- login_attempts end
+
+-- rate_limit_buckets start
+CREATE TABLE rate_limit_buckets
+(
+    key        VARCHAR(400) PRIMARY KEY NOT NULL,
+    tokens     DOUBLE PRECISION         NOT NULL,
+    updated_at TIMESTAMP                NOT NULL,
+    full_at    TIMESTAMP                NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
+-- rate_limit_buckets end
+
+-- password_resets start
+CREATE TABLE password_resets
+(
+    id         BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id    BIGINT                NOT NULL,
+    token_hash VARCHAR(64)           NOT NULL,
+    expires_at TIMESTAMP             NOT NULL,
+    used_at    TIMESTAMP             NULL,
+    created_at TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE password_resets
+    ADD CONSTRAINT fk_password_resets_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE password_resets
+    ADD CONSTRAINT uk_password_resets_token_hash
+        UNIQUE (token_hash);
+-- password_resets end
+
+
+-- email_confirmations start
+CREATE TABLE email_confirmations
+(
+    id         BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id    BIGINT                NOT NULL,
+    email      VARCHAR(255)          NOT NULL,
+    token_hash VARCHAR(64)           NOT NULL,
+    expires_at TIMESTAMP             NOT NULL,
+    used_at    TIMESTAMP             NULL,
+    created_at TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE email_confirmations
+    ADD CONSTRAINT fk_email_confirmations_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE email_confirmations
+    ADD CONSTRAINT uk_email_confirmations_token_hash
+        UNIQUE (token_hash);
+
+CREATE INDEX idx_email_confirmations_user_id
+    ON email_confirmations (user_id, created_at);
+-- email_confirmations end
+
+-- totp_factors start
+CREATE TABLE totp_factors
+(
+    user_id        BIGINT PRIMARY KEY NOT NULL,
+    secret         TEXT               NOT NULL,
+    confirmed_at   TIMESTAMP          NULL,
+    last_used_step BIGINT             NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP          NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMP          NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE totp_factors
+    ADD CONSTRAINT fk_totp_factors_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+-- totp_factors end
+
+-- recovery_codes start
+CREATE TABLE recovery_codes
+(
+    id         BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id    BIGINT                NOT NULL,
+    code_hash  VARCHAR(64)           NOT NULL,
+    used_at    TIMESTAMP             NULL,
+    created_at TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE recovery_codes
+    ADD CONSTRAINT fk_recovery_codes_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
+-- recovery_codes end
+
+-- webauthn_credentials start
+CREATE TABLE webauthn_credentials
+(
+    id              BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id         BIGINT                NOT NULL,
+    name            VARCHAR(100)          NOT NULL,
+    credential_id   BYTEA                 NOT NULL,
+    public_key      BYTEA                 NOT NULL,
+    sign_count      BIGINT                NOT NULL DEFAULT 0,
+    aaguid          BYTEA                 NULL,
+    transports      TEXT[]                NOT NULL DEFAULT '{}',
+    backup_eligible BOOLEAN               NOT NULL DEFAULT FALSE,
+    backup_state    BOOLEAN               NOT NULL DEFAULT FALSE,
+    last_used_at    TIMESTAMP             NULL,
+    created_at      TIMESTAMP             NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE webauthn_credentials
+    ADD CONSTRAINT fk_webauthn_credentials_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE webauthn_credentials
+    ADD CONSTRAINT uk_webauthn_credentials_credential_id
+        UNIQUE (credential_id);
+
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+-- webauthn_credentials end
+
+-- webauthn_challenges start
+CREATE TABLE webauthn_challenges
+(
+    id         BIGSERIAL PRIMARY KEY NOT NULL,
+    challenge  BYTEA                 NOT NULL,
+    user_id    BIGINT                NULL,
+    ceremony   VARCHAR(20)           NOT NULL,
+    expires_at TIMESTAMP             NOT NULL,
+    created_at TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE webauthn_challenges
+    ADD CONSTRAINT fk_webauthn_challenges_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE webauthn_challenges
+    ADD CONSTRAINT uk_webauthn_challenges_challenge
+        UNIQUE (challenge);
+-- webauthn_challenges end
+
+-- login_tokens start
+CREATE TABLE login_tokens
+(
+    id         BIGSERIAL PRIMARY KEY NOT NULL,
+    email      VARCHAR(255)          NOT NULL,
+    kind       VARCHAR(20)           NOT NULL,
+    token_hash VARCHAR(64)           NOT NULL,
+    attempts   INT                   NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP             NOT NULL,
+    used_at    TIMESTAMP             NULL,
+    created_at TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+-- codes are short, two emails may hash to the same one
+CREATE INDEX idx_login_tokens_token_hash ON login_tokens (token_hash);
+CREATE INDEX idx_login_tokens_email_kind ON login_tokens (email, kind);
+-- login_tokens end
+
+-- oauth_authorization_codes start
+CREATE TABLE oauth_authorization_codes
+(
+    id                    BIGSERIAL PRIMARY KEY NOT NULL,
+    code_hash             VARCHAR(64)           NOT NULL,
+    client_id             VARCHAR(36)           NOT NULL,
+    user_id               BIGINT                NOT NULL,
+    redirect_uri          TEXT                  NOT NULL,
+    scope                 TEXT                  NOT NULL DEFAULT '',
+    code_challenge        VARCHAR(128)          NOT NULL,
+    code_challenge_method VARCHAR(10)           NOT NULL,
+    auth_methods          TEXT[]                NOT NULL DEFAULT '{}',
+    expires_at            TIMESTAMP             NOT NULL,
+    used_at               TIMESTAMP             NULL,
+    created_at            TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE oauth_authorization_codes
+    ADD CONSTRAINT fk_oauth_authorization_codes_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE oauth_authorization_codes
+    ADD CONSTRAINT uk_oauth_authorization_codes_code_hash
+        UNIQUE (code_hash);
+-- oauth_authorization_codes end
+
+-- oauth_consents start
+CREATE TABLE oauth_consents
+(
+    id         BIGSERIAL PRIMARY KEY NOT NULL,
+    user_id    BIGINT                NOT NULL,
+    client_id  VARCHAR(36)           NOT NULL,
+    scopes     TEXT[]                NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP             NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP             NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE oauth_consents
+    ADD CONSTRAINT fk_oauth_consents_users
+        FOREIGN KEY (user_id)
+            REFERENCES users (id);
+
+ALTER TABLE oauth_consents
+    ADD CONSTRAINT uk_oauth_consents_user_id_client_id
+        UNIQUE (user_id, client_id);
+-- oauth_consents end
@@ -12,13 +12,97 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 const (
 	UserCreateEvent = "user:created"
 	UserUpdateEvent = "user:updated"
+	UserLockedEvent = "user:locked"
+	UserPasswordChangedEvent = "user:password_changed"
+	InvitationPendingEvent = "invitation:pending"
+	InvitationSuccessfulEvent = "invitation:successful"
+	InvitationCanceledEvent = "invitation:canceled"
+	OrganizationUpdatedEvent = "organization:updated"
+	OrganizationDeletedEvent = "organization:deleted"
+	OrganizationRestoredEvent = "organization:restored"
+	OrganizationTransferRequestedEvent = "organization:transfer_requested"
+	OrganizationTransferCanceledEvent = "organization:transfer_canceled"
+	OrganizationTransferredEvent = "organization:transferred"
+	MFAEnabledEvent = "mfa:enabled"
+	MFADisabledEvent = "mfa:disabled"
+	MFARecoveryCodesRegeneratedEvent = "mfa:recovery_codes_regenerated"
+	PasskeyRegisteredEvent = "passkey:registered"
+	PasskeyRevokedEvent = "passkey:revoked"
 )
 
+// UserLocked is the data of UserLockedEvent
+type UserLocked struct {
+	UserID      int       `json:"user_id"`
+	Email       string    `json:"email"`
+	IP          string    `json:"ip"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// MFAChanged is the data of the MFA events
+type MFAChanged struct {
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// PasskeyChanged is the data of the passkey events
+type PasskeyChanged struct {
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	PasskeyID int       `json:"passkey_id"`
+	Name      string    `json:"name"`
+	IP        string    `json:"ip"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// UserPasswordChanged is the data of UserPasswordChangedEvent
+type UserPasswordChanged struct {
+	UserID          int       `json:"user_id"`
+	Email           string    `json:"email"`
+	IP              string    `json:"ip"`
+	SessionsRevoked bool      `json:"sessions_revoked"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// InvitationStatusChanged is the data of the invitation events, UserID is
+// set once the invitation was accepted
+type InvitationStatusChanged struct {
+	InvitationID   int       `json:"invitation_id"`
+	OrganizationID int       `json:"organization_id"`
+	Email          string    `json:"email"`
+	Status         string    `json:"status"`
+	UserID         int       `json:"user_id,omitempty"`
+	ActorID        int       `json:"actor_id"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// OrganizationChanged is the data of the organization update, delete and
+// restore events
+type OrganizationChanged struct {
+	OrganizationID int       `json:"organization_id"`
+	Name           string    `json:"name"`
+	OwnerID        int       `json:"owner_id"`
+	ActorID        int       `json:"actor_id"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// OrganizationTransfer is the data of the ownership transfer events, ToUserID
+// is the nominated member
+type OrganizationTransfer struct {
+	OrganizationID int       `json:"organization_id"`
+	FromUserID     int       `json:"from_user_id"`
+	ToUserID       int       `json:"to_user_id"`
+	ActorID        int       `json:"actor_id"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
 type EventEmitter interface {
 	Emit(ctx context.Context, eventName string, data interface{})
 	EmitWithDelay(ctx context.Context, eventName string, data interface{})
@@ -67,8 +151,18 @@ func New() EventBus {
 
 func (event *event) Init() {
 	event.wp = workerpool.New(2)
-	event.nonDelayedBus.RegisterTopics(UserCreateEvent, UserUpdateEvent)
-	event.delayedBus.RegisterTopics(UserCreateEvent, UserUpdateEvent)
+	event.nonDelayedBus.RegisterTopics(UserCreateEvent, UserUpdateEvent, UserLockedEvent, UserPasswordChangedEvent,
+		InvitationPendingEvent, InvitationSuccessfulEvent, InvitationCanceledEvent,
+		OrganizationUpdatedEvent, OrganizationDeletedEvent, OrganizationRestoredEvent,
+		OrganizationTransferRequestedEvent, OrganizationTransferCanceledEvent, OrganizationTransferredEvent,
+		MFAEnabledEvent, MFADisabledEvent, MFARecoveryCodesRegeneratedEvent,
+		PasskeyRegisteredEvent, PasskeyRevokedEvent)
+	event.delayedBus.RegisterTopics(UserCreateEvent, UserUpdateEvent, UserLockedEvent, UserPasswordChangedEvent,
+		InvitationPendingEvent, InvitationSuccessfulEvent, InvitationCanceledEvent,
+		OrganizationUpdatedEvent, OrganizationDeletedEvent, OrganizationRestoredEvent,
+		OrganizationTransferRequestedEvent, OrganizationTransferCanceledEvent, OrganizationTransferredEvent,
+		MFAEnabledEvent, MFADisabledEvent, MFARecoveryCodesRegeneratedEvent,
+		PasskeyRegisteredEvent, PasskeyRevokedEvent)
 	event.nonDelayedBus.RegisterHandler("user_event_non_delayed", _userEventHandler.EventHandler(event.wp.Submit, false))
 	event.delayedBus.RegisterHandler("user_event_delayed", _userEventHandler.EventHandler(event.wp.Submit, true))
 }
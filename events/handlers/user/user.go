@@ -1,13 +1,29 @@
 package user
 
 import (
-	"github.com/imtanmoy/authn/confirmation"
+	"context"
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/logx"
 	"github.com/mustafaturan/bus"
 	"time"
 )
 
+// sendTimeout bounds sending the confirmation of a new user
+const sendTimeout = 30 * time.Second
+
+// ConfirmationSender emails the confirmation link to a new user
+type ConfirmationSender interface {
+	Send(ctx context.Context, u *models.User) error
+}
+
+var confirmations ConfirmationSender
+
+// SetConfirmationSender sets the sender of the confirmation emails, it must
+// be called before the bus runs
+func SetConfirmationSender(s ConfirmationSender) {
+	confirmations = s
+}
+
 func EventHandler(send func(fn func()), delayed bool) *bus.Handler {
 	userHandler := bus.Handler{Handle: func(e *bus.Event) {
 		var fn func()
@@ -44,6 +60,17 @@ func createEventHandler(data interface{}) func() {
 }
 
 func sendConfirmation(u *models.User) {
-	token := confirmation.GenerateConfirmationToken()
-	logx.Infof("sending confirmation to %s with token %s", u.Email, token)
+	if confirmations == nil {
+		logx.Errorf("no confirmation sender, %s is not sent a confirmation", u.Email)
+		return
+	}
+	// users registered through an invitation proved their address already
+	if u.IsEmailVerified() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := confirmations.Send(ctx, u); err != nil {
+		logx.Errorf("could not send confirmation to %s: %s", u.Email, err)
+	}
 }
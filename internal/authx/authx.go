@@ -7,28 +7,94 @@ import (
 	"github.com/imtanmoy/authn/internal/errorx"
 	"github.com/imtanmoy/httpx"
 	"net/http"
+	"strings"
 )
 
 type contextKey string
 
 const (
 	identityKey contextKey = "identity"
+	claimsKey   contextKey = "claims"
 )
 
 // Create a struct that will be encoded to a JWT
 type Claims struct {
-	Identity string `json:"identity"`
+	Identity       string   `json:"identity"`
+	UserID         int      `json:"uid,omitempty"`
+	OrganizationID int      `json:"org,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	Scope          string   `json:"scope,omitempty"`
+	AuthMethods    []string `json:"amr,omitempty"`
+	// ClientID is the OAuth2 client the token was issued to, empty for
+	// first-party logins
+	ClientID string `json:"client_id,omitempty"`
+	// IssuedAtMicro is the issue time in microseconds, iat only carries
+	// whole seconds which is too coarse for revocation cut-offs
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
 	jwt.StandardClaims
 }
 
 type AuthxConfig struct {
-	SecretKey             string
-	AccessTokenExpireTime int
+	SecretKey              string
+	AccessTokenExpireTime  int
+	RefreshTokenExpireTime int
+	// Issuer and Audience are set on every token and, when not empty,
+	// required on every token presented
+	Issuer   string
+	Audience string
+	// AdminEmails lists the users allowed through AdminOnly
+	AdminEmails []string
+	// RequireVerifiedEmail makes CheckEmailVerified refuse users who have not
+	// confirmed their email address
+	RequireVerifiedEmail bool
+	// MFATokenExpireTime is the lifetime, in minutes, of MFA pending tokens
+	MFATokenExpireTime int
 }
 
 type Authx struct {
-	userRepo AuthRepo
-	config   *AuthxConfig
+	userRepo       AuthRepo
+	refreshRepo    RefreshTokenRepo
+	revocationRepo RevocationRepo
+	keys           KeyProvider
+	enrichers      []ClaimsEnricher
+	factors        []SecondFactor
+	hashers        *HasherRegistry
+	policy         *PasswordPolicy
+	orgPolicies    PasswordPolicySource
+	lockout        *lockout
+	config         *AuthxConfig
+}
+
+// Option configures optional Authx collaborators
+type Option func(ax *Authx)
+
+// WithRefreshTokenRepo enables refresh token issuing backed by the given store
+func WithRefreshTokenRepo(repo RefreshTokenRepo) Option {
+	return func(ax *Authx) {
+		ax.refreshRepo = repo
+	}
+}
+
+// WithKeyProvider signs and verifies tokens with the given keys instead of
+// the HS256 SecretKey
+func WithKeyProvider(keys KeyProvider) Option {
+	return func(ax *Authx) {
+		ax.keys = keys
+	}
+}
+
+// WithRevocationRepo enables server side token revocation checks
+func WithRevocationRepo(repo RevocationRepo) Option {
+	return func(ax *Authx) {
+		ax.revocationRepo = repo
+	}
+}
+
+// WithPasswordHashers replaces the default argon2id preferring hasher registry
+func WithPasswordHashers(hashers *HasherRegistry) Option {
+	return func(ax *Authx) {
+		ax.hashers = hashers
+	}
 }
 
 // AuthableUser is identified by a password
@@ -44,13 +110,26 @@ type AuthUser interface {
 	GetEmail() (email string)
 }
 
+// VerifiableUser knows whether its email address was confirmed
+type VerifiableUser interface {
+	IsEmailVerified() bool
+}
+
 type AuthRepo interface {
 	ExistsByEmail(ctx context.Context, identity string) bool
 	GetByEmail(ctx context.Context, identity string) (AuthUser, error)
+	GetByID(ctx context.Context, id int) (AuthUser, error)
 }
 
-func New(userRepo AuthRepo, config *AuthxConfig) *Authx {
-	return &Authx{userRepo: userRepo, config: config}
+func New(userRepo AuthRepo, config *AuthxConfig, opts ...Option) *Authx {
+	ax := &Authx{userRepo: userRepo, config: config}
+	ax.keys = NewStaticKeys(NewHMACKey("", []byte(config.SecretKey)))
+	ax.hashers = DefaultHasherRegistry()
+	ax.policy = DefaultPasswordPolicy()
+	for _, opt := range opts {
+		opt(ax)
+	}
+	return ax
 }
 
 func (ax *Authx) AuthMiddleware(next http.Handler) http.Handler {
@@ -65,7 +144,7 @@ func (ax *Authx) AuthMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
-		parsedToken, err := parseToken(token, ax.config.SecretKey)
+		parsedToken, err := parseToken(token, ax.keys, ax.config.Issuer, ax.config.Audience)
 		if err != nil {
 			var ae *AuthError
 			if errors.As(err, &ae) {
@@ -85,7 +164,35 @@ func (ax *Authx) AuthMiddleware(next http.Handler) http.Handler {
 			httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
 			return
 		}
-		ax.setCurrentUserAndServe(w, r, next, claims.Identity)
+		if claims.Scope == ScopeMFAPending {
+			httpx.ResponseJSONError(w, r, http.StatusUnauthorized, "two-factor authentication required")
+			return
+		}
+		// tokens of oauth clients are limited to their scope, which the
+		// first party endpoints don't check
+		if claims.ClientID != "" {
+			httpx.ResponseJSONError(w, r, http.StatusForbidden, "token was issued to an oauth client")
+			return
+		}
+		ax.setCurrentUserAndServe(w, r, next, claims)
+	})
+}
+
+// AdminOnly rejects users not listed in AuthxConfig.AdminEmails, it must run
+// after AuthMiddleware
+func (ax *Authx) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := ax.GetCurrentUser(r)
+		if err != nil {
+			panic(err)
+		}
+		for _, email := range ax.config.AdminEmails {
+			if strings.EqualFold(email, u.GetEmail()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "admin privileges required")
 	})
 }
 
@@ -106,13 +213,43 @@ func (ax *Authx) GetCurrentUser(r *http.Request) (AuthUser, error) {
 	return u, nil
 }
 
-func (ax *Authx) setCurrentUserAndServe(w http.ResponseWriter, r *http.Request, next http.Handler, identity string) {
+// GetCurrentClaims returns the claims of the token which authenticated the request
+func (ax *Authx) GetCurrentClaims(r *http.Request) (*Claims, error) {
+	ctx := r.Context()
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	if !ok {
+		return nil, errorx.ErrInternalServer
+	}
+	return claims, nil
+}
+
+// PeekClaims returns the claims of a validly signed bearer token of the
+// request without loading the user or checking revocation, for middleware
+// running before AuthMiddleware, e.g. rate limiting. ok is false when the
+// request carries no such token.
+func (ax *Authx) PeekClaims(r *http.Request) (claims *Claims, ok bool) {
+	if claims, err := ax.GetCurrentClaims(r); err == nil {
+		return claims, true
+	}
+	token, err := fromAuthHeader(r)
+	if err != nil {
+		return nil, false
+	}
+	parsedToken, err := parseToken(token, ax.keys, ax.config.Issuer, ax.config.Audience)
+	if err != nil || !parsedToken.Valid {
+		return nil, false
+	}
+	claims, ok = parsedToken.Claims.(*Claims)
+	return claims, ok
+}
+
+func (ax *Authx) setCurrentUserAndServe(w http.ResponseWriter, r *http.Request, next http.Handler, claims *Claims) {
 	ctx := r.Context()
 	if ctx == nil {
 		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
 		return
 	}
-	u, err := ax.getUser(ctx, identity)
+	u, err := ax.getUser(ctx, claims.Identity)
 	if err != nil {
 		if errors.Is(err, errorx.ErrorNotFound) {
 			httpx.ResponseJSONError(w, r, http.StatusNotFound, "user not found", err)
@@ -121,22 +258,96 @@ func (ax *Authx) setCurrentUserAndServe(w http.ResponseWriter, r *http.Request,
 		}
 		return
 	}
+	revoked, err := ax.isRevoked(ctx, claims, u)
+	if err != nil {
+		panic(err)
+	}
+	if revoked {
+		httpx.ResponseJSONError(w, r, http.StatusUnauthorized, "token has been revoked")
+		return
+	}
 	ctx = context.WithValue(r.Context(), identityKey, u)
+	ctx = context.WithValue(ctx, claimsKey, claims)
 	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
 func (ax *Authx) GenerateToken(identity string) (string, error) {
-	tokenString, err := createToken(identity, ax.config.SecretKey, ax.config.AccessTokenExpireTime)
+	return ax.signClaims(ax.newClaims(identity))
+}
+
+// GenerateUserToken issues an access token for u. The given options are applied
+// to the claims first, then every configured ClaimsEnricher.
+func (ax *Authx) GenerateUserToken(ctx context.Context, u AuthUser, opts ...ClaimsOption) (string, error) {
+	claims, err := ax.userClaims(ctx, u, opts...)
+	if err != nil {
+		return "", err
+	}
+	return ax.signClaims(claims)
+}
+
+func (ax *Authx) userClaims(ctx context.Context, u AuthUser, opts ...ClaimsOption) (*Claims, error) {
+	claims := ax.newClaims(u.GetEmail())
+	claims.UserID = u.GetId()
+	for _, opt := range opts {
+		opt(claims)
+	}
+	for _, e := range ax.enrichers {
+		if err := e.Enrich(ctx, u, claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// AccessTokenExpiresIn is the lifetime of access tokens in seconds
+func (ax *Authx) AccessTokenExpiresIn() int {
+	return ax.config.AccessTokenExpireTime * 60
+}
+
+func (ax *Authx) newClaims(identity string) *Claims {
+	claims := newClaims(identity, ax.config.AccessTokenExpireTime)
+	claims.Issuer = ax.config.Issuer
+	claims.Audience = ax.config.Audience
+	return claims
+}
+
+func (ax *Authx) signClaims(claims *Claims) (string, error) {
+	key, err := ax.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	tokenString, err := createToken(claims, key)
 	return tokenString, err
 }
 
-// VerifyPassword uses mechanisms to check that a password is correct.
-// Returns nil on success otherwise there will be an error. Simply a helper
-// to do the bcrypt comparison.
+// VerifyPassword checks password against the stored hash of user with the
+// hasher which produced it. Unknown hash formats never verify.
 func (ax *Authx) VerifyPassword(user AuthableUser, password string) bool {
-	return comparePasswords(user.GetPassword(), password)
+	ok, err := ax.hashers.Verify(password, user.GetPassword())
+	return err == nil && ok
+}
+
+// CheckEmailVerified returns errorx.ErrEmailNotVerified when
+// AuthxConfig.RequireVerifiedEmail is set and user has not confirmed the
+// email address. Users which are not a VerifiableUser always pass.
+func (ax *Authx) CheckEmailVerified(user AuthUser) error {
+	if !ax.config.RequireVerifiedEmail {
+		return nil
+	}
+	if vu, ok := user.(VerifiableUser); ok && !vu.IsEmailVerified() {
+		return errorx.ErrEmailNotVerified
+	}
+	return nil
+}
+
+// PasswordNeedsRehash reports whether the stored hash of user was produced by
+// an outdated algorithm or with outdated parameters. Callers holding the
+// plain password, e.g. after a successful login, should hash and store it again.
+func (ax *Authx) PasswordNeedsRehash(user AuthableUser) bool {
+	return ax.hashers.NeedsRehash(user.GetPassword())
 }
 
+// HashPassword hashes password with the preferred hasher
 func (ax *Authx) HashPassword(password string) (string, error) {
-	return hashPassword(password)
+	return ax.hashers.Hash(password)
 }
@@ -0,0 +1,71 @@
+package authx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthx_AdminOnly(t *testing.T) {
+	users := &memUserRepo{users: []*testUser{{id: 1, email: "admin@test.com"}, {id: 2, email: "test@test.com"}}}
+	ax := New(users, &AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5, AdminEmails: []string{"Admin@test.com"}})
+	h := ax.AuthMiddleware(ax.AdminOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	for email, code := range map[string]int{"admin@test.com": http.StatusOK, "test@test.com": http.StatusForbidden} {
+		token, err := ax.GenerateToken(email)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, email)
+	}
+}
+
+func TestAuthx_AuthMiddlewareRejectsClientTokens(t *testing.T) {
+	ctx := context.Background()
+	u := &testUser{id: 1, email: "test@test.com"}
+	ax := New(&memUserRepo{users: []*testUser{u}}, &AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5})
+	h := ax.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	first, err := ax.GenerateUserToken(ctx, u)
+	require.NoError(t, err)
+	client, err := ax.GenerateUserToken(ctx, u, WithScope("profile"), WithClientID("client"))
+	require.NoError(t, err)
+	for token, code := range map[string]int{first: http.StatusOK, client: http.StatusForbidden} {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code)
+	}
+}
+
+type verifiableUser struct {
+	testUser
+	verified bool
+}
+
+func (u *verifiableUser) IsEmailVerified() bool { return u.verified }
+
+func TestAuthx_CheckEmailVerified(t *testing.T) {
+	unverified := &verifiableUser{testUser: testUser{id: 1, email: "test@test.com"}}
+	verified := &verifiableUser{testUser: testUser{id: 2, email: "admin@test.com"}, verified: true}
+
+	ax := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"})
+	assert.NoError(t, ax.CheckEmailVerified(unverified), "verification is not required by default")
+
+	ax = New(&memUserRepo{}, &AuthxConfig{SecretKey: "test", RequireVerifiedEmail: true})
+	assert.Equal(t, errorx.ErrEmailNotVerified, ax.CheckEmailVerified(unverified))
+	assert.NoError(t, ax.CheckEmailVerified(verified))
+	assert.NoError(t, ax.CheckEmailVerified(&testUser{id: 3}), "users without a verification state pass")
+}
@@ -0,0 +1,272 @@
+package authx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BreachChecker tells whether a password appeared in known data breaches
+type BreachChecker interface {
+	Breached(password string) (bool, error)
+}
+
+// HIBPFile is a Have I Been Pwned "ordered by hash" SHA-1 file, one
+// HASH:COUNT line per password, searched in place with a binary search
+type HIBPFile struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// OpenHIBPFile opens the hash file at path, the file stays open for the
+// lifetime of the process
+func OpenHIBPFile(path string) (*HIBPFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return NewHIBPFile(f, info.Size()), nil
+}
+
+// NewHIBPFile searches the size bytes of r
+func NewHIBPFile(r io.ReaderAt, size int64) *HIBPFile {
+	return &HIBPFile{r: r, size: size}
+}
+
+// Count returns how often the password with the given upper case hex SHA-1
+// hash was seen, 0 when it is not in the file
+func (f *HIBPFile) Count(hash string) (int, error) {
+	lo, hi := int64(0), f.size
+	// lo is always the start of a line, the line searched for starts in [lo, hi)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := f.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi || line == "" {
+			hi = mid
+			continue
+		}
+		sep := strings.IndexByte(line, ':')
+		if sep < 0 {
+			return 0, fmt.Errorf("authx: malformed HIBP line at offset %d", start)
+		}
+		switch cmp := strings.Compare(strings.ToUpper(line[:sep]), hash); {
+		case cmp == 0:
+			return strconv.Atoi(strings.TrimSpace(line[sep+1:]))
+		case cmp < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineAt returns the first line starting at or after offset, without its
+// line ending
+func (f *HIBPFile) lineAt(offset int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		// a line starts at offset when the previous byte ends a line
+		start = offset - 1
+	}
+	buf := make([]byte, 256)
+	var data []byte
+	for pos := start; pos < f.size; pos += int64(len(buf)) {
+		n, err := f.r.ReadAt(buf, pos)
+		if err != nil && err != io.EOF {
+			return 0, "", err
+		}
+		data = append(data, buf[:n]...)
+		if bytes.Count(data, []byte{'\n'}) >= 2 || (offset == 0 && bytes.IndexByte(data, '\n') >= 0) {
+			break
+		}
+	}
+	if offset > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return f.size, "", nil
+		}
+		data = data[i+1:]
+		start += int64(i) + 1
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[:i]
+	}
+	return start, strings.TrimRight(string(data), "\r"), nil
+}
+
+const bloomMagic = "HIBPBLM1"
+
+// BloomIndex is a bloom filter of the hashes of a HIBP file seen at least
+// MinCount times. It answers most lookups of unbreached passwords without
+// touching the hash file, or replaces the file altogether at the price of
+// its false positive rate.
+type BloomIndex struct {
+	bits     []byte
+	m        uint64
+	k        uint32
+	MinCount int
+}
+
+// BuildBloomIndex reads a HIBP file from r and writes a bloom filter of the
+// hashes seen at least minCount times, sized for the false positive rate p,
+// to w. n is the number of lines the filter is sized for, an upper bound is
+// good enough.
+func BuildBloomIndex(r io.Reader, w io.Writer, n int, minCount int, p float64) (int, error) {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = (m + 7) / 8 * 8
+	idx := &BloomIndex{
+		bits:     make([]byte, m/8),
+		m:        m,
+		k:        uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2))),
+		MinCount: minCount,
+	}
+
+	added := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		sep := strings.IndexByte(line, ':')
+		if sep != 40 {
+			continue
+		}
+		count, err := strconv.Atoi(line[sep+1:])
+		if err != nil || count < minCount {
+			continue
+		}
+		sum, err := hex.DecodeString(line[:sep])
+		if err != nil {
+			continue
+		}
+		idx.add(sum)
+		added++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, len(bloomMagic)+16)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint64(header[len(bloomMagic):], idx.m)
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+8:], idx.k)
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+12:], uint32(minCount))
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	_, err := w.Write(idx.bits)
+	return added, err
+}
+
+// LoadBloomIndex reads an index written by BuildBloomIndex into memory
+func LoadBloomIndex(path string) (*BloomIndex, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(bloomMagic) + 16
+	if len(data) < headerLen || string(data[:len(bloomMagic)]) != bloomMagic {
+		return nil, errors.New("authx: not a HIBP bloom index")
+	}
+	header := data[len(bloomMagic):]
+	idx := &BloomIndex{
+		m:        binary.BigEndian.Uint64(header),
+		k:        binary.BigEndian.Uint32(header[8:]),
+		MinCount: int(binary.BigEndian.Uint32(header[12:])),
+		bits:     data[headerLen:],
+	}
+	if uint64(len(idx.bits))*8 != idx.m || idx.k == 0 {
+		return nil, errors.New("authx: corrupt HIBP bloom index")
+	}
+	return idx, nil
+}
+
+// MayContain reports whether the raw SHA-1 sum may be in the index, false
+// answers are certain
+func (idx *BloomIndex) MayContain(sum []byte) bool {
+	h1, h2 := bloomHashes(sum)
+	for i := uint64(0); i < uint64(idx.k); i++ {
+		bit := (h1 + i*h2) % idx.m
+		if idx.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *BloomIndex) add(sum []byte) {
+	h1, h2 := bloomHashes(sum)
+	for i := uint64(0); i < uint64(idx.k); i++ {
+		bit := (h1 + i*h2) % idx.m
+		idx.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// bloomHashes derives the double hashing pair from a SHA-1 sum, which is
+// uniformly distributed already
+func bloomHashes(sum []byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
+
+// BreachCorpus checks passwords against a HIBP file, a bloom index of it, or
+// both. With both the index rules out most passwords cheaply and the file
+// confirms the rest. With the index only, its MinCount is the threshold.
+type BreachCorpus struct {
+	file      *HIBPFile
+	index     *BloomIndex
+	threshold int
+}
+
+// NewBreachCorpus rejects passwords seen at least threshold times, file or
+// index may be nil but not both
+func NewBreachCorpus(file *HIBPFile, index *BloomIndex, threshold int) (*BreachCorpus, error) {
+	if file == nil && index == nil {
+		return nil, errors.New("authx: a HIBP file or bloom index is required")
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	if file == nil && index.MinCount != threshold {
+		return nil, fmt.Errorf("authx: bloom index was built for %d occurrences, threshold is %d", index.MinCount, threshold)
+	}
+	if index != nil && index.MinCount > threshold {
+		return nil, fmt.Errorf("authx: bloom index misses passwords seen less than %d times", index.MinCount)
+	}
+	return &BreachCorpus{file: file, index: index, threshold: threshold}, nil
+}
+
+// Breached reports whether password was seen at least threshold times
+func (c *BreachCorpus) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	if c.index != nil && !c.index.MayContain(sum[:]) {
+		return false, nil
+	}
+	if c.file == nil {
+		return true, nil
+	}
+	count, err := c.file.Count(strings.ToUpper(hex.EncodeToString(sum[:])))
+	if err != nil {
+		return false, err
+	}
+	return count >= c.threshold, nil
+}
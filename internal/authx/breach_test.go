@@ -0,0 +1,149 @@
+package authx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// hibpFile writes a sorted HIBP file with CRLF line endings like the download
+func hibpFile(t *testing.T, dir string, counts map[string]int) string {
+	var lines []string
+	for password, count := range counts {
+		lines = append(lines, fmt.Sprintf("%s:%d\r\n", sha1Hex(password), count))
+	}
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d\r\n", sha1Hex(fmt.Sprintf("filler-%d", i)), i+1))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(dir, "pwned.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "")), 0600))
+	return path
+}
+
+func TestHIBPFile_Count(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breach")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := hibpFile(t, dir, map[string]int{"password": 9545824, "hunter2": 17})
+
+	file, err := OpenHIBPFile(path)
+	require.NoError(t, err)
+
+	count, err := file.Count(sha1Hex("password"))
+	require.NoError(t, err)
+	assert.Equal(t, 9545824, count)
+	count, err = file.Count(sha1Hex("hunter2"))
+	require.NoError(t, err)
+	assert.Equal(t, 17, count)
+	count, err = file.Count(sha1Hex("correct horse battery staple"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// every line is found, including the first and the last one
+	for i := 0; i < 500; i++ {
+		count, err := file.Count(sha1Hex(fmt.Sprintf("filler-%d", i)))
+		require.NoError(t, err)
+		require.Equal(t, i+1, count)
+	}
+
+	empty := NewHIBPFile(bytes.NewReader(nil), 0)
+	count, err = empty.Count(sha1Hex("password"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestBreachCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breach")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := hibpFile(t, dir, map[string]int{"password": 9545824, "rarely-leaked": 2})
+	file, err := OpenHIBPFile(path)
+	require.NoError(t, err)
+
+	in, err := os.Open(path)
+	require.NoError(t, err)
+	defer in.Close()
+	var buf bytes.Buffer
+	added, err := BuildBloomIndex(in, &buf, 600, 1, 0.001)
+	require.NoError(t, err)
+	assert.Equal(t, 502, added)
+	indexPath := filepath.Join(dir, "pwned.bloom")
+	require.NoError(t, ioutil.WriteFile(indexPath, buf.Bytes(), 0600))
+	index, err := LoadBloomIndex(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, index.MinCount)
+
+	t.Run("file", func(t *testing.T) {
+		corpus, err := NewBreachCorpus(file, nil, 3)
+		require.NoError(t, err)
+		breached, err := corpus.Breached("password")
+		require.NoError(t, err)
+		assert.True(t, breached)
+		breached, err = corpus.Breached("rarely-leaked")
+		require.NoError(t, err)
+		assert.False(t, breached, "seen less often than the threshold")
+	})
+
+	t.Run("index and file", func(t *testing.T) {
+		corpus, err := NewBreachCorpus(file, index, 3)
+		require.NoError(t, err)
+		breached, err := corpus.Breached("password")
+		require.NoError(t, err)
+		assert.True(t, breached)
+		breached, err = corpus.Breached("correct horse battery staple")
+		require.NoError(t, err)
+		assert.False(t, breached)
+	})
+
+	t.Run("index only", func(t *testing.T) {
+		corpus, err := NewBreachCorpus(nil, index, 1)
+		require.NoError(t, err)
+		breached, err := corpus.Breached("rarely-leaked")
+		require.NoError(t, err)
+		assert.True(t, breached)
+		breached, err = corpus.Breached("correct horse battery staple")
+		require.NoError(t, err)
+		assert.False(t, breached)
+
+		_, err = NewBreachCorpus(nil, index, 3)
+		assert.Error(t, err, "the index can not count")
+		_, err = NewBreachCorpus(nil, nil, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		corpus, err := NewBreachCorpus(file, index, 1)
+		require.NoError(t, err)
+		policy := &PasswordPolicy{MinLength: 8, Breaches: corpus}
+		assert.Equal(t, []string{RuleBreached}, violatedRules(policy.Validate("password")))
+		assert.NoError(t, policy.Validate("correct horse battery staple"))
+		merged := policy.Merge(&PasswordPolicy{MinLength: 12})
+		assert.Equal(t, []string{RuleMinLength, RuleBreached}, violatedRules(merged.Validate("password")))
+	})
+}
+
+func TestLoadBloomIndex_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breach")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pwned.bloom")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not an index"), 0600))
+	_, err = LoadBloomIndex(path)
+	assert.Error(t, err)
+}
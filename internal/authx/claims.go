@@ -0,0 +1,48 @@
+package authx
+
+import "context"
+
+// ClaimsOption customises the claims of a token before enrichers run
+type ClaimsOption func(claims *Claims)
+
+// WithOrganization scopes the token to an organization
+func WithOrganization(organizationID int) ClaimsOption {
+	return func(claims *Claims) {
+		claims.OrganizationID = organizationID
+	}
+}
+
+// WithScope sets the space separated OAuth2 scopes granted to the token
+func WithScope(scope string) ClaimsOption {
+	return func(claims *Claims) {
+		claims.Scope = scope
+	}
+}
+
+// WithClientID marks the token as issued to an OAuth2 client
+func WithClientID(clientID string) ClaimsOption {
+	return func(claims *Claims) {
+		claims.ClientID = clientID
+	}
+}
+
+// ClaimsEnricher adds application specific claims, like organization and
+// roles, to access tokens so downstream services do not need to look them up
+type ClaimsEnricher interface {
+	Enrich(ctx context.Context, u AuthUser, claims *Claims) error
+}
+
+// ClaimsEnricherFunc adapts a function to the ClaimsEnricher interface
+type ClaimsEnricherFunc func(ctx context.Context, u AuthUser, claims *Claims) error
+
+func (f ClaimsEnricherFunc) Enrich(ctx context.Context, u AuthUser, claims *Claims) error {
+	return f(ctx, u, claims)
+}
+
+// WithClaimsEnricher adds enrichers which run, in order, for every token issued
+// through GenerateUserToken
+func WithClaimsEnricher(enrichers ...ClaimsEnricher) Option {
+	return func(ax *Authx) {
+		ax.enrichers = append(ax.enrichers, enrichers...)
+	}
+}
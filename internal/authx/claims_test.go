@@ -0,0 +1,57 @@
+package authx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthx_IssuerAudience(t *testing.T) {
+	key := NewHMACKey("", []byte("test"))
+	ax := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5, Issuer: "authn", Audience: "api"})
+
+	token, err := ax.GenerateToken("test@test.com")
+	require.NoError(t, err)
+	parsed, err := parseToken(token, NewStaticKeys(key), "authn", "api")
+	require.NoError(t, err)
+	claims := parsed.Claims.(*Claims)
+	assert.Equal(t, "authn", claims.Issuer)
+	assert.Equal(t, "api", claims.Audience)
+
+	_, err = parseToken(token, NewStaticKeys(key), "other", "api")
+	assert.Error(t, err, "issuer mismatch")
+	_, err = parseToken(token, NewStaticKeys(key), "authn", "other")
+	assert.Error(t, err, "audience mismatch")
+}
+
+func TestAuthx_GenerateUserToken_Enrichers(t *testing.T) {
+	u := &testUser{id: 7, email: "test@test.com"}
+	roles := ClaimsEnricherFunc(func(ctx context.Context, u AuthUser, claims *Claims) error {
+		if claims.OrganizationID == 0 {
+			claims.OrganizationID = 1
+		}
+		claims.Roles = []string{"owner"}
+		return nil
+	})
+	ax := New(&memUserRepo{users: []*testUser{u}}, &AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5}, WithClaimsEnricher(roles))
+
+	token, err := ax.GenerateUserToken(context.Background(), u, WithOrganization(3))
+	require.NoError(t, err)
+	parsed, err := parseToken(token, ax.keys, "", "")
+	require.NoError(t, err)
+	claims := parsed.Claims.(*Claims)
+	assert.Equal(t, 7, claims.UserID)
+	assert.Equal(t, 3, claims.OrganizationID)
+	assert.Equal(t, []string{"owner"}, claims.Roles)
+
+	failing := errors.New("not a member")
+	ax = New(&memUserRepo{users: []*testUser{u}}, &AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5},
+		WithClaimsEnricher(ClaimsEnricherFunc(func(ctx context.Context, u AuthUser, claims *Claims) error {
+			return failing
+		})))
+	_, err = ax.GenerateUserToken(context.Background(), u)
+	assert.Equal(t, failing, err)
+}
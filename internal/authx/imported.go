@@ -0,0 +1,214 @@
+package authx
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrVerifyOnly is returned when hashing with a hasher which only exists to
+// verify hashes imported from other systems
+var ErrVerifyOnly = errors.New("authx: hasher can only verify imported hashes")
+
+// ImportedHashers verify the hash formats of systems users are migrated from.
+// They never produce hashes, the registry upgrades them on the first login.
+func ImportedHashers() []PasswordHasher {
+	return []PasswordHasher{
+		&djangoPBKDF2Hasher{id: "pbkdf2_sha256", digest: sha256.New},
+		&djangoPBKDF2Hasher{id: "pbkdf2_sha1", digest: sha1.New},
+		&firebaseScryptHasher{},
+		&saltedSHA256Hasher{},
+	}
+}
+
+// djangoPBKDF2Hasher verifies Django's pbkdf2_<digest>$<iterations>$<salt>$<hash>
+type djangoPBKDF2Hasher struct {
+	id     string
+	digest func() hash.Hash
+}
+
+func (h *djangoPBKDF2Hasher) ID() string {
+	return h.id
+}
+
+func (h *djangoPBKDF2Hasher) Hash(password string) (string, error) {
+	return "", ErrVerifyOnly
+}
+
+func (h *djangoPBKDF2Hasher) Verify(password, encoded string) (bool, error) {
+	iterations, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := pbkdf2.Key([]byte(password), salt, iterations, len(key), h.digest)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *djangoPBKDF2Hasher) check(encoded string) error {
+	_, _, _, err := h.decode(encoded)
+	return err
+}
+
+// decode splits encoded, Django derives keys as long as the digest
+func (h *djangoPBKDF2Hasher) decode(encoded string) (int, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != h.id {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > maxPBKDF2Iterations {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) != h.digest().Size() {
+		return 0, nil, nil, ErrUnknownHash
+	}
+	return iterations, []byte(parts[2]), key, nil
+}
+
+func (h *djangoPBKDF2Hasher) NeedsRehash(encoded string) bool {
+	return true
+}
+
+// firebaseScryptHasher verifies Firebase's modified scrypt, stored as
+// $firebase-scrypt$ln=<mem_cost>,r=<rounds>$<salt>$<salt separator>$<signer key>$<hash>
+type firebaseScryptHasher struct{}
+
+// EncodeFirebaseScrypt packs a hash exported from Firebase Authentication
+// together with the hash parameters of its project
+func EncodeFirebaseScrypt(hash, salt, saltSeparator, signerKey []byte, memCost, rounds int) string {
+	return fmt.Sprintf("$firebase-scrypt$ln=%d,r=%d$%s$%s$%s$%s", memCost, rounds,
+		encodeB64(salt), encodeB64(saltSeparator), encodeB64(signerKey), encodeB64(hash))
+}
+
+func (h *firebaseScryptHasher) ID() string {
+	return "firebase-scrypt"
+}
+
+func (h *firebaseScryptHasher) Hash(password string) (string, error) {
+	return "", ErrVerifyOnly
+}
+
+func (h *firebaseScryptHasher) Verify(password, encoded string) (bool, error) {
+	p, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	salt, separator, signerKey, key := p.salt, p.separator, p.signerKey, p.key
+
+	derived, err := scrypt.Key([]byte(password), append(salt, separator...), 1<<p.memCost, p.rounds, 1, 64)
+	if err != nil {
+		return false, err
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return false, err
+	}
+	other := make([]byte, len(signerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(other, signerKey)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *firebaseScryptHasher) NeedsRehash(encoded string) bool {
+	return true
+}
+
+func (h *firebaseScryptHasher) check(encoded string) error {
+	_, err := h.decode(encoded)
+	return err
+}
+
+type firebaseScryptParams struct {
+	memCost                         uint
+	rounds                          int
+	salt, separator, signerKey, key []byte
+}
+
+func (h *firebaseScryptHasher) decode(encoded string) (*firebaseScryptParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 7 || parts[1] != h.ID() {
+		return nil, ErrUnknownHash
+	}
+	var p firebaseScryptParams
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d", &p.memCost, &p.rounds); err != nil ||
+		!scryptMemoryInBounds(p.memCost, p.rounds) {
+		return nil, ErrUnknownHash
+	}
+	var decoded [4][]byte
+	for i := range decoded {
+		b, err := decodeB64(parts[3+i])
+		if err != nil {
+			return nil, ErrUnknownHash
+		}
+		decoded[i] = b
+	}
+	p.salt, p.separator, p.signerKey, p.key = decoded[0], decoded[1], decoded[2], decoded[3]
+	// the hash is the signer key encrypted, it can't be shorter
+	if len(p.key) < minKeyLength || len(p.key) != len(p.signerKey) {
+		return nil, ErrUnknownHash
+	}
+	return &p, nil
+}
+
+// saltedSHA256Hasher verifies a single round of SHA-256 over the salt and the
+// password, stored as $salted-sha256$pos=<prefix|suffix>$<salt>$<hash>
+type saltedSHA256Hasher struct{}
+
+// EncodeSaltedSHA256 packs a salted SHA-256 hash, suffix tells whether the
+// salt was appended to the password instead of prepended
+func EncodeSaltedSHA256(hash, salt []byte, suffix bool) string {
+	pos := "prefix"
+	if suffix {
+		pos = "suffix"
+	}
+	return fmt.Sprintf("$salted-sha256$pos=%s$%s$%s", pos, encodeB64(salt), encodeB64(hash))
+}
+
+func (h *saltedSHA256Hasher) ID() string {
+	return "salted-sha256"
+}
+
+func (h *saltedSHA256Hasher) Hash(password string) (string, error) {
+	return "", ErrVerifyOnly
+}
+
+func (h *saltedSHA256Hasher) Verify(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != h.ID() {
+		return false, ErrUnknownHash
+	}
+	salt, err := decodeB64(parts[3])
+	if err != nil {
+		return false, ErrUnknownHash
+	}
+	key, err := decodeB64(parts[4])
+	if err != nil {
+		return false, ErrUnknownHash
+	}
+	var input []byte
+	switch parts[2] {
+	case "pos=prefix":
+		input = append(salt, password...)
+	case "pos=suffix":
+		input = append([]byte(password), salt...)
+	default:
+		return false, ErrUnknownHash
+	}
+	sum := sha256.Sum256(input)
+	return subtle.ConstantTimeCompare(key, sum[:]) == 1, nil
+}
+
+func (h *saltedSHA256Hasher) NeedsRehash(encoded string) bool {
+	return true
+}
@@ -0,0 +1,47 @@
+package authx
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestImportedHashers(t *testing.T) {
+	saltedSum := sha256.Sum256([]byte("pepperpassword"))
+	encoded := map[string]string{
+		"django": "pbkdf2_sha256$260000$seasalt$ftMWvEdczZQK5azuap2CQYKRjHLa1wOuMrfMiYEswYQ=",
+		// test vector published with Firebase's scrypt fork
+		"firebase": EncodeFirebaseScrypt(
+			mustDecode(t, "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="),
+			mustDecode(t, "42xEC+ixf3L2lw=="),
+			mustDecode(t, "Bw=="),
+			mustDecode(t, "jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA=="),
+			14, 8),
+		"salted sha256": EncodeSaltedSHA256(saltedSum[:], []byte("pepper"), false),
+	}
+	passwords := map[string]string{"django": "password", "firebase": "user1password", "salted sha256": "password"}
+
+	hr := DefaultHasherRegistry()
+	for name, e := range encoded {
+		assert.True(t, hr.Supports(e), name)
+		ok, err := hr.Verify(passwords[name], e)
+		require.NoError(t, err, name)
+		assert.True(t, ok, name)
+		ok, err = hr.Verify("wrong", e)
+		require.NoError(t, err, name)
+		assert.False(t, ok, name)
+		assert.True(t, hr.NeedsRehash(e), name)
+	}
+
+	_, err := NewHasherRegistry("pbkdf2_sha256", ImportedHashers()...)
+	assert.Error(t, err, "imported hashers can not be preferred")
+}
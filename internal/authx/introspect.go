@@ -0,0 +1,144 @@
+package authx
+
+import (
+	"context"
+	"errors"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+)
+
+// Token type hints and types as registered for RFC 7662
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// Introspection is the RFC 7662 description of a token. Inactive tokens
+// only carry Active, every other member is omitted.
+type Introspection struct {
+	Active         bool     `json:"active"`
+	Scope          string   `json:"scope,omitempty"`
+	ClientID       string   `json:"client_id,omitempty"`
+	Username       string   `json:"username,omitempty"`
+	TokenType      string   `json:"token_type,omitempty"`
+	ExpiresAt      int64    `json:"exp,omitempty"`
+	IssuedAt       int64    `json:"iat,omitempty"`
+	NotBefore      int64    `json:"nbf,omitempty"`
+	Subject        string   `json:"sub,omitempty"`
+	Audience       string   `json:"aud,omitempty"`
+	Issuer         string   `json:"iss,omitempty"`
+	JTI            string   `json:"jti,omitempty"`
+	UserID         int      `json:"uid,omitempty"`
+	OrganizationID int      `json:"org,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	AuthMethods    []string `json:"amr,omitempty"`
+}
+
+var inactive = &Introspection{Active: false}
+
+// Introspect describes any access or refresh token issued by Authx. Tokens
+// which are malformed, expired, revoked or belong to a deleted user are
+// reported as inactive. The hint, TokenTypeAccess or TokenTypeRefresh, only
+// decides which kind is tried first.
+func (ax *Authx) Introspect(ctx context.Context, token, hint string) (*Introspection, error) {
+	lookups := []func(context.Context, string) (*Introspection, error){
+		ax.introspectAccessToken,
+		ax.introspectRefreshToken,
+	}
+	if hint == TokenTypeRefresh {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		info, err := lookup(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if info.Active {
+			return info, nil
+		}
+	}
+	return inactive, nil
+}
+
+func (ax *Authx) introspectAccessToken(ctx context.Context, token string) (*Introspection, error) {
+	parsedToken, err := parseToken(token, ax.keys, ax.config.Issuer, ax.config.Audience)
+	if err != nil || !parsedToken.Valid {
+		return inactive, nil
+	}
+	claims, ok := parsedToken.Claims.(*Claims)
+	// tokens pending the second factor authenticate nothing yet
+	if !ok || claims.Scope == ScopeMFAPending {
+		return inactive, nil
+	}
+	u, err := ax.getUser(ctx, claims.Identity)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if claims.UserID != 0 && claims.UserID != u.GetId() {
+		// the account was deleted and the email registered again
+		return inactive, nil
+	}
+	revoked, err := ax.isRevoked(ctx, claims, u)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return inactive, nil
+	}
+	return &Introspection{
+		Active:         true,
+		Scope:          claims.Scope,
+		ClientID:       claims.ClientID,
+		Username:       u.GetEmail(),
+		TokenType:      TokenTypeAccess,
+		ExpiresAt:      claims.ExpiresAt,
+		IssuedAt:       claims.IssuedAt,
+		NotBefore:      claims.NotBefore,
+		Subject:        claims.Subject,
+		Audience:       claims.Audience,
+		Issuer:         claims.Issuer,
+		JTI:            claims.Id,
+		UserID:         u.GetId(),
+		OrganizationID: claims.OrganizationID,
+		Roles:          claims.Roles,
+		AuthMethods:    claims.AuthMethods,
+	}, nil
+}
+
+func (ax *Authx) introspectRefreshToken(ctx context.Context, token string) (*Introspection, error) {
+	if ax.refreshRepo == nil {
+		return inactive, nil
+	}
+	rt, err := ax.refreshRepo.FindRefreshTokenByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if rt.IsRevoked() || rt.IsExpired() {
+		return inactive, nil
+	}
+	u, err := ax.userRepo.GetByID(ctx, rt.UserID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	return &Introspection{
+		Active:    true,
+		Scope:     rt.Scope,
+		ClientID:  rt.ClientID,
+		Username:  u.GetEmail(),
+		TokenType: TokenTypeRefresh,
+		ExpiresAt: rt.ExpiresAt.Unix(),
+		IssuedAt:  rt.CreatedAt.Unix(),
+		Subject:   u.GetEmail(),
+		Issuer:    ax.config.Issuer,
+		UserID:    u.GetId(),
+	}, nil
+}
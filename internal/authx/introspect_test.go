@@ -0,0 +1,63 @@
+package authx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthx_Introspect(t *testing.T) {
+	ctx := context.Background()
+	ax, _ := newTestAuthx()
+	revocations := newMemRevocationRepo()
+	WithRevocationRepo(revocations)(ax)
+	u := &testUser{id: 1, email: "test@test.com"}
+
+	pair, err := ax.GenerateTokenPair(ctx, u)
+	require.NoError(t, err)
+
+	info, err := ax.Introspect(ctx, pair.AccessToken, "")
+	require.NoError(t, err)
+	assert.True(t, info.Active)
+	assert.Equal(t, TokenTypeAccess, info.TokenType)
+	assert.Equal(t, "test@test.com", info.Subject)
+	assert.Equal(t, 1, info.UserID)
+	assert.NotZero(t, info.ExpiresAt)
+
+	info, err = ax.Introspect(ctx, pair.RefreshToken, TokenTypeAccess)
+	require.NoError(t, err)
+	assert.True(t, info.Active, "the hint is only a hint")
+	assert.Equal(t, TokenTypeRefresh, info.TokenType)
+
+	info, err = ax.Introspect(ctx, "garbage", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Introspection{}, info)
+
+	t.Run("pending second factor", func(t *testing.T) {
+		token, err := ax.GenerateMFAToken(u)
+		require.NoError(t, err)
+		info, err := ax.Introspect(ctx, token, "")
+		require.NoError(t, err)
+		assert.False(t, info.Active)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		require.NoError(t, ax.RevokeAllTokens(ctx, u, time.Now().Add(time.Second)))
+		for _, token := range []string{pair.AccessToken, pair.RefreshToken} {
+			info, err := ax.Introspect(ctx, token, "")
+			require.NoError(t, err)
+			assert.False(t, info.Active)
+		}
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		token, err := ax.GenerateUserToken(ctx, &testUser{id: 2, email: "gone@test.com"})
+		require.NoError(t, err)
+		info, err := ax.Introspect(ctx, token, "")
+		require.NoError(t, err)
+		assert.False(t, info.Active)
+	})
+}
@@ -11,6 +11,14 @@ import (
 	"time"
 )
 
+const (
+	// accessTokenType is the typ header of access tokens, the jwt-go default
+	accessTokenType = "JWT"
+	// mfaTokenType is the typ header of MFA pending tokens, which parseToken
+	// rejects so they can never stand in for access tokens
+	mfaTokenType = "mfa+jwt"
+)
+
 // fromAuthHeader is a "TokenExtractor" that takes a give request and extracts
 // the JWT token from the Authorization header.
 func fromAuthHeader(r *http.Request) (string, error) {
@@ -25,11 +33,12 @@ func fromAuthHeader(r *http.Request) (string, error) {
 	return authHeaderParts[1], nil
 }
 
-func createToken(identity, secreteKey string, expireTime int) (string, error) {
+func newClaims(identity string, expireTime int) *Claims {
 	now := time.Now()
 	expirationTime := now.Add(time.Duration(expireTime) * time.Minute)
-	claims := &Claims{
-		Identity: identity,
+	return &Claims{
+		Identity:      identity,
+		IssuedAtMicro: now.UnixNano() / int64(time.Microsecond),
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
 			Id:        uuid.New().String(),
@@ -38,17 +47,44 @@ func createToken(identity, secreteKey string, expireTime int) (string, error) {
 			Subject:   identity,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secreteKey))
+}
+
+func createToken(claims *Claims, key *SigningKey) (string, error) {
+	return createTypedToken(claims, key, accessTokenType)
+}
+
+// createTypedToken is createToken with typ as the typ header
+func createTypedToken(claims *Claims, key *SigningKey, typ string) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["typ"] = typ
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	tokenString, err := token.SignedString(key.PrivateKey)
 	return tokenString, err
 }
 
-func parseToken(token, secretKey string) (*jwt.Token, error) {
+// parseToken verifies the token with the key named by its kid header. The
+// token's alg must match the algorithm of that key, so a token can never
+// choose how it is verified. Empty issuer or audience are not checked. Only
+// access tokens are accepted, see parseTypedToken for the others.
+func parseToken(token string, keys KeyProvider, issuer, audience string) (*jwt.Token, error) {
+	return parseTypedToken(token, keys, issuer, audience, accessTokenType)
+}
+
+// parseTypedToken is parseToken for tokens with the typ header typ. Tokens
+// without the header are access tokens.
+func parseTypedToken(token string, keys KeyProvider, issuer, audience, typ string) (*jwt.Token, error) {
 	parsedToken, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, err := keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(secretKey), nil
+		return key.PublicKey, nil
 	})
 	if err != nil {
 		message := ""
@@ -71,5 +107,19 @@ func parseToken(token, secretKey string) (*jwt.Token, error) {
 		}
 		return nil, err
 	}
+	tokenType, _ := parsedToken.Header["typ"].(string)
+	if tokenType == "" {
+		tokenType = accessTokenType
+	}
+	if !strings.EqualFold(tokenType, typ) {
+		return nil, &AuthError{Message: "invalid token type", Code: http.StatusUnauthorized, Status: http.StatusUnauthorized}
+	}
+	claims, ok := parsedToken.Claims.(*Claims)
+	if ok && issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		return nil, &AuthError{Message: "invalid token issuer", Code: http.StatusUnauthorized, Status: http.StatusUnauthorized}
+	}
+	if ok && audience != "" && !claims.VerifyAudience(audience, true) {
+		return nil, &AuthError{Message: "invalid token audience", Code: http.StatusUnauthorized, Status: http.StatusUnauthorized}
+	}
 	return parsedToken, nil
 }
@@ -6,13 +6,14 @@ import (
 )
 
 func TestAuthx_createToken(t *testing.T) {
-	_, err := createToken("test@test.com", "test", 5)
+	_, err := createToken(newClaims("test@test.com", 5), NewHMACKey("", []byte("test")))
 	assert.Nil(t, err)
 }
 
 func TestAuthx_parseToken(t *testing.T) {
-	token, _ := createToken("test@test.com", "test", 5)
-	parsedToken, err := parseToken(token, "test")
+	key := NewHMACKey("", []byte("test"))
+	token, _ := createToken(newClaims("test@test.com", 5), key)
+	parsedToken, err := parseToken(token, NewStaticKeys(key), "", "")
 	assert.Nil(t, err)
 	assert.Equal(t, true, parsedToken.Valid)
 	claims, ok := parsedToken.Claims.(*Claims)
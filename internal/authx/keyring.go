@@ -0,0 +1,357 @@
+package authx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/imtanmoy/logx"
+)
+
+// KeyState is the lifecycle state of a key in a Keyring
+type KeyState string
+
+const (
+	// KeyActive signs new tokens, exactly one key is active
+	KeyActive KeyState = "active"
+	// KeyVerifying no longer signs but tokens signed with it are still accepted
+	KeyVerifying KeyState = "verifying"
+	// KeyRetired is neither used for signing nor accepted for verification
+	KeyRetired KeyState = "retired"
+)
+
+const manifestFile = "keyring.json"
+
+// missReloadInterval is the least time between reloads triggered by tokens
+// naming an unknown kid, which anyone can send
+const missReloadInterval = 5 * time.Second
+
+// ErrKeyRetired is returned when a token was signed with a retired key
+var ErrKeyRetired = errors.New("signing key retired")
+
+// KeyringEntry describes one key of the keyring manifest
+type KeyringEntry struct {
+	ID        string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	File      string    `json:"file"`
+	State     KeyState  `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type keyringManifest struct {
+	Keys []*KeyringEntry `json:"keys"`
+}
+
+// Keyring is a KeyProvider backed by a directory holding PEM files and a
+// keyring.json manifest with the state of every key. The manifest is reloaded
+// periodically and when a token names an unknown kid, at most once per
+// missReloadInterval, so keys rotated by another process are picked up
+// without a restart.
+type Keyring struct {
+	dir            string
+	reloadInterval time.Duration
+
+	mu       sync.RWMutex
+	keys     map[string]*SigningKey
+	retired  map[string]bool
+	active   *SigningKey
+	loadedAt time.Time
+	missedAt time.Time
+}
+
+var _ KeyProvider = (*Keyring)(nil)
+
+// OpenKeyring loads the keyring stored in dir
+func OpenKeyring(dir string, reloadInterval time.Duration) (*Keyring, error) {
+	kr := &Keyring{dir: dir, reloadInterval: reloadInterval}
+	if err := kr.Reload(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Reload re-reads the manifest and the keys it references
+func (kr *Keyring) Reload() error {
+	manifest, err := readManifest(kr.dir)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]*SigningKey)
+	retired := make(map[string]bool)
+	var active *SigningKey
+	for _, entry := range manifest.Keys {
+		if entry.State == KeyRetired {
+			retired[entry.ID] = true
+			continue
+		}
+		key, err := LoadSigningKey(entry.ID, filepath.Join(kr.dir, entry.File))
+		if err != nil {
+			return fmt.Errorf("authx: loading key %s: %v", entry.ID, err)
+		}
+		keys[entry.ID] = key
+		if entry.State == KeyActive {
+			active = key
+		}
+	}
+	if active == nil {
+		return errors.New("authx: keyring has no active key")
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = keys
+	kr.retired = retired
+	kr.active = active
+	kr.loadedAt = time.Now()
+	return nil
+}
+
+func (kr *Keyring) SigningKey() (*SigningKey, error) {
+	kr.reloadIfStale()
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.active, nil
+}
+
+func (kr *Keyring) VerificationKey(kid string) (*SigningKey, error) {
+	kr.reloadIfStale()
+	key, err := kr.lookup(kid)
+	if err == ErrKeyNotFound && kid != "" && kr.reserveMissReload() {
+		// the key may have been rotated in by another process
+		if err := kr.Reload(); err != nil {
+			logx.Errorf("could not reload keyring: %s", err)
+		}
+		key, err = kr.lookup(kid)
+	}
+	return key, err
+}
+
+func (kr *Keyring) PublicKeys() []*SigningKey {
+	kr.reloadIfStale()
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	list := make([]*SigningKey, 0, len(kr.keys))
+	for _, k := range kr.keys {
+		list = append(list, k)
+	}
+	return publicKeys(list)
+}
+
+func (kr *Keyring) lookup(kid string) (*SigningKey, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if key, ok := kr.keys[kid]; ok {
+		return key, nil
+	}
+	if kr.retired[kid] {
+		return nil, ErrKeyRetired
+	}
+	return nil, ErrKeyNotFound
+}
+
+// reserveMissReload reports whether an unknown kid may reload the keyring now
+func (kr *Keyring) reserveMissReload() bool {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if time.Since(kr.missedAt) < missReloadInterval {
+		return false
+	}
+	kr.missedAt = time.Now()
+	return true
+}
+
+func (kr *Keyring) reloadIfStale() {
+	if kr.reloadInterval <= 0 {
+		return
+	}
+	kr.mu.RLock()
+	stale := time.Since(kr.loadedAt) > kr.reloadInterval
+	kr.mu.RUnlock()
+	if stale {
+		if err := kr.Reload(); err != nil {
+			logx.Errorf("could not reload keyring: %s", err)
+		}
+	}
+}
+
+// ListKeys returns the manifest entries of the keyring in dir
+func ListKeys(dir string) ([]*KeyringEntry, error) {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Keys, nil
+}
+
+// RotateKeyring generates a new key for alg (RS256, ES256 or EdDSA) and adds it
+// as a verifying key, so it is published in the JWKS before it signs anything.
+// Once relying parties had time to refresh their cached JWKS the key is made
+// active with PromoteKey. The keyring is created when dir has no manifest yet,
+// its first key is active right away.
+func RotateKeyring(dir, alg string) (*KeyringEntry, error) {
+	manifest, err := readManifest(dir)
+	if os.IsNotExist(err) {
+		manifest = &keyringManifest{}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewSigningKey("", privateKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := EncodePrivateKeyPEM(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	entry := &KeyringEntry{
+		ID:        key.ID,
+		Algorithm: key.Method.Alg(),
+		File:      key.ID + ".pem",
+		State:     KeyActive,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, e := range manifest.Keys {
+		if e.State == KeyActive {
+			entry.State = KeyVerifying
+		}
+	}
+	if err := writeFileAtomic(filepath.Join(dir, entry.File), data, 0600); err != nil {
+		return nil, err
+	}
+	manifest.Keys = append(manifest.Keys, entry)
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// PromoteKey makes the verifying key kid the active key. The previously active
+// key is demoted to verifying so tokens it signed stay valid until the key is
+// retired.
+func PromoteKey(dir, kid string) error {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	var promoted *KeyringEntry
+	for _, e := range manifest.Keys {
+		if e.ID == kid {
+			promoted = e
+		}
+	}
+	if promoted == nil {
+		return ErrKeyNotFound
+	}
+	switch promoted.State {
+	case KeyActive:
+		return nil
+	case KeyRetired:
+		return ErrKeyRetired
+	}
+	for _, e := range manifest.Keys {
+		if e.State == KeyActive {
+			e.State = KeyVerifying
+		}
+	}
+	promoted.State = KeyActive
+	return writeManifest(dir, manifest)
+}
+
+// RetireKey stops accepting tokens signed with kid. The active key can not be retired.
+func RetireKey(dir, kid string) error {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range manifest.Keys {
+		if e.ID != kid {
+			continue
+		}
+		if e.State == KeyActive {
+			return errors.New("authx: the active key can not be retired, promote another key first")
+		}
+		e.State = KeyRetired
+		return writeManifest(dir, manifest)
+	}
+	return ErrKeyNotFound
+}
+
+// GenerateSigningKey creates a new private key for alg
+func GenerateSigningKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case "RS256":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, fmt.Errorf("authx: unsupported signing algorithm %q", alg)
+	}
+}
+
+func readManifest(dir string) (*keyringManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest keyringManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("authx: invalid keyring manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+func writeManifest(dir string, manifest *keyringManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, manifestFile), data, 0600)
+}
+
+// writeFileAtomic writes through a temporary file so readers never observe a
+// partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,105 @@
+package authx
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = OpenKeyring(dir, 0)
+	assert.Error(t, err, "empty keyring")
+
+	first, err := RotateKeyring(dir, "ES256")
+	require.NoError(t, err)
+	kr, err := OpenKeyring(dir, 0)
+	require.NoError(t, err)
+	ax := New(&memUserRepo{}, &AuthxConfig{AccessTokenExpireTime: 5}, WithKeyProvider(kr))
+
+	oldToken, err := ax.GenerateToken("test@test.com")
+	require.NoError(t, err)
+
+	// rotated by another process, kr has not reloaded yet
+	second, err := RotateKeyring(dir, "EdDSA")
+	require.NoError(t, err)
+	assert.Equal(t, KeyVerifying, second.State, "published before it signs")
+	other, err := OpenKeyring(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, mustKey(t, other).ID)
+	assert.Len(t, other.PublicKeys(), 2)
+
+	require.NoError(t, PromoteKey(dir, second.ID))
+	require.NoError(t, other.Reload())
+	require.NoError(t, err)
+	newToken, err := createToken(newClaims("test@test.com", 5), mustKey(t, other))
+	require.NoError(t, err)
+
+	parsed, err := parseToken(newToken, kr, "", "")
+	require.NoError(t, err, "unknown kid triggers a reload")
+	assert.Equal(t, second.ID, parsed.Header["kid"])
+
+	parsed, err = parseToken(oldToken, kr, "", "")
+	require.NoError(t, err, "demoted key still verifies")
+	assert.Equal(t, first.ID, parsed.Header["kid"])
+
+	signing, err := kr.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, signing.ID)
+	assert.Len(t, kr.PublicKeys(), 2)
+
+	entries, err := ListKeys(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, KeyVerifying, entries[0].State)
+	assert.Equal(t, KeyActive, entries[1].State)
+
+	t.Run("unknown kids reload rarely", func(t *testing.T) {
+		third, err := RotateKeyring(dir, "ES256")
+		require.NoError(t, err)
+		require.NoError(t, PromoteKey(dir, third.ID))
+		other, err := OpenKeyring(dir, 0)
+		require.NoError(t, err)
+		token, err := createToken(newClaims("test@test.com", 5), mustKey(t, other))
+		require.NoError(t, err)
+
+		_, err = parseToken(token, kr, "", "")
+		assert.Error(t, err, "the keyring reloaded for an unknown kid moments ago")
+		kr.missedAt = time.Now().Add(-missReloadInterval)
+		parsed, err := parseToken(token, kr, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, third.ID, parsed.Header["kid"])
+	})
+
+	t.Run("promote", func(t *testing.T) {
+		assert.Equal(t, ErrKeyNotFound, PromoteKey(dir, "unknown"))
+		require.NoError(t, PromoteKey(dir, second.ID))
+		require.NoError(t, kr.Reload())
+		assert.Equal(t, second.ID, mustKey(t, kr).ID)
+	})
+
+	t.Run("retire", func(t *testing.T) {
+		assert.Error(t, RetireKey(dir, mustKey(t, kr).ID), "active key")
+		assert.Equal(t, ErrKeyNotFound, RetireKey(dir, "unknown"))
+		require.NoError(t, RetireKey(dir, first.ID))
+		require.NoError(t, kr.Reload())
+
+		_, err := parseToken(oldToken, kr, "", "")
+		assert.Error(t, err)
+		assert.Len(t, kr.PublicKeys(), 2)
+		assert.Equal(t, ErrKeyRetired, PromoteKey(dir, first.ID))
+	})
+}
+
+func mustKey(t *testing.T, kp KeyProvider) *SigningKey {
+	key, err := kp.SigningKey()
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,177 @@
+package authx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrKeyNotFound is returned when no key matches a token's kid
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// SigningKey is a key used to sign and verify tokens. For HMAC keys both
+// PrivateKey and PublicKey hold the shared secret.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// IsSymmetric reports whether the key is a shared secret
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// KeyProvider supplies the keys used to sign and verify tokens
+type KeyProvider interface {
+	// SigningKey returns the key new tokens are signed with
+	SigningKey() (*SigningKey, error)
+	// VerificationKey returns the key for kid, an empty kid selects the signing key
+	VerificationKey(kid string) (*SigningKey, error)
+	// PublicKeys returns every asymmetric key tokens may currently be verified with
+	PublicKeys() []*SigningKey
+}
+
+// StaticKeys is a fixed set of keys, the first one signs
+type StaticKeys struct {
+	keys []*SigningKey
+}
+
+var _ KeyProvider = (*StaticKeys)(nil)
+
+// NewStaticKeys creates a KeyProvider signing with the first key and verifying with all of them
+func NewStaticKeys(signing *SigningKey, verifying ...*SigningKey) *StaticKeys {
+	return &StaticKeys{keys: append([]*SigningKey{signing}, verifying...)}
+}
+
+func (s *StaticKeys) SigningKey() (*SigningKey, error) {
+	return s.keys[0], nil
+}
+
+func (s *StaticKeys) VerificationKey(kid string) (*SigningKey, error) {
+	if kid == "" {
+		return s.keys[0], nil
+	}
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return k, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (s *StaticKeys) PublicKeys() []*SigningKey {
+	return publicKeys(s.keys)
+}
+
+func publicKeys(keys []*SigningKey) []*SigningKey {
+	var list []*SigningKey
+	for _, k := range keys {
+		if !k.IsSymmetric() {
+			list = append(list, k)
+		}
+	}
+	return list
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, PrivateKey: secret, PublicKey: secret}
+}
+
+// LoadSigningKey reads a PEM encoded RSA, ECDSA or Ed25519 private key. The
+// algorithm is derived from the key: RS256, ES256/ES384/ES512 or EdDSA. When id
+// is empty the RFC 7638 thumbprint of the public key is used as kid.
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(id, data)
+}
+
+// ParseSigningKey is like LoadSigningKey for an in memory PEM block
+func ParseSigningKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("authx: no PEM data found")
+	}
+	privateKey, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(id, privateKey)
+}
+
+// NewSigningKey wraps an RSA, ECDSA or Ed25519 private key
+func NewSigningKey(id string, privateKey crypto.Signer) (*SigningKey, error) {
+	key := &SigningKey{ID: id, PrivateKey: privateKey, PublicKey: privateKey.Public()}
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("authx: unsupported curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		key.Method = SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("authx: unsupported key type %T", privateKey)
+	}
+	if key.ID == "" {
+		kid, err := thumbprint(key)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = kid
+	}
+	return key, nil
+}
+
+// EncodePrivateKeyPEM encodes a private key as a PKCS#8 PEM block
+func EncodePrivateKeyPEM(privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := k.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("authx: unsupported key type %T", k)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("authx: unsupported PEM block %q", block.Type)
+	}
+}
@@ -0,0 +1,128 @@
+package authx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateTestKeys(t *testing.T) map[string]crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return map[string]crypto.Signer{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authx")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for alg, privateKey := range generateTestKeys(t) {
+		alg, privateKey := alg, privateKey
+		t.Run(alg, func(t *testing.T) {
+			data, err := EncodePrivateKeyPEM(privateKey)
+			require.NoError(t, err)
+			path := filepath.Join(dir, alg+".pem")
+			require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+			key, err := LoadSigningKey("", path)
+			require.NoError(t, err)
+			assert.Equal(t, alg, key.Method.Alg())
+			assert.NotEmpty(t, key.ID)
+
+			token, err := createToken(newClaims("test@test.com", 5), key)
+			require.NoError(t, err)
+			parsedToken, err := parseToken(token, NewStaticKeys(key), "", "")
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, parsedToken.Header["kid"])
+			assert.Equal(t, "test@test.com", parsedToken.Claims.(*Claims).Identity)
+		})
+	}
+}
+
+func TestParseSigningKey_PKCS1(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	data := pemEncode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	key, err := ParseSigningKey("rsa-1", data)
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-1", key.ID)
+	assert.Equal(t, "RS256", key.Method.Alg())
+
+	_, err = ParseSigningKey("", []byte("not a pem"))
+	assert.Error(t, err)
+}
+
+func TestParseToken_RejectsForeignKeys(t *testing.T) {
+	keys := generateTestKeys(t)
+	rsaKey, err := NewSigningKey("rsa", keys["RS256"])
+	require.NoError(t, err)
+	ecKey, err := NewSigningKey("ec", keys["ES256"])
+	require.NoError(t, err)
+	provider := NewStaticKeys(rsaKey)
+
+	t.Run("unknown kid", func(t *testing.T) {
+		token, err := createToken(newClaims("test@test.com", 5), ecKey)
+		require.NoError(t, err)
+		_, err = parseToken(token, provider, "", "")
+		assert.Error(t, err)
+	})
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(keys["RS256"].Public())
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Identity: "test@test.com"})
+		forged.Header["kid"] = "rsa"
+		token, err := forged.SignedString(pemEncode("PUBLIC KEY", der))
+		require.NoError(t, err)
+		_, err = parseToken(token, provider, "", "")
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthx_JWKS(t *testing.T) {
+	var list []*SigningKey
+	for _, privateKey := range generateTestKeys(t) {
+		key, err := NewSigningKey("", privateKey)
+		require.NoError(t, err)
+		list = append(list, key)
+	}
+	ax := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"},
+		WithKeyProvider(NewStaticKeys(list[0], append(list[1:], NewHMACKey("hmac", []byte("test")))...)))
+
+	set, err := ax.JWKS()
+	require.NoError(t, err)
+	require.Len(t, set.Keys, 3)
+	kty := map[string]string{}
+	for _, jwk := range set.Keys {
+		kty[jwk.Alg] = jwk.Kty
+		assert.Equal(t, "sig", jwk.Use)
+		assert.NotEmpty(t, jwk.Kid)
+	}
+	assert.Equal(t, map[string]string{"RS256": "RSA", "ES256": "EC", "EdDSA": "OKP"}, kty)
+
+	empty, err := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"}).JWKS()
+	require.NoError(t, err)
+	assert.Len(t, empty.Keys, 0)
+}
+
+func pemEncode(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
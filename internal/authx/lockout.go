@@ -0,0 +1,254 @@
+package authx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+)
+
+// LoginAttempt is the failed login state of an account or a source IP
+type LoginAttempt struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  time.Time
+}
+
+// LoginAttemptRepo persists failed logins, keyed by AccountLoginKey and IPLoginKey
+type LoginAttemptRepo interface {
+	// FindLoginAttempt returns errorx.ErrorNotFound when key has no failures
+	FindLoginAttempt(ctx context.Context, key string) (*LoginAttempt, error)
+	// RecordLoginFailure counts a failure at now. The count starts over when
+	// the previous failure is older than since.
+	RecordLoginFailure(ctx context.Context, key string, now, since time.Time) (*LoginAttempt, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	DeleteLoginAttempt(ctx context.Context, key string) error
+	// PurgeLoginAttempts deletes the entries neither failed nor locked since before
+	PurgeLoginAttempts(ctx context.Context, before time.Time) error
+}
+
+// LockoutPolicy throttles failed logins. After FreeAttempts failures every
+// attempt has to wait BaseDelay, doubled with each further failure up to
+// MaxDelay, a MaxDelay of 0 keeps BaseDelay. LockAfter failures lock the key
+// for LockDuration, a failure right after the lock expired locks it again.
+// Failures older than Window are forgotten, a Window of 0 never forgets them.
+type LockoutPolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// LockAfter of 0 never locks
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+// delay is how long to wait after the last of failures before the next attempt
+func (p *LockoutPolicy) delay(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// retryAfter returns how long a attempts have to wait, 0 when they may go ahead
+func (p *LockoutPolicy) retryAfter(a *LoginAttempt, now time.Time) (time.Duration, bool) {
+	if a.LockedUntil.After(now) {
+		return a.LockedUntil.Sub(now), true
+	}
+	if p.Window > 0 && a.LastFailedAt.Before(now.Add(-p.Window)) {
+		return 0, false
+	}
+	if next := a.LastFailedAt.Add(p.delay(a.Failures)); next.After(now) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+type lockout struct {
+	repo    LoginAttemptRepo
+	account LockoutPolicy
+	ip      LockoutPolicy
+}
+
+// WithLoginLockout tracks failed logins per account and per source IP and
+// throttles both according to their policies
+func WithLoginLockout(repo LoginAttemptRepo, account, ip LockoutPolicy) Option {
+	return func(ax *Authx) {
+		ax.lockout = &lockout{repo: repo, account: account, ip: ip}
+	}
+}
+
+// LoginBlockedError is returned by CheckLogin while an account or IP has to wait
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	// Locked is set for a lock, unset for a backoff delay
+	Locked bool
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("login locked, retry after %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("too many failed logins, retry after %s", e.RetryAfter)
+}
+
+// AccountLoginKey is the LoginAttemptRepo key of an identity, whether or not
+// an account with it exists
+func AccountLoginKey(identity string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(identity))
+}
+
+// IPLoginKey is the LoginAttemptRepo key of a source IP
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ClientIP returns the IP of the client, chi's RealIP middleware has already
+// replaced RemoteAddr with the forwarded address when there is one
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// CheckLogin must be called before verifying credentials. It returns a
+// *LoginBlockedError when the identity or ip has to wait, the longer wait wins.
+func (ax *Authx) CheckLogin(ctx context.Context, identity, ip string) error {
+	if ax.lockout == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	var blocked *LoginBlockedError
+	for _, k := range ax.lockout.keys(identity, ip) {
+		a, err := ax.lockout.repo.FindLoginAttempt(ctx, k.key)
+		if err != nil {
+			if errors.Is(err, errorx.ErrorNotFound) {
+				continue
+			}
+			return err
+		}
+		wait, locked := k.policy.retryAfter(a, now)
+		if wait > 0 && (blocked == nil || wait > blocked.RetryAfter) {
+			blocked = &LoginBlockedError{RetryAfter: wait, Locked: locked}
+		}
+	}
+	if blocked != nil {
+		return blocked
+	}
+	return nil
+}
+
+// LoginFailed records a failed login. When it locks the account the end of
+// the lock is returned, the zero time otherwise.
+func (ax *Authx) LoginFailed(ctx context.Context, identity, ip string) (time.Time, error) {
+	if ax.lockout == nil {
+		return time.Time{}, nil
+	}
+	now := time.Now().UTC()
+	var accountLockedUntil time.Time
+	for i, k := range ax.lockout.keys(identity, ip) {
+		since := time.Time{}
+		if k.policy.Window > 0 {
+			since = now.Add(-k.policy.Window)
+		}
+		a, err := ax.lockout.repo.RecordLoginFailure(ctx, k.key, now, since)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if k.policy.LockAfter == 0 || a.Failures < k.policy.LockAfter || a.LockedUntil.After(now) {
+			continue
+		}
+		until := now.Add(k.policy.LockDuration)
+		if err := ax.lockout.repo.LockLogin(ctx, k.key, until); err != nil {
+			return time.Time{}, err
+		}
+		if i == 0 {
+			accountLockedUntil = until
+		}
+	}
+	return accountLockedUntil, nil
+}
+
+// LoginSucceeded forgets the failures of the account. Failures of the IP are
+// kept, a valid account must not reset the count of an IP guessing others.
+func (ax *Authx) LoginSucceeded(ctx context.Context, identity string) error {
+	if ax.lockout == nil {
+		return nil
+	}
+	if err := ax.lockout.repo.DeleteLoginAttempt(ctx, AccountLoginKey(identity)); err != nil {
+		return err
+	}
+	if retention := ax.lockout.retention(); retention > 0 {
+		return ax.lockout.repo.PurgeLoginAttempts(ctx, time.Now().UTC().Add(-retention))
+	}
+	return nil
+}
+
+// UnlockLogin lifts the lock and delays of an identity
+func (ax *Authx) UnlockLogin(ctx context.Context, identity string) error {
+	if ax.lockout == nil {
+		return nil
+	}
+	return ax.lockout.repo.DeleteLoginAttempt(ctx, AccountLoginKey(identity))
+}
+
+// UnlockUser lifts the lock and delays of the user with id
+func (ax *Authx) UnlockUser(ctx context.Context, id int) (AuthUser, error) {
+	u, err := ax.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return u, ax.UnlockLogin(ctx, u.GetEmail())
+}
+
+// UnlockIP lifts the lock and delays of a source IP
+func (ax *Authx) UnlockIP(ctx context.Context, ip string) error {
+	if ax.lockout == nil {
+		return nil
+	}
+	return ax.lockout.repo.DeleteLoginAttempt(ctx, IPLoginKey(ip))
+}
+
+type lockoutKey struct {
+	key    string
+	policy *LockoutPolicy
+}
+
+// keys returns the account key first, the ip key is left out when unknown
+func (l *lockout) keys(identity, ip string) []lockoutKey {
+	keys := []lockoutKey{{key: AccountLoginKey(identity), policy: &l.account}}
+	if ip != "" {
+		keys = append(keys, lockoutKey{key: IPLoginKey(ip), policy: &l.ip})
+	}
+	return keys
+}
+
+// retention is how long an entry can still matter after its last failure, 0
+// when failures are never forgotten
+func (l *lockout) retention() time.Duration {
+	if l.account.Window == 0 || l.ip.Window == 0 {
+		return 0
+	}
+	d := l.account.Window
+	for _, other := range []time.Duration{l.account.LockDuration, l.account.MaxDelay, l.ip.Window, l.ip.LockDuration, l.ip.MaxDelay} {
+		if other > d {
+			d = other
+		}
+	}
+	return d
+}
@@ -0,0 +1,150 @@
+package authx
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memLoginAttemptRepo struct {
+	attempts map[string]*LoginAttempt
+}
+
+func (m *memLoginAttemptRepo) FindLoginAttempt(ctx context.Context, key string) (*LoginAttempt, error) {
+	a, ok := m.attempts[key]
+	if !ok {
+		return nil, errorx.ErrorNotFound
+	}
+	copied := *a
+	return &copied, nil
+}
+
+func (m *memLoginAttemptRepo) RecordLoginFailure(ctx context.Context, key string, now, since time.Time) (*LoginAttempt, error) {
+	a, ok := m.attempts[key]
+	if !ok || a.LastFailedAt.Before(since) {
+		a = &LoginAttempt{Key: key}
+		m.attempts[key] = a
+	}
+	a.Failures++
+	a.LastFailedAt = now
+	copied := *a
+	return &copied, nil
+}
+
+func (m *memLoginAttemptRepo) LockLogin(ctx context.Context, key string, until time.Time) error {
+	m.attempts[key].LockedUntil = until
+	return nil
+}
+
+func (m *memLoginAttemptRepo) DeleteLoginAttempt(ctx context.Context, key string) error {
+	delete(m.attempts, key)
+	return nil
+}
+
+func (m *memLoginAttemptRepo) PurgeLoginAttempts(ctx context.Context, before time.Time) error {
+	for key, a := range m.attempts {
+		if a.LastFailedAt.Before(before) && a.LockedUntil.Before(before) {
+			delete(m.attempts, key)
+		}
+	}
+	return nil
+}
+
+func TestLockoutPolicy_delay(t *testing.T) {
+	p := &LockoutPolicy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for failures, want := range map[int]time.Duration{
+		0: 0, 3: 0, 4: time.Second, 5: 2 * time.Second, 6: 4 * time.Second, 7: 8 * time.Second, 8: 10 * time.Second, 50: 10 * time.Second,
+	} {
+		assert.Equal(t, want, p.delay(failures), "%d failures", failures)
+	}
+}
+
+func TestAuthx_Lockout(t *testing.T) {
+	ctx := context.Background()
+	users := &memUserRepo{users: []*testUser{{id: 1, email: "test@test.com"}}}
+	repo := &memLoginAttemptRepo{attempts: make(map[string]*LoginAttempt)}
+	account := LockoutPolicy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, LockAfter: 3, LockDuration: time.Hour, Window: time.Hour}
+	ip := LockoutPolicy{FreeAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	ax := New(users, &AuthxConfig{SecretKey: "test"}, WithLoginLockout(repo, account, ip))
+
+	blocked := func(identity, ip string) *LoginBlockedError {
+		var be *LoginBlockedError
+		if err := ax.CheckLogin(ctx, identity, ip); err != nil {
+			require.True(t, errors.As(err, &be), err)
+		}
+		return be
+	}
+
+	lockedUntil, err := ax.LoginFailed(ctx, "Test@test.com", "10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, lockedUntil.IsZero())
+	assert.Nil(t, blocked("test@test.com", "10.0.0.2"), "first failure is free")
+
+	_, err = ax.LoginFailed(ctx, "test@test.com", "10.0.0.1")
+	require.NoError(t, err)
+	be := blocked("test@test.com", "10.0.0.2")
+	require.NotNil(t, be, "second failure delays the account from any IP")
+	assert.False(t, be.Locked)
+	assert.InDelta(t, time.Minute.Seconds(), be.RetryAfter.Seconds(), 1)
+	assert.Nil(t, blocked("other@test.com", "10.0.0.1"), "the IP is below its own limit")
+
+	lockedUntil, err = ax.LoginFailed(ctx, "test@test.com", "10.0.0.1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), lockedUntil, 5*time.Second)
+	be = blocked("test@test.com", "")
+	require.NotNil(t, be)
+	assert.True(t, be.Locked)
+
+	t.Run("unlock", func(t *testing.T) {
+		u, err := ax.UnlockUser(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "test@test.com", u.GetEmail())
+		assert.Nil(t, blocked("test@test.com", ""))
+		_, err = ax.UnlockUser(ctx, 2)
+		assert.Error(t, err)
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		for i := 0; i < 6; i++ {
+			_, err := ax.LoginFailed(ctx, "", "10.0.0.9")
+			require.NoError(t, err)
+		}
+		be := blocked("fresh@test.com", "10.0.0.9")
+		require.NotNil(t, be)
+		assert.False(t, be.Locked, "the ip policy never locks")
+		require.NoError(t, ax.UnlockIP(ctx, "10.0.0.9"))
+		assert.Nil(t, blocked("fresh@test.com", "10.0.0.9"))
+	})
+
+	t.Run("success resets the account only", func(t *testing.T) {
+		_, err := ax.LoginFailed(ctx, "test@test.com", "10.0.0.3")
+		require.NoError(t, err)
+		require.NoError(t, ax.LoginSucceeded(ctx, "test@test.com"))
+		_, err = repo.FindLoginAttempt(ctx, AccountLoginKey("test@test.com"))
+		assert.Equal(t, errorx.ErrorNotFound, err)
+		_, err = repo.FindLoginAttempt(ctx, IPLoginKey("10.0.0.3"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		plain := New(users, &AuthxConfig{SecretKey: "test"})
+		lockedUntil, err := plain.LoginFailed(ctx, "test@test.com", "10.0.0.1")
+		assert.NoError(t, err)
+		assert.True(t, lockedUntil.IsZero())
+		assert.NoError(t, plain.CheckLogin(ctx, "test@test.com", "10.0.0.1"))
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("POST", "/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", ClientIP(r))
+	r.RemoteAddr = "2001:db8::1"
+	assert.Equal(t, "2001:db8::1", ClientIP(r))
+}
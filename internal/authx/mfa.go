@@ -0,0 +1,149 @@
+package authx
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+)
+
+// Authentication methods recorded in the amr claim, as registered by RFC 8176
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	// AuthMethodHardwareKey marks proofs of possession of a WebAuthn
+	// credential
+	AuthMethodHardwareKey = "hwk"
+	// AuthMethodMFA marks tokens of users who presented a second factor
+	AuthMethodMFA = "mfa"
+	// AuthMethodEmail marks proofs of access to the email address of the
+	// user, by a magic link or an emailed code. RFC 8176 registers no value
+	// for it.
+	AuthMethodEmail = "email"
+)
+
+// ScopeMFAPending is the scope of tokens which only prove the password of a
+// user who still has to present a second factor. AuthMiddleware rejects them.
+const ScopeMFAPending = "mfa_pending"
+
+// defaultMFATokenExpireTime is the lifetime, in minutes, of MFA pending tokens
+// when AuthxConfig.MFATokenExpireTime is not set
+const defaultMFATokenExpireTime = 5
+
+// SecondFactor tells whether a user has to present a second factor after the
+// password, e.g. because an authenticator app is enrolled
+type SecondFactor interface {
+	SecondFactorRequired(ctx context.Context, u AuthUser) (bool, error)
+	// AuthMethod is the amr value of the factor, clients pick the factor to
+	// present by it
+	AuthMethod() string
+}
+
+// WithSecondFactor makes SecondFactorRequired consult the given factors
+func WithSecondFactor(factors ...SecondFactor) Option {
+	return func(ax *Authx) {
+		ax.factors = append(ax.factors, factors...)
+	}
+}
+
+// WithAuthMethods records how the user authenticated in the amr claim
+func WithAuthMethods(methods ...string) ClaimsOption {
+	return func(claims *Claims) {
+		claims.AuthMethods = append([]string(nil), methods...)
+	}
+}
+
+// HasAuthMethod reports whether the token was issued after authenticating
+// with method
+func (c *Claims) HasAuthMethod(method string) bool {
+	for _, m := range c.AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// SecondFactorRequired reports whether any configured SecondFactor requires
+// one from u
+func (ax *Authx) SecondFactorRequired(ctx context.Context, u AuthUser) (bool, error) {
+	methods, err := ax.SecondFactorMethods(ctx, u)
+	return len(methods) > 0, err
+}
+
+// SecondFactorMethods returns the amr values of the second factors u can
+// present, none when u needs no second factor
+func (ax *Authx) SecondFactorMethods(ctx context.Context, u AuthUser) ([]string, error) {
+	var methods []string
+	for _, f := range ax.factors {
+		required, err := f.SecondFactorRequired(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			methods = append(methods, f.AuthMethod())
+		}
+	}
+	return methods, nil
+}
+
+// GenerateMFAToken issues a short lived MFA pending token for u, to be
+// exchanged for a token pair once the second factor is verified. The amr
+// claim records the first factor, the password unless WithAuthMethods says
+// otherwise. Its typ header keeps it from passing as an access token.
+func (ax *Authx) GenerateMFAToken(u AuthUser, opts ...ClaimsOption) (string, error) {
+	claims := ax.newClaims(u.GetEmail())
+	claims.UserID = u.GetId()
+	claims.Scope = ScopeMFAPending
+	claims.AuthMethods = []string{AuthMethodPassword}
+	for _, opt := range opts {
+		opt(claims)
+	}
+	claims.ExpiresAt = time.Now().Add(time.Duration(ax.MFATokenExpiresIn()) * time.Second).Unix()
+	key, err := ax.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	return createTypedToken(claims, key, mfaTokenType)
+}
+
+// MFATokenExpiresIn is the lifetime of MFA pending tokens in seconds
+func (ax *Authx) MFATokenExpiresIn() int {
+	if ax.config.MFATokenExpireTime > 0 {
+		return ax.config.MFATokenExpireTime * 60
+	}
+	return defaultMFATokenExpireTime * 60
+}
+
+// VerifyMFAToken returns the user and claims of a valid MFA pending token, it
+// returns errorx.ErrInvalidToken for any other token
+func (ax *Authx) VerifyMFAToken(ctx context.Context, token string) (AuthUser, *Claims, error) {
+	parsedToken, err := parseTypedToken(token, ax.keys, ax.config.Issuer, ax.config.Audience, mfaTokenType)
+	if err != nil {
+		var ae *AuthError
+		if errors.As(err, &ae) {
+			return nil, nil, errorx.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	claims, ok := parsedToken.Claims.(*Claims)
+	if !ok || !parsedToken.Valid || claims.Scope != ScopeMFAPending {
+		return nil, nil, errorx.ErrInvalidToken
+	}
+	u, err := ax.getUser(ctx, claims.Identity)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, nil, errorx.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	revoked, err := ax.isRevoked(ctx, claims, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, errorx.ErrInvalidToken
+	}
+	return u, claims, nil
+}
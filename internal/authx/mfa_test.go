@@ -0,0 +1,120 @@
+package authx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type enrolledUsers struct {
+	method string
+	users  map[int]bool
+}
+
+func (e *enrolledUsers) SecondFactorRequired(ctx context.Context, u AuthUser) (bool, error) {
+	return e.users[u.GetId()], nil
+}
+
+func (e *enrolledUsers) AuthMethod() string {
+	return e.method
+}
+
+func TestAuthx_SecondFactorRequired(t *testing.T) {
+	ctx := context.Background()
+	ax := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"})
+	required, err := ax.SecondFactorRequired(ctx, &testUser{id: 1})
+	require.NoError(t, err)
+	assert.False(t, required, "no factor is required without SecondFactor")
+
+	ax = New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"}, WithSecondFactor(
+		&enrolledUsers{method: AuthMethodOTP, users: map[int]bool{2: true, 3: true}},
+		&enrolledUsers{method: AuthMethodHardwareKey, users: map[int]bool{3: true}},
+	))
+	required, err = ax.SecondFactorRequired(ctx, &testUser{id: 1})
+	require.NoError(t, err)
+	assert.False(t, required)
+	required, err = ax.SecondFactorRequired(ctx, &testUser{id: 2})
+	require.NoError(t, err)
+	assert.True(t, required)
+
+	methods, err := ax.SecondFactorMethods(ctx, &testUser{id: 1})
+	require.NoError(t, err)
+	assert.Empty(t, methods)
+	methods, err = ax.SecondFactorMethods(ctx, &testUser{id: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{AuthMethodOTP, AuthMethodHardwareKey}, methods)
+}
+
+func TestAuthx_MFAToken(t *testing.T) {
+	ctx := context.Background()
+	u := &testUser{id: 1, email: "test@test.com"}
+	ax := New(&memUserRepo{users: []*testUser{u}}, &AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5})
+
+	token, err := ax.GenerateMFAToken(u)
+	require.NoError(t, err)
+
+	got, claims, err := ax.VerifyMFAToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.GetId())
+	assert.Equal(t, ScopeMFAPending, claims.Scope)
+	assert.Equal(t, []string{AuthMethodPassword}, claims.AuthMethods)
+	assert.InDelta(t, claims.IssuedAt+int64(ax.MFATokenExpiresIn()), claims.ExpiresAt, 1)
+
+	t.Run("records the first factor", func(t *testing.T) {
+		emailed, err := ax.GenerateMFAToken(u, WithAuthMethods(AuthMethodEmail))
+		require.NoError(t, err)
+		_, claims, err := ax.VerifyMFAToken(ctx, emailed)
+		require.NoError(t, err)
+		assert.Equal(t, []string{AuthMethodEmail}, claims.AuthMethods)
+	})
+
+	t.Run("rejected by AuthMiddleware", func(t *testing.T) {
+		h := ax.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("typed apart from access tokens", func(t *testing.T) {
+		_, err := parseToken(token, ax.keys, "", "")
+		assert.Error(t, err)
+		parsed, err := parseTypedToken(token, ax.keys, "", "", mfaTokenType)
+		require.NoError(t, err)
+		assert.Equal(t, mfaTokenType, parsed.Header["typ"])
+	})
+
+	t.Run("access tokens are not MFA tokens", func(t *testing.T) {
+		access, err := ax.GenerateUserToken(ctx, u)
+		require.NoError(t, err)
+		_, _, err = ax.VerifyMFAToken(ctx, access)
+		assert.Equal(t, errorx.ErrInvalidToken, err)
+		_, _, err = ax.VerifyMFAToken(ctx, "garbage")
+		assert.Equal(t, errorx.ErrInvalidToken, err)
+	})
+}
+
+func TestAuthx_RefreshKeepsAuthMethods(t *testing.T) {
+	ctx := context.Background()
+	ax, _ := newTestAuthx()
+	u := &testUser{id: 1, email: "test@test.com"}
+
+	pair, err := ax.GenerateTokenPair(ctx, u, WithAuthMethods(AuthMethodPassword, AuthMethodOTP, AuthMethodMFA))
+	require.NoError(t, err)
+	rotated, err := ax.RefreshTokenPair(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+
+	for _, token := range []string{pair.AccessToken, rotated.AccessToken} {
+		info, err := ax.Introspect(ctx, token, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{AuthMethodPassword, AuthMethodOTP, AuthMethodMFA}, info.AuthMethods)
+	}
+}
@@ -1,13 +1,376 @@
 package authx
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
 
-func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// Bounds of the parameters read from encoded hashes, which may have been
+// imported from other systems. Hashes outside of them are rejected as
+// ErrUnknownHash, they would let a single login exhaust memory or CPU, or
+// verify any password against an empty key.
+const (
+	minKeyLength        = 16
+	maxKeyLength        = 1024
+	maxArgon2Memory     = 1 << 20 // KiB
+	maxArgon2Iterations = 64
+	maxScryptMemory     = 1 << 30 // bytes
+	maxScryptP          = 16
+	maxBcryptCost       = 16
+	maxPBKDF2Iterations = 5000000
+)
+
+// ErrUnknownHash is returned for encoded hashes no registered hasher understands
+var ErrUnknownHash = errors.New("authx: unknown password hash format")
+
+// PasswordHasher produces and verifies PHC style encoded password hashes,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type PasswordHasher interface {
+	// ID is the identifier of the algorithm in the encoded hash
+	ID() string
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with other parameters
+	// than the ones the hasher is configured with
+	NeedsRehash(encoded string) bool
+}
+
+// hashChecker is implemented by hashers which can check an encoded hash
+// without verifying a password against it
+type hashChecker interface {
+	check(encoded string) error
+}
+
+// HasherRegistry hashes new passwords with the preferred hasher and verifies
+// passwords against any registered one, so stored hashes can be migrated
+// one login at a time.
+type HasherRegistry struct {
+	preferred PasswordHasher
+	hashers   map[string]PasswordHasher
+}
+
+// NewHasherRegistry registers hashers, preferred names the ID of the one used
+// for new hashes and must not be one of the ImportedHashers
+func NewHasherRegistry(preferred string, hashers ...PasswordHasher) (*HasherRegistry, error) {
+	hr := &HasherRegistry{hashers: make(map[string]PasswordHasher)}
+	for _, h := range hashers {
+		hr.hashers[h.ID()] = h
+	}
+	p, ok := hr.hashers[preferred]
+	if !ok {
+		return nil, fmt.Errorf("authx: unknown password hasher %q", preferred)
+	}
+	for _, imported := range ImportedHashers() {
+		if imported.ID() == preferred {
+			return nil, fmt.Errorf("authx: password hasher %q can not hash new passwords", preferred)
+		}
+	}
+	hr.preferred = p
+	return hr, nil
+}
+
+// DefaultHasherRegistry prefers argon2id with the parameters recommended by
+// RFC 9106 for memory constrained environments
+func DefaultHasherRegistry() *HasherRegistry {
+	hashers := append([]PasswordHasher{
+		NewArgon2idHasher(64*1024, 3, 2),
+		NewBcryptHasher(bcrypt.DefaultCost),
+		NewScryptHasher(32768, 8, 1),
+	}, ImportedHashers()...)
+	hr, _ := NewHasherRegistry("argon2id", hashers...)
+	return hr
+}
+
+// Hash hashes password with the preferred hasher
+func (hr *HasherRegistry) Hash(password string) (string, error) {
+	return hr.preferred.Hash(password)
+}
+
+// Verify checks password against encoded with the hasher which produced it
+func (hr *HasherRegistry) Verify(password, encoded string) (bool, error) {
+	h, ok := hr.hashers[hashID(encoded)]
+	if !ok {
+		return false, ErrUnknownHash
+	}
+	return h.Verify(password, encoded)
+}
+
+// Supports reports whether encoded was produced by a registered hasher
+func (hr *HasherRegistry) Supports(encoded string) bool {
+	_, ok := hr.hashers[hashID(encoded)]
+	return ok
+}
+
+// Check returns ErrUnknownHash unless encoded was produced by a registered
+// hasher and its parameters are within bounds, it is cheap enough to run on
+// every hash of an import
+func (hr *HasherRegistry) Check(encoded string) error {
+	h, ok := hr.hashers[hashID(encoded)]
+	if !ok {
+		return ErrUnknownHash
+	}
+	if c, ok := h.(hashChecker); ok {
+		return c.check(encoded)
+	}
+	return nil
+}
+
+// NeedsRehash reports whether encoded was not produced by the preferred
+// hasher with its current parameters
+func (hr *HasherRegistry) NeedsRehash(encoded string) bool {
+	if hashID(encoded) != hr.preferred.ID() {
+		return true
+	}
+	return hr.preferred.NeedsRehash(encoded)
+}
+
+// hashID extracts the algorithm identifier of an encoded hash. The bcrypt
+// variants 2a, 2b and 2y all map to bcrypt, Django hashes lack the leading $.
+func hashID(encoded string) string {
+	parts := strings.SplitN(encoded, "$", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	if parts[0] != "" {
+		return parts[0]
+	}
+	switch parts[1] {
+	case "2a", "2b", "2y":
+		return "bcrypt"
+	}
+	return parts[1]
+}
+
+type argon2idHasher struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// NewArgon2idHasher creates an argon2id hasher, memory is in KiB
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) PasswordHasher {
+	return &argon2idHasher{memory: memory, iterations: iterations, parallelism: parallelism}
+}
+
+func (h *argon2idHasher) ID() string {
+	return "argon2id"
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.iterations, h.memory, h.parallelism, keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.memory, h.iterations, h.parallelism, encodeB64(salt), encodeB64(key)), nil
+}
+
+func (h *argon2idHasher) Verify(password, encoded string) (bool, error) {
+	p, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	p, _, _, err := h.decode(encoded)
+	return err != nil || *p != *h
+}
+
+func (h *argon2idHasher) check(encoded string) error {
+	_, _, _, err := h.decode(encoded)
+	return err
+}
+
+func (h *argon2idHasher) decode(encoded string) (*argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	var p argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	// argon2 needs at least 8 KiB of memory per lane
+	if p.iterations < 1 || p.iterations > maxArgon2Iterations || p.parallelism < 1 ||
+		p.memory < 8*uint32(p.parallelism) || p.memory > maxArgon2Memory {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	salt, err := decodeB64(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	key, err := decodeKey(parts[5])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	return &p, salt, key, nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a bcrypt hasher, bcrypt hashes keep their own
+// modular crypt format
+func NewBcryptHasher(cost int) PasswordHasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) ID() string {
+	return "bcrypt"
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(b), err
+}
+
+func (h *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	if err := h.check(encoded); err != nil {
+		return false, err
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+func (h *bcryptHasher) check(encoded string) error {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil || cost > maxBcryptCost {
+		return ErrUnknownHash
+	}
+	return nil
+}
+
+type scryptHasher struct {
+	logN uint
+	r    int
+	p    int
+}
+
+// NewScryptHasher creates a scrypt hasher, n must be a power of two
+func NewScryptHasher(n, r, p int) PasswordHasher {
+	return &scryptHasher{logN: uint(bits.Len(uint(n)) - 1), r: r, p: p}
+}
+
+func (h *scryptHasher) ID() string {
+	return "scrypt"
+}
+
+func (h *scryptHasher) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(password), salt, 1<<h.logN, h.r, h.p, keyLength)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", h.logN, h.r, h.p, encodeB64(salt), encodeB64(key)), nil
+}
+
+func (h *scryptHasher) Verify(password, encoded string) (bool, error) {
+	p, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	other, err := scrypt.Key([]byte(password), salt, 1<<p.logN, p.r, p.p, len(key))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *scryptHasher) NeedsRehash(encoded string) bool {
+	p, _, _, err := h.decode(encoded)
+	return err != nil || *p != *h
+}
+
+func (h *scryptHasher) check(encoded string) error {
+	_, _, _, err := h.decode(encoded)
+	return err
+}
+
+func (h *scryptHasher) decode(encoded string) (*scryptHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	var p scryptHasher
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &p.logN, &p.r, &p.p); err != nil ||
+		p.p < 1 || p.p > maxScryptP || !scryptMemoryInBounds(p.logN, p.r) {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	salt, err := decodeB64(parts[3])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	key, err := decodeKey(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownHash
+	}
+	return &p, salt, key, nil
+}
+
+// scryptMemoryInBounds reports whether scrypt with N = 2^logN and block size
+// r needs no more than maxScryptMemory, which is 128*r*N bytes
+func scryptMemoryInBounds(logN uint, r int) bool {
+	return logN >= 1 && logN <= 30 && r >= 1 && r <= maxScryptMemory/128>>logN
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// PHC strings use standard base64 without padding
+func encodeB64(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func decodeB64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(s)
 }
 
-func comparePasswords(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err != nil
+// decodeKey decodes the derived key of an encoded hash, its length must be
+// within bounds
+func decodeKey(s string) ([]byte, error) {
+	key, err := decodeB64(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < minKeyLength || len(key) > maxKeyLength {
+		return nil, ErrUnknownHash
+	}
+	return key, nil
 }
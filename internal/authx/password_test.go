@@ -1,18 +1,114 @@
 package authx
 
 import (
-	"github.com/stretchr/testify/assert"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestAuthx_HashPassword(t *testing.T) {
-	_, err := hashPassword("password")
-	assert.Nil(t, err)
+func testHashers() []PasswordHasher {
+	return []PasswordHasher{
+		NewArgon2idHasher(1024, 1, 1),
+		NewBcryptHasher(4),
+		NewScryptHasher(1024, 8, 1),
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	for _, h := range testHashers() {
+		h := h
+		t.Run(h.ID(), func(t *testing.T) {
+			encoded, err := h.Hash("password")
+			require.NoError(t, err)
+			assert.Equal(t, h.ID(), hashID(encoded))
+
+			ok, err := h.Verify("password", encoded)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = h.Verify("password2", encoded)
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, h.NeedsRehash(encoded))
+		})
+	}
+}
+
+func TestHasherRegistry(t *testing.T) {
+	hashers := testHashers()
+	bcryptHash, err := NewBcryptHasher(4).Hash("password")
+	require.NoError(t, err)
+	weakArgon2, err := NewArgon2idHasher(512, 1, 1).Hash("password")
+	require.NoError(t, err)
+
+	_, err = NewHasherRegistry("md5", hashers...)
+	assert.Error(t, err)
+
+	hr, err := NewHasherRegistry("argon2id", hashers...)
+	require.NoError(t, err)
+
+	encoded, err := hr.Hash("password")
+	require.NoError(t, err)
+	assert.Equal(t, "argon2id", hashID(encoded))
+	assert.False(t, hr.NeedsRehash(encoded))
+
+	for name, encoded := range map[string]string{"other algorithm": bcryptHash, "outdated parameters": weakArgon2} {
+		ok, err := hr.Verify("password", encoded)
+		require.NoError(t, err, name)
+		assert.True(t, ok, name)
+		assert.True(t, hr.NeedsRehash(encoded), name)
+	}
+
+	_, err = hr.Verify("password", "plain")
+	assert.Equal(t, ErrUnknownHash, err)
 }
-func TestAuthx_ComparePassword(t *testing.T) {
-	hash, _ := hashPassword("password")
-	b := comparePasswords("password", hash)
-	assert.Equal(t, b, false)
-	b2 := comparePasswords("password2", hash)
-	assert.NotEqual(t, b2, false)
+
+func TestHasherRegistry_Check(t *testing.T) {
+	hr := DefaultHasherRegistry()
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	valid, err := NewArgon2idHasher(1024, 1, 1).Hash("password")
+	require.NoError(t, err)
+	assert.NoError(t, hr.Check(valid))
+
+	for name, encoded := range map[string]string{
+		"unknown":         "plain",
+		"argon2 t=0":      "$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key,
+		"argon2 p=0":      "$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key,
+		"argon2 4 TiB":    "$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key,
+		"argon2 no key":   "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$",
+		"scrypt 64 GiB":   "$scrypt$ln=26,r=8,p=1$" + salt + "$" + key,
+		"scrypt p=0":      "$scrypt$ln=10,r=8,p=0$" + salt + "$" + key,
+		"bcrypt cost 31":  "$2a$31$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"pbkdf2 2e9":      "pbkdf2_sha256$2000000000$seasalt$ftMWvEdczZQK5azuap2CQYKRjHLa1wOuMrfMiYEswYQ=",
+		"pbkdf2 no key":   "pbkdf2_sha256$1000$seasalt$",
+		"firebase 64 GiB": EncodeFirebaseScrypt(make([]byte, 32), []byte("salt"), nil, make([]byte, 32), 26, 8),
+	} {
+		assert.Equal(t, ErrUnknownHash, hr.Check(encoded), name)
+		ok, err := hr.Verify("password", encoded)
+		assert.False(t, ok, name)
+		assert.Error(t, err, name)
+	}
 }
+
+func TestAuthx_VerifyPassword(t *testing.T) {
+	hr, err := NewHasherRegistry("scrypt", testHashers()...)
+	require.NoError(t, err)
+	ax := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"}, WithPasswordHashers(hr))
+
+	hash, err := ax.HashPassword("password")
+	require.NoError(t, err)
+	u := &passwordUser{password: hash}
+	assert.True(t, ax.VerifyPassword(u, "password"))
+	assert.False(t, ax.VerifyPassword(u, "password2"))
+	assert.False(t, ax.PasswordNeedsRehash(u))
+	assert.False(t, ax.VerifyPassword(&passwordUser{password: "password"}, "password"), "plain text is not a hash")
+}
+
+type passwordUser struct {
+	password string
+}
+
+func (u *passwordUser) GetEmail() string            { return "test@test.com" }
+func (u *passwordUser) GetPassword() string         { return u.password }
+func (u *passwordUser) PutPassword(password string) { u.password = password }
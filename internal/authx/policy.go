@@ -0,0 +1,282 @@
+package authx
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rules, used as keys of PolicyViolation
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUppercase = "uppercase"
+	RuleLowercase = "lowercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleDenylist  = "denylist"
+	RuleUserInfo  = "user_info"
+	RuleStrength  = "strength"
+	RuleBreached  = "breached"
+)
+
+// Denylist is a set of lower cased passwords which are never accepted
+type Denylist map[string]struct{}
+
+// LoadDenylist reads a file with one password per line, blank lines and
+// lines starting with # are skipped
+func LoadDenylist(path string) (Denylist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list := make(Denylist)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+	return list, scanner.Err()
+}
+
+// Contains reports whether password is on the list, ignoring case
+func (d Denylist) Contains(password string) bool {
+	_, ok := d[strings.ToLower(password)]
+	return ok
+}
+
+// PasswordPolicy are the rules a new password must satisfy. The zero value
+// accepts any password.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	MaxLength     int  `json:"max_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	// CheckUserInfo rejects passwords containing the email or name of the user
+	CheckUserInfo bool `json:"check_user_info"`
+	// MinScore is the minimal PasswordStrength, 0 to 4
+	MinScore int `json:"min_score"`
+	// Denylist and Breaches are only configured per deployment
+	Denylist Denylist      `json:"-"`
+	Breaches BreachChecker `json:"-"`
+}
+
+// DefaultPasswordPolicy follows NIST SP 800-63B: length and guessability
+// matter, composition rules do not
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{MinLength: 8, MaxLength: 128, CheckUserInfo: true, MinScore: 2}
+}
+
+// PolicyViolation is one rule a password does not satisfy
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password violates
+type PolicyError struct {
+	Violations []*PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password " + strings.Join(messages, ", ")
+}
+
+// Values renders the violations as validation errors of field, every message
+// is listed under field and under field.rule
+func (e *PolicyError) Values(field string) url.Values {
+	values := make(url.Values)
+	for _, v := range e.Violations {
+		values.Add(field, v.Message)
+		values.Add(field+"."+v.Rule, v.Message)
+	}
+	return values
+}
+
+// Merge returns a policy at least as strict as p and other
+func (p *PasswordPolicy) Merge(other *PasswordPolicy) *PasswordPolicy {
+	if other == nil {
+		return p
+	}
+	merged := *p
+	if other.MinLength > merged.MinLength {
+		merged.MinLength = other.MinLength
+	}
+	if other.MaxLength > 0 && (merged.MaxLength == 0 || other.MaxLength < merged.MaxLength) {
+		merged.MaxLength = other.MaxLength
+	}
+	merged.RequireUpper = merged.RequireUpper || other.RequireUpper
+	merged.RequireLower = merged.RequireLower || other.RequireLower
+	merged.RequireDigit = merged.RequireDigit || other.RequireDigit
+	merged.RequireSymbol = merged.RequireSymbol || other.RequireSymbol
+	merged.CheckUserInfo = merged.CheckUserInfo || other.CheckUserInfo
+	if other.MinScore > merged.MinScore {
+		merged.MinScore = other.MinScore
+	}
+	return &merged
+}
+
+// Validate checks password against every rule. userInputs are the email, name
+// and other words of the user which must not make up the password. The
+// returned error is a *PolicyError, unless the breach corpus could not be read.
+func (p *PasswordPolicy) Validate(password string, userInputs ...string) error {
+	var violations []*PolicyViolation
+	fail := func(rule, format string, args ...interface{}) {
+		violations = append(violations, &PolicyViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		fail(RuleMinLength, "must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		// the password is rejected anyway, checking it further only costs time
+		fail(RuleMaxLength, "must be at most %d characters long", p.MaxLength)
+		return &PolicyError{Violations: violations}
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		fail(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		fail(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		fail(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		fail(RuleSymbol, "must contain a symbol")
+	}
+
+	if p.Denylist.Contains(password) {
+		fail(RuleDenylist, "is too common")
+	}
+	if p.CheckUserInfo && containsUserInfo(password, userInputs) {
+		fail(RuleUserInfo, "must not contain your email or name")
+	}
+	if p.MinScore > 0 {
+		if score := PasswordStrength(password, p.Denylist, userInputs...); score < p.MinScore {
+			fail(RuleStrength, "is too easy to guess (strength %d of 4, at least %d required)", score, p.MinScore)
+		}
+	}
+
+	if p.Breaches != nil {
+		breached, err := p.Breaches.Breached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			fail(RuleBreached, "has appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// userWords splits emails and names into the words worth looking for
+func userWords(userInputs []string) []string {
+	var words []string
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if i := strings.Index(input, "@"); i >= 0 {
+			words = append(words, input, input[:i])
+			input = input[:i]
+		}
+		for _, w := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(w) >= 3 {
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+func containsUserInfo(password string, userInputs []string) bool {
+	password = strings.ToLower(password)
+	for _, w := range userWords(userInputs) {
+		if strings.Contains(password, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordPolicySource provides the password policy of an organization, nil
+// when the organization has none
+type PasswordPolicySource interface {
+	PasswordPolicy(ctx context.Context, organizationID int) (*PasswordPolicy, error)
+}
+
+// WithPasswordPolicy sets the deployment wide password policy and, optionally,
+// the source of per organization policies which can only make it stricter
+func WithPasswordPolicy(policy *PasswordPolicy, organizations PasswordPolicySource) Option {
+	return func(ax *Authx) {
+		ax.policy = policy
+		ax.orgPolicies = organizations
+	}
+}
+
+// PasswordPolicy returns the policy new passwords of a member of every given
+// organization must satisfy. Without organizations, or only 0, it returns the
+// deployment policy.
+func (ax *Authx) PasswordPolicy(ctx context.Context, organizationIDs ...int) (*PasswordPolicy, error) {
+	policy := ax.policy
+	if ax.orgPolicies == nil {
+		return policy, nil
+	}
+	for _, id := range organizationIDs {
+		if id == 0 {
+			continue
+		}
+		orgPolicy, err := ax.orgPolicies.PasswordPolicy(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		policy = policy.Merge(orgPolicy)
+	}
+	return policy, nil
+}
+
+// ValidatePassword checks a new password, e.g. on registration, password change
+// or reset. Policy violations, including breached passwords, are returned as
+// *PolicyError.
+func (ax *Authx) ValidatePassword(ctx context.Context, password string, organizationID int, userInputs ...string) error {
+	policy, err := ax.PasswordPolicy(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	return policy.Validate(password, userInputs...)
+}
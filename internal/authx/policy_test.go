@@ -0,0 +1,124 @@
+package authx
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func violatedRules(err error) []string {
+	pe, ok := err.(*PolicyError)
+	if !ok {
+		return nil
+	}
+	var rules []string
+	for _, v := range pe.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "denylist.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("# common\nLetMeIn2020\n\nhunter2\n"), 0600))
+	denylist, err := LoadDenylist(path)
+	require.NoError(t, err)
+	assert.Len(t, denylist, 2)
+
+	policy := &PasswordPolicy{
+		MinLength:     10,
+		MaxLength:     64,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		CheckUserInfo: true,
+		MinScore:      3,
+		Denylist:      denylist,
+	}
+
+	data := []struct {
+		password string
+		rules    []string
+	}{
+		{"Vq8#mZt!kLw2", nil},
+		{"short", []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol, RuleStrength}},
+		{"letmein2020", []string{RuleUppercase, RuleSymbol, RuleDenylist, RuleStrength}},
+		{"Jane.Doe#2024x", []string{RuleUserInfo}},
+		{"Aaaaaaaaaaaa1!", []string{RuleStrength}},
+	}
+	for _, d := range data {
+		err := policy.Validate(d.password, "jane.doe@test.com", "Jane Doe")
+		assert.Equal(t, d.rules, violatedRules(err), d.password)
+	}
+
+	err = policy.Validate("short")
+	values := err.(*PolicyError).Values("password")
+	assert.Contains(t, values, "password")
+	assert.Contains(t, values, "password.min_length")
+
+	assert.NoError(t, (&PasswordPolicy{}).Validate(""), "the zero policy accepts anything")
+}
+
+func TestPasswordStrength(t *testing.T) {
+	weak := []string{"password", "p@ssw0rd", "qwertyuiop", "abcdefgh", "aaaaaaaaaa", "123456789"}
+	for _, p := range weak {
+		assert.Less(t, PasswordStrength(p, nil), 2, p)
+	}
+	strong := []string{"correct horse battery staple", "Vq8#mZt!kLw2"}
+	for _, p := range strong {
+		assert.Equal(t, 4, PasswordStrength(p, nil), p)
+	}
+	assert.Less(t, PasswordStrength("janedoejanedoe", nil, "Jane Doe"), PasswordStrength("janedoejanedoe", nil))
+	assert.Less(t, PasswordStrength(strings.Repeat("a", 200), nil), 2, "repeats longer than a pattern")
+}
+
+func TestPasswordPolicy_ValidateLongPassword(t *testing.T) {
+	long := strings.Repeat("Vq8#mZt!kLw2", 10000)
+	policy := &PasswordPolicy{MaxLength: 64, MinScore: 3, CheckUserInfo: true}
+
+	start := time.Now()
+	err := policy.Validate(long, "jane.doe@test.com")
+	assert.Equal(t, []string{RuleMaxLength}, violatedRules(err))
+	policy.MaxLength = 0
+	assert.NoError(t, policy.Validate(long, "jane.doe@test.com"), "no maximum length")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "the strength estimator reads a bounded input")
+}
+
+type orgPolicies map[int]*PasswordPolicy
+
+func (o orgPolicies) PasswordPolicy(ctx context.Context, organizationID int) (*PasswordPolicy, error) {
+	return o[organizationID], nil
+}
+
+func TestAuthx_ValidatePassword(t *testing.T) {
+	ctx := context.Background()
+	ax := New(&memUserRepo{}, &AuthxConfig{SecretKey: "test"}, WithPasswordPolicy(
+		&PasswordPolicy{MinLength: 8, MaxLength: 64},
+		orgPolicies{1: {MinLength: 12, MaxLength: 128, RequireDigit: true}, 3: {MinLength: 16, RequireUpper: true}},
+	))
+
+	assert.NoError(t, ax.ValidatePassword(ctx, "abcdefghij", 0))
+	assert.NoError(t, ax.ValidatePassword(ctx, "abcdefghij", 2), "organization without a policy")
+	err := ax.ValidatePassword(ctx, "abcdefghij", 1)
+	assert.Equal(t, []string{RuleMinLength, RuleDigit}, violatedRules(err))
+
+	policy, err := ax.PasswordPolicy(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 64, policy.MaxLength, "organizations can only make the policy stricter")
+
+	policy, err = ax.PasswordPolicy(ctx, 1, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 16, policy.MinLength)
+	assert.True(t, policy.RequireDigit)
+	assert.True(t, policy.RequireUpper, "members of several organizations get the strictest of all")
+}
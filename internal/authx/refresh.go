@@ -0,0 +1,219 @@
+package authx
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imtanmoy/authn/internal/errorx"
+)
+
+// RefreshToken is an opaque, long lived token which can be exchanged for a new
+// access token. Only the SHA-256 hash of the token is persisted. Tokens issued
+// from the same login share a FamilyID so the whole chain can be revoked.
+type RefreshToken struct {
+	ID         int
+	UserID     int
+	FamilyID   string
+	TokenHash  string
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	ReplacedBy int
+	// AuthMethods of the login which started the family, carried over to the
+	// access tokens issued on refresh like Scope and ClientID
+	AuthMethods []string
+	Scope       string
+	// ClientID is the OAuth2 client the family was issued to, empty for
+	// first-party logins
+	ClientID string
+	// OrganizationID the access tokens of the family are scoped to
+	OrganizationID int
+	CreatedAt      time.Time
+}
+
+// IsRevoked reports whether the token was already rotated or revoked
+func (rt *RefreshToken) IsRevoked() bool {
+	return !rt.RevokedAt.IsZero()
+}
+
+// IsExpired reports whether the token is past its expiry
+func (rt *RefreshToken) IsExpired() bool {
+	return time.Now().After(rt.ExpiresAt)
+}
+
+// RefreshTokenRepo persists refresh tokens
+type RefreshTokenRepo interface {
+	SaveRefreshToken(ctx context.Context, rt *RefreshToken) error
+	FindRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	// RotateRefreshToken marks old as revoked and stores next in one step. It
+	// must return errorx.ErrTokenReused when old was already revoked.
+	RotateRefreshToken(ctx context.Context, old, next *RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+}
+
+// TokenPair is the result of a successful authentication
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+// GenerateTokenPair issues an access token and, when a refresh token store is
+// configured, a refresh token starting a new token family. The options apply
+// to the access token as in GenerateUserToken.
+func (ax *Authx) GenerateTokenPair(ctx context.Context, u AuthUser, opts ...ClaimsOption) (*TokenPair, error) {
+	claims, err := ax.userClaims(ctx, u, opts...)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := ax.signClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	pair := &TokenPair{AccessToken: accessToken, ExpiresIn: ax.AccessTokenExpiresIn()}
+	if ax.refreshRepo == nil {
+		return pair, nil
+	}
+	refreshToken, rt, err := ax.newRefreshToken(u.GetId(), uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+	rt.AuthMethods = claims.AuthMethods
+	rt.Scope = claims.Scope
+	rt.ClientID = claims.ClientID
+	rt.OrganizationID = claims.OrganizationID
+	if err := ax.refreshRepo.SaveRefreshToken(ctx, rt); err != nil {
+		return nil, err
+	}
+	pair.RefreshToken = refreshToken
+	return pair, nil
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair. The presented
+// token is rotated; presenting an already rotated token revokes its family.
+// Tokens issued to OAuth2 clients are refreshed by RefreshClientTokenPair.
+func (ax *Authx) RefreshTokenPair(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return ax.RefreshClientTokenPair(ctx, "", refreshToken)
+}
+
+// RefreshClientTokenPair is RefreshTokenPair for the tokens issued to the
+// OAuth2 client with clientID. Tokens of other clients are invalid and left
+// alone, whether or not they were rotated already.
+func (ax *Authx) RefreshClientTokenPair(ctx context.Context, clientID, refreshToken string) (*TokenPair, error) {
+	if ax.refreshRepo == nil || refreshToken == "" {
+		return nil, errorx.ErrInvalidToken
+	}
+	old, err := ax.refreshRepo.FindRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, errorx.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if old.ClientID != clientID {
+		return nil, errorx.ErrInvalidToken
+	}
+	if old.IsRevoked() {
+		if err := ax.refreshRepo.RevokeRefreshTokenFamily(ctx, old.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, errorx.ErrTokenReused
+	}
+	if old.IsExpired() {
+		return nil, errorx.ErrTokenExpired
+	}
+
+	u, err := ax.userRepo.GetByID(ctx, old.UserID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, errorx.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	// sign the access token before rotating, so a failure here leaves the
+	// presented refresh token usable for a retry
+	accessToken, err := ax.GenerateUserToken(ctx, u, WithAuthMethods(old.AuthMethods...),
+		WithScope(old.Scope), WithClientID(old.ClientID), WithOrganization(old.OrganizationID))
+	if err != nil {
+		if errors.Is(err, errorx.ErrUnauthorized) {
+			// the user left the organization the family is scoped to
+			return nil, errorx.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	refresh, next, err := ax.newRefreshToken(old.UserID, old.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	next.AuthMethods = old.AuthMethods
+	next.Scope = old.Scope
+	next.ClientID = old.ClientID
+	next.OrganizationID = old.OrganizationID
+	if err := ax.refreshRepo.RotateRefreshToken(ctx, old, next); err != nil {
+		if errors.Is(err, errorx.ErrTokenReused) {
+			if err := ax.refreshRepo.RevokeRefreshTokenFamily(ctx, old.FamilyID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refresh,
+		ExpiresIn:    ax.AccessTokenExpiresIn(),
+	}, nil
+}
+
+// RevokeRefreshToken revokes the family of the given refresh token, provided
+// it was issued to u. Unknown tokens are ignored.
+func (ax *Authx) RevokeRefreshToken(ctx context.Context, u AuthUser, refreshToken string) error {
+	if ax.refreshRepo == nil || refreshToken == "" {
+		return nil
+	}
+	rt, err := ax.refreshRepo.FindRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil
+		}
+		return err
+	}
+	if rt.UserID != u.GetId() {
+		return nil
+	}
+	return ax.refreshRepo.RevokeRefreshTokenFamily(ctx, rt.FamilyID)
+}
+
+func (ax *Authx) newRefreshToken(userID int, familyID string) (string, *RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	rt := &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(time.Duration(ax.config.RefreshTokenExpireTime) * time.Minute),
+	}
+	return token, rt, nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,207 @@
+package authx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUser struct {
+	id    int
+	email string
+}
+
+func (u *testUser) GetId() int       { return u.id }
+func (u *testUser) GetEmail() string { return u.email }
+
+type memUserRepo struct {
+	users []*testUser
+}
+
+func (m *memUserRepo) ExistsByEmail(ctx context.Context, identity string) bool {
+	_, err := m.GetByEmail(ctx, identity)
+	return err == nil
+}
+
+func (m *memUserRepo) GetByEmail(ctx context.Context, identity string) (AuthUser, error) {
+	for _, u := range m.users {
+		if u.email == identity {
+			return u, nil
+		}
+	}
+	return nil, errorx.ErrorNotFound
+}
+
+func (m *memUserRepo) GetByID(ctx context.Context, id int) (AuthUser, error) {
+	for _, u := range m.users {
+		if u.id == id {
+			return u, nil
+		}
+	}
+	return nil, errorx.ErrorNotFound
+}
+
+type memRefreshRepo struct {
+	tokens []*RefreshToken
+}
+
+func (m *memRefreshRepo) SaveRefreshToken(ctx context.Context, rt *RefreshToken) error {
+	rt.ID = len(m.tokens) + 1
+	rt.CreatedAt = time.Now()
+	m.tokens = append(m.tokens, rt)
+	return nil
+}
+
+func (m *memRefreshRepo) FindRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	for _, rt := range m.tokens {
+		if rt.TokenHash == hash {
+			c := *rt
+			return &c, nil
+		}
+	}
+	return nil, errorx.ErrorNotFound
+}
+
+func (m *memRefreshRepo) RotateRefreshToken(ctx context.Context, old, next *RefreshToken) error {
+	stored := m.tokens[old.ID-1]
+	if stored.IsRevoked() {
+		return errorx.ErrTokenReused
+	}
+	stored.RevokedAt = time.Now()
+	_ = m.SaveRefreshToken(ctx, next)
+	stored.ReplacedBy = next.ID
+	return nil
+}
+
+func (m *memRefreshRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	for _, rt := range m.tokens {
+		if rt.FamilyID == familyID && !rt.IsRevoked() {
+			rt.RevokedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (m *memRefreshRepo) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	for _, rt := range m.tokens {
+		if rt.UserID == userID && !rt.IsRevoked() {
+			rt.RevokedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func newTestAuthx() (*Authx, *memRefreshRepo) {
+	users := &memUserRepo{users: []*testUser{{id: 1, email: "test@test.com"}}}
+	refreshRepo := &memRefreshRepo{}
+	ax := New(users, &AuthxConfig{
+		SecretKey:              "test",
+		AccessTokenExpireTime:  5,
+		RefreshTokenExpireTime: 60,
+	}, WithRefreshTokenRepo(refreshRepo))
+	return ax, refreshRepo
+}
+
+func TestAuthx_RefreshTokenPair(t *testing.T) {
+	ctx := context.Background()
+	ax, repo := newTestAuthx()
+
+	pair, err := ax.GenerateTokenPair(ctx, &testUser{id: 1, email: "test@test.com"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.NotEmpty(t, pair.RefreshToken)
+
+	rotated, err := ax.RefreshTokenPair(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, rotated.RefreshToken)
+	assert.Equal(t, repo.tokens[0].FamilyID, repo.tokens[1].FamilyID)
+	assert.Equal(t, 2, repo.tokens[0].ReplacedBy)
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		_, err := ax.RefreshTokenPair(ctx, pair.RefreshToken)
+		assert.Equal(t, errorx.ErrTokenReused, err)
+		_, err = ax.RefreshTokenPair(ctx, rotated.RefreshToken)
+		assert.Equal(t, errorx.ErrTokenReused, err)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := ax.RefreshTokenPair(ctx, "unknown")
+		assert.Equal(t, errorx.ErrInvalidToken, err)
+	})
+
+	t.Run("signing failures keep the token", func(t *testing.T) {
+		pair, err := ax.GenerateTokenPair(ctx, &testUser{id: 1, email: "test@test.com"})
+		require.NoError(t, err)
+		failing := errors.New("enricher failed")
+		ax.enrichers = []ClaimsEnricher{ClaimsEnricherFunc(func(ctx context.Context, u AuthUser, claims *Claims) error {
+			return failing
+		})}
+		_, err = ax.RefreshTokenPair(ctx, pair.RefreshToken)
+		assert.Equal(t, failing, err)
+		assert.False(t, repo.tokens[len(repo.tokens)-1].IsRevoked())
+
+		ax.enrichers = nil
+		_, err = ax.RefreshTokenPair(ctx, pair.RefreshToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("organization is kept", func(t *testing.T) {
+		pair, err := ax.GenerateTokenPair(ctx, &testUser{id: 1, email: "test@test.com"}, WithOrganization(7))
+		require.NoError(t, err)
+		rotated, err := ax.RefreshTokenPair(ctx, pair.RefreshToken)
+		require.NoError(t, err)
+		info, err := ax.Introspect(ctx, rotated.AccessToken, "")
+		require.NoError(t, err)
+		assert.Equal(t, 7, info.OrganizationID)
+
+		// the user left the organization meanwhile
+		ax.enrichers = []ClaimsEnricher{ClaimsEnricherFunc(func(ctx context.Context, u AuthUser, claims *Claims) error {
+			return errorx.ErrUnauthorized
+		})}
+		defer func() { ax.enrichers = nil }()
+		_, err = ax.RefreshTokenPair(ctx, rotated.RefreshToken)
+		assert.Equal(t, errorx.ErrInvalidToken, err)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		pair, err := ax.GenerateTokenPair(ctx, &testUser{id: 1, email: "test@test.com"})
+		require.NoError(t, err)
+		repo.tokens[len(repo.tokens)-1].ExpiresAt = time.Now().Add(-time.Minute)
+		_, err = ax.RefreshTokenPair(ctx, pair.RefreshToken)
+		assert.Equal(t, errorx.ErrTokenExpired, err)
+	})
+}
+
+func TestAuthx_RefreshClientTokenPair(t *testing.T) {
+	ctx := context.Background()
+	ax, _ := newTestAuthx()
+	u := &testUser{id: 1, email: "test@test.com"}
+
+	pair, err := ax.GenerateTokenPair(ctx, u, WithScope("profile email"), WithClientID("client-1"))
+	require.NoError(t, err)
+
+	_, err = ax.RefreshTokenPair(ctx, pair.RefreshToken)
+	assert.Equal(t, errorx.ErrInvalidToken, err, "client tokens are not first-party tokens")
+	_, err = ax.RefreshClientTokenPair(ctx, "client-2", pair.RefreshToken)
+	assert.Equal(t, errorx.ErrInvalidToken, err, "nor tokens of another client")
+
+	rotated, err := ax.RefreshClientTokenPair(ctx, "client-1", pair.RefreshToken)
+	require.NoError(t, err)
+	for _, token := range []string{pair.AccessToken, rotated.AccessToken, rotated.RefreshToken} {
+		info, err := ax.Introspect(ctx, token, "")
+		require.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, "profile email", info.Scope)
+		assert.Equal(t, "client-1", info.ClientID)
+	}
+
+	first, err := ax.GenerateTokenPair(ctx, u)
+	require.NoError(t, err)
+	_, err = ax.RefreshClientTokenPair(ctx, "client-1", first.RefreshToken)
+	assert.Equal(t, errorx.ErrInvalidToken, err, "first-party tokens are not client tokens")
+}
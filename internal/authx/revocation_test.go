@@ -0,0 +1,127 @@
+package authx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memRevocationRepo struct {
+	tokens map[string]time.Time
+	users  map[int]time.Time
+}
+
+func newMemRevocationRepo() *memRevocationRepo {
+	return &memRevocationRepo{tokens: map[string]time.Time{}, users: map[int]time.Time{}}
+}
+
+func (m *memRevocationRepo) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *memRevocationRepo) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	_, ok := m.tokens[jti]
+	return ok, nil
+}
+
+func (m *memRevocationRepo) RevokeUserTokens(ctx context.Context, userID int, before time.Time) error {
+	m.users[userID] = before
+	return nil
+}
+
+func (m *memRevocationRepo) UserTokensRevokedBefore(ctx context.Context, userID int) (time.Time, error) {
+	return m.users[userID], nil
+}
+
+func (m *memRevocationRepo) PurgeExpiredRevocations(ctx context.Context, now time.Time) error {
+	for jti, expiresAt := range m.tokens {
+		if expiresAt.Before(now) {
+			delete(m.tokens, jti)
+		}
+	}
+	return nil
+}
+
+func authenticate(ax *Authx, token string) (int, *Claims) {
+	var claims *Claims
+	h := ax.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = ax.GetCurrentClaims(r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code, claims
+}
+
+func TestAuthx_RevokeToken(t *testing.T) {
+	ctx := context.Background()
+	revocations := newMemRevocationRepo()
+	ax, _ := newTestAuthx()
+	WithRevocationRepo(revocations)(ax)
+	u := &testUser{id: 1, email: "test@test.com"}
+
+	token, err := ax.GenerateToken(u.email)
+	require.NoError(t, err)
+	other, err := ax.GenerateToken(u.email)
+	require.NoError(t, err)
+
+	code, claims := authenticate(ax, token)
+	require.Equal(t, http.StatusOK, code)
+
+	revocations.tokens["expired"] = time.Now().Add(-time.Minute)
+	require.NoError(t, ax.RevokeToken(ctx, u, claims))
+
+	code, _ = authenticate(ax, token)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = authenticate(ax, other)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, ok := revocations.tokens["expired"]
+	assert.False(t, ok, "expired entries are purged")
+}
+
+func TestAuthx_RevokeAllTokens(t *testing.T) {
+	ctx := context.Background()
+	revocations := newMemRevocationRepo()
+	ax, refreshRepo := newTestAuthx()
+	WithRevocationRepo(revocations)(ax)
+	u := &testUser{id: 1, email: "test@test.com"}
+
+	pair, err := ax.GenerateTokenPair(ctx, u)
+	require.NoError(t, err)
+
+	cutOff := time.Now()
+	require.NoError(t, ax.RevokeAllTokens(ctx, u, cutOff))
+
+	code, _ := authenticate(ax, pair.AccessToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.True(t, refreshRepo.tokens[0].IsRevoked())
+
+	token, err := ax.GenerateToken(u.email)
+	require.NoError(t, err)
+	code, _ = authenticate(ax, token)
+	assert.Equal(t, http.StatusOK, code, "issued right after the cut-off, in the same second")
+
+	claims := newClaims(u.email, 5)
+	claims.IssuedAtMicro = 0
+	claims.IssuedAt = cutOff.Unix()
+	legacy, err := createToken(claims, mustKey(t, ax.keys))
+	require.NoError(t, err)
+	code, _ = authenticate(ax, legacy)
+	assert.Equal(t, http.StatusUnauthorized, code, "tokens without iat_us are cut off by the second")
+
+	// tokens issued after the cut-off are accepted
+	revocations.users[u.id] = time.Now().Add(-time.Hour)
+	token, err = ax.GenerateToken(u.email)
+	require.NoError(t, err)
+	code, _ = authenticate(ax, token)
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,204 @@
+package authx
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// commonWords seeds the dictionary of the strength estimator, the denylist
+// and the user's own words are added to it
+var commonWords = []string{
+	"password", "passw0rd", "qwerty", "letmein", "welcome", "admin", "login",
+	"dragon", "monkey", "iloveyou", "football", "baseball", "master", "sunshine",
+	"shadow", "princess", "trustno1", "secret", "summer", "winter", "spring",
+	"autumn", "hello", "freedom", "whatever", "superman", "batman", "starwars",
+	"computer", "internet", "access", "changeme", "default", "love", "test",
+}
+
+const (
+	// maxStrengthInput is the number of runes of a password the estimator
+	// reads, longer passwords are scored by their beginning
+	maxStrengthInput = 256
+	// maxPatternLength bounds the length of dictionary words, sequences and
+	// keyboard walks the estimator looks for, repeats may be longer
+	maxPatternLength = 32
+)
+
+var keyboardRows = []string{
+	"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm", "qwertzuiop", "azertyuiop",
+}
+
+var leet = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i",
+	"0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+// PasswordStrength estimates how hard password is to guess on the 0 to 4
+// scale of zxcvbn: 0 is too guessable, 4 very unguessable. Like zxcvbn it
+// looks for the cheapest way to build the password from dictionary words,
+// keyboard walks, sequences, repeats and brute forced characters, and maps
+// the number of guesses that takes to a score. Only the first
+// maxStrengthInput runes are read, so the cost stays bounded.
+func PasswordStrength(password string, denylist Denylist, userInputs ...string) int {
+	if utf8.RuneCountInString(password) > maxStrengthInput {
+		password = string([]rune(password)[:maxStrengthInput])
+	}
+	runes := []rune(strings.ToLower(password))
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+	dictionary := make(map[string]struct{}, len(commonWords))
+	for _, w := range commonWords {
+		dictionary[w] = struct{}{}
+	}
+	for _, w := range userWords(userInputs) {
+		dictionary[w] = struct{}{}
+	}
+	dictionaryBits := math.Log2(float64(len(dictionary) + len(denylist)))
+	charBits := math.Log2(float64(charsetSize(password)))
+
+	// bits[i] is the cheapest way, in log2 guesses, to produce runes[:i]
+	bits := make([]float64, n+1)
+	// run is the length of the repeat ending at i
+	run := 0
+	for i := 1; i <= n; i++ {
+		bits[i] = bits[i-1] + charBits
+		if i > 1 && runes[i-1] == runes[i-2] {
+			run++
+		} else {
+			run = 1
+		}
+		if run > maxPatternLength {
+			if c := bits[i-run] + charBits + math.Log2(float64(run)) + 1; c < bits[i] {
+				bits[i] = c
+			}
+		}
+		start := i - maxPatternLength
+		if start < 0 {
+			start = 0
+		}
+		for j := start; j <= i-3; j++ {
+			if cost, ok := patternBits(runes[j:i], dictionary, denylist, dictionaryBits, charBits); ok {
+				// every additional pattern also has to be guessed
+				if c := bits[j] + cost + 1; c < bits[i] {
+					bits[i] = c
+				}
+			}
+		}
+	}
+
+	guesses := bits[n] * math.Log10(2)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// patternBits returns the cost of s when it matches a guessable pattern
+func patternBits(s []rune, dictionary map[string]struct{}, denylist Denylist, dictionaryBits, charBits float64) (float64, bool) {
+	word := string(s)
+	if _, ok := dictionary[word]; ok {
+		return dictionaryBits, true
+	}
+	if _, ok := denylist[word]; ok {
+		return dictionaryBits, true
+	}
+	if unleeted := leet.Replace(word); unleeted != word {
+		if _, ok := dictionary[unleeted]; ok {
+			return dictionaryBits + 1, true
+		}
+		if _, ok := denylist[unleeted]; ok {
+			return dictionaryBits + 1, true
+		}
+	}
+	length := math.Log2(float64(len(s)))
+	if isRepeat(s) {
+		return charBits + length, true
+	}
+	if isSequence(s) {
+		return charBits + length + 1, true
+	}
+	if len(s) >= 4 && isKeyboardWalk(word) {
+		return math.Log2(float64(len(keyboardRows)*10)) + length + 1, true
+	}
+	return 0, false
+}
+
+func isRepeat(s []rune) bool {
+	for _, r := range s[1:] {
+		if r != s[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSequence matches runs like abc, 1234 or zyx
+func isSequence(s []rune) bool {
+	delta := s[1] - s[0]
+	if delta != 1 && delta != -1 {
+		return false
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i]-s[i-1] != delta {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyboardWalk(word string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, word) || strings.Contains(reverse(row), word) {
+			return true
+		}
+	}
+	return false
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// charsetSize is the size of the alphabet a brute force attack on password
+// has to try
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+	return size
+}
@@ -0,0 +1,67 @@
+package authx
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		step := TOTPStep(time.Unix(v.unix, 0))
+		assert.Equal(t, v.code, hotp(key, uint64(step), 8), "time %d", v.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	now := time.Unix(1600000000, 0)
+	code, err := TOTPCode(secret, now)
+	require.NoError(t, err)
+	assert.Len(t, code, TOTPDigits)
+
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	_, ok = ValidateTOTP(secret, code, now.Add(TOTPPeriod), 0)
+	assert.True(t, ok, "the previous step is accepted")
+	_, ok = ValidateTOTP(secret, code, now.Add(3*TOTPPeriod), 0)
+	assert.False(t, ok, "older steps are rejected")
+	_, ok = ValidateTOTP(secret, code, now, step)
+	assert.False(t, ok, "a used step is rejected")
+	_, ok = ValidateTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP("not base32!", code, now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Authn", "test@test.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Authn:test@test.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "Authn", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
@@ -13,4 +13,10 @@ var (
 	ErrInvalidAccount = errors.New("invalid account")
 	// ErrUnauthorized unauthorized error
 	ErrUnauthorized = errors.New("unauthorized")
+	// ErrInvalidToken invalid or unknown token error
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenReused an already used single-use token was presented again
+	ErrTokenReused = errors.New("token reused")
+	// ErrEmailNotVerified the user has not confirmed the email address yet
+	ErrEmailNotVerified = errors.New("email not verified")
 )
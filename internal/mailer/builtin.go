@@ -0,0 +1,111 @@
+package mailer
+
+// builtins are the English templates of the account flows, deployments
+// override them with LoadTemplates
+var builtins = map[string]struct{ text, html string }{
+	"confirmation": {
+		text: `{{define "subject"}}Confirm your email address{{end}}
+Hi {{.Name}},
+
+Please confirm your email address within {{duration .TTL}} at:
+
+{{.Link}}
+
+If you did not create an account, ignore this email.
+`,
+		html: `<!DOCTYPE html>
+<html>
+<body>
+<p>Hi {{.Name}},</p>
+<p>Please confirm your email address within {{duration .TTL}}.</p>
+<p><a href="{{.Link}}">Confirm email address</a></p>
+<p>If you did not create an account, ignore this email.</p>
+</body>
+</html>
+`,
+	},
+	"password_reset": {
+		text: `{{define "subject"}}Reset your password{{end}}
+Hi {{.Name}},
+
+Someone asked to reset the password of your account. Choose a new password within {{duration .TTL}} at:
+
+{{.Link}}
+
+If it wasn't you, ignore this email, your password stays the same.
+`,
+		html: `<!DOCTYPE html>
+<html>
+<body>
+<p>Hi {{.Name}},</p>
+<p>Someone asked to reset the password of your account. Choose a new password within {{duration .TTL}}.</p>
+<p><a href="{{.Link}}">Reset password</a></p>
+<p>If it wasn't you, ignore this email, your password stays the same.</p>
+</body>
+</html>
+`,
+	},
+	"invitation": {
+		text: `{{define "subject"}}{{.InviterName}} invited you to {{.OrganizationName}}{{end}}
+Hi,
+
+{{.InviterName}} invited you to join {{.OrganizationName}}. Accept the invitation within {{duration .TTL}} at:
+
+{{.Link}}
+
+If you don't want to join, ignore this email.
+`,
+		html: `<!DOCTYPE html>
+<html>
+<body>
+<p>Hi,</p>
+<p>{{.InviterName}} invited you to join {{.OrganizationName}}. Accept the invitation within {{duration .TTL}}.</p>
+<p><a href="{{.Link}}">Accept invitation</a></p>
+<p>If you don't want to join, ignore this email.</p>
+</body>
+</html>
+`,
+	},
+	"magic_link": {
+		text: `{{define "subject"}}Your login link{{end}}
+Hi{{if .Name}} {{.Name}}{{end}},
+
+Log in within {{duration .TTL}} at:
+
+{{.Link}}
+
+The link works once. If you did not ask for it, ignore this email.
+`,
+		html: `<!DOCTYPE html>
+<html>
+<body>
+<p>Hi{{if .Name}} {{.Name}}{{end}},</p>
+<p>Log in within {{duration .TTL}}.</p>
+<p><a href="{{.Link}}">Log in</a></p>
+<p>The link works once. If you did not ask for it, ignore this email.</p>
+</body>
+</html>
+`,
+	},
+	"login_code": {
+		text: `{{define "subject"}}Your login code is {{.Code}}{{end}}
+Hi{{if .Name}} {{.Name}}{{end}},
+
+Enter this code within {{duration .TTL}} to log in:
+
+{{.Code}}
+
+The code works once. If you did not ask for it, ignore this email and don't share the code with anyone.
+`,
+		html: `<!DOCTYPE html>
+<html>
+<body>
+<p>Hi{{if .Name}} {{.Name}}{{end}},</p>
+<p>Enter this code within {{duration .TTL}} to log in:</p>
+<p><strong>{{.Code}}</strong></p>
+<p>The code works once. If you did not ask for it, ignore this email and don't share the code with anyone.</p>
+</body>
+</html>
+`,
+	},
+}
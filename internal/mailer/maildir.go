@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+type maildirMailer struct {
+	dir  string
+	from string
+	seq  uint64
+	now  func() time.Time
+}
+
+// NewMaildirMailer writes messages into the maildir at dir, for development.
+// The tmp, new and cur folders are created when missing, every message ends
+// up in new ready for any mail client to open.
+func NewMaildirMailer(dir, from string) (Mailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &maildirMailer{dir: dir, from: from, now: time.Now}, nil
+}
+
+func (m *maildirMailer) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		withFrom := *msg
+		withFrom.From = m.from
+		msg = &withFrom
+	}
+	now := m.now()
+	raw, err := encode(msg, now)
+	if err != nil {
+		return err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	// the maildir convention for unique names: time, process and counter, host
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), atomic.AddUint64(&m.seq, 1), host)
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.dir, "new", name))
+}
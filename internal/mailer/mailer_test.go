@@ -0,0 +1,221 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type linkData struct {
+	Name string
+	Link string
+	TTL  time.Duration
+}
+
+func TestTemplates_Render(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "de"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "de", "confirmation.txt"),
+		[]byte(`{{define "subject"}}E-Mail-Adresse bestätigen{{end}}Hallo {{.Name}}, {{.Link}}`), 0600))
+
+	templates, err := LoadTemplates(dir, "en")
+	require.NoError(t, err)
+	data := &linkData{Name: "Jane", Link: "https://example.com/confirm?token=a&b", TTL: 24 * time.Hour}
+
+	msg, err := templates.Render(context.Background(), "confirmation", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Confirm your email address", msg.Subject)
+	assert.Contains(t, msg.Text, "within 1 day")
+	assert.Contains(t, msg.Text, "https://example.com/confirm?token=a&b")
+	assert.Contains(t, msg.HTML, `href="https://example.com/confirm?token=a&amp;b"`, "the HTML is escaped")
+
+	msg, err = templates.Render(WithLocale(context.Background(), "de-AT"), "confirmation", data)
+	require.NoError(t, err)
+	assert.Equal(t, "E-Mail-Adresse bestätigen", msg.Subject)
+	assert.Equal(t, "Hallo Jane, https://example.com/confirm?token=a&b", msg.Text)
+	assert.Empty(t, msg.HTML, "the locale has no HTML template")
+
+	msg, err = templates.Render(WithLocale(context.Background(), "de"), "password_reset", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Reset your password", msg.Subject, "missing templates fall back to the built-in ones")
+
+	_, err = templates.Render(context.Background(), "unknown", data)
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "de", "broken.txt"), []byte("no subject"), 0600))
+	_, err = LoadTemplates(dir, "en")
+	assert.Error(t, err)
+}
+
+func TestRequestLocale(t *testing.T) {
+	data := map[string]string{
+		"":                            "",
+		"de":                          "de",
+		"fr;q=0.5, pt-BR, en;q=0.8":   "pt-BR",
+		"*, es;q=0.9":                 "es",
+		"en-US;q=0.3,  de-CH ; q=0.7": "de-CH",
+	}
+	for header, locale := range data {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", header)
+		assert.Equal(t, locale, RequestLocale(r), header)
+	}
+}
+
+func testMessage() *Message {
+	return &Message{
+		To:      "Jane Doe <jane@test.com>",
+		Subject: "Grüße",
+		Text:    "Hi Jane,\nplease confirm.\n",
+		HTML:    "<p>Hi Jane,</p>",
+	}
+}
+
+// assertMessage parses raw and checks it carries testMessage
+func assertMessage(t *testing.T, raw []byte) {
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	require.NoError(t, err)
+	assert.Equal(t, `"Jane Doe" <jane@test.com>`, parsed.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Grüße", subject)
+	assert.NotEmpty(t, parsed.Header.Get("Message-ID"))
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		b, err := ioutil.ReadAll(part)
+		require.NoError(t, err)
+		bodies = append(bodies, string(b))
+	}
+	assert.Equal(t, []string{"Hi Jane,\r\nplease confirm.\r\n", "<p>Hi Jane,</p>"}, bodies)
+}
+
+func TestMaildirMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maildir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m, err := NewMaildirMailer(dir, "Authn <no-reply@test.com>")
+	require.NoError(t, err)
+	require.NoError(t, m.Send(context.Background(), testMessage()))
+	require.NoError(t, m.Send(context.Background(), testMessage()))
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "new", files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "From: \"Authn\" <no-reply@test.com>")
+	assertMessage(t, raw)
+
+	tmp, err := ioutil.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmp)
+}
+
+// fakeSMTP accepts a single message and sends its envelope and data on the
+// returned channel
+func fakeSMTP(t *testing.T) (string, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	received := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		var lines []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				lines = append(lines, data.String())
+				reply("250 OK")
+			case "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return l.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	m := NewSMTPMailer(&SMTPConfig{Host: host, Port: portNum, From: "no-reply@test.com"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, m.Send(ctx, testMessage()))
+
+	select {
+	case lines := <-received:
+		require.Len(t, lines, 3)
+		assert.Equal(t, "MAIL FROM:<no-reply@test.com>", strings.SplitN(lines[0], " BODY", 2)[0])
+		assert.Equal(t, "RCPT TO:<jane@test.com>", lines[1])
+		assertMessage(t, []byte(lines[2]))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	assert.Equal(t, "1 day", humanDuration(24*time.Hour))
+	assert.Equal(t, "36 hours", humanDuration(36*time.Hour))
+	assert.Equal(t, "1 hour", humanDuration(time.Hour))
+	assert.Equal(t, "90 minutes", humanDuration(90*time.Minute))
+}
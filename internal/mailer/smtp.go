@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig of an SMTP relay
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN when set, which net/smtp
+	// only allows over TLS or to localhost
+	Username string
+	Password string
+	// TLS connects with implicit TLS, e.g. port 465. Without it the
+	// connection is upgraded with STARTTLS when the server offers it.
+	TLS bool
+	// From is the sender of messages which have none
+	From string
+}
+
+type smtpMailer struct {
+	config *SMTPConfig
+	now    func() time.Time
+}
+
+// NewSMTPMailer sends messages through an SMTP relay, a connection per message
+func NewSMTPMailer(config *SMTPConfig) Mailer {
+	return &smtpMailer{config: config, now: time.Now}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		withFrom := *msg
+		withFrom.From = m.config.From
+		msg = &withFrom
+	}
+	raw, err := encode(msg, m.now())
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(msg.From)
+	to, _ := mail.ParseAddress(msg.To)
+
+	c, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if m.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// dial connects to the relay within the deadline of ctx and says hello
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+	if m.config.TLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !m.config.TLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				_ = c.Close()
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,168 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// builtinLocale is the locale of the built-in templates
+const builtinLocale = "en"
+
+var funcs = map[string]interface{}{
+	"duration": humanDuration,
+}
+
+// Templates renders messages from a text and an optional HTML template per
+// name and locale. The text template has to define the subject as
+//
+//	{{define "subject"}}...{{end}}
+//
+// Lookups fall back from the requested locale, e.g. pt-br, to its language,
+// pt, to the default locale and finally to the built-in English templates.
+type Templates struct {
+	defaultLocale string
+	text          map[string]*texttemplate.Template
+	html          map[string]*htmltemplate.Template
+}
+
+// NewTemplates returns the built-in templates
+func NewTemplates(defaultLocale string) *Templates {
+	t := &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		text:          make(map[string]*texttemplate.Template),
+		html:          make(map[string]*htmltemplate.Template),
+	}
+	for name, b := range builtins {
+		if err := t.add(builtinLocale, name, b.text, b.html); err != nil {
+			panic(err)
+		}
+	}
+	return t
+}
+
+// LoadTemplates returns the built-in templates overridden and extended by
+// the ones in dir, laid out as <locale>/<name>.txt and <locale>/<name>.html
+func LoadTemplates(dir, defaultLocale string) (*Templates, error) {
+	t := NewTemplates(defaultLocale)
+	locales, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, locale := range locales {
+		if !locale.IsDir() {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(dir, locale.Name(), "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			text, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			html, err := ioutil.ReadFile(strings.TrimSuffix(file, ".txt") + ".html")
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			name := strings.TrimSuffix(filepath.Base(file), ".txt")
+			if err := t.add(locale.Name(), name, string(text), string(html)); err != nil {
+				return nil, fmt.Errorf("mailer: template %s: %w", file, err)
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *Templates) add(locale, name, text, html string) error {
+	key := normalizeLocale(locale) + "/" + name
+	tt, err := texttemplate.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	if tt.Lookup("subject") == nil {
+		return fmt.Errorf("mailer: template %s defines no subject", key)
+	}
+	t.text[key] = tt
+	delete(t.html, key)
+	if html != "" {
+		ht, err := htmltemplate.New(name).Funcs(funcs).Parse(html)
+		if err != nil {
+			return err
+		}
+		t.html[key] = ht
+	}
+	return nil
+}
+
+// Render renders the template name in the locale of ctx, the returned
+// message has no recipient yet
+func (t *Templates) Render(ctx context.Context, name string, data interface{}) (*Message, error) {
+	key, ok := t.lookup(Locale(ctx), name)
+	if !ok {
+		return nil, fmt.Errorf("mailer: unknown template %q", name)
+	}
+	var subject, text, html bytes.Buffer
+	if err := t.text[key].ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := t.text[key].Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if ht, ok := t.html[key]; ok {
+		if err := ht.Execute(&html, data); err != nil {
+			return nil, err
+		}
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimLeft(text.String(), "\n"),
+		HTML:    html.String(),
+	}, nil
+}
+
+func (t *Templates) lookup(locale, name string) (string, bool) {
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, t.defaultLocale, builtinLocale)
+	for _, l := range candidates {
+		if _, ok := t.text[l+"/"+name]; ok && l != "" {
+			return l + "/" + name, true
+		}
+	}
+	return "", false
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// humanDuration formats d in whole days, hours or minutes, whichever is the
+// largest unit d is a multiple of
+func humanDuration(d time.Duration) string {
+	unit := func(n int64, name string) string {
+		if n == 1 {
+			return "1 " + name
+		}
+		return fmt.Sprintf("%d %ss", n, name)
+	}
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return unit(int64(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return unit(int64(d/time.Hour), "hour")
+	default:
+		return unit(int64(d/time.Minute), "minute")
+	}
+}
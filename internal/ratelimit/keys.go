@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/imtanmoy/authn/internal/authx"
+)
+
+// ByIP buckets requests by their source IP, see authx.ClientIP
+func ByIP(r *http.Request) string {
+	return "ip:" + authx.ClientIP(r)
+}
+
+// ByUser buckets requests by the user of their bearer token, anonymous
+// requests get no key
+func ByUser(ax *authx.Authx) KeyFunc {
+	return func(r *http.Request) string {
+		claims, ok := ax.PeekClaims(r)
+		if !ok {
+			return ""
+		}
+		if claims.UserID != 0 {
+			return "user:" + strconv.Itoa(claims.UserID)
+		}
+		return "user:" + strings.ToLower(claims.Identity)
+	}
+}
+
+// ByClient buckets requests by the OAuth2 client_id of their basic auth
+// credentials or, for form posts, of the client_id field
+func ByClient(r *http.Request) string {
+	if id, _, ok := r.BasicAuth(); ok && id != "" {
+		return "client:" + id
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if id := r.PostFormValue("client_id"); id != "" {
+			return "client:" + id
+		}
+	}
+	return ""
+}
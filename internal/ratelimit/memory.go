@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full buckets are dropped from memory
+const sweepInterval = time.Minute
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	*Bucket
+	// full is when the bucket is full again and can be forgotten
+	full time.Time
+}
+
+// NewMemoryStore keeps the buckets in memory, limits only hold per replica
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, rate Rate, now time.Time) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, b := range s.buckets {
+			if b.full.Before(now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	var b *Bucket
+	if mb, ok := s.buckets[key]; ok {
+		b = mb.Bucket
+	}
+	b, res := rate.Take(b, now)
+	s.buckets[key] = &memoryBucket{Bucket: b, full: now.Add(res.Reset)}
+	return res, nil
+}
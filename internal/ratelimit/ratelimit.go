@@ -0,0 +1,173 @@
+// Package ratelimit limits request rates with token buckets. Policies match
+// requests by method and path and put them into buckets by IP, user or
+// client; the buckets live in a Store shared by every replica.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/httpx"
+	"github.com/imtanmoy/logx"
+)
+
+// Bucket is the state of a token bucket
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Rate allows Limit requests per Period, in bursts of up to Limit
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token, 0 when allowed
+	RetryAfter time.Duration
+}
+
+// Take refills b for the time passed since its last update and takes a token
+// when there is one. A nil bucket is a full one.
+func (rate Rate) Take(b *Bucket, now time.Time) (*Bucket, *Result) {
+	perSecond := float64(rate.Limit) / rate.Period.Seconds()
+	tokens := float64(rate.Limit)
+	if b != nil {
+		elapsed := now.Sub(b.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(tokens, b.Tokens+elapsed*perSecond)
+	}
+	res := &Result{}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / perSecond)
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((float64(rate.Limit) - tokens) / perSecond)
+	return &Bucket{Tokens: tokens, UpdatedAt: now}, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps the buckets
+type Store interface {
+	// Take takes a token from the bucket of key, atomically with respect to
+	// other callers for the same key
+	Take(ctx context.Context, key string, rate Rate, now time.Time) (*Result, error)
+}
+
+// KeyFunc puts a request into a bucket, an empty key skips the policy
+type KeyFunc func(r *http.Request) string
+
+// FirstKey uses the first of keys which is not empty, e.g. the user and
+// the IP for anonymous requests
+func FirstKey(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, key := range keys {
+			if k := key(r); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+// Policy limits the requests matching Method and Path. Path is either exact
+// or, ending in /*, a prefix like in chi routes. An empty Method matches all.
+type Policy struct {
+	Name   string
+	Method string
+	Path   string
+	Rate
+	Key KeyFunc
+}
+
+func (p *Policy) matches(r *http.Request) bool {
+	if p.Method != "" && !strings.EqualFold(p.Method, r.Method) {
+		return false
+	}
+	if strings.HasSuffix(p.Path, "/*") {
+		return strings.HasPrefix(r.URL.Path, strings.TrimSuffix(p.Path, "*")) ||
+			r.URL.Path == strings.TrimSuffix(p.Path, "/*")
+	}
+	return r.URL.Path == p.Path
+}
+
+// Limiter applies the first matching policy to every request
+type Limiter struct {
+	store    Store
+	policies []*Policy
+}
+
+// New creates a Limiter, policies are tried in order
+func New(store Store, policies ...*Policy) *Limiter {
+	return &Limiter{store: store, policies: policies}
+}
+
+// Handler is a middleware answering 429 Too Many Requests once the bucket of
+// a request is empty. Every limited response carries the RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers of the
+// IETF draft, denied ones Retry-After too. When the store fails the request
+// is let through.
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := l.match(r)
+		if policy == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := policy.Key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res, err := l.store.Take(r.Context(), policy.Name+":"+key, policy.Rate, time.Now().UTC())
+		if err != nil {
+			logx.Errorf("rate limit %s: %s", policy.Name, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Period)))
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			httpx.ResponseJSONError(w, r, http.StatusTooManyRequests, "too many requests, try again later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) match(r *http.Request) *Policy {
+	for _, p := range l.policies {
+		if p.matches(r) {
+			return p
+		}
+	}
+	return nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRate_Take(t *testing.T) {
+	rate := Rate{Limit: 2, Period: 10 * time.Second}
+	now := time.Now()
+
+	b, res := rate.Take(nil, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	assert.Equal(t, 5*time.Second, res.Reset)
+
+	b, res = rate.Take(b, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	b, res = rate.Take(b, now.Add(time.Second))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 4*time.Second, res.RetryAfter)
+
+	// a denied request takes nothing, the token is there 5s after the last one
+	_, res = rate.Take(b, now.Add(5*time.Second))
+	assert.True(t, res.Allowed)
+
+	// the bucket never holds more than Limit
+	_, res = rate.Take(b, now.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+}
+
+func TestPolicy_matches(t *testing.T) {
+	login := &Policy{Method: "POST", Path: "/login"}
+	oauth := &Policy{Path: "/oauth/*"}
+	for _, tc := range []struct {
+		policy *Policy
+		method string
+		path   string
+		want   bool
+	}{
+		{login, "POST", "/login", true},
+		{login, "GET", "/login", false},
+		{login, "POST", "/login/mfa", false},
+		{oauth, "POST", "/oauth/token", true},
+		{oauth, "GET", "/oauth", true},
+		{oauth, "GET", "/oauthx", false},
+	} {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		assert.Equal(t, tc.want, tc.policy.matches(r), "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestLimiter_Handler(t *testing.T) {
+	limiter := New(NewMemoryStore(),
+		&Policy{Name: "login", Method: "POST", Path: "/login", Rate: Rate{Limit: 2, Period: time.Minute}, Key: ByIP},
+		&Policy{Name: "oauth", Path: "/oauth/*", Rate: Rate{Limit: 1, Period: time.Minute}, Key: ByClient},
+	)
+	h := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	login := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/login", nil)
+		r.RemoteAddr = ip + ":1234"
+		return serve(r)
+	}
+
+	w := login("192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusOK, login("192.0.2.1").Code)
+
+	w = login("192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, login("192.0.2.2").Code, "other IPs have their own bucket")
+
+	t.Run("unmatched routes are not limited", func(t *testing.T) {
+		w := serve(httptest.NewRequest("GET", "/me", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("client key", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/oauth/introspect", nil)
+		r.SetBasicAuth("client-a", "secret")
+		assert.Equal(t, http.StatusOK, serve(r).Code)
+		r = httptest.NewRequest("POST", "/oauth/introspect", nil)
+		r.SetBasicAuth("client-a", "secret")
+		assert.Equal(t, http.StatusTooManyRequests, serve(r).Code)
+
+		r = httptest.NewRequest("POST", "/oauth/introspect", nil)
+		assert.Equal(t, http.StatusOK, serve(r).Code, "requests without a client are not limited")
+	})
+}
+
+func TestMemoryStore_sweep(t *testing.T) {
+	store := NewMemoryStore().(*memoryStore)
+	rate := Rate{Limit: 1, Period: time.Second}
+	now := time.Now()
+	_, err := store.Take(context.Background(), "a", rate, now)
+	require.NoError(t, err)
+	_, err = store.Take(context.Background(), "b", rate, now.Add(2*sweepInterval))
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestFirstKey(t *testing.T) {
+	key := FirstKey(ByClient, ByIP)
+	r := httptest.NewRequest("POST", "/oauth/token", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "ip:192.0.2.1", key(r))
+	r.SetBasicAuth("client-a", "secret")
+	assert.Equal(t, "client:client-a", key(r))
+}
+
+func TestByUser(t *testing.T) {
+	ax := authx.New(nil, &authx.AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 5})
+	key := FirstKey(ByUser(ax), ByIP)
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "ip:192.0.2.1", key(r))
+
+	token, err := ax.GenerateToken("Test@test.com")
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, "user:test@test.com", key(r))
+
+	r.Header.Set("Authorization", "Bearer "+token+"x")
+	assert.Equal(t, "ip:192.0.2.1", key(r), "forged tokens fall back to the IP")
+}
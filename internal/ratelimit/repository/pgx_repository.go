@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/ratelimit"
+)
+
+// purgeInterval is how often buckets which are full again are deleted
+const purgeInterval = time.Minute
+
+type pgxRepository struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+var _ ratelimit.Store = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the ratelimit.Store
+// interface, shared by every replica using the database. The limiter runs in
+// front of every request, so it takes connections from the pool of db instead
+// of sharing the single connection of the other repositories.
+func NewPgxRepository(db *sql.DB) ratelimit.Store {
+	return &pgxRepository{db: db}
+}
+
+func (repo *pgxRepository) Take(ctx context.Context, key string, rate ratelimit.Rate, now time.Time) (*ratelimit.Result, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer tx.Rollback()
+
+	var b *ratelimit.Bucket
+	var stored ratelimit.Bucket
+	err = tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE", key).
+		Scan(&stored.Tokens, &stored.UpdatedAt)
+	if err == nil {
+		b = &stored
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	b, res := rate.Take(b, now)
+	_, err = tx.ExecContext(ctx, "INSERT INTO rate_limit_buckets(key, tokens, updated_at, full_at) "+
+		"VALUES ($1,$2,$3,$4) "+
+		"ON CONFLICT (key) DO UPDATE SET tokens = EXCLUDED.tokens, updated_at = EXCLUDED.updated_at, full_at = EXCLUDED.full_at",
+		key, b.Tokens, b.UpdatedAt, now.Add(res.Reset))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	if err := repo.purge(ctx, now); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// purge deletes the buckets which are full again, at most once per
+// purgeInterval across all callers
+func (repo *pgxRepository) purge(ctx context.Context, now time.Time) error {
+	repo.mu.Lock()
+	due := now.Sub(repo.lastPurge) > purgeInterval
+	if due {
+		repo.lastPurge = now
+	}
+	repo.mu.Unlock()
+	if !due {
+		return nil
+	}
+	if _, err := repo.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at < $1", now); err != nil {
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/ratelimit"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo ratelimit.Store
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(db)
+}
+
+func TestPgxRepository_Take(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+	rate := ratelimit.Rate{Limit: 2, Period: time.Minute}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	res, err := repo.Take(ctx, "login:ip:192.0.2.1", rate, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, err = repo.Take(ctx, "login:ip:192.0.2.1", rate, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	res, err = repo.Take(ctx, "login:ip:192.0.2.1", rate, now)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 30*time.Second, res.RetryAfter)
+
+	res, err = repo.Take(ctx, "login:ip:192.0.2.1", rate, now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "a token is refilled every 30 seconds")
+
+	res, err = repo.Take(ctx, "login:ip:192.0.2.2", rate, now)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	t.Run("full buckets are purged", func(t *testing.T) {
+		_, err := repo.Take(ctx, "login:ip:192.0.2.3", rate, now.Add(time.Hour))
+		require.NoError(t, err)
+		found := 0
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM rate_limit_buckets").Scan(&found))
+		assert.Equal(t, 1, found)
+	})
+
+	t.Run("concurrent takes", func(t *testing.T) {
+		rate := ratelimit.Rate{Limit: 10, Period: time.Hour}
+		_, err := repo.Take(ctx, "login:ip:192.0.2.4", rate, now)
+		require.NoError(t, err)
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		allowed := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := repo.Take(ctx, "login:ip:192.0.2.4", rate, now)
+				assert.NoError(t, err)
+				if err == nil && res.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 9, allowed)
+	})
+}
@@ -0,0 +1,182 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// ErrNoCredential the authenticator holds no credential matching the options
+var ErrNoCredential = errors.New("no matching credential on the authenticator")
+
+// Authenticator is a software authenticator keeping ES256 credentials in
+// memory, it lets tests run the ceremonies without a browser. It answers as
+// a browser on Origin would.
+type Authenticator struct {
+	Origin string
+	// UserVerification makes the authenticator verify the user, e.g. ask for
+	// a PIN, in every ceremony
+	UserVerification bool
+	credentials      []*softCredential
+}
+
+type softCredential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// NewAuthenticator creates an Authenticator verifying its user
+func NewAuthenticator(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, UserVerification: true}
+}
+
+// Create makes a new credential as navigator.credentials.create() would
+func (a *Authenticator) Create(options *CreationOptions) (*CredentialCreation, error) {
+	supported := false
+	for _, p := range options.PubKeyCredParams {
+		supported = supported || p.Alg == AlgES256
+	}
+	if !supported {
+		return nil, ErrUnsupported
+	}
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(options.RP.ID, excluded.ID) != nil {
+			return nil, errors.New("the authenticator holds an excluded credential")
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &softCredential{id: id, rpID: options.RP.ID, userHandle: options.User.ID, key: key}
+
+	cose, err := encodePublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	attested := make([]byte, 18, 18+len(id)+len(cose))
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(id)))
+	attested = append(append(attested, id...), cose...)
+	authData := a.authenticatorData(cred, FlagAttestedCredentialData, attested)
+
+	clientDataJSON, err := a.clientData(typeCreate, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := encodeCBOR(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.credentials = append(a.credentials, cred)
+	return &CredentialCreation{
+		ID:    URLEncodedBytes(id).String(),
+		RawID: id,
+		Type:  "public-key",
+		Response: AttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestation,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Get signs an assertion as navigator.credentials.get() would, with the
+// first allowed credential or the first discoverable one
+func (a *Authenticator) Get(options *RequestOptions) (*CredentialAssertion, error) {
+	var cred *softCredential
+	if len(options.AllowCredentials) == 0 {
+		cred = a.find(options.RPID, nil)
+	}
+	for _, allowed := range options.AllowCredentials {
+		if cred = a.find(options.RPID, allowed.ID); cred != nil {
+			break
+		}
+	}
+	if cred == nil {
+		return nil, ErrNoCredential
+	}
+
+	cred.signCount++
+	authData := a.authenticatorData(cred, 0, nil)
+	clientDataJSON, err := a.clientData(typeGet, options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(signedData(authData, clientDataJSON))
+	r, s, err := ecdsa.Sign(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := asn1Signature(r, s)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialAssertion{
+		ID:    URLEncodedBytes(cred.id).String(),
+		RawID: cred.id,
+		Type:  "public-key",
+		Response: AssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         sig,
+			UserHandle:        cred.userHandle,
+		},
+	}, nil
+}
+
+// find returns the credential with id for rpID, or the first one for rpID
+// when id is nil
+func (a *Authenticator) find(rpID string, id []byte) *softCredential {
+	for _, cred := range a.credentials {
+		if cred.rpID == rpID && (id == nil || bytes.Equal(cred.id, id)) {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) authenticatorData(cred *softCredential, flags byte, attested []byte) []byte {
+	flags |= FlagUserPresent
+	if a.UserVerification {
+		flags |= FlagUserVerified
+	}
+	rpHash := sha256.Sum256([]byte(cred.rpID))
+	data := make([]byte, 37, 37+len(attested))
+	copy(data, rpHash[:])
+	data[32] = flags
+	binary.BigEndian.PutUint32(data[33:], cred.signCount)
+	return append(data, attested...)
+}
+
+func (a *Authenticator) clientData(typ string, challenge []byte) ([]byte, error) {
+	return json.Marshal(&clientData{
+		Type:      typ,
+		Challenge: challenge,
+		Origin:    a.Origin,
+	})
+}
+
+func asn1Signature(r, s *big.Int) ([]byte, error) {
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{r, s})
+}
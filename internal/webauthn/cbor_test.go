@@ -0,0 +1,72 @@
+package webauthn
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCBOR_RFC8949Vectors(t *testing.T) {
+	vectors := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"c11a514b67b0", int64(1363896240)},
+	}
+	for _, v := range vectors {
+		b, err := hex.DecodeString(v.hex)
+		require.NoError(t, err)
+		got, rest, err := decodeCBOR(b)
+		require.NoError(t, err, v.hex)
+		assert.Empty(t, rest, v.hex)
+		assert.Equal(t, v.want, got, v.hex)
+	}
+}
+
+func TestDecodeCBOR_Malformed(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"18",                 // missing argument
+		"45010203",           // short byte string
+		"9f01ff",             // indefinite array
+		"a20102",             // missing map entry
+		"a201020103",         // duplicate key
+		"a1f401",             // bool key
+		"9b00000000ffffffff", // huge array
+	} {
+		b, err := hex.DecodeString(h)
+		require.NoError(t, err)
+		_, _, err = decodeCBOR(b)
+		assert.Error(t, err, h)
+	}
+}
+
+func TestEncodeCBOR_Canonical(t *testing.T) {
+	b, err := encodeCBOR(map[int]interface{}{-1: 1, 3: -7, 1: 2, -300: []byte{1}})
+	require.NoError(t, err)
+	assert.Equal(t, "a401020326200139012b4101", hex.EncodeToString(b))
+
+	v, rest, err := decodeCBOR(b)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, map[interface{}]interface{}{
+		int64(-1): int64(1), int64(3): int64(-7), int64(1): int64(2), int64(-300): []byte{1},
+	}, v)
+}
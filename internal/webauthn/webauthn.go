@@ -0,0 +1,511 @@
+// Package webauthn implements the relying party side of the WebAuthn Level 2
+// registration and authentication ceremonies.
+//
+// Only the "none" attestation and "packed" self attestation are verified,
+// the relying party asks for no attestation and trusts no authenticator
+// vendor. Credentials sign with ES256, EdDSA or RS256.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidResponse the authenticator response does not verify
+	ErrInvalidResponse = errors.New("invalid webauthn response")
+	// ErrUnsupported the response uses an algorithm or attestation format
+	// which is not supported
+	ErrUnsupported = errors.New("unsupported webauthn credential")
+	// ErrClonedAuthenticator the signature counter went backwards, the
+	// credential's private key was likely copied
+	ErrClonedAuthenticator = errors.New("webauthn signature counter did not increase")
+)
+
+// User verification requirements
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// Client data types of the ceremonies
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// Flags of the authenticator data
+const (
+	FlagUserPresent            = 0x01
+	FlagUserVerified           = 0x04
+	FlagBackupEligible         = 0x08
+	FlagBackupState            = 0x10
+	FlagAttestedCredentialData = 0x40
+	FlagExtensionData          = 0x80
+)
+
+// challengeSize is the number of random bytes in a challenge
+const challengeSize = 32
+
+// maxCredentialIDLength is the longest credential ID allowed by the spec
+const maxCredentialIDLength = 1023
+
+// URLEncodedBytes are bytes in JSON as unpadded base64url, the encoding the
+// WebAuthn JSON serialization uses
+type URLEncodedBytes []byte
+
+// MarshalJSON encodes b as unpadded base64url
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON accepts base64url with or without padding
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// String returns b as unpadded base64url
+func (b URLEncodedBytes) String() string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Config of the relying party
+type Config struct {
+	// RPID is the domain credentials are scoped to, e.g. example.com
+	RPID   string
+	RPName string
+	// Origins are the web origins allowed to run the ceremonies, e.g.
+	// https://login.example.com
+	Origins []string
+	// Timeout is the time the user has to complete a ceremony
+	Timeout time.Duration
+	// UserVerification is asked of authenticators when they are a second
+	// factor, passwordless logins always require it
+	UserVerification string
+}
+
+// RelyingParty creates ceremony options and verifies authenticator responses
+type RelyingParty struct {
+	config *Config
+	rpHash [32]byte
+}
+
+// New creates a RelyingParty
+func New(config *Config) *RelyingParty {
+	return &RelyingParty{
+		config: config,
+		rpHash: sha256.Sum256([]byte(config.RPID)),
+	}
+}
+
+// NewChallenge returns a random ceremony challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// RelyingPartyEntity names the relying party to the user
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity is the account a credential is created for, ID is the user
+// handle returned by authenticators
+type UserEntity struct {
+	ID          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+// CredentialParameters is a credential type and algorithm the relying party
+// accepts
+type CredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CredentialDescriptor identifies a credential
+type CredentialDescriptor struct {
+	Type       string          `json:"type"`
+	ID         URLEncodedBytes `json:"id"`
+	Transports []string        `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states the requirements on the authenticator
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions are the PublicKeyCredentialCreationOptions passed to
+// navigator.credentials.create()
+type CreationOptions struct {
+	Challenge              URLEncodedBytes         `json:"challenge"`
+	RP                     RelyingPartyEntity      `json:"rp"`
+	User                   UserEntity              `json:"user"`
+	PubKeyCredParams       []CredentialParameters  `json:"pubKeyCredParams"`
+	Timeout                int                     `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor  `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection *AuthenticatorSelection `json:"authenticatorSelection,omitempty"`
+	Attestation            string                  `json:"attestation"`
+}
+
+// RequestOptions are the PublicKeyCredentialRequestOptions passed to
+// navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	Timeout          int                    `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CredentialCreation is the JSON serialization of the PublicKeyCredential
+// returned by navigator.credentials.create()
+type CredentialCreation struct {
+	ID       string              `json:"id"`
+	RawID    URLEncodedBytes     `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AttestationResponse is the AuthenticatorAttestationResponse
+type AttestationResponse struct {
+	ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+	AttestationObject URLEncodedBytes `json:"attestationObject"`
+	Transports        []string        `json:"transports,omitempty"`
+}
+
+// CredentialAssertion is the JSON serialization of the PublicKeyCredential
+// returned by navigator.credentials.get()
+type CredentialAssertion struct {
+	ID       string            `json:"id"`
+	RawID    URLEncodedBytes   `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+// AssertionResponse is the AuthenticatorAssertionResponse
+type AssertionResponse struct {
+	ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+	AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+	Signature         URLEncodedBytes `json:"signature"`
+	UserHandle        URLEncodedBytes `json:"userHandle,omitempty"`
+}
+
+// Challenge returns the challenge the client signed, to find the ceremony
+// the response belongs to
+func (c *CredentialCreation) Challenge() ([]byte, error) {
+	cd, err := parseClientData(c.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	return cd.Challenge, nil
+}
+
+// Challenge returns the challenge the client signed, to find the ceremony
+// the response belongs to
+func (c *CredentialAssertion) Challenge() ([]byte, error) {
+	cd, err := parseClientData(c.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	return cd.Challenge, nil
+}
+
+// clientData is the CollectedClientData
+type clientData struct {
+	Type        string          `json:"type"`
+	Challenge   URLEncodedBytes `json:"challenge"`
+	Origin      string          `json:"origin"`
+	CrossOrigin bool            `json:"crossOrigin"`
+}
+
+func parseClientData(raw []byte) (*clientData, error) {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("%w: malformed client data", ErrInvalidResponse)
+	}
+	return &cd, nil
+}
+
+// AuthenticatorData is the parsed authenticator data of a response
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// AAGUID, CredentialID and PublicKey are only set at registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// UserVerified reports whether the authenticator verified the user, e.g.
+// with a PIN or biometrics
+func (ad *AuthenticatorData) UserVerified() bool {
+	return ad.Flags&FlagUserVerified != 0
+}
+
+// BackupEligible reports whether the credential may be synced, i.e. it is a
+// multi-device passkey
+func (ad *AuthenticatorData) BackupEligible() bool {
+	return ad.Flags&FlagBackupEligible != 0
+}
+
+// BackupState reports whether the credential is currently backed up
+func (ad *AuthenticatorData) BackupState() bool {
+	return ad.Flags&FlagBackupState != 0
+}
+
+func parseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+	ad := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+	if ad.Flags&FlagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		ad.AAGUID = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n > maxCredentialIDLength || len(rest) < n {
+			return nil, fmt.Errorf("%w: invalid credential id length", ErrInvalidResponse)
+		}
+		ad.CredentialID = rest[:n]
+		rest = rest[n:]
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed public key", ErrInvalidResponse)
+		}
+		ad.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if ad.Flags&FlagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed extensions", ErrInvalidResponse)
+		}
+		rest = after
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing bytes in authenticator data", ErrInvalidResponse)
+	}
+	return ad, nil
+}
+
+// CreationOptions returns the options to create a credential for user,
+// excluding the credentials the user registered already. Discoverable
+// credentials are preferred so they also serve passwordless logins.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) *CreationOptions {
+	params := make([]CredentialParameters, len(SupportedAlgorithms))
+	for i, alg := range SupportedAlgorithms {
+		params[i] = CredentialParameters{Type: "public-key", Alg: alg}
+	}
+	return &CreationOptions{
+		Challenge:          challenge,
+		RP:                 RelyingPartyEntity{ID: rp.config.RPID, Name: rp.config.RPName},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            rp.timeout(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: &AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: rp.userVerification(),
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options to get an assertion from one of allow,
+// or from any discoverable credential when allow is empty. Passwordless
+// logins ask for user verification.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor) *RequestOptions {
+	uv := rp.userVerification()
+	if len(allow) == 0 {
+		uv = UserVerificationRequired
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.timeout(),
+		RPID:             rp.config.RPID,
+		AllowCredentials: allow,
+		UserVerification: uv,
+	}
+}
+
+// VerifyRegistration verifies the response to CreationOptions with challenge
+// and returns the authenticator data holding the new credential
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, c *CredentialCreation) (*AuthenticatorData, error) {
+	if c.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrInvalidResponse, c.Type)
+	}
+	if err := rp.verifyClientData(c.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	v, rest, err := decodeCBOR(c.Response.AttestationObject)
+	if err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	obj, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	format, _ := obj["fmt"].(string)
+	stmt, _ := obj["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := obj["authData"].([]byte)
+	if stmt == nil {
+		return nil, fmt.Errorf("%w: missing attestation statement", ErrInvalidResponse)
+	}
+
+	ad, err := rp.verifyAuthenticatorData(rawAuthData, rp.userVerification() == UserVerificationRequired)
+	if err != nil {
+		return nil, err
+	}
+	if ad.CredentialID == nil {
+		return nil, fmt.Errorf("%w: missing attested credential data", ErrInvalidResponse)
+	}
+	if len(c.RawID) > 0 && !bytes.Equal(c.RawID, ad.CredentialID) {
+		return nil, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+	key, err := parsePublicKey(ad.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(stmt) > 0 {
+			return nil, fmt.Errorf("%w: none attestation with a statement", ErrInvalidResponse)
+		}
+	case "packed":
+		// self attestation, signed by the credential itself
+		if _, ok := stmt["x5c"]; ok {
+			return nil, fmt.Errorf("%w: packed attestation with a certificate", ErrUnsupported)
+		}
+		alg, _ := stmt["alg"].(int64)
+		sig, _ := stmt["sig"].([]byte)
+		if int(alg) != key.alg || !key.verify(signedData(rawAuthData, c.Response.ClientDataJSON), sig) {
+			return nil, fmt.Errorf("%w: invalid self attestation", ErrInvalidResponse)
+		}
+	default:
+		return nil, fmt.Errorf("%w: attestation format %q", ErrUnsupported, format)
+	}
+	return ad, nil
+}
+
+// VerifyAssertion verifies the response to RequestOptions with challenge,
+// signed by the credential with the COSE publicKey whose signature counter
+// was signCount. requireUV is set for passwordless logins.
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, c *CredentialAssertion, publicKey []byte, signCount uint32, requireUV bool) (*AuthenticatorData, error) {
+	if c.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrInvalidResponse, c.Type)
+	}
+	if err := rp.verifyClientData(c.Response.ClientDataJSON, typeGet, challenge); err != nil {
+		return nil, err
+	}
+	ad, err := rp.verifyAuthenticatorData(c.Response.AuthenticatorData, requireUV || rp.userVerification() == UserVerificationRequired)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !key.verify(signedData(c.Response.AuthenticatorData, c.Response.ClientDataJSON), c.Response.Signature) {
+		return nil, fmt.Errorf("%w: invalid signature", ErrInvalidResponse)
+	}
+	// authenticators without a counter always report zero
+	if (ad.SignCount != 0 || signCount != 0) && ad.SignCount <= signCount {
+		return nil, ErrClonedAuthenticator
+	}
+	return ad, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, typ string, challenge []byte) error {
+	cd, err := parseClientData(raw)
+	if err != nil {
+		return err
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("%w: unexpected client data type %q", ErrInvalidResponse, cd.Type)
+	}
+	if subtle.ConstantTimeCompare(cd.Challenge, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidResponse)
+	}
+	if cd.CrossOrigin {
+		return fmt.Errorf("%w: cross origin request", ErrInvalidResponse)
+	}
+	for _, origin := range rp.config.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: origin %q is not allowed", ErrInvalidResponse, cd.Origin)
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte, requireUV bool) (*AuthenticatorData, error) {
+	ad, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(ad.RPIDHash, rp.rpHash[:]) != 1 {
+		return nil, fmt.Errorf("%w: relying party id mismatch", ErrInvalidResponse)
+	}
+	if ad.Flags&FlagUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user not present", ErrInvalidResponse)
+	}
+	if requireUV && !ad.UserVerified() {
+		return nil, fmt.Errorf("%w: user not verified", ErrInvalidResponse)
+	}
+	return ad, nil
+}
+
+// timeout is Config.Timeout in milliseconds
+func (rp *RelyingParty) timeout() int {
+	return int(rp.config.Timeout / time.Millisecond)
+}
+
+func (rp *RelyingParty) userVerification() string {
+	if rp.config.UserVerification == "" {
+		return UserVerificationPreferred
+	}
+	return rp.config.UserVerification
+}
+
+// signedData is what authenticators sign, the authenticator data followed by
+// the hash of the client data
+func signedData(authData, clientDataJSON []byte) []byte {
+	hash := sha256.Sum256(clientDataJSON)
+	return append(append([]byte(nil), authData...), hash[:]...)
+}
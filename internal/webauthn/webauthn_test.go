@@ -0,0 +1,159 @@
+package webauthn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOrigin = "https://login.example.com"
+
+func newTestRelyingParty() *RelyingParty {
+	return New(&Config{
+		RPID:    "example.com",
+		RPName:  "Example",
+		Origins: []string{testOrigin},
+	})
+}
+
+func register(t *testing.T, rp *RelyingParty, a *Authenticator) *AuthenticatorData {
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	c, err := a.Create(rp.CreationOptions(challenge, UserEntity{ID: []byte{1}, Name: "test@test.com"}, nil))
+	require.NoError(t, err)
+	ad, err := rp.VerifyRegistration(challenge, c)
+	require.NoError(t, err)
+	return ad
+}
+
+func TestRelyingParty_Registration(t *testing.T) {
+	rp := newTestRelyingParty()
+	a := NewAuthenticator(testOrigin)
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options := rp.CreationOptions(challenge, UserEntity{ID: []byte{1}, Name: "test@test.com"}, nil)
+
+	b, err := json.Marshal(options)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"rp":{"id":"example.com","name":"Example"}`)
+	assert.Contains(t, string(b), `"user":{"id":"AQ","name":"test@test.com","displayName":""}`)
+	assert.Contains(t, string(b), `"attestation":"none"`)
+
+	c, err := a.Create(options)
+	require.NoError(t, err)
+	// the response survives the JSON round trip through the client
+	b, err = json.Marshal(c)
+	require.NoError(t, err)
+	var decoded CredentialCreation
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	got, err := decoded.Challenge()
+	require.NoError(t, err)
+	assert.Equal(t, challenge, got)
+
+	ad, err := rp.VerifyRegistration(challenge, &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(c.RawID), ad.CredentialID)
+	assert.True(t, ad.UserVerified())
+	assert.Zero(t, ad.SignCount)
+	_, err = parsePublicKey(ad.PublicKey)
+	assert.NoError(t, err)
+
+	other, err := NewChallenge()
+	require.NoError(t, err)
+	_, err = rp.VerifyRegistration(other, &decoded)
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "the challenge must match")
+
+	elsewhere := New(&Config{RPID: "example.org", Origins: []string{testOrigin}})
+	_, err = elsewhere.VerifyRegistration(challenge, &decoded)
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "the relying party id must match")
+
+	phishing := NewAuthenticator("https://login.example.com.evil.test")
+	c, err = phishing.Create(options)
+	require.NoError(t, err)
+	_, err = rp.VerifyRegistration(challenge, c)
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "the origin must be allowed")
+}
+
+func TestRelyingParty_Assertion(t *testing.T) {
+	rp := newTestRelyingParty()
+	a := NewAuthenticator(testOrigin)
+	cred := register(t, rp, a)
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options := rp.RequestOptions(challenge, []CredentialDescriptor{{Type: "public-key", ID: cred.CredentialID}})
+	assert.Equal(t, UserVerificationPreferred, options.UserVerification)
+	c, err := a.Get(options)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, []byte(c.Response.UserHandle))
+
+	ad, err := rp.VerifyAssertion(challenge, c, cred.PublicKey, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), ad.SignCount)
+
+	_, err = rp.VerifyAssertion(challenge, c, cred.PublicKey, ad.SignCount, false)
+	assert.Equal(t, ErrClonedAuthenticator, err, "a replayed assertion has an old counter")
+
+	c, err = a.Get(options)
+	require.NoError(t, err)
+	c.Response.Signature[len(c.Response.Signature)-1] ^= 0xff
+	_, err = rp.VerifyAssertion(challenge, c, cred.PublicKey, ad.SignCount, false)
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "the signature must verify")
+
+	c, err = a.Get(options)
+	require.NoError(t, err)
+	creation, err := a.Create(rp.CreationOptions(challenge, UserEntity{ID: []byte{1}}, nil))
+	require.NoError(t, err)
+	c.Response.ClientDataJSON = creation.Response.ClientDataJSON
+	_, err = rp.VerifyAssertion(challenge, c, cred.PublicKey, ad.SignCount, false)
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "registration client data is no assertion")
+}
+
+func TestRelyingParty_PasswordlessRequiresUserVerification(t *testing.T) {
+	rp := newTestRelyingParty()
+	a := NewAuthenticator(testOrigin)
+	a.UserVerification = false
+	cred := register(t, rp, a)
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	options := rp.RequestOptions(challenge, nil)
+	assert.Equal(t, UserVerificationRequired, options.UserVerification)
+	c, err := a.Get(options)
+	require.NoError(t, err, "discoverable credentials need no allow list")
+
+	_, err = rp.VerifyAssertion(challenge, c, cred.PublicKey, 0, true)
+	assert.True(t, errors.Is(err, ErrInvalidResponse))
+	_, err = rp.VerifyAssertion(challenge, c, cred.PublicKey, 0, false)
+	assert.NoError(t, err)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cose, err := encodeCBOR(map[int]interface{}{
+		coseKeyType: coseKeyTypeOKP, coseKeyAlg: AlgEdDSA, coseKeyCurve: coseCurveEd25519, coseKeyX: []byte(pub),
+	})
+	require.NoError(t, err)
+	key, err := parsePublicKey(cose)
+	require.NoError(t, err)
+	assert.Equal(t, AlgEdDSA, key.alg)
+
+	cose, err = encodeCBOR(map[int]interface{}{coseKeyType: coseKeyTypeEC2, coseKeyAlg: -36})
+	require.NoError(t, err)
+	_, err = parsePublicKey(cose)
+	assert.True(t, errors.Is(err, ErrUnsupported))
+
+	cose, err = encodeCBOR(map[int]interface{}{
+		coseKeyType: coseKeyTypeEC2, coseKeyAlg: AlgES256, coseKeyCurve: coseCurveP256,
+		coseKeyX: make([]byte, 32), coseKeyY: make([]byte, 32),
+	})
+	require.NoError(t, err)
+	_, err = parsePublicKey(cose)
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "the point must be on the curve")
+}
@@ -0,0 +1,449 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/events"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/invitation"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/organization"
+	_orgDeliveryHttp "github.com/imtanmoy/authn/organization/delivery/http"
+	"github.com/imtanmoy/authn/user"
+	"github.com/imtanmoy/httpx"
+	param "github.com/oceanicdev/chi-param"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+type contextKey string
+
+const (
+	orgKey        contextKey = "organization"
+	invitationKey contextKey = "invitation"
+)
+
+type invitePayload struct {
+	Email string `json:"email"`
+}
+
+func (ip *invitePayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"email": []string{"required", "min:4", "max:100", "email"},
+	}
+	opts := govalidator.Options{
+		Data:  ip,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type acceptPayload struct {
+	Token string `json:"token"`
+}
+
+func (ap *acceptPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"token": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  ap,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type registerPayload struct {
+	Token           string `json:"token"`
+	Name            string `json:"name"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+func (rp *registerPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"token":            []string{"required"},
+		"name":             []string{"required", "min:4", "max:100"},
+		"password":         []string{"required"},
+		"confirm_password": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  rp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	if rp.Password != "" && rp.ConfirmPassword != "" && rp.Password != rp.ConfirmPassword {
+		e.Add("password", "password and confirmation password do not match")
+		e.Add("confirm_password", "password and confirmation password do not match")
+	}
+	return e
+}
+
+type invitationResponse struct {
+	ID             int       `json:"id"`
+	Email          string    `json:"email"`
+	Status         string    `json:"status"`
+	OrganizationID int       `json:"organization_id"`
+	InvitedBy      int       `json:"invited_by"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func newInvitationResponse(inv *models.Invitation) *invitationResponse {
+	return &invitationResponse{
+		ID:             inv.ID,
+		Email:          inv.Email,
+		Status:         inv.Status,
+		OrganizationID: inv.OrganizationId,
+		InvitedBy:      inv.InvitedBy,
+		ExpiresAt:      inv.ExpiresAt,
+		CreatedAt:      inv.CreatedAt,
+	}
+}
+
+// tokenInvitationResponse describes an invitation to whoever holds its token,
+// Registered tells whether to log in or to register for accepting it
+type tokenInvitationResponse struct {
+	Email            string    `json:"email"`
+	OrganizationID   int       `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	Registered       bool      `json:"registered"`
+}
+
+type userResponse struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// invitationHandler represent the http handler for invitations
+type invitationHandler struct {
+	useCase     invitation.UseCase
+	userUseCase user.UseCase
+	orgUseCase  organization.UseCase
+	*authx.Authx
+	event events.EventEmitter
+}
+
+// OrgCtx loads the organization of the request
+func (handler *invitationHandler) OrgCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id, err := param.Int(r, "id")
+		if err != nil {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+			return
+		}
+		org, err := handler.orgUseCase.FindByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, errorx.ErrorNotFound) {
+				httpx.ResponseJSONError(w, r, http.StatusNotFound, "organization not found", err)
+			} else {
+				panic(err)
+			}
+			return
+		}
+		ctx = context.WithValue(ctx, orgKey, org)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// InvitationCtx loads a pending invitation of the organization of the request
+func (handler *invitationHandler) InvitationCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		org := ctx.Value(orgKey).(*models.Organization)
+		id, err := param.Int(r, "invitationID")
+		if err != nil {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+			return
+		}
+		inv, err := handler.useCase.FindByID(ctx, id)
+		if err != nil && !errors.Is(err, errorx.ErrorNotFound) {
+			panic(err)
+		}
+		if inv == nil || inv.OrganizationId != org.ID || !inv.IsPending() {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "invitation not found")
+			return
+		}
+		ctx = context.WithValue(ctx, invitationKey, inv)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Create invites an email to the organization
+func (handler *invitationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := ctx.Value(orgKey).(*models.Organization)
+	data := &invitePayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	inviter := handler.currentUser(r)
+	inv, err := handler.useCase.Invite(mailer.WithLocale(ctx, mailer.RequestLocale(r)), org, inviter, data.Email)
+	if err != nil {
+		if errors.Is(err, invitation.ErrAlreadyMember) || errors.Is(err, invitation.ErrAlreadyInvited) {
+			validationErrors.Add("email", err.Error())
+			httpx.ResponseJSONError(w, r, http.StatusConflict, "invalid request", validationErrors)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(ctx, events.InvitationPendingEvent, inv, inviter.ID)
+	httpx.ResponseJSON(w, http.StatusCreated, newInvitationResponse(inv))
+}
+
+// List returns the pending invitations of the organization
+func (handler *invitationHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := ctx.Value(orgKey).(*models.Organization)
+	invitations, err := handler.useCase.FindPendingByOrganizationID(ctx, org.ID)
+	if err != nil {
+		panic(err)
+	}
+	resp := make([]*invitationResponse, len(invitations))
+	for i, inv := range invitations {
+		resp[i] = newInvitationResponse(inv)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, resp)
+}
+
+// Resend emails a new link for a pending invitation, the old one stops working
+func (handler *invitationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org := ctx.Value(orgKey).(*models.Organization)
+	inv := ctx.Value(invitationKey).(*models.Invitation)
+	if err := handler.useCase.Resend(mailer.WithLocale(ctx, mailer.RequestLocale(r)), org, handler.currentUser(r), inv); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "invitation not found", err)
+			return
+		}
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, newInvitationResponse(inv))
+}
+
+// Cancel cancels a pending invitation
+func (handler *invitationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	inv := ctx.Value(invitationKey).(*models.Invitation)
+	if err := handler.useCase.Cancel(ctx, inv); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "invitation not found", err)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(ctx, events.InvitationCanceledEvent, inv, handler.currentUser(r).ID)
+	httpx.NoContent(w)
+}
+
+// Get describes the invitation of a token
+func (handler *invitationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	inv, ok := handler.verify(w, r, chi.URLParam(r, "token"))
+	if !ok {
+		return
+	}
+	org, err := handler.orgUseCase.FindByID(ctx, inv.OrganizationId)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", errorx.ErrInvalidToken)
+			return
+		}
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, &tokenInvitationResponse{
+		Email:            inv.Email,
+		OrganizationID:   org.ID,
+		OrganizationName: org.Name,
+		ExpiresAt:        inv.ExpiresAt,
+		Registered:       handler.userUseCase.ExistsByEmail(ctx, inv.Email),
+	})
+}
+
+// Accept adds the current user to the organization of an invitation sent to
+// the user's email address
+func (handler *invitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := &acceptPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	inv, ok := handler.verify(w, r, data.Token)
+	if !ok {
+		return
+	}
+	u := handler.currentUser(r)
+	if err := handler.useCase.Accept(ctx, inv, u); err != nil {
+		if errors.Is(err, invitation.ErrEmailMismatch) {
+			httpx.ResponseJSONError(w, r, http.StatusForbidden, err.Error(), err)
+			return
+		}
+		if errors.Is(err, errorx.ErrTokenReused) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(ctx, events.InvitationSuccessfulEvent, inv, u.ID)
+	httpx.NoContent(w)
+}
+
+// Register creates the account of the invited email and accepts the invitation
+func (handler *invitationHandler) Register(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := &registerPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	inv, ok := handler.verify(w, r, data.Token)
+	if !ok {
+		return
+	}
+	if handler.userUseCase.ExistsByEmail(ctx, inv.Email) {
+		httpx.ResponseJSONError(w, r, http.StatusConflict, "user with this email already exists, log in to accept the invitation")
+		return
+	}
+	// the organization's password policy applies to its invitees right away
+	if err := handler.ValidatePassword(ctx, data.Password, inv.OrganizationId, inv.Email, data.Name); err != nil {
+		var pe *authx.PolicyError
+		if !errors.As(err, &pe) {
+			panic(err)
+		}
+		httpx.ResponseJSONError(w, r, 400, "invalid request", pe.Values("password"))
+		return
+	}
+	hash, err := handler.HashPassword(data.Password)
+	if err != nil {
+		panic(err)
+	}
+
+	u := &models.User{Name: data.Name, Password: hash}
+	if err := handler.useCase.Register(ctx, inv, u); err != nil {
+		if errors.Is(err, errorx.ErrTokenReused) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return
+		}
+		panic(err)
+	}
+	handler.event.EmitWithDelay(ctx, events.UserCreateEvent, *u)
+	handler.emit(ctx, events.InvitationSuccessfulEvent, inv, u.ID)
+	httpx.ResponseJSON(w, http.StatusCreated, &userResponse{ID: u.ID, Name: u.Name, Email: u.Email})
+}
+
+// verify writes the error response and returns false when token is not the
+// token of a pending invitation
+func (handler *invitationHandler) verify(w http.ResponseWriter, r *http.Request, token string) (*models.Invitation, bool) {
+	inv, err := handler.useCase.Verify(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, errorx.ErrInvalidToken) ||
+			errors.Is(err, errorx.ErrTokenExpired) ||
+			errors.Is(err, errorx.ErrTokenReused) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return nil, false
+		}
+		panic(err)
+	}
+	return inv, true
+}
+
+func (handler *invitationHandler) currentUser(r *http.Request) *models.User {
+	au, err := handler.GetCurrentUser(r)
+	u, ok := au.(*models.User)
+	if err != nil || !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	return u
+}
+
+func (handler *invitationHandler) emit(ctx context.Context, topic string, inv *models.Invitation, actorID int) {
+	handler.event.Emit(ctx, topic, events.InvitationStatusChanged{
+		InvitationID:   inv.ID,
+		OrganizationID: inv.OrganizationId,
+		Email:          inv.Email,
+		Status:         inv.Status,
+		UserID:         inv.UserId,
+		ActorID:        actorID,
+		ChangedAt:      inv.UpdatedAt,
+	})
+}
+
+// NewHandler will initialize the invitation endpoints
+func NewHandler(
+	r *chi.Mux,
+	aux *authx.Authx,
+	useCase invitation.UseCase,
+	userUseCase user.UseCase,
+	orgUseCase organization.UseCase,
+	event events.EventEmitter,
+) {
+	handler := &invitationHandler{
+		useCase:     useCase,
+		userUseCase: userUseCase,
+		orgUseCase:  orgUseCase,
+		Authx:       aux,
+		event:       event,
+	}
+	r.Route("/invitations", func(r chi.Router) {
+		r.Get("/{token}", handler.Get)
+		r.Post("/register", handler.Register)
+		r.With(handler.AuthMiddleware).Post("/accept", handler.Accept)
+	})
+	read := _orgDeliveryHttp.RequirePermission(aux, orgUseCase, organization.PermInvitationsRead)
+	write := _orgDeliveryHttp.RequirePermission(aux, orgUseCase, organization.PermInvitationsWrite)
+	r.Group(func(r chi.Router) {
+		r.Use(handler.AuthMiddleware, handler.OrgCtx)
+		r.With(write).Post("/organizations/{id}/invitations", handler.Create)
+		r.With(read).Get("/organizations/{id}/invitations", handler.List)
+		r.With(write, handler.InvitationCtx).Post("/organizations/{id}/invitations/{invitationID}/resend", handler.Resend)
+		r.With(write, handler.InvitationCtx).Delete("/organizations/{id}/invitations/{invitationID}", handler.Cancel)
+	})
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/invitation"
+	_inviteRepo "github.com/imtanmoy/authn/invitation/repository"
+	_inviteUseCase "github.com/imtanmoy/authn/invitation/usecase"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/organization"
+	_orgRepo "github.com/imtanmoy/authn/organization/repository"
+	_orgUseCase "github.com/imtanmoy/authn/organization/usecase"
+	"github.com/imtanmoy/authn/tests"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	_userUseCase "github.com/imtanmoy/authn/user/usecase"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	r    = chi.NewRouter()
+	db   *sql.DB
+	conn *pgx.Conn
+	aux  *authx.Authx
+	mail = mailer.NewMemoryMailer()
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err = stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setup()
+}
+
+func setup() {
+	timeoutContext := 30 * time.Millisecond * time.Second
+	userRepo := _userRepo.NewPgxRepository(conn)
+	aux = authx.New(userRepo, &authx.AuthxConfig{SecretKey: "test", AccessTokenExpireTime: 1})
+	useCase := _inviteUseCase.NewUseCase(_inviteRepo.NewPgxRepository(conn), userRepo, mail, mailer.NewTemplates("en"),
+		&invitation.Config{URL: "http://localhost:3000/invitations", TTL: time.Hour}, timeoutContext)
+	orgUseCase := _orgUseCase.NewUseCase(_orgRepo.NewPgxRepository(conn), &organization.Config{RetentionPeriod: time.Hour}, timeoutContext)
+	NewHandler(r, aux, useCase, _userUseCase.NewUseCase(userRepo, timeoutContext), orgUseCase, tests.NewMockEventEmitter())
+}
+
+func request(t *testing.T, method, path, email string, payload interface{}) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		body = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, body)
+	if email != "" {
+		token, err := aux.GenerateToken(email)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// invitationToken returns the token of the last invitation emailed to email
+func invitationToken(t *testing.T, email string) string {
+	msg := mail.Last(email)
+	require.NotNil(t, msg)
+	i := strings.Index(msg.Text, "http://localhost:3000/invitations?")
+	require.True(t, i >= 0, msg.Text)
+	link, err := url.Parse(strings.Fields(msg.Text[i:])[0])
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+func TestInvitationHandler(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	_, err := db.Exec("INSERT INTO users(name, email, password) VALUES ('Other User', 'other@test.com', 'password')")
+	require.NoError(t, err)
+	require.NoError(t, tests.InsertTestOrgs(db, []*models.Organization{{Name: "Test Org", OwnerID: 1}}))
+
+	t.Run("only members with org:invitations:write invite", func(t *testing.T) {
+		w := request(t, "POST", "/organizations/1/invitations", "other@test.com", &invitePayload{Email: "new@test.com"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(t, "POST", "/organizations/1/invitations", "test@test.com", &invitePayload{Email: "test@test.com"})
+		assert.Equal(t, http.StatusConflict, w.Code, "the owner is a member already")
+	})
+
+	var invited invitationResponse
+	t.Run("Create, Resend and List", func(t *testing.T) {
+		w := request(t, "POST", "/organizations/1/invitations", "test@test.com", &invitePayload{Email: "new@test.com"})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invited))
+		assert.Equal(t, "pending", invited.Status)
+		first := invitationToken(t, "new@test.com")
+
+		w = request(t, "POST", "/organizations/1/invitations", "test@test.com", &invitePayload{Email: "new@test.com"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = request(t, "POST", fmt.Sprintf("/organizations/1/invitations/%d/resend", invited.ID), "test@test.com", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotEqual(t, first, invitationToken(t, "new@test.com"))
+		w = request(t, "GET", "/invitations/"+first, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "resending replaces the token")
+
+		w = request(t, "GET", "/organizations/1/invitations", "test@test.com", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var list []*invitationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Len(t, list, 1)
+		assert.Equal(t, "new@test.com", list[0].Email)
+	})
+
+	t.Run("Register through the invitation", func(t *testing.T) {
+		token := invitationToken(t, "new@test.com")
+		w := request(t, "GET", "/invitations/"+token, "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got tokenInvitationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, "Test Org", got.OrganizationName)
+		assert.False(t, got.Registered)
+
+		payload := &registerPayload{Token: token, Name: "New User", Password: "correct horse battery", ConfirmPassword: "correct horse battery"}
+		w = request(t, "POST", "/invitations/register", "", payload)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = request(t, "POST", "/invitations/register", "", payload)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "tokens are single use")
+
+		var verified bool
+		require.NoError(t, db.QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE email = 'new@test.com'").Scan(&verified))
+		assert.True(t, verified)
+		w = request(t, "POST", "/organizations/1/invitations", "test@test.com", &invitePayload{Email: "new@test.com"})
+		assert.Equal(t, http.StatusConflict, w.Code, "new@test.com is a member")
+	})
+
+	t.Run("Accept as an existing user", func(t *testing.T) {
+		w := request(t, "POST", "/organizations/1/invitations", "test@test.com", &invitePayload{Email: "other@test.com"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		token := invitationToken(t, "other@test.com")
+
+		w = request(t, "POST", "/invitations/accept", "new@test.com", &acceptPayload{Token: token})
+		assert.Equal(t, http.StatusForbidden, w.Code, "the invitation is for another email")
+		w = request(t, "POST", "/invitations/accept", "other@test.com", &acceptPayload{Token: token})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = request(t, "POST", "/invitations/accept", "other@test.com", &acceptPayload{Token: token})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		w := request(t, "POST", "/organizations/1/invitations", "test@test.com", &invitePayload{Email: "late@test.com"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var inv invitationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inv))
+		token := invitationToken(t, "late@test.com")
+
+		path := fmt.Sprintf("/organizations/1/invitations/%d", inv.ID)
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path, "test@test.com", nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(t, "DELETE", path, "test@test.com", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, request(t, "GET", "/invitations/"+token, "", nil).Code)
+	})
+}
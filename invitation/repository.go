@@ -0,0 +1,30 @@
+package invitation
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the invitation's repository contract
+type Repository interface {
+	Save(ctx context.Context, inv *models.Invitation) error
+	FindByID(ctx context.Context, id int) (*models.Invitation, error)
+	FindByTokenHash(ctx context.Context, hash string) (*models.Invitation, error)
+	// FindPendingByOrganizationID lists the pending invitations, expired ones
+	// included, newest first
+	FindPendingByOrganizationID(ctx context.Context, organizationID int) ([]*models.Invitation, error)
+	// ExistsPending reports whether email has a pending invitation to the organization
+	ExistsPending(ctx context.Context, organizationID int, email string) (bool, error)
+	// ExistsMember reports whether the user with email belongs to the organization
+	ExistsMember(ctx context.Context, organizationID int, email string) (bool, error)
+	// UpdateToken replaces the token and expiry of a pending invitation
+	UpdateToken(ctx context.Context, inv *models.Invitation) error
+	// Cancel cancels a pending invitation, it must return errorx.ErrorNotFound
+	// when inv is no longer pending
+	Cancel(ctx context.Context, inv *models.Invitation) error
+	// Accept marks a pending invitation successful and adds the user to the
+	// organization at once, it must return errorx.ErrTokenReused when inv is
+	// no longer pending
+	Accept(ctx context.Context, inv *models.Invitation, userID int) error
+}
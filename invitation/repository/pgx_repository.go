@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/invitation"
+	"github.com/imtanmoy/authn/models"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const selectInvitation = "SELECT id, email, token_hash, status, organization_id, user_id, invited_by, " +
+	"expires_at, accepted_at, created_at, updated_at FROM invitations "
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ invitation.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the invitation.Repository interface
+func NewPgxRepository(conn dbx.DB) invitation.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) Save(ctx context.Context, inv *models.Invitation) error {
+	err := repo.conn.QueryRow(ctx, "INSERT INTO invitations(email, token_hash, organization_id, invited_by, expires_at) "+
+		"VALUES ($1,$2,$3,$4,$5) "+
+		"RETURNING id, status, created_at, updated_at",
+		inv.Email, inv.TokenHash, inv.OrganizationId, inv.InvitedBy, inv.ExpiresAt).
+		Scan(&inv.ID, &inv.Status, &inv.CreatedAt, &inv.UpdatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok {
+			if pgErr.Code == "23505" {
+				return invitation.ErrAlreadyInvited
+			}
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindByID(ctx context.Context, id int) (*models.Invitation, error) {
+	return repo.findOne(ctx, selectInvitation+"WHERE id = $1", id)
+}
+
+func (repo *pgxRepository) FindByTokenHash(ctx context.Context, hash string) (*models.Invitation, error) {
+	return repo.findOne(ctx, selectInvitation+"WHERE token_hash = $1", hash)
+}
+
+func (repo *pgxRepository) FindPendingByOrganizationID(ctx context.Context, organizationID int) ([]*models.Invitation, error) {
+	rows, err := repo.conn.Query(ctx, selectInvitation+"WHERE organization_id = $1 AND status = 'pending' "+
+		"ORDER BY created_at DESC, id DESC", organizationID)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	invitations := make([]*models.Invitation, 0)
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+func (repo *pgxRepository) ExistsPending(ctx context.Context, organizationID int, email string) (bool, error) {
+	found := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) FROM invitations "+
+		"WHERE organization_id = $1 AND LOWER(email) = LOWER($2) AND status = 'pending'", organizationID, email).
+		Scan(&found)
+	if err != nil {
+		return false, errorx.ErrInternalDB
+	}
+	return found > 0, nil
+}
+
+func (repo *pgxRepository) ExistsMember(ctx context.Context, organizationID int, email string) (bool, error) {
+	found := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) FROM users u "+
+		"JOIN users_organizations uo ON uo.user_id = u.id AND uo.organization_id = $1 "+
+		"WHERE LOWER(u.email) = LOWER($2) AND u.deleted_at IS NULL",
+		organizationID, email).
+		Scan(&found)
+	if err != nil {
+		return false, errorx.ErrInternalDB
+	}
+	return found > 0, nil
+}
+
+func (repo *pgxRepository) UpdateToken(ctx context.Context, inv *models.Invitation) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE invitations SET token_hash = $1, expires_at = $2, updated_at = $3 "+
+		"WHERE id = $4 AND status = 'pending'", inv.TokenHash, inv.ExpiresAt, now, inv.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	inv.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) Cancel(ctx context.Context, inv *models.Invitation) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE invitations SET status = 'canceled', updated_at = $1 "+
+		"WHERE id = $2 AND status = 'pending'", now, inv.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	inv.Status = models.InvitationCanceled
+	inv.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) Accept(ctx context.Context, inv *models.Invitation, userID int) error {
+	now := time.Now().UTC()
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, "UPDATE invitations SET status = 'successful', user_id = $1, accepted_at = $2, updated_at = $2 "+
+		"WHERE id = $3 AND status = 'pending'", userID, now, inv.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrTokenReused
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO users_organizations(user_id, organization_id) "+
+		"SELECT $1, $2 WHERE NOT EXISTS "+
+		"(SELECT 1 FROM users_organizations WHERE user_id = $1 AND organization_id = $2)",
+		userID, inv.OrganizationId)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	inv.Status = models.InvitationSuccessful
+	inv.UserId = userID
+	inv.AcceptedAt = now
+	inv.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) findOne(ctx context.Context, query string, args ...interface{}) (*models.Invitation, error) {
+	inv, err := scanInvitation(repo.conn.QueryRow(ctx, query, args...))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	return inv, nil
+}
+
+func scanInvitation(row pgx.Row) (*models.Invitation, error) {
+	var inv models.Invitation
+	var userID *int
+	var acceptedAt *time.Time
+	err := row.Scan(&inv.ID, &inv.Email, &inv.TokenHash, &inv.Status, &inv.OrganizationId, &userID, &inv.InvitedBy,
+		&inv.ExpiresAt, &acceptedAt, &inv.CreatedAt, &inv.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if userID != nil {
+		inv.UserId = *userID
+	}
+	if acceptedAt != nil {
+		inv.AcceptedAt = *acceptedAt
+	}
+	return &inv, nil
+}
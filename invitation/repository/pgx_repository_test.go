@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/invitation"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo invitation.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func fakeInvitation(email, hash string) *models.Invitation {
+	return &models.Invitation{
+		Email:          email,
+		TokenHash:      hash,
+		OrganizationId: 1,
+		InvitedBy:      1,
+		ExpiresAt:      time.Now().UTC().Add(time.Hour),
+	}
+}
+
+func TestPgxRepository_Invitations(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	require.NoError(t, tests.InsertTestOrgs(db, tests.FakeOrgs(1)))
+	ctx := context.Background()
+
+	first := fakeInvitation("first@test.com", "hash-1")
+	require.NoError(t, repo.Save(ctx, first))
+	assert.NotZero(t, first.ID)
+	assert.True(t, first.IsPending())
+	second := fakeInvitation("second@test.com", "hash-2")
+	require.NoError(t, repo.Save(ctx, second))
+
+	assert.Equal(t, invitation.ErrAlreadyInvited, repo.Save(ctx, fakeInvitation("First@test.com", "hash-3")),
+		"one pending invitation per email")
+
+	found, err := repo.FindByTokenHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, found.ID)
+	_, err = repo.FindByTokenHash(ctx, "unknown")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	pending, err := repo.FindPendingByOrganizationID(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, second.ID, pending[0].ID, "newest first")
+
+	exists, err := repo.ExistsPending(ctx, 1, "FIRST@test.com")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = repo.ExistsMember(ctx, 1, "test@test.com")
+	require.NoError(t, err)
+	assert.True(t, exists, "the owner is a member")
+
+	t.Run("UpdateToken", func(t *testing.T) {
+		first.TokenHash = "hash-4"
+		require.NoError(t, repo.UpdateToken(ctx, first))
+		_, err := repo.FindByTokenHash(ctx, "hash-1")
+		assert.Equal(t, errorx.ErrorNotFound, err)
+		_, err = repo.FindByTokenHash(ctx, "hash-4")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		require.NoError(t, repo.Cancel(ctx, second))
+		assert.Equal(t, models.InvitationCanceled, second.Status)
+		assert.Equal(t, errorx.ErrorNotFound, repo.Cancel(ctx, second))
+		assert.Equal(t, errorx.ErrTokenReused, repo.Accept(ctx, second, 1))
+
+		require.NoError(t, repo.Save(ctx, fakeInvitation("second@test.com", "hash-5")),
+			"canceled invitations don't block a new one")
+	})
+
+	t.Run("Accept", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users(name, email, password) VALUES ('First User', 'first@test.com', 'password')")
+		require.NoError(t, err)
+		var userID int
+		require.NoError(t, db.QueryRow("SELECT id FROM users WHERE email = 'first@test.com'").Scan(&userID))
+
+		require.NoError(t, repo.Accept(ctx, first, userID))
+		assert.Equal(t, models.InvitationSuccessful, first.Status)
+		assert.Equal(t, userID, first.UserId)
+		assert.Equal(t, errorx.ErrTokenReused, repo.Accept(ctx, first, userID))
+
+		exists, err := repo.ExistsMember(ctx, 1, "first@test.com")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = repo.ExistsPending(ctx, 1, "first@test.com")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,50 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+var (
+	// ErrAlreadyMember the invited email belongs to a member of the organization
+	ErrAlreadyMember = errors.New("already a member of the organization")
+	// ErrAlreadyInvited the email has a pending invitation to the organization
+	ErrAlreadyInvited = errors.New("already invited to the organization")
+	// ErrEmailMismatch the invitation was sent to another email address
+	ErrEmailMismatch = errors.New("invitation was sent to another email address")
+)
+
+// Config of the invitation emails
+type Config struct {
+	// URL of the page which accepts the invitation, the token is appended as
+	// the token query parameter
+	URL string
+	TTL time.Duration
+}
+
+// UseCase represent the invitation's use cases
+type UseCase interface {
+	// Invite emails an invitation to the organization to email. It returns
+	// ErrAlreadyMember or ErrAlreadyInvited when there is no one to invite.
+	Invite(ctx context.Context, org *models.Organization, inviter *models.User, email string) (*models.Invitation, error)
+	// Resend emails a new token for a pending invitation and restarts its expiry
+	Resend(ctx context.Context, org *models.Organization, inviter *models.User, inv *models.Invitation) error
+	// Cancel cancels a pending invitation, it returns errorx.ErrorNotFound
+	// when inv is no longer pending
+	Cancel(ctx context.Context, inv *models.Invitation) error
+	FindByID(ctx context.Context, id int) (*models.Invitation, error)
+	FindPendingByOrganizationID(ctx context.Context, organizationID int) ([]*models.Invitation, error)
+	// Verify returns the pending invitation of a token. It returns
+	// errorx.ErrInvalidToken, errorx.ErrTokenExpired or errorx.ErrTokenReused
+	// for tokens which can't be accepted.
+	Verify(ctx context.Context, token string) (*models.Invitation, error)
+	// Accept adds u to the organization of inv. It returns ErrEmailMismatch
+	// when u has another email address than the invited one.
+	Accept(ctx context.Context, inv *models.Invitation, u *models.User) error
+	// Register creates the account of the invited email, which the token
+	// proves to be verified, and accepts inv with it
+	Register(ctx context.Context, inv *models.Invitation, u *models.User) error
+}
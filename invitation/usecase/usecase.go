@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/confirmation"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/invitation"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/user"
+)
+
+type useCase struct {
+	repo           invitation.Repository
+	userRepo       user.Repository
+	mailer         mailer.Mailer
+	templates      *mailer.Templates
+	config         *invitation.Config
+	contextTimeout time.Duration
+}
+
+var _ invitation.UseCase = (*useCase)(nil)
+
+// NewUseCase will create new an useCase object representation of invitation.UseCase interface
+func NewUseCase(repo invitation.Repository, userRepo user.Repository, m mailer.Mailer, t *mailer.Templates, config *invitation.Config, timeout time.Duration) invitation.UseCase {
+	return &useCase{
+		repo:           repo,
+		userRepo:       userRepo,
+		mailer:         m,
+		templates:      t,
+		config:         config,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) Invite(ctx context.Context, org *models.Organization, inviter *models.User, email string) (*models.Invitation, error) {
+	member, err := u.repo.ExistsMember(ctx, org.ID, email)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, invitation.ErrAlreadyMember
+	}
+	pending, err := u.repo.ExistsPending(ctx, org.ID, email)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, invitation.ErrAlreadyInvited
+	}
+
+	token := confirmation.GenerateConfirmationToken()
+	inv := &models.Invitation{
+		Email:          email,
+		TokenHash:      confirmation.HashToken(token),
+		OrganizationId: org.ID,
+		InvitedBy:      inviter.ID,
+		ExpiresAt:      time.Now().UTC().Add(u.config.TTL),
+	}
+	if err := u.repo.Save(ctx, inv); err != nil {
+		return nil, err
+	}
+	return inv, u.send(ctx, org, inviter, inv, token)
+}
+
+func (u *useCase) Resend(ctx context.Context, org *models.Organization, inviter *models.User, inv *models.Invitation) error {
+	token := confirmation.GenerateConfirmationToken()
+	inv.TokenHash = confirmation.HashToken(token)
+	inv.ExpiresAt = time.Now().UTC().Add(u.config.TTL)
+	if err := u.repo.UpdateToken(ctx, inv); err != nil {
+		return err
+	}
+	return u.send(ctx, org, inviter, inv, token)
+}
+
+func (u *useCase) send(ctx context.Context, org *models.Organization, inviter *models.User, inv *models.Invitation, token string) error {
+	msg, err := u.templates.Render(ctx, "invitation", struct {
+		InviterName      string
+		OrganizationName string
+		Link             string
+		TTL              time.Duration
+	}{inviter.Name, org.Name, confirmation.TokenLink(u.config.URL, token), u.config.TTL})
+	if err != nil {
+		return err
+	}
+	msg.To = inv.Email
+	return u.mailer.Send(ctx, msg)
+}
+
+func (u *useCase) Cancel(ctx context.Context, inv *models.Invitation) error {
+	return u.repo.Cancel(ctx, inv)
+}
+
+func (u *useCase) FindByID(ctx context.Context, id int) (*models.Invitation, error) {
+	return u.repo.FindByID(ctx, id)
+}
+
+func (u *useCase) FindPendingByOrganizationID(ctx context.Context, organizationID int) ([]*models.Invitation, error) {
+	return u.repo.FindPendingByOrganizationID(ctx, organizationID)
+}
+
+func (u *useCase) Verify(ctx context.Context, token string) (*models.Invitation, error) {
+	inv, err := u.repo.FindByTokenHash(ctx, confirmation.HashToken(token))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, errorx.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !inv.IsPending() {
+		return nil, errorx.ErrTokenReused
+	}
+	if inv.IsExpired() {
+		return nil, errorx.ErrTokenExpired
+	}
+	return inv, nil
+}
+
+func (u *useCase) Accept(ctx context.Context, inv *models.Invitation, us *models.User) error {
+	if !strings.EqualFold(inv.Email, us.Email) {
+		return invitation.ErrEmailMismatch
+	}
+	return u.repo.Accept(ctx, inv, us.ID)
+}
+
+func (u *useCase) Register(ctx context.Context, inv *models.Invitation, us *models.User) error {
+	us.Email = inv.Email
+	if err := u.userRepo.Save(ctx, us); err != nil {
+		return err
+	}
+	if err := u.userRepo.MarkEmailVerified(ctx, us); err != nil {
+		return err
+	}
+	return u.repo.Accept(ctx, inv, us.ID)
+}
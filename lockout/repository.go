@@ -0,0 +1,10 @@
+package lockout
+
+import (
+	"github.com/imtanmoy/authn/internal/authx"
+)
+
+// Repository persists failed logins per account and per source IP
+type Repository interface {
+	authx.LoginAttemptRepo
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/lockout"
+	"github.com/jackc/pgx/v4"
+)
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ lockout.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the lockout.Repository interface
+func NewPgxRepository(conn dbx.DB) lockout.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) FindLoginAttempt(ctx context.Context, key string) (*authx.LoginAttempt, error) {
+	a, err := scanLoginAttempt(repo.conn.QueryRow(ctx, "SELECT key, failures, last_failed_at, locked_until "+
+		"FROM login_attempts WHERE key = $1", key))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+func (repo *pgxRepository) RecordLoginFailure(ctx context.Context, key string, now, since time.Time) (*authx.LoginAttempt, error) {
+	return scanLoginAttempt(repo.conn.QueryRow(ctx, "INSERT INTO login_attempts(key, failures, last_failed_at) "+
+		"VALUES ($1,1,$2) "+
+		"ON CONFLICT (key) DO UPDATE SET "+
+		"failures = CASE WHEN login_attempts.last_failed_at < $3 THEN 1 ELSE login_attempts.failures + 1 END, "+
+		"locked_until = CASE WHEN login_attempts.last_failed_at < $3 THEN NULL ELSE login_attempts.locked_until END, "+
+		"last_failed_at = EXCLUDED.last_failed_at "+
+		"RETURNING key, failures, last_failed_at, locked_until", key, now, since))
+}
+
+func (repo *pgxRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	_, err := repo.conn.Exec(ctx, "UPDATE login_attempts SET locked_until = $1 WHERE key = $2", until, key)
+	return err
+}
+
+func (repo *pgxRepository) DeleteLoginAttempt(ctx context.Context, key string) error {
+	_, err := repo.conn.Exec(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+func (repo *pgxRepository) PurgeLoginAttempts(ctx context.Context, before time.Time) error {
+	_, err := repo.conn.Exec(ctx, "DELETE FROM login_attempts "+
+		"WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $1)", before)
+	return err
+}
+
+func scanLoginAttempt(row pgx.Row) (*authx.LoginAttempt, error) {
+	var a authx.LoginAttempt
+	var lockedUntil *time.Time
+	if err := row.Scan(&a.Key, &a.Failures, &a.LastFailedAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil {
+		a.LockedUntil = *lockedUntil
+	}
+	return &a, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/lockout"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo lockout.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func TestPgxRepository_LoginAttempts(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, err := repo.FindLoginAttempt(ctx, "account:test@test.com")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	a, err := repo.RecordLoginFailure(ctx, "account:test@test.com", now, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Failures)
+	a, err = repo.RecordLoginFailure(ctx, "account:test@test.com", now.Add(time.Second), now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, a.Failures)
+	assert.True(t, a.LockedUntil.IsZero())
+
+	require.NoError(t, repo.LockLogin(ctx, "account:test@test.com", now.Add(time.Minute)))
+	a, err = repo.FindLoginAttempt(ctx, "account:test@test.com")
+	require.NoError(t, err)
+	assert.Equal(t, 2, a.Failures)
+	assert.Equal(t, now.Add(time.Minute).Unix(), a.LockedUntil.Unix())
+
+	t.Run("count starts over after the window", func(t *testing.T) {
+		later := now.Add(2 * time.Hour)
+		a, err := repo.RecordLoginFailure(ctx, "account:test@test.com", later, later.Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, a.Failures)
+		assert.True(t, a.LockedUntil.IsZero())
+	})
+
+	t.Run("purge keeps recent entries", func(t *testing.T) {
+		_, err := repo.RecordLoginFailure(ctx, "ip:10.0.0.1", now.Add(-2*time.Hour), time.Time{})
+		require.NoError(t, err)
+		require.NoError(t, repo.PurgeLoginAttempts(ctx, now.Add(-time.Hour)))
+		_, err = repo.FindLoginAttempt(ctx, "ip:10.0.0.1")
+		assert.Equal(t, errorx.ErrorNotFound, err)
+		_, err = repo.FindLoginAttempt(ctx, "account:test@test.com")
+		assert.NoError(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, repo.DeleteLoginAttempt(ctx, "account:test@test.com"))
+		_, err := repo.FindLoginAttempt(ctx, "account:test@test.com")
+		assert.Equal(t, errorx.ErrorNotFound, err)
+	})
+}
@@ -0,0 +1,329 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/events"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/mfa"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/httpx"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+type codePayload struct {
+	Code string `json:"code"`
+}
+
+func (cp *codePayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"code": []string{"required", "min:6", "max:20"},
+	}
+	opts := govalidator.Options{
+		Data:  cp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type loginPayload struct {
+	MFAToken string `json:"mfa_token"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code"`
+}
+
+func (lp *loginPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"mfa_token": []string{"required"},
+		"code":      []string{"required", "min:6", "max:20"},
+	}
+	opts := govalidator.Options{
+		Data:  lp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type statusResponse struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recovery_codes_remaining"`
+}
+
+type enrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRPayload is the text to encode in the QR code shown to the user
+	QRPayload string `json:"qr_payload"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type loginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// mfaHandler represent the http handler for two-factor authentication
+type mfaHandler struct {
+	useCase mfa.UseCase
+	*authx.Authx
+	event events.EventEmitter
+}
+
+// Status tells whether the current user enabled two-factor authentication
+func (handler *mfaHandler) Status(w http.ResponseWriter, r *http.Request) {
+	status, err := handler.useCase.Status(r.Context(), handler.currentUser(r))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, &statusResponse{
+		Enabled:       status.Enabled,
+		RecoveryCodes: status.RecoveryCodes,
+	})
+}
+
+// Enroll starts enrolling an authenticator app, it is enabled once Confirm
+// receives its first code
+func (handler *mfaHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := handler.useCase.Enroll(r.Context(), handler.currentUser(r))
+	if err != nil {
+		if errors.Is(err, mfa.ErrAlreadyEnrolled) {
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+			return
+		}
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusCreated, &enrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.URI,
+		QRPayload:  enrollment.URI,
+	})
+}
+
+// Confirm enables the enrolled app and returns the recovery codes, the only
+// time they are shown
+func (handler *mfaHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	data, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	u := handler.currentUser(r)
+	codes, err := handler.useCase.Confirm(r.Context(), u, data.Code)
+	if err != nil {
+		handler.codeError(w, r, err)
+		return
+	}
+	handler.emit(r, events.MFAEnabledEvent, u)
+	httpx.ResponseJSON(w, http.StatusOK, &recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable removes the app and the recovery codes of the current user
+func (handler *mfaHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	data, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	u := handler.currentUser(r)
+	if err := handler.useCase.Disable(r.Context(), u, data.Code); err != nil {
+		handler.codeError(w, r, err)
+		return
+	}
+	handler.emit(r, events.MFADisabledEvent, u)
+	httpx.NoContent(w)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the current user
+func (handler *mfaHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	data, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	u := handler.currentUser(r)
+	codes, err := handler.useCase.RegenerateRecoveryCodes(r.Context(), u, data.Code)
+	if err != nil {
+		handler.codeError(w, r, err)
+		return
+	}
+	handler.emit(r, events.MFARecoveryCodesRegeneratedEvent, u)
+	httpx.ResponseJSON(w, http.StatusOK, &recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Login exchanges the MFA pending token of a correct password and a TOTP or
+// recovery code for the token pair. Wrong codes count as failed logins.
+func (handler *mfaHandler) Login(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &loginPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	au, claims, err := handler.VerifyMFAToken(ctx, data.MFAToken)
+	if err != nil {
+		if errors.Is(err, errorx.ErrInvalidToken) {
+			httpx.ResponseJSONError(w, r, http.StatusUnauthorized, "invalid or expired mfa token", err)
+			return
+		}
+		panic(err)
+	}
+	u, ok := au.(*models.User)
+	if !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	ip := authx.ClientIP(r)
+	if err := handler.CheckLogin(ctx, u.Email, ip); err != nil {
+		var be *authx.LoginBlockedError
+		if !errors.As(err, &be) {
+			panic(err)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+		httpx.ResponseJSONError(w, r, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return
+	}
+
+	recovery, err := handler.useCase.Verify(ctx, u, data.Code)
+	if err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			handler.loginFailed(ctx, u, ip)
+		}
+		handler.codeError(w, r, err)
+		return
+	}
+	if err := handler.LoginSucceeded(ctx, u.Email); err != nil {
+		panic(err)
+	}
+	// the pending token is spent, it can't be exchanged for another pair
+	if err := handler.RevokeToken(ctx, u, claims); err != nil {
+		panic(err)
+	}
+
+	// the first factor recorded in the pending token, then the second
+	methods := append([]string(nil), claims.AuthMethods...)
+	if !recovery {
+		methods = append(methods, authx.AuthMethodOTP)
+	}
+	methods = append(methods, authx.AuthMethodMFA)
+	pair, err := handler.GenerateTokenPair(ctx, u, authx.WithAuthMethods(methods...))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, &loginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+// loginFailed counts a wrong code like a wrong password, locking the account
+// is announced on the bus
+func (handler *mfaHandler) loginFailed(ctx context.Context, u *models.User, ip string) {
+	lockedUntil, err := handler.LoginFailed(ctx, u.Email, ip)
+	if err != nil {
+		panic(err)
+	}
+	if lockedUntil.IsZero() {
+		return
+	}
+	handler.event.Emit(ctx, events.UserLockedEvent, events.UserLocked{
+		UserID:      u.ID,
+		Email:       u.Email,
+		IP:          ip,
+		LockedUntil: lockedUntil,
+	})
+}
+
+func (handler *mfaHandler) codeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode):
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, mfa.ErrNotEnrolled), errors.Is(err, mfa.ErrAlreadyEnrolled),
+		errors.Is(err, mfa.ErrRequiredByOrganization):
+		httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+	default:
+		panic(err)
+	}
+}
+
+func (handler *mfaHandler) emit(r *http.Request, topic string, u *models.User) {
+	handler.event.Emit(r.Context(), topic, events.MFAChanged{
+		UserID:    u.ID,
+		Email:     u.Email,
+		IP:        authx.ClientIP(r),
+		ChangedAt: time.Now().UTC(),
+	})
+}
+
+func (handler *mfaHandler) currentUser(r *http.Request) *models.User {
+	au, err := handler.GetCurrentUser(r)
+	u, ok := au.(*models.User)
+	if err != nil || !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	return u
+}
+
+func decodeCode(w http.ResponseWriter, r *http.Request) (*codePayload, bool) {
+	data := &codePayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return nil, false
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return nil, false
+	}
+	return data, true
+}
+
+// NewHandler will initialize the two-factor authentication endpoints
+func NewHandler(r *chi.Mux, aux *authx.Authx, useCase mfa.UseCase, event events.EventEmitter) {
+	handler := &mfaHandler{
+		useCase: useCase,
+		Authx:   aux,
+		event:   event,
+	}
+	r.Post("/login/mfa", handler.Login)
+	r.Route("/me/mfa", func(r chi.Router) {
+		r.Use(handler.AuthMiddleware)
+		r.Get("/", handler.Status)
+		r.Post("/totp", handler.Enroll)
+		r.Post("/totp/verify", handler.Confirm)
+		r.Delete("/totp", handler.Disable)
+		r.Post("/recovery-codes", handler.RegenerateRecoveryCodes)
+	})
+}
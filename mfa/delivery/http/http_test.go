@@ -0,0 +1,219 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi"
+	_authDeliveryHttp "github.com/imtanmoy/authn/auth/delivery/http"
+	_authUseCase "github.com/imtanmoy/authn/auth/usecase"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/mfa"
+	_mfaRepo "github.com/imtanmoy/authn/mfa/repository"
+	_mfaUseCase "github.com/imtanmoy/authn/mfa/usecase"
+	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	_userUseCase "github.com/imtanmoy/authn/user/usecase"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	r    = chi.NewRouter()
+	db   *sql.DB
+	conn *pgx.Conn
+	aux  *authx.Authx
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err = stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setup()
+}
+
+func setup() {
+	timeoutContext := 30 * time.Millisecond * time.Second
+	userRepo := _userRepo.NewPgxRepository(conn)
+	tokenRepo := _tokenRepo.NewPgxRepository(conn)
+
+	useCase := _mfaUseCase.NewUseCase(_mfaRepo.NewPgxRepository(conn), &mfa.Config{
+		Issuer:        "Authn",
+		EncryptionKey: []byte("test"),
+		RecoveryCodes: 10,
+	}, timeoutContext)
+	aux = authx.New(userRepo, &authx.AuthxConfig{
+		SecretKey:              "test",
+		AccessTokenExpireTime:  1,
+		RefreshTokenExpireTime: 5,
+	}, authx.WithRefreshTokenRepo(tokenRepo), authx.WithRevocationRepo(tokenRepo), authx.WithSecondFactor(useCase))
+
+	evt := tests.NewMockEventEmitter()
+	_authDeliveryHttp.NewHandler(r, aux, _authUseCase.NewUseCase(userRepo, timeoutContext),
+		_userUseCase.NewUseCase(userRepo, timeoutContext), evt)
+	NewHandler(r, aux, useCase, evt)
+}
+
+func request(t *testing.T, method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		body = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, body)
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+type passwordLogin struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	MFA      bool   `json:"mfa_required"`
+	MFAToken string `json:"mfa_token"`
+}
+
+func login(t *testing.T) *passwordLogin {
+	w := request(t, "POST", "/login", "", map[string]string{"email": "test@test.com", "password": "password"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got passwordLogin
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	return &got
+}
+
+func recoveryCodes(t *testing.T, w *httptest.ResponseRecorder) []string {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got recoveryCodesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	return got.RecoveryCodes
+}
+
+func TestMFAHandler(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	first := login(t)
+	require.False(t, first.MFA)
+	require.NotEmpty(t, first.Token)
+	token := first.Token
+
+	var secret, confirmCode string
+	var codes []string
+	t.Run("Enroll", func(t *testing.T) {
+		w := request(t, "POST", "/me/mfa/totp", token, nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var got enrollmentResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, got.OTPAuthURI, got.QRPayload)
+		assert.Contains(t, got.OTPAuthURI, "otpauth://totp/Authn:test@test.com?")
+		secret = got.Secret
+
+		var stored string
+		require.NoError(t, db.QueryRow("SELECT secret FROM totp_factors WHERE user_id = 1").Scan(&stored))
+		assert.NotContains(t, stored, secret, "the secret is encrypted at rest")
+
+		w = request(t, "POST", "/me/mfa/totp/verify", token, &codePayload{Code: "000000"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var err error
+		confirmCode, err = authx.TOTPCode(secret, time.Now())
+		require.NoError(t, err)
+		codes = recoveryCodes(t, request(t, "POST", "/me/mfa/totp/verify", token, &codePayload{Code: confirmCode}))
+		assert.Len(t, codes, 10)
+
+		assert.Equal(t, http.StatusConflict, request(t, "POST", "/me/mfa/totp", token, nil).Code)
+		w = request(t, "GET", "/me/mfa", token, nil)
+		assert.JSONEq(t, `{"enabled": true, "recovery_codes_remaining": 10}`, w.Body.String())
+	})
+
+	t.Run("Login with TOTP", func(t *testing.T) {
+		pending := login(t)
+		require.True(t, pending.MFA)
+		assert.Empty(t, pending.Token)
+		assert.Equal(t, http.StatusUnauthorized, request(t, "GET", "/me/mfa", pending.MFAToken, nil).Code,
+			"the pending token is no access token")
+
+		w := request(t, "POST", "/login/mfa", "", &loginPayload{MFAToken: pending.MFAToken, Code: confirmCode})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "the code confirming the app can't be replayed")
+		w = request(t, "POST", "/login/mfa", "", &loginPayload{MFAToken: first.Token, Code: confirmCode})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		code, err := authx.TOTPCode(secret, time.Now().Add(authx.TOTPPeriod))
+		require.NoError(t, err)
+		w = request(t, "POST", "/login/mfa", "", &loginPayload{MFAToken: pending.MFAToken, Code: code})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.NotEmpty(t, got.RefreshToken)
+		info, err := aux.Introspect(ctx, got.Token, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{authx.AuthMethodPassword, authx.AuthMethodOTP, authx.AuthMethodMFA}, info.AuthMethods)
+
+		w = request(t, "POST", "/login/mfa", "", &loginPayload{MFAToken: pending.MFAToken, Code: codes[0]})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the pending token is single use")
+	})
+
+	t.Run("Login with a recovery code", func(t *testing.T) {
+		pending := login(t)
+		w := request(t, "POST", "/login/mfa", "", &loginPayload{MFAToken: pending.MFAToken, Code: codes[0]})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		token = got.Token
+
+		pending = login(t)
+		w = request(t, "POST", "/login/mfa", "", &loginPayload{MFAToken: pending.MFAToken, Code: codes[0]})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "recovery codes are single use")
+		w = request(t, "GET", "/me/mfa", token, nil)
+		assert.JSONEq(t, `{"enabled": true, "recovery_codes_remaining": 9}`, w.Body.String())
+	})
+
+	t.Run("Regenerate recovery codes", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request(t, "POST", "/me/mfa/recovery-codes", token, &codePayload{Code: codes[0]}).Code)
+		fresh := recoveryCodes(t, request(t, "POST", "/me/mfa/recovery-codes", token, &codePayload{Code: codes[1]}))
+		assert.Len(t, fresh, 10)
+		assert.NotContains(t, fresh, codes[2])
+		assert.Equal(t, http.StatusBadRequest, request(t, "POST", "/me/mfa/recovery-codes", token, &codePayload{Code: codes[2]}).Code)
+		codes = fresh
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO organizations(name, owner_id, require_mfa) VALUES ('Strict Org', 1, TRUE)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO users_organizations(user_id, organization_id, role) VALUES (1, 1, 'owner')")
+		require.NoError(t, err)
+		w := request(t, "DELETE", "/me/mfa/totp", token, &codePayload{Code: codes[0]})
+		assert.Equal(t, http.StatusConflict, w.Code, "an organization requires MFA")
+
+		_, err = db.Exec("UPDATE organizations SET require_mfa = FALSE")
+		require.NoError(t, err)
+		w = request(t, "DELETE", "/me/mfa/totp", token, &codePayload{Code: codes[0]})
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		w = request(t, "GET", "/me/mfa", token, nil)
+		assert.JSONEq(t, `{"enabled": false, "recovery_codes_remaining": 0}`, w.Body.String())
+		assert.False(t, login(t).MFA)
+	})
+}
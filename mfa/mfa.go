@@ -0,0 +1,74 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/models"
+)
+
+var (
+	// ErrAlreadyEnrolled the user has a confirmed authenticator app already
+	ErrAlreadyEnrolled = errors.New("two-factor authentication is already enabled")
+	// ErrNotEnrolled the user has no authenticator app, or has not confirmed it
+	ErrNotEnrolled = errors.New("two-factor authentication is not enabled")
+	// ErrInvalidCode the code is wrong, expired or was used already
+	ErrInvalidCode = errors.New("invalid two-factor authentication code")
+	// ErrRequiredByOrganization an organization of the user requires two-factor
+	// authentication
+	ErrRequiredByOrganization = errors.New("two-factor authentication is required by an organization")
+)
+
+// Config of the two-factor authentication
+type Config struct {
+	// Issuer names the service in authenticator apps
+	Issuer string
+	// EncryptionKey encrypts the TOTP secrets at rest, its SHA-256 hash is the
+	// AES-256-GCM key
+	EncryptionKey []byte
+	// RecoveryCodes is the number of recovery codes handed out at once
+	RecoveryCodes int
+}
+
+// Enrollment is a TOTP secret waiting for its first code
+type Enrollment struct {
+	Secret string
+	// URI is the otpauth URI authenticator apps import, it is the payload of
+	// the QR code to show to the user
+	URI string
+}
+
+// Status of the user's two-factor authentication
+type Status struct {
+	Enabled bool
+	// RecoveryCodes is the number of unused recovery codes
+	RecoveryCodes int
+}
+
+// UseCase represent the two-factor authentication's use cases
+type UseCase interface {
+	// SecondFactorRequired reports whether the user enabled an authenticator
+	// app, which makes the use case an authx.SecondFactor
+	SecondFactorRequired(ctx context.Context, u authx.AuthUser) (bool, error)
+	// AuthMethod is authx.AuthMethodOTP
+	AuthMethod() string
+	Status(ctx context.Context, u *models.User) (*Status, error)
+	// Enroll starts enrolling an authenticator app, replacing an unconfirmed
+	// one. It returns ErrAlreadyEnrolled when one is confirmed.
+	Enroll(ctx context.Context, u *models.User) (*Enrollment, error)
+	// Confirm enables the enrolled app with its first code and returns the
+	// recovery codes, which are not retrievable later. It returns
+	// ErrNotEnrolled without an enrollment and ErrInvalidCode.
+	Confirm(ctx context.Context, u *models.User, code string) ([]string, error)
+	// Verify checks a TOTP or a recovery code of a user with an enabled app,
+	// each is accepted only once. It returns ErrNotEnrolled and
+	// ErrInvalidCode, recovery is true when a recovery code was redeemed.
+	Verify(ctx context.Context, u *models.User, code string) (recovery bool, err error)
+	// Disable removes the app and the recovery codes after verifying code. It
+	// returns ErrRequiredByOrganization while an organization of the user
+	// requires two-factor authentication.
+	Disable(ctx context.Context, u *models.User, code string) error
+	// RegenerateRecoveryCodes replaces the recovery codes after verifying code
+	RegenerateRecoveryCodes(ctx context.Context, u *models.User, code string) ([]string, error)
+}
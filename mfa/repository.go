@@ -0,0 +1,33 @@
+package mfa
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the two-factor authentication's repository contract
+type Repository interface {
+	// FindTOTP returns errorx.ErrorNotFound when the user has no factor,
+	// confirmed or not
+	FindTOTP(ctx context.Context, userID int) (*models.TOTPFactor, error)
+	// SaveTOTP replaces an unconfirmed factor of the user, it must return
+	// ErrAlreadyEnrolled when the user has a confirmed one
+	SaveTOTP(ctx context.Context, f *models.TOTPFactor) error
+	// ConfirmTOTP confirms f and replaces the user's recovery codes in one step
+	ConfirmTOTP(ctx context.Context, f *models.TOTPFactor, codes []*models.RecoveryCode) error
+	// UseTOTPStep records step as the last used one, it must return
+	// errorx.ErrTokenReused when step is not after the last used one
+	UseTOTPStep(ctx context.Context, f *models.TOTPFactor, step int64) error
+	// DeleteTOTP removes the factor and the recovery codes of the user
+	DeleteTOTP(ctx context.Context, userID int) error
+	// ReplaceRecoveryCodes deletes the user's recovery codes and saves codes
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codes []*models.RecoveryCode) error
+	// UseRecoveryCode redeems the unused code of the user with hash, it must
+	// return errorx.ErrorNotFound when there is none
+	UseRecoveryCode(ctx context.Context, userID int, hash string) error
+	CountUnusedRecoveryCodes(ctx context.Context, userID int) (int, error)
+	// ExistsRequiringOrganization reports whether the user belongs to an
+	// organization which requires two-factor authentication
+	ExistsRequiringOrganization(ctx context.Context, userID int) (bool, error)
+}
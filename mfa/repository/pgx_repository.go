@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/mfa"
+	"github.com/imtanmoy/authn/models"
+	"github.com/jackc/pgconn"
+)
+
+// execer is implemented by both dbx.DB and pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ mfa.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the mfa.Repository interface
+func NewPgxRepository(conn dbx.DB) mfa.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) FindTOTP(ctx context.Context, userID int) (*models.TOTPFactor, error) {
+	var f models.TOTPFactor
+	var confirmedAt *time.Time
+	err := repo.conn.QueryRow(ctx, "SELECT user_id, secret, confirmed_at, last_used_step, created_at, updated_at "+
+		"FROM totp_factors WHERE user_id = $1", userID).
+		Scan(&f.UserID, &f.Secret, &confirmedAt, &f.LastUsedStep, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	if confirmedAt != nil {
+		f.ConfirmedAt = *confirmedAt
+	}
+	return &f, nil
+}
+
+func (repo *pgxRepository) SaveTOTP(ctx context.Context, f *models.TOTPFactor) error {
+	err := repo.conn.QueryRow(ctx, "INSERT INTO totp_factors(user_id, secret) "+
+		"VALUES ($1,$2) "+
+		"ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = NOW() "+
+		"WHERE totp_factors.confirmed_at IS NULL "+
+		"RETURNING last_used_step, created_at, updated_at",
+		f.UserID, f.Secret).
+		Scan(&f.LastUsedStep, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return mfa.ErrAlreadyEnrolled
+		}
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) ConfirmTOTP(ctx context.Context, f *models.TOTPFactor, codes []*models.RecoveryCode) error {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	tag, err := tx.Exec(ctx, "UPDATE totp_factors SET confirmed_at = $1, last_used_step = $2, updated_at = $1 "+
+		"WHERE user_id = $3 AND secret = $4 AND confirmed_at IS NULL", now, f.LastUsedStep, f.UserID, f.Secret)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	if err := replaceRecoveryCodes(ctx, tx, f.UserID, codes); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	f.ConfirmedAt = now
+	f.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) UseTOTPStep(ctx context.Context, f *models.TOTPFactor, step int64) error {
+	tag, err := repo.conn.Exec(ctx, "UPDATE totp_factors SET last_used_step = $1 "+
+		"WHERE user_id = $2 AND last_used_step < $1", step, f.UserID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrTokenReused
+	}
+	f.LastUsedStep = step
+	return nil
+}
+
+func (repo *pgxRepository) DeleteTOTP(ctx context.Context, userID int) error {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM totp_factors WHERE user_id = $1", userID); err != nil {
+		return errorx.ErrInternalDB
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
+
+func (repo *pgxRepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codes []*models.RecoveryCode) error {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codes); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
+
+func (repo *pgxRepository) UseRecoveryCode(ctx context.Context, userID int, hash string) error {
+	tag, err := repo.conn.Exec(ctx, "UPDATE recovery_codes SET used_at = $1 "+
+		"WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL", time.Now().UTC(), userID, hash)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	return nil
+}
+
+func (repo *pgxRepository) CountUnusedRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	count := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) FROM recovery_codes "+
+		"WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, errorx.ErrInternalDB
+	}
+	return count, nil
+}
+
+func (repo *pgxRepository) ExistsRequiringOrganization(ctx context.Context, userID int) (bool, error) {
+	found := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) FROM users_organizations uo "+
+		"JOIN organizations o ON o.id = uo.organization_id "+
+		"WHERE uo.user_id = $1 AND o.require_mfa AND o.deleted_at IS NULL", userID).
+		Scan(&found)
+	if err != nil {
+		return false, errorx.ErrInternalDB
+	}
+	return found > 0, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, q execer, userID int, codes []*models.RecoveryCode) error {
+	if _, err := q.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return errorx.ErrInternalDB
+	}
+	for _, rc := range codes {
+		_, err := q.Exec(ctx, "INSERT INTO recovery_codes(user_id, code_hash) VALUES ($1,$2)", userID, rc.CodeHash)
+		if err != nil {
+			return errorx.ErrInternalDB
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/mfa"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+)
+
+var db *sql.DB
+var repo mfa.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func recoveryCodes(hashes ...string) []*models.RecoveryCode {
+	codes := make([]*models.RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		codes[i] = &models.RecoveryCode{UserID: 1, CodeHash: hash}
+	}
+	return codes
+}
+
+func TestPgxRepository_TOTP(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	_, err := repo.FindTOTP(ctx, 1)
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	f := &models.TOTPFactor{UserID: 1, Secret: "sealed-1"}
+	require.NoError(t, repo.SaveTOTP(ctx, f))
+	f = &models.TOTPFactor{UserID: 1, Secret: "sealed-2"}
+	require.NoError(t, repo.SaveTOTP(ctx, f), "an unconfirmed factor is replaced")
+
+	found, err := repo.FindTOTP(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "sealed-2", found.Secret)
+	assert.False(t, found.IsConfirmed())
+
+	stale := &models.TOTPFactor{UserID: 1, Secret: "sealed-1", LastUsedStep: 10}
+	assert.Equal(t, errorx.ErrorNotFound, repo.ConfirmTOTP(ctx, stale, nil), "a replaced secret can't be confirmed")
+
+	found.LastUsedStep = 10
+	require.NoError(t, repo.ConfirmTOTP(ctx, found, recoveryCodes("code-1", "code-2")))
+	assert.True(t, found.IsConfirmed())
+	assert.Equal(t, mfa.ErrAlreadyEnrolled, repo.SaveTOTP(ctx, &models.TOTPFactor{UserID: 1, Secret: "sealed-3"}))
+
+	assert.Equal(t, errorx.ErrTokenReused, repo.UseTOTPStep(ctx, found, 10))
+	require.NoError(t, repo.UseTOTPStep(ctx, found, 11))
+	found, err = repo.FindTOTP(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, found.IsConfirmed())
+	assert.Equal(t, int64(11), found.LastUsedStep)
+
+	count, err := repo.CountUnusedRecoveryCodes(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	require.NoError(t, repo.UseRecoveryCode(ctx, 1, "code-1"))
+	assert.Equal(t, errorx.ErrorNotFound, repo.UseRecoveryCode(ctx, 1, "code-1"), "recovery codes are single use")
+	assert.Equal(t, errorx.ErrorNotFound, repo.UseRecoveryCode(ctx, 2, "code-2"))
+	count, err = repo.CountUnusedRecoveryCodes(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, repo.ReplaceRecoveryCodes(ctx, 1, recoveryCodes("code-3", "code-4", "code-5")))
+	assert.Equal(t, errorx.ErrorNotFound, repo.UseRecoveryCode(ctx, 1, "code-2"), "replaced codes are gone")
+	count, err = repo.CountUnusedRecoveryCodes(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	require.NoError(t, repo.DeleteTOTP(ctx, 1))
+	_, err = repo.FindTOTP(ctx, 1)
+	assert.Equal(t, errorx.ErrorNotFound, err)
+	count, err = repo.CountUnusedRecoveryCodes(ctx, 1)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestPgxRepository_ExistsRequiringOrganization(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	require.NoError(t, tests.InsertTestOrgs(db, tests.FakeOrgs(2)))
+	ctx := context.Background()
+
+	required, err := repo.ExistsRequiringOrganization(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, required)
+
+	_, err = db.Exec("UPDATE organizations SET require_mfa = TRUE WHERE id = 2")
+	require.NoError(t, err)
+	required, err = repo.ExistsRequiringOrganization(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, required)
+
+	_, err = db.Exec("UPDATE organizations SET deleted_at = NOW() WHERE id = 2")
+	require.NoError(t, err)
+	required, err = repo.ExistsRequiringOrganization(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, required, "deleted organizations require nothing")
+}
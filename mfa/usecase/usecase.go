@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/mfa"
+	"github.com/imtanmoy/authn/models"
+)
+
+// recoveryCodeEncoding spells recovery codes in lowercase base32
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type useCase struct {
+	repo           mfa.Repository
+	config         *mfa.Config
+	aead           cipher.AEAD
+	contextTimeout time.Duration
+}
+
+var _ mfa.UseCase = (*useCase)(nil)
+
+// NewUseCase will create new an useCase object representation of mfa.UseCase interface
+func NewUseCase(repo mfa.Repository, config *mfa.Config, timeout time.Duration) mfa.UseCase {
+	key := sha256.Sum256(config.EncryptionKey)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &useCase{
+		repo:           repo,
+		config:         config,
+		aead:           aead,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) SecondFactorRequired(ctx context.Context, au authx.AuthUser) (bool, error) {
+	f, err := u.repo.FindTOTP(ctx, au.GetId())
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return f.IsConfirmed(), nil
+}
+
+func (u *useCase) AuthMethod() string {
+	return authx.AuthMethodOTP
+}
+
+func (u *useCase) Status(ctx context.Context, us *models.User) (*mfa.Status, error) {
+	enabled, err := u.SecondFactorRequired(ctx, us)
+	if err != nil || !enabled {
+		return &mfa.Status{}, err
+	}
+	count, err := u.repo.CountUnusedRecoveryCodes(ctx, us.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &mfa.Status{Enabled: true, RecoveryCodes: count}, nil
+}
+
+func (u *useCase) Enroll(ctx context.Context, us *models.User) (*mfa.Enrollment, error) {
+	secret, err := authx.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := u.seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.SaveTOTP(ctx, &models.TOTPFactor{UserID: us.ID, Secret: sealed}); err != nil {
+		return nil, err
+	}
+	return &mfa.Enrollment{
+		Secret: secret,
+		URI:    authx.TOTPURI(u.config.Issuer, us.Email, secret),
+	}, nil
+}
+
+func (u *useCase) Confirm(ctx context.Context, us *models.User, code string) ([]string, error) {
+	f, err := u.repo.FindTOTP(ctx, us.ID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, mfa.ErrNotEnrolled
+		}
+		return nil, err
+	}
+	if f.IsConfirmed() {
+		return nil, mfa.ErrAlreadyEnrolled
+	}
+	secret, err := u.open(f.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := authx.ValidateTOTP(secret, code, time.Now(), f.LastUsedStep)
+	if !ok {
+		return nil, mfa.ErrInvalidCode
+	}
+	f.LastUsedStep = step
+	codes, hashed, err := u.generateRecoveryCodes(us.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.ConfirmTOTP(ctx, f, hashed); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, mfa.ErrNotEnrolled
+		}
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (u *useCase) Verify(ctx context.Context, us *models.User, code string) (bool, error) {
+	f, err := u.repo.FindTOTP(ctx, us.ID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return false, mfa.ErrNotEnrolled
+		}
+		return false, err
+	}
+	if !f.IsConfirmed() {
+		return false, mfa.ErrNotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != authx.TOTPDigits {
+		err := u.repo.UseRecoveryCode(ctx, us.ID, hashRecoveryCode(code))
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return false, mfa.ErrInvalidCode
+		}
+		return err == nil, err
+	}
+	secret, err := u.open(f.Secret)
+	if err != nil {
+		return false, err
+	}
+	step, ok := authx.ValidateTOTP(secret, code, time.Now(), f.LastUsedStep)
+	if !ok {
+		return false, mfa.ErrInvalidCode
+	}
+	if err := u.repo.UseTOTPStep(ctx, f, step); err != nil {
+		if errors.Is(err, errorx.ErrTokenReused) {
+			return false, mfa.ErrInvalidCode
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+func (u *useCase) Disable(ctx context.Context, us *models.User, code string) error {
+	required, err := u.repo.ExistsRequiringOrganization(ctx, us.ID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return mfa.ErrRequiredByOrganization
+	}
+	if _, err := u.Verify(ctx, us, code); err != nil {
+		return err
+	}
+	return u.repo.DeleteTOTP(ctx, us.ID)
+}
+
+func (u *useCase) RegenerateRecoveryCodes(ctx context.Context, us *models.User, code string) ([]string, error) {
+	if _, err := u.Verify(ctx, us, code); err != nil {
+		return nil, err
+	}
+	codes, hashed, err := u.generateRecoveryCodes(us.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.repo.ReplaceRecoveryCodes(ctx, us.ID, hashed); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCodes returns Config.RecoveryCodes codes of 50 random bits,
+// spelled as two groups of five characters, and their hashed records
+func (u *useCase) generateRecoveryCodes(userID int) ([]string, []*models.RecoveryCode, error) {
+	codes := make([]string, u.config.RecoveryCodes)
+	hashed := make([]*models.RecoveryCode, u.config.RecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashed[i] = &models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+	return codes, hashed, nil
+}
+
+// hashRecoveryCode ignores case and the separators users may type
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// seal encrypts a TOTP secret for storage
+func (u *useCase) seal(secret string) (string, error) {
+	nonce := make([]byte, u.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(u.aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// open decrypts a TOTP secret sealed with the same key
+func (u *useCase) open(sealed string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < u.aead.NonceSize() {
+		return "", errorx.ErrInternalServer
+	}
+	secret, err := u.aead.Open(nil, b[:u.aead.NonceSize()], b[u.aead.NonceSize():], nil)
+	if err != nil {
+		return "", errorx.ErrInternalServer
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailConfirmation represent email_confirmations table, only the SHA-256
+// hash of the emailed token is stored
+type EmailConfirmation struct {
+	ID     int
+	UserID int
+	// Email is the address the token was sent to, it only confirms the user
+	// while that is still the user's address
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the token was already redeemed or superseded
+func (ec *EmailConfirmation) IsUsed() bool {
+	return !ec.UsedAt.IsZero()
+}
+
+// IsExpired reports whether the token is past its expiry
+func (ec *EmailConfirmation) IsExpired() bool {
+	return time.Now().After(ec.ExpiresAt)
+}
@@ -4,16 +4,36 @@ import (
 	"time"
 )
 
-// Invitation represent invites table
+// The statuses of an invitation, it starts pending and is either accepted,
+// which makes it successful, or canceled
+const (
+	InvitationPending    = "pending"
+	InvitationSuccessful = "successful"
+	InvitationCanceled   = "canceled"
+)
+
+// Invitation represent invites table, only the SHA-256 hash of the emailed
+// token is stored
 type Invitation struct {
 	ID             int
 	Email          string
-	Token          string
+	TokenHash      string
 	Status         string
 	OrganizationId int
 	UserId         int
 	InvitedBy      int
+	ExpiresAt      time.Time
 	AcceptedAt     time.Time
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
+
+// IsPending reports whether the invitation was neither accepted nor canceled
+func (inv *Invitation) IsPending() bool {
+	return inv.Status == InvitationPending
+}
+
+// IsExpired reports whether the token is past its expiry
+func (inv *Invitation) IsExpired() bool {
+	return time.Now().After(inv.ExpiresAt)
+}
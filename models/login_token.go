@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginToken represent login_tokens table, the magic links and codes of
+// passwordless logins. Only the SHA-256 hash of the emailed token or code is
+// stored. Tokens belong to an email address rather than a user, so the
+// first login can create the account.
+type LoginToken struct {
+	ID        int
+	Email     string
+	Kind      string
+	TokenHash string
+	// Attempts counts the verifications of a code, right or wrong
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the token was already redeemed or superseded
+func (lt *LoginToken) IsUsed() bool {
+	return !lt.UsedAt.IsZero()
+}
+
+// IsExpired reports whether the token is past its expiry
+func (lt *LoginToken) IsExpired() bool {
+	return time.Now().After(lt.ExpiresAt)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// TOTPFactor represent totp_factors table, the authenticator app enrolled by
+// a user. Secret is encrypted at rest.
+type TOTPFactor struct {
+	UserID int
+	Secret string
+	// ConfirmedAt is zero until the user proved the app works with a code
+	ConfirmedAt time.Time
+	// LastUsedStep is the time step of the last accepted code, codes of it
+	// and earlier steps are refused so a code can't be replayed
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsConfirmed reports whether the factor is in use
+func (f *TOTPFactor) IsConfirmed() bool {
+	return !f.ConfirmedAt.IsZero()
+}
+
+// RecoveryCode represent recovery_codes table, only the SHA-256 hash of the
+// code is stored
+type RecoveryCode struct {
+	ID        int
+	UserID    int
+	CodeHash  string
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the code was already redeemed
+func (rc *RecoveryCode) IsUsed() bool {
+	return !rc.UsedAt.IsZero()
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuthClient represent oauth_clients table. Public clients, like SPAs and
+// mobile apps, can't keep a secret and have none.
+type OAuthClient struct {
+	ID           int
+	ClientID     string
+	SecretHash   string
+	Name         string
+	Public       bool
+	RedirectURIs []string
+	// Scopes the client may ask for
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+}
+
+// HasRedirectURI reports whether uri is registered, it must match exactly
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthAuthorizationCode represent oauth_authorization_codes table, only the
+// SHA-256 hash of the code is stored
+type OAuthAuthorizationCode struct {
+	ID          int
+	CodeHash    string
+	ClientID    string
+	UserID      int
+	RedirectURI string
+	Scope       string
+	// CodeChallenge is the PKCE challenge of the client, the token request
+	// must present its verifier
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// AuthMethods of the login which approved the authorization, carried
+	// over to the tokens
+	AuthMethods []string
+	ExpiresAt   time.Time
+	UsedAt      time.Time
+	CreatedAt   time.Time
+}
+
+// IsExpired reports whether the code is past its expiry
+func (ac *OAuthAuthorizationCode) IsExpired() bool {
+	return time.Now().After(ac.ExpiresAt)
+}
+
+// OAuthConsent represent oauth_consents table, the scopes a user granted to
+// a client
+type OAuthConsent struct {
+	ID       int
+	UserID   int
+	ClientID string
+	// ClientName is joined from oauth_clients
+	ClientName string
+	Scopes     []string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Covers reports whether every scope was granted
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	for _, s := range scopes {
+		granted := false
+		for _, g := range c.Scopes {
+			if g == s {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
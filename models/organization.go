@@ -6,11 +6,19 @@ import (
 
 // Organization represent organizations table
 type Organization struct {
-	ID        int
-	Name      string
-	OwnerID   int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt time.Time
-	Users     []*User
+	ID                  int
+	Name                string
+	OwnerID             int
+	PendingOwnerID      int // member nominated as the new owner, 0 without a pending transfer
+	TransferRequestedAt time.Time
+	RequireMFA          bool // members must sign in with a second factor
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletedAt           time.Time
+	Users               []*User
+}
+
+// IsDeleted reports whether the organization was soft deleted
+func (o *Organization) IsDeleted() bool {
+	return !o.DeletedAt.IsZero()
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// Passkey represent webauthn_credentials table, a WebAuthn credential
+// registered by a user
+type Passkey struct {
+	ID     int
+	UserID int
+	// Name is chosen by the user to tell the passkeys apart
+	Name         string
+	CredentialID []byte
+	// PublicKey is the COSE encoded public key of the credential
+	PublicKey []byte
+	// SignCount is the signature counter of the last assertion, zero for
+	// authenticators without one
+	SignCount  uint32
+	AAGUID     []byte
+	Transports []string
+	// BackupEligible marks multi-device passkeys which may be synced
+	BackupEligible bool
+	BackupState    bool
+	LastUsedAt     time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// WebAuthnChallenge represent webauthn_challenges table, a challenge handed
+// out to start a ceremony. It is consumed by the response.
+type WebAuthnChallenge struct {
+	ID        int
+	Challenge []byte
+	// UserID is zero for passwordless logins, the user is not known yet
+	UserID    int
+	Ceremony  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// IsExpired reports whether the ceremony took too long
+func (c *WebAuthnChallenge) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordReset represent password_resets table, only the SHA-256 hash of
+// the emailed token is stored
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the token was already redeemed or superseded
+func (pr *PasswordReset) IsUsed() bool {
+	return !pr.UsedAt.IsZero()
+}
+
+// IsExpired reports whether the token is past its expiry
+func (pr *PasswordReset) IsExpired() bool {
+	return time.Now().After(pr.ExpiresAt)
+}
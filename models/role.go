@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Built-in roles every organization has
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Role represent organization_roles table, built-in roles are not stored
+type Role struct {
+	ID             int
+	OrganizationID int
+	Name           string
+	Permissions    []string
+	Builtin        bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Can reports whether the role grants permission
+func (r *Role) Can(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
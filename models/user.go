@@ -6,13 +6,14 @@ import (
 
 // User represent users table
 type User struct {
-	ID        int
-	Name      string
-	Email     string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt time.Time
+	ID              int
+	Name            string
+	Email           string
+	Password        string
+	EmailVerifiedAt time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       time.Time
 }
 
 func (u *User) GetEmail() (email string) {
@@ -30,3 +31,8 @@ func (u *User) PutPassword(password string) {
 func (u *User) GetId() (id int) {
 	return u.ID
 }
+
+// IsEmailVerified reports whether the user confirmed the email address
+func (u *User) IsEmailVerified() bool {
+	return !u.EmailVerifiedAt.IsZero()
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type UserOrganization struct {
 	tableName struct{}
 
@@ -7,4 +9,6 @@ type UserOrganization struct {
 	User           *User
 	OrganizationId int
 	Organization   *Organization
+	Role           string
+	CreatedAt      time.Time
 }
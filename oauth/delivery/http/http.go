@@ -0,0 +1,437 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/oauth"
+	"github.com/imtanmoy/authn/user"
+	"github.com/imtanmoy/httpx"
+)
+
+// oauthError is the error response format of RFC 6749 section 5.2
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func responseOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="authn"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httpx.ResponseJSON(w, status, &oauthError{Error: code, ErrorDescription: description})
+}
+
+// authorizePayload is an authorization request the user answers. Approve is
+// left out to ask whether the user has to consent first.
+type authorizePayload struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             *bool  `json:"approve"`
+}
+
+func (ap *authorizePayload) request() *oauth.AuthorizationRequest {
+	return &oauth.AuthorizationRequest{
+		ResponseType:        ap.ResponseType,
+		ClientID:            ap.ClientID,
+		RedirectURI:         ap.RedirectURI,
+		Scope:               ap.Scope,
+		State:               ap.State,
+		CodeChallenge:       ap.CodeChallenge,
+		CodeChallengeMethod: ap.CodeChallengeMethod,
+	}
+}
+
+type clientResponse struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+}
+
+// consentRequiredResponse asks the consent page to show the client and the
+// scopes and to send the request again with approve set
+type consentRequiredResponse struct {
+	ConsentRequired bool            `json:"consent_required"`
+	Client          *clientResponse `json:"client"`
+	Scopes          []string        `json:"scopes"`
+}
+
+// redirectResponse tells the consent page where to send the browser back to
+// the client, with the code or the error
+type redirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// tokenResponse is the access token response of RFC 6749 section 5.1
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type consentResponse struct {
+	Client    *clientResponse `json:"client"`
+	Scopes    []string        `json:"scopes"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// oauthHandler represent the http handler for the oauth endpoints
+type oauthHandler struct {
+	useCase     oauth.UseCase
+	userUseCase user.UseCase
+	*authx.Authx
+	consentURL string
+}
+
+// clientCredentials reads the client credentials from the Authorization
+// header or, as a fallback, from the form body (RFC 6749 section 2.3.1)
+func clientCredentials(r *http.Request) (string, string) {
+	if clientID, secret, ok := r.BasicAuth(); ok {
+		return clientID, secret
+	}
+	return r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+}
+
+// Introspect implements RFC 7662 for resource servers holding client credentials
+func (handler *oauthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := r.ParseForm(); err != nil {
+		responseOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed request body")
+		return
+	}
+	clientID, secret := clientCredentials(r)
+	_, err := handler.useCase.AuthenticateClient(ctx, clientID, secret)
+	if err != nil {
+		if errors.Is(err, errorx.ErrUnauthorized) {
+			responseOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+		panic(err)
+	}
+	token := r.PostFormValue("token")
+	if token == "" {
+		responseOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+	info, err := handler.Authx.Introspect(ctx, token, r.PostFormValue("token_type_hint"))
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httpx.ResponseJSON(w, http.StatusOK, info)
+}
+
+// Authorize is the authorization endpoint browsers are sent to by clients.
+// Valid requests are passed on to the consent page, which logs the user in
+// and answers them at AuthorizeDecision.
+func (handler *oauthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := &oauth.AuthorizationRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+	if _, _, err := handler.useCase.ValidateAuthorization(r.Context(), req); err != nil {
+		var oe *oauth.Error
+		if errors.As(err, &oe) {
+			http.Redirect(w, r, errorRedirect(req, oe), http.StatusFound)
+			return
+		}
+		handler.authorizationError(w, err)
+		return
+	}
+	http.Redirect(w, r, handler.consentURL+"?"+r.URL.RawQuery, http.StatusFound)
+}
+
+// AuthorizeDecision answers an authorization request for the current user.
+// Clients the user consented to before get a code right away, others only
+// once approve is set.
+func (handler *oauthHandler) AuthorizeDecision(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &authorizePayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	u := handler.currentUser(r)
+	claims, err := handler.GetCurrentClaims(r)
+	if err != nil {
+		panic(err)
+	}
+	if err := handler.CheckEmailVerified(u); err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "email address is not verified", err)
+		return
+	}
+
+	req := data.request()
+	client, scopes, err := handler.useCase.ValidateAuthorization(ctx, req)
+	if err != nil {
+		var oe *oauth.Error
+		if errors.As(err, &oe) {
+			httpx.ResponseJSON(w, http.StatusOK, &redirectResponse{RedirectTo: errorRedirect(req, oe)})
+			return
+		}
+		handler.authorizationError(w, err)
+		return
+	}
+	if data.Approve != nil && !*data.Approve {
+		oe := &oauth.Error{Code: "access_denied", Description: "the user denied the authorization"}
+		httpx.ResponseJSON(w, http.StatusOK, &redirectResponse{RedirectTo: errorRedirect(req, oe)})
+		return
+	}
+	if data.Approve == nil {
+		consented, err := handler.useCase.HasConsent(ctx, u, client, scopes)
+		if err != nil {
+			panic(err)
+		}
+		if !consented {
+			httpx.ResponseJSON(w, http.StatusOK, &consentRequiredResponse{
+				ConsentRequired: true,
+				Client:          &clientResponse{ClientID: client.ClientID, Name: client.Name},
+				Scopes:          scopes,
+			})
+			return
+		}
+	}
+
+	code, err := handler.useCase.Authorize(ctx, u, req, scopes, claims.AuthMethods)
+	if err != nil {
+		panic(err)
+	}
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, &redirectResponse{RedirectTo: withQuery(req.RedirectURI, params)})
+}
+
+// Token is the token endpoint, it redeems authorization codes and refresh
+// tokens. Confidential clients authenticate, public clients only name
+// themselves.
+func (handler *oauthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := r.ParseForm(); err != nil {
+		responseOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed request body")
+		return
+	}
+	client, ok := handler.tokenClient(w, r)
+	if !ok {
+		return
+	}
+
+	var pair *authx.TokenPair
+	scope := ""
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		ac, err := handler.useCase.ExchangeCode(ctx, client, r.PostFormValue("code"),
+			r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+		if err != nil {
+			if errors.Is(err, oauth.ErrInvalidGrant) {
+				responseOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid or expired authorization code")
+				return
+			}
+			panic(err)
+		}
+		u, err := handler.userUseCase.FindByID(ctx, ac.UserID)
+		if err != nil {
+			if errors.Is(err, errorx.ErrorNotFound) {
+				responseOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid or expired authorization code")
+				return
+			}
+			panic(err)
+		}
+		pair, err = handler.GenerateTokenPair(ctx, u, authx.WithAuthMethods(ac.AuthMethods...),
+			authx.WithScope(ac.Scope), authx.WithClientID(client.ClientID))
+		if err != nil {
+			panic(err)
+		}
+		scope = ac.Scope
+	case "refresh_token":
+		var err error
+		pair, err = handler.RefreshClientTokenPair(ctx, client.ClientID, r.PostFormValue("refresh_token"))
+		if err != nil {
+			if errors.Is(err, errorx.ErrInvalidToken) ||
+				errors.Is(err, errorx.ErrTokenExpired) ||
+				errors.Is(err, errorx.ErrTokenReused) {
+				responseOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+				return
+			}
+			panic(err)
+		}
+	case "":
+		responseOAuthError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		responseOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code and refresh_token are supported")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	httpx.ResponseJSON(w, http.StatusOK, &tokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    pair.ExpiresIn,
+		RefreshToken: pair.RefreshToken,
+		Scope:        scope,
+	})
+}
+
+// Consents lists the clients the current user granted access to
+func (handler *oauthHandler) Consents(w http.ResponseWriter, r *http.Request) {
+	consents, err := handler.useCase.FindConsents(r.Context(), handler.currentUser(r))
+	if err != nil {
+		panic(err)
+	}
+	res := make([]*consentResponse, len(consents))
+	for i, c := range consents {
+		res[i] = &consentResponse{
+			Client:    &clientResponse{ClientID: c.ClientID, Name: c.ClientName},
+			Scopes:    c.Scopes,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+		}
+	}
+	httpx.ResponseJSON(w, http.StatusOK, res)
+}
+
+// RevokeConsent forgets the consent to a client, it has to ask the user again
+func (handler *oauthHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	err := handler.useCase.RevokeConsent(r.Context(), handler.currentUser(r), chi.URLParam(r, "clientID"))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "consent not found", err)
+			return
+		}
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// tokenClient authenticates the client of a token request, answering 401 to
+// wrong credentials. Public clients must not send a secret.
+func (handler *oauthHandler) tokenClient(w http.ResponseWriter, r *http.Request) (*models.OAuthClient, bool) {
+	clientID, secret := clientCredentials(r)
+	var client *models.OAuthClient
+	var err error
+	if secret == "" {
+		client, err = handler.useCase.FindClient(r.Context(), clientID)
+		if err == nil && !client.Public {
+			err = errorx.ErrUnauthorized
+		}
+	} else {
+		client, err = handler.useCase.AuthenticateClient(r.Context(), clientID, secret)
+	}
+	if err != nil {
+		if errors.Is(err, oauth.ErrInvalidClient) || errors.Is(err, errorx.ErrUnauthorized) {
+			responseOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return nil, false
+		}
+		panic(err)
+	}
+	return client, true
+}
+
+// authorizationError answers requests which can't be redirected to the
+// client, RFC 6749 section 4.1.2.1
+func (handler *oauthHandler) authorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, oauth.ErrInvalidClient):
+		responseOAuthError(w, http.StatusBadRequest, "invalid_request", "unknown client_id")
+	case errors.Is(err, oauth.ErrInvalidRedirectURI):
+		responseOAuthError(w, http.StatusBadRequest, "invalid_request", "redirect_uri is not registered for the client")
+	default:
+		panic(err)
+	}
+}
+
+func (handler *oauthHandler) currentUser(r *http.Request) *models.User {
+	au, err := handler.GetCurrentUser(r)
+	u, ok := au.(*models.User)
+	if err != nil || !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	return u
+}
+
+// errorRedirect reports oe to the redirect URI of req, RFC 6749 section
+// 4.1.2.1
+func errorRedirect(req *oauth.AuthorizationRequest, oe *oauth.Error) string {
+	params := url.Values{"error": {oe.Code}, "error_description": {oe.Description}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return withQuery(req.RedirectURI, params)
+}
+
+// withQuery adds params to the query of the registered redirect URI, which
+// may have one already
+func withQuery(uri string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + params.Encode()
+}
+
+// NewHandler will initialize the oauth endpoints. Valid authorization
+// requests are passed on to consentURL.
+func NewHandler(
+	r *chi.Mux,
+	aux *authx.Authx,
+	useCase oauth.UseCase,
+	userUseCase user.UseCase,
+	consentURL string,
+) {
+	handler := &oauthHandler{
+		useCase:     useCase,
+		userUseCase: userUseCase,
+		Authx:       aux,
+		consentURL:  consentURL,
+	}
+	r.Route("/oauth", func(r chi.Router) {
+		r.Post("/introspect", handler.Introspect)
+		r.Get("/authorize", handler.Authorize)
+		r.With(handler.AuthMiddleware).Post("/authorize", handler.AuthorizeDecision)
+		r.Post("/token", handler.Token)
+	})
+	r.Route("/me/consents", func(r chi.Router) {
+		r.Use(handler.AuthMiddleware)
+		r.Get("/", handler.Consents)
+		r.Delete("/{clientID}", handler.RevokeConsent)
+	})
+}
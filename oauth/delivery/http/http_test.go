@@ -0,0 +1,328 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/oauth"
+	_oauthRepo "github.com/imtanmoy/authn/oauth/repository"
+	_oauthUseCase "github.com/imtanmoy/authn/oauth/usecase"
+	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	_userUseCase "github.com/imtanmoy/authn/user/usecase"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const consentURL = "http://localhost:3000/oauth/consent"
+
+var (
+	r       = chi.NewRouter()
+	db      *sql.DB
+	aux     *authx.Authx
+	useCase oauth.UseCase
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userRepo := _userRepo.NewPgxRepository(conn)
+	tokenRepo := _tokenRepo.NewPgxRepository(conn)
+	aux = authx.New(userRepo, &authx.AuthxConfig{
+		SecretKey:              "test",
+		AccessTokenExpireTime:  1,
+		RefreshTokenExpireTime: 5,
+	}, authx.WithRefreshTokenRepo(tokenRepo), authx.WithRevocationRepo(tokenRepo))
+	useCase = _oauthUseCase.NewUseCase(_oauthRepo.NewPgxRepository(conn), &oauth.Config{
+		Scopes: []string{"profile", "email"},
+	}, 30*time.Second)
+	NewHandler(r, aux, useCase, _userUseCase.NewUseCase(userRepo, 30*time.Second), consentURL)
+}
+
+func TestOAuthHandler_Introspect(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tests.SeedUser(db)
+	client := &models.OAuthClient{Name: "resource server"}
+	secret, err := useCase.CreateClient(ctx, client)
+	require.NoError(t, err)
+
+	token, err := aux.GenerateToken("test@test.com")
+	require.NoError(t, err)
+
+	introspect := func(clientSecret string, token string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest("POST", ts.URL+"/oauth/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(client.ClientID, clientSecret)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		return res, body
+	}
+
+	t.Run("active token", func(t *testing.T) {
+		res, body := introspect(secret, token)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "test@test.com", body["sub"])
+		assert.NotNil(t, body["exp"])
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		res, body := introspect(secret, "unknown")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, map[string]interface{}{"active": false}, body)
+	})
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		res, body := introspect("wrong", token)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, "invalid_client", body["error"])
+	})
+}
+
+const redirectURI = "https://app.test/callback"
+
+// pkce returns a code verifier and its S256 challenge
+func pkce(t *testing.T) (string, string) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorize(t *testing.T, token string, payload *authorizePayload) (int, map[string]interface{}) {
+	b, err := json.Marshal(payload)
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/oauth/authorize", bytes.NewReader(b))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return w.Code, body
+}
+
+// redirectQuery parses the query the client receives at its redirect URI
+func redirectQuery(t *testing.T, body map[string]interface{}) url.Values {
+	to, ok := body["redirect_to"].(string)
+	require.True(t, ok, body)
+	u, err := url.Parse(to)
+	require.NoError(t, err)
+	assert.Equal(t, redirectURI, u.Scheme+"://"+u.Host+u.Path)
+	return u.Query()
+}
+
+func tokenRequest(t *testing.T, clientID, secret string, form url.Values) (int, map[string]interface{}) {
+	if secret == "" {
+		form.Set("client_id", clientID)
+	}
+	req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		req.SetBasicAuth(clientID, secret)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return w.Code, body
+}
+
+func TestOAuthHandler_AuthorizationCode(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	app := &models.OAuthClient{Name: "App", Public: true, RedirectURIs: []string{redirectURI}, Scopes: []string{"profile", "email"}}
+	_, err := useCase.CreateClient(ctx, app)
+	require.NoError(t, err)
+	token, err := aux.GenerateToken("test@test.com")
+	require.NoError(t, err)
+	verifier, challenge := pkce(t)
+	request := func() *authorizePayload {
+		return &authorizePayload{ResponseType: "code", ClientID: app.ClientID, RedirectURI: redirectURI,
+			Scope: "profile", State: "xyz", CodeChallenge: challenge, CodeChallengeMethod: oauth.CodeChallengeS256}
+	}
+	approve := func(p *authorizePayload, approved bool) *authorizePayload {
+		p.Approve = &approved
+		return p
+	}
+
+	t.Run("Authorize redirects", func(t *testing.T) {
+		q := url.Values{"response_type": {"code"}, "client_id": {app.ClientID}, "redirect_uri": {redirectURI},
+			"state": {"xyz"}, "code_challenge": {challenge}, "code_challenge_method": {"S256"}}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/oauth/authorize?"+q.Encode(), nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, consentURL+"?"+q.Encode(), w.Header().Get("Location"))
+
+		q.Del("code_challenge")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/oauth/authorize?"+q.Encode(), nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, w.Header().Get("Location"), redirectURI+"?error=invalid_request")
+
+		q.Set("redirect_uri", "https://evil.test/callback")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/oauth/authorize?"+q.Encode(), nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "unregistered redirect URIs are never redirected to")
+	})
+
+	t.Run("Consent", func(t *testing.T) {
+		code, body := authorize(t, token, request())
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, true, body["consent_required"])
+		assert.Equal(t, []interface{}{"profile"}, body["scopes"])
+
+		_, body = authorize(t, token, approve(request(), false))
+		q := redirectQuery(t, body)
+		assert.Equal(t, "access_denied", q.Get("error"))
+		assert.Equal(t, "xyz", q.Get("state"))
+
+		p := request()
+		p.Scope = "admin"
+		_, body = authorize(t, token, p)
+		assert.Equal(t, "invalid_scope", redirectQuery(t, body).Get("error"))
+	})
+
+	var refreshToken string
+	t.Run("Code exchange", func(t *testing.T) {
+		_, body := authorize(t, token, approve(request(), true))
+		q := redirectQuery(t, body)
+		assert.Equal(t, "xyz", q.Get("state"))
+		code := q.Get("code")
+		require.NotEmpty(t, code)
+
+		exchange := func(redirect, verifier string) (int, map[string]interface{}) {
+			return tokenRequest(t, app.ClientID, "", url.Values{"grant_type": {"authorization_code"}, "code": {code},
+				"redirect_uri": {redirect}, "code_verifier": {verifier}})
+		}
+		status, body := exchange(redirectURI, strings.Repeat("a", 43))
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", body["error"], "a wrong verifier spends the code")
+		status, _ = exchange(redirectURI, verifier)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		_, body = authorize(t, token, request())
+		assert.Nil(t, body["consent_required"], "the consent is remembered")
+		code = redirectQuery(t, body).Get("code")
+		status, _ = exchange("https://app.test/other", verifier)
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		_, body = authorize(t, token, request())
+		code = redirectQuery(t, body).Get("code")
+		status, body = exchange(redirectURI, verifier)
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Equal(t, "Bearer", body["token_type"])
+		assert.Equal(t, "profile", body["scope"])
+		refreshToken, _ = body["refresh_token"].(string)
+		require.NotEmpty(t, refreshToken)
+
+		info, err := aux.Introspect(ctx, body["access_token"].(string), "")
+		require.NoError(t, err)
+		assert.Equal(t, app.ClientID, info.ClientID)
+		assert.Equal(t, "profile", info.Scope)
+		status, _ = exchange(redirectURI, verifier)
+		assert.Equal(t, http.StatusBadRequest, status, "codes are single use")
+
+		req := httptest.NewRequest("GET", "/me/consents", nil)
+		req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "client tokens can't call first party endpoints")
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		_, err := aux.RefreshTokenPair(ctx, refreshToken)
+		assert.Equal(t, errorx.ErrInvalidToken, err, "client tokens are refreshed by the client only")
+
+		status, body := tokenRequest(t, app.ClientID, "", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+		require.Equal(t, http.StatusOK, status, body)
+		assert.NotEqual(t, refreshToken, body["refresh_token"])
+		info, err := aux.Introspect(ctx, body["access_token"].(string), "")
+		require.NoError(t, err)
+		assert.Equal(t, "profile", info.Scope)
+
+		status, body = tokenRequest(t, app.ClientID, "", url.Values{"grant_type": {"password"}})
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "unsupported_grant_type", body["error"])
+	})
+
+	t.Run("Confidential client", func(t *testing.T) {
+		server := &models.OAuthClient{Name: "Server", RedirectURIs: []string{redirectURI}, Scopes: []string{"email"}}
+		secret, err := useCase.CreateClient(ctx, server)
+		require.NoError(t, err)
+		status, body := tokenRequest(t, server.ClientID, "", url.Values{"grant_type": {"authorization_code"}})
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "invalid_client", body["error"], "confidential clients authenticate")
+
+		p := request()
+		p.ClientID, p.Scope = server.ClientID, ""
+		_, body = authorize(t, token, approve(p, true))
+		status, body = tokenRequest(t, server.ClientID, secret, url.Values{"grant_type": {"authorization_code"},
+			"code": {redirectQuery(t, body).Get("code")}, "redirect_uri": {redirectURI}, "code_verifier": {verifier}})
+		require.Equal(t, http.StatusOK, status, body)
+		assert.Equal(t, "email", body["scope"])
+	})
+
+	t.Run("Revoke consent", func(t *testing.T) {
+		list := func() []map[string]interface{} {
+			req := httptest.NewRequest("GET", "/me/consents", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var got []map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			return got
+		}
+		require.Len(t, list(), 2)
+		for _, status := range []int{http.StatusNoContent, http.StatusNotFound} {
+			req := httptest.NewRequest("DELETE", "/me/consents/"+app.ClientID, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code)
+		}
+		assert.Len(t, list(), 1)
+		_, body := authorize(t, token, request())
+		assert.Equal(t, true, body["consent_required"])
+	})
+}
@@ -0,0 +1,23 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the oauth client's repository contract
+type Repository interface {
+	SaveClient(ctx context.Context, c *models.OAuthClient) error
+	FindClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	SaveCode(ctx context.Context, ac *models.OAuthAuthorizationCode) error
+	// ConsumeCode marks the code with hash used and returns it, it returns
+	// errorx.ErrorNotFound for unknown codes and codes used already
+	ConsumeCode(ctx context.Context, hash string) (*models.OAuthAuthorizationCode, error)
+	FindConsent(ctx context.Context, userID int, clientID string) (*models.OAuthConsent, error)
+	// SaveConsent inserts c or replaces the scopes of the existing consent
+	// of its user to its client
+	SaveConsent(ctx context.Context, c *models.OAuthConsent) error
+	FindConsentsByUserID(ctx context.Context, userID int) ([]*models.OAuthConsent, error)
+	DeleteConsent(ctx context.Context, userID int, clientID string) error
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/oauth"
+	"github.com/jackc/pgconn"
+)
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ oauth.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the oauth.Repository interface
+func NewPgxRepository(conn dbx.DB) oauth.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) SaveClient(ctx context.Context, c *models.OAuthClient) error {
+	if c.RedirectURIs == nil {
+		c.RedirectURIs = []string{}
+	}
+	if c.Scopes == nil {
+		c.Scopes = []string{}
+	}
+	var createdAt time.Time
+	var updatedAt time.Time
+	err := repo.conn.QueryRow(ctx, "INSERT INTO oauth_clients(client_id, secret_hash, name, public, redirect_uris, scopes) "+
+		"VALUES ($1,$2,$3,$4,$5,$6) "+
+		"RETURNING id, created_at, updated_at",
+		c.ClientID, c.SecretHash, c.Name, c.Public, c.RedirectURIs, c.Scopes).
+		Scan(&c.ID, &createdAt, &updatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	c.CreatedAt = createdAt
+	c.UpdatedAt = updatedAt
+	return nil
+}
+
+func (repo *pgxRepository) FindClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var c models.OAuthClient
+	err := repo.conn.QueryRow(ctx, "SELECT id, client_id, secret_hash, name, public, redirect_uris, scopes, "+
+		"created_at, updated_at FROM oauth_clients WHERE client_id = $1 "+
+		"AND deleted_at IS NULL", clientID).
+		Scan(&c.ID, &c.ClientID, &c.SecretHash, &c.Name, &c.Public, &c.RedirectURIs, &c.Scopes,
+			&c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (repo *pgxRepository) SaveCode(ctx context.Context, ac *models.OAuthAuthorizationCode) error {
+	if _, err := repo.conn.Exec(ctx, "DELETE FROM oauth_authorization_codes WHERE expires_at < $1", time.Now().UTC()); err != nil {
+		return errorx.ErrInternalDB
+	}
+	methods := ac.AuthMethods
+	if methods == nil {
+		methods = []string{}
+	}
+	err := repo.conn.QueryRow(ctx, "INSERT INTO oauth_authorization_codes(code_hash, client_id, user_id, "+
+		"redirect_uri, scope, code_challenge, code_challenge_method, auth_methods, expires_at) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) "+
+		"RETURNING id, created_at",
+		ac.CodeHash, ac.ClientID, ac.UserID, ac.RedirectURI, ac.Scope, ac.CodeChallenge, ac.CodeChallengeMethod,
+		methods, ac.ExpiresAt).
+		Scan(&ac.ID, &ac.CreatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) ConsumeCode(ctx context.Context, hash string) (*models.OAuthAuthorizationCode, error) {
+	var ac models.OAuthAuthorizationCode
+	err := repo.conn.QueryRow(ctx, "UPDATE oauth_authorization_codes SET used_at = $1 "+
+		"WHERE code_hash = $2 AND used_at IS NULL "+
+		"RETURNING id, code_hash, client_id, user_id, redirect_uri, scope, code_challenge, code_challenge_method, "+
+		"auth_methods, expires_at, used_at, created_at", time.Now().UTC(), hash).
+		Scan(&ac.ID, &ac.CodeHash, &ac.ClientID, &ac.UserID, &ac.RedirectURI, &ac.Scope, &ac.CodeChallenge,
+			&ac.CodeChallengeMethod, &ac.AuthMethods, &ac.ExpiresAt, &ac.UsedAt, &ac.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return &ac, nil
+}
+
+func (repo *pgxRepository) FindConsent(ctx context.Context, userID int, clientID string) (*models.OAuthConsent, error) {
+	var c models.OAuthConsent
+	err := repo.conn.QueryRow(ctx, "SELECT oc.id, oc.user_id, oc.client_id, cl.name, oc.scopes, oc.created_at, oc.updated_at "+
+		"FROM oauth_consents oc JOIN oauth_clients cl ON cl.client_id = oc.client_id "+
+		"WHERE oc.user_id = $1 AND oc.client_id = $2", userID, clientID).
+		Scan(&c.ID, &c.UserID, &c.ClientID, &c.ClientName, &c.Scopes, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return &c, nil
+}
+
+func (repo *pgxRepository) SaveConsent(ctx context.Context, c *models.OAuthConsent) error {
+	if c.Scopes == nil {
+		c.Scopes = []string{}
+	}
+	err := repo.conn.QueryRow(ctx, "INSERT INTO oauth_consents(user_id, client_id, scopes) "+
+		"VALUES ($1,$2,$3) "+
+		"ON CONFLICT (user_id, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = NOW() "+
+		"RETURNING id, created_at, updated_at",
+		c.UserID, c.ClientID, c.Scopes).
+		Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindConsentsByUserID(ctx context.Context, userID int) ([]*models.OAuthConsent, error) {
+	rows, err := repo.conn.Query(ctx, "SELECT oc.id, oc.user_id, oc.client_id, cl.name, oc.scopes, oc.created_at, oc.updated_at "+
+		"FROM oauth_consents oc JOIN oauth_clients cl ON cl.client_id = oc.client_id "+
+		"WHERE oc.user_id = $1 AND cl.deleted_at IS NULL ORDER BY oc.id", userID)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	consents := make([]*models.OAuthConsent, 0)
+	for rows.Next() {
+		var c models.OAuthConsent
+		if err := rows.Scan(&c.ID, &c.UserID, &c.ClientID, &c.ClientName, &c.Scopes, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, errorx.ErrInternalDB
+		}
+		consents = append(consents, &c)
+	}
+	return consents, rows.Err()
+}
+
+func (repo *pgxRepository) DeleteConsent(ctx context.Context, userID int, clientID string) error {
+	tag, err := repo.conn.Exec(ctx, "DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2", userID, clientID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/oauth"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var db *sql.DB
+var repo oauth.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func TestPgxRepository_Clients(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	c := &models.OAuthClient{ClientID: "client-1", SecretHash: "hash", Name: "resource server"}
+	require.NoError(t, repo.SaveClient(ctx, c))
+	assert.NotZero(t, c.ID)
+	assert.NotZero(t, c.CreatedAt)
+
+	found, err := repo.FindClientByClientID(ctx, "client-1")
+	require.NoError(t, err)
+	assert.Equal(t, c.ID, found.ID)
+	assert.Equal(t, "hash", found.SecretHash)
+	assert.False(t, found.Public)
+	assert.Equal(t, []string{}, found.RedirectURIs)
+
+	spa := &models.OAuthClient{ClientID: "client-2", Name: "spa", Public: true,
+		RedirectURIs: []string{"http://localhost:3000/callback"}, Scopes: []string{"profile", "email"}}
+	require.NoError(t, repo.SaveClient(ctx, spa))
+	found, err = repo.FindClientByClientID(ctx, "client-2")
+	require.NoError(t, err)
+	assert.True(t, found.Public)
+	assert.True(t, found.HasRedirectURI("http://localhost:3000/callback"))
+	assert.Equal(t, []string{"profile", "email"}, found.Scopes)
+
+	_, err = repo.FindClientByClientID(ctx, "unknown")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+}
+
+func TestPgxRepository_Codes(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	ac := &models.OAuthAuthorizationCode{CodeHash: "hash", ClientID: "client-1", UserID: 1,
+		RedirectURI: "http://localhost:3000/callback", Scope: "profile", CodeChallenge: "challenge",
+		CodeChallengeMethod: oauth.CodeChallengeS256, AuthMethods: []string{"pwd"},
+		ExpiresAt: time.Now().Add(time.Minute).UTC()}
+	require.NoError(t, repo.SaveCode(ctx, ac))
+	assert.NotZero(t, ac.ID)
+
+	found, err := repo.ConsumeCode(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, "client-1", found.ClientID)
+	assert.Equal(t, []string{"pwd"}, found.AuthMethods)
+	assert.False(t, found.UsedAt.IsZero())
+	_, err = repo.ConsumeCode(ctx, "hash")
+	assert.Equal(t, errorx.ErrorNotFound, err, "codes are single use")
+	_, err = repo.ConsumeCode(ctx, "unknown")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+}
+
+func TestPgxRepository_Consents(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+	require.NoError(t, repo.SaveClient(ctx, &models.OAuthClient{ClientID: "client-1", Name: "spa", Public: true}))
+
+	_, err := repo.FindConsent(ctx, 1, "client-1")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	c := &models.OAuthConsent{UserID: 1, ClientID: "client-1", Scopes: []string{"profile"}}
+	require.NoError(t, repo.SaveConsent(ctx, c))
+	require.NoError(t, repo.SaveConsent(ctx, &models.OAuthConsent{UserID: 1, ClientID: "client-1",
+		Scopes: []string{"profile", "email"}}))
+
+	found, err := repo.FindConsent(ctx, 1, "client-1")
+	require.NoError(t, err)
+	assert.Equal(t, c.ID, found.ID, "saving again updates the consent")
+	assert.Equal(t, "spa", found.ClientName)
+	assert.Equal(t, []string{"profile", "email"}, found.Scopes)
+
+	all, err := repo.FindConsentsByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	require.NoError(t, repo.DeleteConsent(ctx, 1, "client-1"))
+	assert.Equal(t, errorx.ErrorNotFound, repo.DeleteConsent(ctx, 1, "client-1"))
+}
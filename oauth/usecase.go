@@ -0,0 +1,88 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+var (
+	// ErrInvalidClient the client is unknown. Authorization requests naming
+	// it can't be answered by redirecting.
+	ErrInvalidClient = errors.New("unknown client")
+	// ErrInvalidRedirectURI the redirect URI is missing, malformed or not
+	// registered for the client. Authorization requests with it can't be
+	// answered by redirecting.
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	// ErrInvalidScope a scope is unknown or not allowed for the client
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidGrant the authorization code is unknown, expired, used
+	// already, issued to another client or its PKCE verifier does not match
+	ErrInvalidGrant = errors.New("invalid authorization grant")
+)
+
+// PKCE code challenge methods, plain is not supported
+const CodeChallengeS256 = "S256"
+
+// Config of the authorization server
+type Config struct {
+	// Scopes clients can be registered with
+	Scopes []string
+	// CodeTTL is the lifetime of authorization codes
+	CodeTTL time.Duration
+}
+
+// Error is an authorization request error which is reported to the client by
+// redirecting to its redirect URI, as in RFC 6749 section 4.1.2.1
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// AuthorizationRequest is the request of a client for an authorization code,
+// RFC 6749 section 4.1.1 with the PKCE extension of RFC 7636
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// UseCase represent the oauth client's use cases
+type UseCase interface {
+	// CreateClient registers c and returns its plain client secret, which is
+	// only ever available at creation time. Public clients get no secret.
+	CreateClient(ctx context.Context, c *models.OAuthClient) (string, error)
+	// AuthenticateClient returns errorx.ErrUnauthorized for unknown clients and
+	// wrong secrets alike. Public clients never authenticate.
+	AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error)
+	// FindClient returns ErrInvalidClient for unknown clients
+	FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	// ValidateAuthorization checks req and returns its client and the scopes
+	// asked for, all scopes of the client when req names none. Errors about
+	// the client or the redirect URI are ErrInvalidClient and
+	// ErrInvalidRedirectURI, any other is an *Error.
+	ValidateAuthorization(ctx context.Context, req *AuthorizationRequest) (*models.OAuthClient, []string, error)
+	// HasConsent reports whether u granted every scope to c before
+	HasConsent(ctx context.Context, u *models.User, c *models.OAuthClient, scopes []string) (bool, error)
+	// Authorize records the consent of u to scopes and returns an
+	// authorization code for req. authMethods are the amr of the login of u.
+	Authorize(ctx context.Context, u *models.User, req *AuthorizationRequest, scopes []string, authMethods []string) (string, error)
+	// ExchangeCode redeems an authorization code issued to c. It returns
+	// ErrInvalidGrant for codes which can't be redeemed.
+	ExchangeCode(ctx context.Context, c *models.OAuthClient, code, redirectURI, codeVerifier string) (*models.OAuthAuthorizationCode, error)
+	// FindConsents lists the clients u granted access to
+	FindConsents(ctx context.Context, u *models.User) ([]*models.OAuthConsent, error)
+	// RevokeConsent forgets the consent of u to the client, the client has to
+	// ask again. It returns errorx.ErrorNotFound when there is none.
+	RevokeConsent(ctx context.Context, u *models.User, clientID string) error
+}
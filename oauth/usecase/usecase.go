@@ -0,0 +1,266 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/oauth"
+)
+
+// defaultCodeTTL is the lifetime of authorization codes when the config sets
+// none, RFC 6749 recommends at most ten minutes
+const defaultCodeTTL = time.Minute
+
+type useCase struct {
+	repo           oauth.Repository
+	config         *oauth.Config
+	codeTTL        time.Duration
+	contextTimeout time.Duration
+}
+
+var _ oauth.UseCase = (*useCase)(nil)
+
+// NewUseCase will create new an useCase object representation of oauth.UseCase interface
+func NewUseCase(repo oauth.Repository, config *oauth.Config, timeout time.Duration) oauth.UseCase {
+	codeTTL := config.CodeTTL
+	if codeTTL <= 0 {
+		codeTTL = defaultCodeTTL
+	}
+	return &useCase{
+		repo:           repo,
+		config:         config,
+		codeTTL:        codeTTL,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) CreateClient(ctx context.Context, c *models.OAuthClient) (string, error) {
+	for _, uri := range c.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return "", oauth.ErrInvalidRedirectURI
+		}
+	}
+	for _, scope := range c.Scopes {
+		if !contains(u.config.Scopes, scope) {
+			return "", oauth.ErrInvalidScope
+		}
+	}
+	secret := ""
+	if !c.Public {
+		var err error
+		if secret, err = randomToken(); err != nil {
+			return "", err
+		}
+		c.SecretHash = hashSecret(secret)
+	}
+	c.ClientID = uuid.New().String()
+	if err := u.repo.SaveClient(ctx, c); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (u *useCase) AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	if clientID == "" || secret == "" {
+		return nil, errorx.ErrUnauthorized
+	}
+	c, err := u.repo.FindClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, errorx.ErrUnauthorized
+		}
+		return nil, err
+	}
+	if c.Public || subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return nil, errorx.ErrUnauthorized
+	}
+	return c, nil
+}
+
+func (u *useCase) FindClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oauth.ErrInvalidClient
+	}
+	c, err := u.repo.FindClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, oauth.ErrInvalidClient
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (u *useCase) ValidateAuthorization(ctx context.Context, req *oauth.AuthorizationRequest) (*models.OAuthClient, []string, error) {
+	c, err := u.FindClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !c.HasRedirectURI(req.RedirectURI) {
+		return nil, nil, oauth.ErrInvalidRedirectURI
+	}
+	if req.ResponseType != "code" {
+		return nil, nil, &oauth.Error{Code: "unsupported_response_type", Description: "only the code response type is supported"}
+	}
+	if req.CodeChallenge == "" {
+		return nil, nil, &oauth.Error{Code: "invalid_request", Description: "code_challenge is required"}
+	}
+	if req.CodeChallengeMethod != oauth.CodeChallengeS256 {
+		return nil, nil, &oauth.Error{Code: "invalid_request", Description: "code_challenge_method must be S256"}
+	}
+	if !validCodeChallenge(req.CodeChallenge) {
+		return nil, nil, &oauth.Error{Code: "invalid_request", Description: "malformed code_challenge"}
+	}
+	scopes := c.Scopes
+	if req.Scope != "" {
+		scopes = nil
+		for _, scope := range strings.Fields(req.Scope) {
+			if !contains(c.Scopes, scope) {
+				return nil, nil, &oauth.Error{Code: "invalid_scope", Description: "the client may not ask for " + scope}
+			}
+			if !contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return c, scopes, nil
+}
+
+func (u *useCase) HasConsent(ctx context.Context, us *models.User, c *models.OAuthClient, scopes []string) (bool, error) {
+	consent, err := u.repo.FindConsent(ctx, us.ID, c.ClientID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return consent.Covers(scopes), nil
+}
+
+func (u *useCase) Authorize(ctx context.Context, us *models.User, req *oauth.AuthorizationRequest, scopes []string, authMethods []string) (string, error) {
+	granted := scopes
+	consent, err := u.repo.FindConsent(ctx, us.ID, req.ClientID)
+	if err != nil && !errors.Is(err, errorx.ErrorNotFound) {
+		return "", err
+	}
+	if consent != nil {
+		granted = consent.Scopes
+		for _, scope := range scopes {
+			if !contains(granted, scope) {
+				granted = append(granted, scope)
+			}
+		}
+	}
+	if err := u.repo.SaveConsent(ctx, &models.OAuthConsent{UserID: us.ID, ClientID: req.ClientID, Scopes: granted}); err != nil {
+		return "", err
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	err = u.repo.SaveCode(ctx, &models.OAuthAuthorizationCode{
+		CodeHash:            hashSecret(code),
+		ClientID:            req.ClientID,
+		UserID:              us.ID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               strings.Join(scopes, " "),
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthMethods:         authMethods,
+		ExpiresAt:           time.Now().UTC().Add(u.codeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func (u *useCase) ExchangeCode(ctx context.Context, c *models.OAuthClient, code, redirectURI, codeVerifier string) (*models.OAuthAuthorizationCode, error) {
+	if code == "" {
+		return nil, oauth.ErrInvalidGrant
+	}
+	// the code is spent even when the request turns out wrong, a stolen code
+	// can't be tried twice
+	ac, err := u.repo.ConsumeCode(ctx, hashSecret(code))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, oauth.ErrInvalidGrant
+		}
+		return nil, err
+	}
+	if ac.ClientID != c.ClientID || ac.IsExpired() || ac.RedirectURI != redirectURI {
+		return nil, oauth.ErrInvalidGrant
+	}
+	if !verifyCodeChallenge(ac.CodeChallenge, codeVerifier) {
+		return nil, oauth.ErrInvalidGrant
+	}
+	return ac, nil
+}
+
+func (u *useCase) FindConsents(ctx context.Context, us *models.User) ([]*models.OAuthConsent, error) {
+	return u.repo.FindConsentsByUserID(ctx, us.ID)
+}
+
+func (u *useCase) RevokeConsent(ctx context.Context, us *models.User, clientID string) error {
+	return u.repo.DeleteConsent(ctx, us.ID, clientID)
+}
+
+// validRedirectURI accepts absolute URIs without a fragment, RFC 6749
+// section 3.1.2. Custom schemes of native apps are absolute too.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.IsAbs() && !strings.Contains(uri, "#")
+}
+
+// validCodeChallenge accepts the unpadded base64url SHA-256 digests of S256
+func validCodeChallenge(challenge string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(b) == sha256.Size
+}
+
+// verifyCodeChallenge checks the S256 transformation of RFC 7636 section 4.6,
+// verifiers are 43 to 128 characters long
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret uses a plain SHA-256, client secrets are random and long enough
+// that a slow password hash buys nothing
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
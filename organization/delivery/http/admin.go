@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/events"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/httpx"
+	param "github.com/oceanicdev/chi-param"
+)
+
+// AdminHandler represent the http handler for organization administration
+type AdminHandler struct {
+	useCase organization.UseCase
+	*authx.Authx
+	event events.EventEmitter
+}
+
+// Restore undoes the deletion of an organization during the retention period
+func (handler *AdminHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := param.Int(r, "id")
+	if err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+		return
+	}
+	org, err := handler.useCase.Restore(ctx, id)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "deleted organization not found", err)
+			return
+		}
+		if errors.Is(err, organization.ErrRetentionExpired) {
+			httpx.ResponseJSONError(w, r, http.StatusGone, err.Error(), err)
+			return
+		}
+		panic(err)
+	}
+	au, err := handler.GetCurrentUser(r)
+	if err != nil {
+		panic(err)
+	}
+	handler.event.Emit(ctx, events.OrganizationRestoredEvent, events.OrganizationChanged{
+		OrganizationID: org.ID,
+		Name:           org.Name,
+		OwnerID:        org.OwnerID,
+		ActorID:        au.GetId(),
+		ChangedAt:      org.UpdatedAt,
+	})
+	httpx.ResponseJSON(w, http.StatusOK, newOrgResponse(org))
+}
+
+// NewAdminHandler will initialize the organization administration endpoints
+func NewAdminHandler(r *chi.Mux, aux *authx.Authx, useCase organization.UseCase, event events.EventEmitter) {
+	handler := &AdminHandler{
+		useCase: useCase,
+		Authx:   aux,
+		event:   event,
+	}
+	r.With(handler.AuthMiddleware, handler.AdminOnly).Post("/admin/organizations/{id}/restore", handler.Restore)
+}
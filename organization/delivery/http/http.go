@@ -10,6 +10,7 @@ import (
 	"github.com/imtanmoy/authn/internal/errorx"
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/authn/user"
 	"github.com/imtanmoy/httpx"
 	param "github.com/oceanicdev/chi-param"
 	"gopkg.in/thedevsaddam/govalidator.v1"
@@ -43,16 +44,52 @@ func (rp *orgCreatePayload) validate() url.Values {
 }
 
 type orgResponse struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	OwnerId   int       `json:"owner_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	OwnerId        int       `json:"owner_id"`
+	PendingOwnerId int       `json:"pending_owner_id,omitempty"`
+	RequireMFA     bool      `json:"require_mfa"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func newOrgResponse(org *models.Organization) *orgResponse {
+	return &orgResponse{
+		ID:             org.ID,
+		Name:           org.Name,
+		OwnerId:        org.OwnerID,
+		PendingOwnerId: org.PendingOwnerID,
+		RequireMFA:     org.RequireMFA,
+		CreatedAt:      org.CreatedAt,
+		UpdatedAt:      org.UpdatedAt,
+	}
+}
+
+type passwordPolicyPayload struct {
+	authx.PasswordPolicy
+}
+
+func (pp *passwordPolicyPayload) validate() url.Values {
+	e := make(url.Values)
+	if pp.MinLength < 0 {
+		e.Add("min_length", "min_length must not be negative")
+	}
+	if pp.MaxLength < 0 {
+		e.Add("max_length", "max_length must not be negative")
+	}
+	if pp.MaxLength > 0 && pp.MaxLength < pp.MinLength {
+		e.Add("max_length", "max_length must not be lower than min_length")
+	}
+	if pp.MinScore < 0 || pp.MinScore > 4 {
+		e.Add("min_score", "min_score must be between 0 and 4")
+	}
+	return e
 }
 
 // orgHandler  represent the http handler for org
 type orgHandler struct {
-	useCase organization.UseCase
+	useCase     organization.UseCase
+	userUseCase user.UseCase
 	*authx.Authx
 	event events.EventEmitter
 }
@@ -118,14 +155,13 @@ func (handler *orgHandler) Create(w http.ResponseWriter, r *http.Request) {
 		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	err = handler.userUseCase.AddToOrganization(ctx, us, &org, models.RoleOwner)
+	if err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
-	httpx.ResponseJSON(w, http.StatusCreated, &orgResponse{
-		ID:        org.ID,
-		Name:      org.Name,
-		OwnerId:   org.OwnerID,
-		CreatedAt: org.CreatedAt,
-		UpdatedAt: org.UpdatedAt,
-	})
+	httpx.ResponseJSON(w, http.StatusCreated, newOrgResponse(&org))
 	return
 }
 
@@ -136,37 +172,218 @@ func (handler *orgHandler) Get(w http.ResponseWriter, r *http.Request) {
 		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
 		return
 	}
-	httpx.ResponseJSON(w, http.StatusOK, &orgResponse{
-		ID:        org.ID,
-		Name:      org.Name,
-		OwnerId:   org.OwnerID,
-		CreatedAt: org.CreatedAt,
-		UpdatedAt: org.UpdatedAt,
-	})
+	httpx.ResponseJSON(w, http.StatusOK, newOrgResponse(org))
 	return
 }
 
+// Update renames the organization
+func (handler *orgHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	data := &orgCreatePayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	org.Name = data.Name
+	if err := handler.useCase.Update(ctx, org); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "organization not found", err)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(r, events.OrganizationUpdatedEvent, org, org.UpdatedAt)
+	httpx.ResponseJSON(w, http.StatusOK, newOrgResponse(org))
+}
+
+// Delete soft deletes the organization, admins can restore it during the
+// retention period
+func (handler *orgHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	if err := handler.useCase.Delete(ctx, org); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "organization not found", err)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(r, events.OrganizationDeletedEvent, org, org.DeletedAt)
+	httpx.NoContent(w)
+}
+
+func (handler *orgHandler) emit(r *http.Request, topic string, org *models.Organization, at time.Time) {
+	handler.event.Emit(r.Context(), topic, events.OrganizationChanged{
+		OrganizationID: org.ID,
+		Name:           org.Name,
+		OwnerID:        org.OwnerID,
+		ActorID:        handler.currentUser(r).ID,
+		ChangedAt:      at,
+	})
+}
+
+type mfaPolicyPayload struct {
+	Required bool `json:"required"`
+}
+
+// UpdateMFAPolicy requires members to sign in with a second factor before
+// they can act in the organization. Only users signed in that way can require it.
+func (handler *orgHandler) UpdateMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	data := &mfaPolicyPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	claims, err := handler.GetCurrentClaims(r)
+	if err != nil {
+		panic(err)
+	}
+	if data.Required && !claims.HasAuthMethod(authx.AuthMethodMFA) {
+		httpx.ResponseJSONError(w, r, http.StatusConflict, "sign in with two-factor authentication before requiring it")
+		return
+	}
+	org.RequireMFA = data.Required
+	if err := handler.useCase.Update(ctx, org); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "organization not found", err)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(r, events.OrganizationUpdatedEvent, org, org.UpdatedAt)
+	httpx.ResponseJSON(w, http.StatusOK, newOrgResponse(org))
+}
+
+// GetPasswordPolicy returns the policy members' passwords must satisfy, the
+// deployment policy made stricter by the organization's own one
+func (handler *orgHandler) GetPasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	policy, err := handler.PasswordPolicy(ctx, org.ID)
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, policy)
+}
+
+// UpdatePasswordPolicy sets the organization's own policy, it can only make
+// the deployment policy stricter
+func (handler *orgHandler) UpdatePasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	data := &passwordPolicyPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	if err := handler.useCase.SavePasswordPolicy(ctx, org.ID, &data.PasswordPolicy); err != nil {
+		panic(err)
+	}
+	handler.GetPasswordPolicy(w, r)
+}
+
+// DeletePasswordPolicy falls back to the deployment policy
+func (handler *orgHandler) DeletePasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	if err := handler.useCase.SavePasswordPolicy(ctx, org.ID, nil); err != nil {
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
 // NewHandler will initialize the org's resources endpoint
 func NewHandler(
 	r *chi.Mux,
 	aux *authx.Authx,
 	useCase organization.UseCase,
+	userUseCase user.UseCase,
 	event events.EventEmitter,
 ) {
 	handler := &orgHandler{
-		useCase: useCase,
-		Authx:   aux,
-		event:   event,
+		useCase:     useCase,
+		userUseCase: userUseCase,
+		Authx:       aux,
+		event:       event,
 	}
+	r.With(handler.AuthMiddleware).Get("/me/organizations", handler.List)
 	r.Route("/organizations", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Use(handler.AuthMiddleware)
+			r.Get("/", handler.List)
 			r.Post("/", handler.Create)
+			r.Get("/permissions", handler.ListPermissions)
 			r.Group(func(r chi.Router) {
 				r.Use(handler.OrgCtx)
-				r.Get("/{id}", handler.Get)
-				//				r.Put("/{id}", handler.Update)
-				//				r.Delete("/{id}", handler.Delete)
+				r.With(handler.RequirePermission(organization.PermOrgRead)).Get("/{id}", handler.Get)
+				r.With(handler.RequirePermission(organization.PermOrgWrite)).Put("/{id}", handler.Update)
+				r.With(handler.MemberOnly).Post("/{id}/switch", handler.Switch)
+				r.With(handler.RequirePermission(organization.PermOrgDelete)).Delete("/{id}", handler.Delete)
+				r.With(handler.RequirePermission(organization.PermOrgRead)).Get("/{id}/password-policy", handler.GetPasswordPolicy)
+				r.With(handler.RequirePermission(organization.PermOrgWrite)).Put("/{id}/password-policy", handler.UpdatePasswordPolicy)
+				r.With(handler.RequirePermission(organization.PermOrgWrite)).Put("/{id}/mfa", handler.UpdateMFAPolicy)
+				r.With(handler.RequirePermission(organization.PermOrgWrite)).Delete("/{id}/password-policy", handler.DeletePasswordPolicy)
+				r.With(handler.RequirePermission(organization.PermMembersRead)).Get("/{id}/members", handler.ListMembers)
+				r.With(handler.RequirePermission(organization.PermMembersWrite)).Post("/{id}/members", handler.AddMember)
+				r.With(handler.MemberOnly).Delete("/{id}/members/me", handler.Leave)
+				r.With(handler.RequirePermission(organization.PermMembersWrite)).Delete("/{id}/members/{userID}", handler.RemoveMember)
+				r.With(handler.RequirePermission(organization.PermMembersWrite)).Put("/{id}/members/{userID}/role", handler.AssignRole)
+				r.With(handler.RequirePermission(organization.PermOrgRead)).Get("/{id}/roles", handler.ListRoles)
+				r.With(handler.RequirePermission(organization.PermRolesWrite)).Post("/{id}/roles", handler.CreateRole)
+				r.With(handler.RequirePermission(organization.PermRolesWrite)).Put("/{id}/roles/{role}", handler.UpdateRole)
+				r.With(handler.RequirePermission(organization.PermRolesWrite)).Delete("/{id}/roles/{role}", handler.DeleteRole)
+				r.With(handler.RequirePermission(organization.PermOwnersWrite)).Post("/{id}/transfer", handler.RequestTransfer)
+				r.With(handler.MemberOnly).Post("/{id}/transfer/accept", handler.AcceptTransfer)
+				r.With(handler.MemberOnly).Delete("/{id}/transfer", handler.CancelTransfer)
 			})
 		})
 	})
@@ -2,19 +2,26 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/go-chi/chi"
 	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/organization"
 	_orgRepo "github.com/imtanmoy/authn/organization/repository"
 	_orgUseCase "github.com/imtanmoy/authn/organization/usecase"
 	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	"github.com/imtanmoy/authn/user"
 	_userRepo "github.com/imtanmoy/authn/user/repository"
+	_userUseCase "github.com/imtanmoy/authn/user/usecase"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -24,10 +31,12 @@ import (
 )
 
 var (
-	r    = chi.NewRouter()
-	db   *sql.DB
-	conn *pgx.Conn
-	aux  *authx.Authx
+	r           = chi.NewRouter()
+	db          *sql.DB
+	conn        *pgx.Conn
+	aux         *authx.Authx
+	orgUseCase  organization.UseCase
+	userUseCase user.UseCase
 )
 
 func init() {
@@ -51,13 +60,16 @@ func setup() {
 	authxConfig := authx.AuthxConfig{
 		SecretKey:             "test",
 		AccessTokenExpireTime: 1,
+		AdminEmails:           []string{"admin@test.com"},
 	}
 
-	aux = authx.New(userRepo, &authxConfig)
+	aux = authx.New(userRepo, &authxConfig, authx.WithRefreshTokenRepo(_tokenRepo.NewPgxRepository(conn)))
 
 	evt := tests.NewMockEventEmitter()
-	orgUseCase := _orgUseCase.NewUseCase(orgRepo, timeoutContext)
-	NewHandler(r, aux, orgUseCase, evt)
+	orgUseCase = _orgUseCase.NewUseCase(orgRepo, &organization.Config{RetentionPeriod: time.Hour}, timeoutContext)
+	userUseCase = _userUseCase.NewUseCase(userRepo, timeoutContext)
+	NewHandler(r, aux, orgUseCase, userUseCase, evt)
+	NewAdminHandler(r, aux, orgUseCase, evt)
 }
 
 func TestOrgHandler_Create(t *testing.T) {
@@ -168,3 +180,412 @@ func TestOrgHandler_Get(t *testing.T) {
 		})
 	}
 }
+
+func request(t *testing.T, method, path, email string, payload interface{}) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		body = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, body)
+	token, err := aux.GenerateToken(email)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestOrgHandler_Members(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	tests.SeedUser(db)
+	_, err := db.Exec("INSERT INTO users(name, email, password) VALUES ('Other User', 'other@test.com', 'password')")
+	require.NoError(t, err)
+
+	w := request(t, "POST", "/organizations", "test@test.com", &orgCreatePayload{Name: "Test Org"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var org orgResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+	path := fmt.Sprintf("/organizations/%d/members", org.ID)
+
+	members := func(t *testing.T, email string) []*memberResponse {
+		w := request(t, "GET", path, email, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got []*memberResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		return got
+	}
+
+	t.Run("the owner is a member", func(t *testing.T) {
+		got := members(t, "test@test.com")
+		require.Len(t, got, 1)
+		assert.Equal(t, "test@test.com", got[0].Email)
+		assert.Equal(t, "owner", got[0].Role)
+		assert.Equal(t, http.StatusForbidden, request(t, "GET", path, "other@test.com", nil).Code)
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		w := request(t, "POST", path, "other@test.com", &memberPayload{Email: "other@test.com"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "only members with org:members:write add members")
+		w = request(t, "POST", path, "test@test.com", &memberPayload{Email: "nobody@test.com"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = request(t, "POST", path, "test@test.com", &memberPayload{Email: "other@test.com"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = request(t, "POST", path, "test@test.com", &memberPayload{Email: "other@test.com"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Len(t, members(t, "other@test.com"), 2)
+	})
+
+	t.Run("Leave and Remove", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, request(t, "DELETE", path+"/me", "test@test.com", nil).Code, "the last owner stays")
+		assert.Equal(t, http.StatusConflict, request(t, "DELETE", path+"/1", "test@test.com", nil).Code)
+
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path+"/me", "other@test.com", nil).Code)
+		assert.Equal(t, http.StatusForbidden, request(t, "DELETE", path+"/me", "other@test.com", nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(t, "DELETE", path+"/2", "test@test.com", nil).Code)
+
+		require.Equal(t, http.StatusCreated, request(t, "POST", path, "test@test.com", &memberPayload{Email: "other@test.com"}).Code)
+		assert.Equal(t, http.StatusForbidden, request(t, "DELETE", path+"/1", "other@test.com", nil).Code)
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path+"/2", "test@test.com", nil).Code)
+		assert.Len(t, members(t, "test@test.com"), 1)
+	})
+}
+
+func TestOrgHandler_Roles(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	tests.SeedUser(db)
+	_, err := db.Exec("INSERT INTO users(name, email, password) VALUES " +
+		"('Other User', 'other@test.com', 'password'), ('Third User', 'third@test.com', 'password')")
+	require.NoError(t, err)
+
+	w := request(t, "POST", "/organizations", "test@test.com", &orgCreatePayload{Name: "Test Org"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var org orgResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+	path := fmt.Sprintf("/organizations/%d", org.ID)
+
+	assert.Equal(t, http.StatusForbidden, request(t, "GET", path, "other@test.com", nil).Code, "non members can't read the organization")
+	require.Equal(t, http.StatusCreated, request(t, "POST", path+"/members", "test@test.com", &memberPayload{Email: "other@test.com"}).Code)
+	assert.Equal(t, http.StatusOK, request(t, "GET", path, "other@test.com", nil).Code)
+
+	t.Run("members have read permissions only", func(t *testing.T) {
+		w := request(t, "PUT", path+"/password-policy", "other@test.com", &passwordPolicyPayload{})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(t, "POST", path+"/members", "other@test.com", &memberPayload{Email: "third@test.com"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("custom roles", func(t *testing.T) {
+		w := request(t, "POST", path+"/roles", "test@test.com", &rolePayload{Name: "admin"})
+		assert.Equal(t, http.StatusConflict, w.Code, "built-in names are taken")
+		w = request(t, "POST", path+"/roles", "test@test.com", &rolePayload{Name: "recruiter", Permissions: []string{"org:unknown"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = request(t, "POST", path+"/roles", "test@test.com", &rolePayload{Name: "recruiter", Permissions: []string{"org:read"}})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = request(t, "PUT", path+"/roles/recruiter", "test@test.com", &rolePermissionsPayload{Permissions: []string{"org:read", "org:members:write"}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusForbidden, request(t, "DELETE", path+"/roles/owner", "test@test.com", nil).Code)
+
+		w = request(t, "PUT", path+"/members/2/role", "test@test.com", &memberRolePayload{Role: "recruiter"})
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		w = request(t, "POST", path+"/members", "other@test.com", &memberPayload{Email: "third@test.com"})
+		assert.Equal(t, http.StatusCreated, w.Code, "the custom role grants org:members:write")
+		assert.Equal(t, http.StatusConflict, request(t, "DELETE", path+"/roles/recruiter", "test@test.com", nil).Code, "the role is in use")
+
+		w = request(t, "GET", path+"/roles", "other@test.com", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var roles []*roleResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &roles))
+		assert.Len(t, roles, 4)
+	})
+
+	t.Run("only owners grant the owner role", func(t *testing.T) {
+		w := request(t, "PUT", path+"/members/2/role", "test@test.com", &memberRolePayload{Role: "admin"})
+		require.Equal(t, http.StatusNoContent, w.Code)
+		w = request(t, "PUT", path+"/members/2/role", "other@test.com", &memberRolePayload{Role: "owner"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(t, "PUT", path+"/members/1/role", "other@test.com", &memberRolePayload{Role: "member"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "admins can't demote owners")
+		w = request(t, "DELETE", path+"/members/1", "other@test.com", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code, "admins can't remove owners")
+		w = request(t, "PUT", path+"/members/3/role", "other@test.com", &memberRolePayload{Role: "nobody"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("admins can't grant permissions they lack", func(t *testing.T) {
+		w := request(t, "POST", path+"/roles", "other@test.com", &rolePayload{Name: "superuser", Permissions: []string{"org:read", "org:delete"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(t, "POST", path+"/roles", "other@test.com", &rolePayload{Name: "helper", Permissions: []string{"org:read"}})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = request(t, "PUT", path+"/roles/helper", "other@test.com", &rolePermissionsPayload{Permissions: []string{"org:owners:write"}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = request(t, "POST", path+"/roles", "test@test.com", &rolePayload{Name: "superuser", Permissions: []string{"org:read", "org:delete"}})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		w = request(t, "PUT", path+"/members/2/role", "other@test.com", &memberRolePayload{Role: "superuser"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "admins can't promote themselves")
+		w = request(t, "PUT", path+"/roles/superuser", "other@test.com", &rolePermissionsPayload{Permissions: []string{"org:read"}})
+		assert.Equal(t, http.StatusForbidden, w.Code, "nor change roles granting more than theirs")
+		w = request(t, "PUT", path+"/members/3/role", "other@test.com", &memberRolePayload{Role: "helper"})
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	})
+
+	t.Run("the organization keeps an owner", func(t *testing.T) {
+		w := request(t, "PUT", path+"/members/1/role", "test@test.com", &memberRolePayload{Role: "admin"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = request(t, "PUT", path+"/members/2/role", "test@test.com", &memberRolePayload{Role: "owner"})
+		require.Equal(t, http.StatusNoContent, w.Code)
+		w = request(t, "PUT", path+"/members/1/role", "test@test.com", &memberRolePayload{Role: "member"})
+		assert.Equal(t, http.StatusNoContent, w.Code, "another owner is left")
+		assert.Equal(t, http.StatusConflict, request(t, "DELETE", path+"/members/me", "other@test.com", nil).Code)
+	})
+}
+
+func TestOrgHandler_Lifecycle(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	tests.SeedUser(db)
+	_, err := db.Exec("INSERT INTO users(name, email, password) VALUES " +
+		"('Other User', 'other@test.com', 'password'), ('Admin User', 'admin@test.com', 'password')")
+	require.NoError(t, err)
+
+	w := request(t, "POST", "/organizations", "test@test.com", &orgCreatePayload{Name: "Test Org"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var org orgResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+	path := fmt.Sprintf("/organizations/%d", org.ID)
+	require.Equal(t, http.StatusCreated, request(t, "POST", path+"/members", "test@test.com", &memberPayload{Email: "other@test.com"}).Code)
+
+	t.Run("Update", func(t *testing.T) {
+		w := request(t, "PUT", path, "other@test.com", &orgCreatePayload{Name: "Renamed Org"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(t, "PUT", path, "test@test.com", &orgCreatePayload{Name: "Renamed Org"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var got orgResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, "Renamed Org", got.Name)
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		w := request(t, "POST", path+"/transfer", "test@test.com", &transferPayload{UserID: 1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = request(t, "POST", path+"/transfer", "test@test.com", &transferPayload{UserID: 3})
+		assert.Equal(t, http.StatusNotFound, w.Code, "only members can be nominated")
+		w = request(t, "POST", path+"/transfer", "other@test.com", &transferPayload{UserID: 2})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = request(t, "POST", path+"/transfer", "test@test.com", &transferPayload{UserID: 2})
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusConflict, request(t, "POST", path+"/transfer/accept", "test@test.com", nil).Code)
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path+"/transfer", "other@test.com", nil).Code, "the nominee declines")
+		assert.Equal(t, http.StatusConflict, request(t, "POST", path+"/transfer/accept", "other@test.com", nil).Code)
+
+		require.Equal(t, http.StatusAccepted, request(t, "POST", path+"/transfer", "test@test.com", &transferPayload{UserID: 2}).Code)
+		w = request(t, "POST", path+"/transfer/accept", "other@test.com", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got orgResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, 2, got.OwnerId)
+		assert.Zero(t, got.PendingOwnerId)
+
+		roles := map[string]string{}
+		w = request(t, "GET", path+"/members", "other@test.com", nil)
+		var members []*memberResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &members))
+		for _, m := range members {
+			roles[m.Email] = m.Role
+		}
+		assert.Equal(t, map[string]string{"test@test.com": "admin", "other@test.com": "owner"}, roles)
+	})
+
+	t.Run("Delete and Restore", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, request(t, "DELETE", path, "test@test.com", nil).Code, "admins can't delete")
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path, "other@test.com", nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(t, "GET", path, "other@test.com", nil).Code)
+
+		restore := fmt.Sprintf("/admin/organizations/%d/restore", org.ID)
+		assert.Equal(t, http.StatusForbidden, request(t, "POST", restore, "other@test.com", nil).Code)
+		assert.Equal(t, http.StatusOK, request(t, "POST", restore, "admin@test.com", nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(t, "POST", restore, "admin@test.com", nil).Code, "the organization is not deleted")
+		assert.Equal(t, http.StatusOK, request(t, "GET", path, "other@test.com", nil).Code)
+
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path, "other@test.com", nil).Code)
+		_, err := db.Exec("UPDATE organizations SET deleted_at = $1 WHERE id = $2", time.Now().UTC().Add(-2*time.Hour), org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusGone, request(t, "POST", restore, "admin@test.com", nil).Code, "the retention period is over")
+	})
+}
+
+func TestOrgHandler_ListAndSwitch(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	tests.SeedUser(db)
+	_, err := db.Exec("INSERT INTO users(name, email, password) VALUES ('Other User', 'other@test.com', 'password')")
+	require.NoError(t, err)
+
+	ids := make([]int, 3)
+	for i, name := range []string{"First Org", "Second Org", "Third Org"} {
+		w := request(t, "POST", "/organizations", "test@test.com", &orgCreatePayload{Name: name})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var org orgResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+		ids[i] = org.ID
+	}
+	w := request(t, "POST", "/organizations", "other@test.com", &orgCreatePayload{Name: "Other Org"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var other orgResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &other))
+	w = request(t, "POST", fmt.Sprintf("/organizations/%d/members", other.ID), "other@test.com", &memberPayload{Email: "test@test.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	list := func(t *testing.T, path string) *membershipListResponse {
+		w := request(t, "GET", path, "test@test.com", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got membershipListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		return &got
+	}
+
+	t.Run("List", func(t *testing.T) {
+		got := list(t, "/organizations")
+		assert.Equal(t, 4, got.Total)
+		assert.Equal(t, 1, got.Page)
+		assert.Equal(t, defaultPageSize, got.PerPage)
+		require.Len(t, got.Data, 4)
+		assert.Equal(t, ids[0], got.Data[0].ID)
+		assert.Equal(t, "owner", got.Data[0].Role)
+		assert.Equal(t, other.ID, got.Data[3].ID)
+		assert.Equal(t, "member", got.Data[3].Role)
+
+		got = list(t, "/me/organizations?page=2&per_page=3")
+		assert.Equal(t, 4, got.Total)
+		require.Len(t, got.Data, 1)
+		assert.Equal(t, other.ID, got.Data[0].ID)
+
+		got = list(t, "/me/organizations?page=3&per_page=3")
+		assert.Equal(t, 4, got.Total)
+		assert.Empty(t, got.Data)
+
+		assert.Equal(t, http.StatusBadRequest, request(t, "GET", "/organizations?page=0", "test@test.com", nil).Code)
+		assert.Equal(t, http.StatusBadRequest, request(t, "GET", "/organizations?per_page=1000", "test@test.com", nil).Code)
+	})
+
+	t.Run("List skips deleted organizations", func(t *testing.T) {
+		require.Equal(t, http.StatusNoContent, request(t, "DELETE", fmt.Sprintf("/organizations/%d", ids[1]), "test@test.com", nil).Code)
+		got := list(t, "/organizations")
+		assert.Equal(t, 3, got.Total)
+		require.Len(t, got.Data, 3)
+		assert.Equal(t, ids[2], got.Data[1].ID)
+	})
+
+	t.Run("Switch", func(t *testing.T) {
+		w := request(t, "POST", fmt.Sprintf("/organizations/%d/switch", other.ID), "test@test.com", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got switchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, other.ID, got.OrganizationID)
+		assert.Equal(t, "member", got.Role)
+		assert.Equal(t, 60, got.ExpiresIn)
+		claims, err := aux.Introspect(context.Background(), got.Token, "")
+		require.NoError(t, err)
+		assert.True(t, claims.Active)
+		assert.Equal(t, other.ID, claims.OrganizationID)
+
+		require.NotEmpty(t, got.RefreshToken)
+		refreshed, err := aux.RefreshTokenPair(context.Background(), got.RefreshToken)
+		require.NoError(t, err)
+		claims, err = aux.Introspect(context.Background(), refreshed.AccessToken, "")
+		require.NoError(t, err)
+		assert.Equal(t, other.ID, claims.OrganizationID, "refreshing keeps the organization")
+
+		w = request(t, "POST", fmt.Sprintf("/organizations/%d/switch", ids[0]), "other@test.com", nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(t, "POST", fmt.Sprintf("/organizations/%d/switch", ids[1]), "test@test.com", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code, "deleted organizations can't be switched to")
+	})
+}
+
+func TestOrgHandler_MFAPolicy(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+
+	tests.SeedUser(db)
+	w := request(t, "POST", "/organizations", "test@test.com", &orgCreatePayload{Name: "Strict Org"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var org orgResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+	path := fmt.Sprintf("/organizations/%d", org.ID)
+
+	u, err := userUseCase.FindByEmail(context.Background(), "test@test.com")
+	require.NoError(t, err)
+	token, err := aux.GenerateUserToken(context.Background(), u, authx.WithAuthMethods(authx.AuthMethodPassword, authx.AuthMethodMFA))
+	require.NoError(t, err)
+	withMFA := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w = request(t, "PUT", path+"/mfa", "test@test.com", &mfaPolicyPayload{Required: true})
+	assert.Equal(t, http.StatusConflict, w.Code, "only users signed in with MFA can require it")
+
+	w = withMFA("PUT", path+"/mfa", &mfaPolicyPayload{Required: true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+	assert.True(t, org.RequireMFA)
+
+	w = request(t, "PUT", path, "test@test.com", &orgCreatePayload{Name: "Renamed Org"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "the organization requires two-factor authentication")
+	assert.Equal(t, http.StatusOK, withMFA("PUT", path, &orgCreatePayload{Name: "Renamed Org"}).Code)
+
+	w = withMFA("POST", path+"/switch", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var switched switchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &switched))
+	info, err := aux.Introspect(context.Background(), switched.Token, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{authx.AuthMethodPassword, authx.AuthMethodMFA}, info.AuthMethods)
+	token = switched.Token
+	assert.Equal(t, http.StatusOK, withMFA("PUT", path, &orgCreatePayload{Name: "Switched Org"}).Code,
+		"the switched token keeps the second factor")
+
+	t.Run("tokens without MFA are not scoped to the organization", func(t *testing.T) {
+		ctx := context.Background()
+		enricher := _orgUseCase.NewClaimsEnricher(orgUseCase)
+		password := []string{authx.AuthMethodPassword}
+
+		claims := &authx.Claims{AuthMethods: password}
+		require.NoError(t, enricher.Enrich(ctx, u, claims))
+		assert.Zero(t, claims.OrganizationID)
+		claims = &authx.Claims{OrganizationID: org.ID, AuthMethods: password}
+		assert.Equal(t, errorx.ErrUnauthorized, enricher.Enrich(ctx, u, claims))
+		claims = &authx.Claims{AuthMethods: []string{authx.AuthMethodPassword, authx.AuthMethodMFA}}
+		require.NoError(t, enricher.Enrich(ctx, u, claims))
+		assert.Equal(t, org.ID, claims.OrganizationID)
+
+		w := request(t, "POST", "/organizations", "test@test.com", &orgCreatePayload{Name: "Lax Org"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var lax orgResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lax))
+		claims = &authx.Claims{AuthMethods: password}
+		require.NoError(t, enricher.Enrich(ctx, u, claims))
+		assert.Equal(t, lax.ID, claims.OrganizationID, "the first organization not requiring MFA")
+	})
+
+	w = withMFA("PUT", path+"/mfa", &mfaPolicyPayload{Required: false})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, request(t, "PUT", path, "test@test.com", &orgCreatePayload{Name: "Relaxed Org"}).Code)
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/httpx"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type membershipResponse struct {
+	*orgResponse
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type membershipListResponse struct {
+	Data    []*membershipResponse `json:"data"`
+	Page    int                   `json:"page"`
+	PerPage int                   `json:"per_page"`
+	Total   int                   `json:"total"`
+}
+
+type switchResponse struct {
+	Token          string `json:"token"`
+	RefreshToken   string `json:"refresh_token,omitempty"`
+	ExpiresIn      int    `json:"expires_in"`
+	OrganizationID int    `json:"organization_id"`
+	Role           string `json:"role"`
+}
+
+// parsePage reads the page and per_page query parameters, which default to the
+// first page of defaultPageSize items
+func parsePage(r *http.Request) (*organization.Page, url.Values) {
+	e := make(url.Values)
+	page := &organization.Page{Number: 1, Size: defaultPageSize}
+	query := r.URL.Query()
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			e.Add("page", "page must be a positive number")
+		}
+		page.Number = n
+	}
+	if v := query.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			e.Add("per_page", "per_page must be between 1 and "+strconv.Itoa(maxPageSize))
+		}
+		page.Size = n
+	}
+	return page, e
+}
+
+// List lists the organizations the current user owns or belongs to, with the
+// user's role in each
+func (handler *orgHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	page, validationErrors := parsePage(r)
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	memberships, total, err := handler.useCase.Memberships(ctx, handler.currentUser(r).ID, page)
+	if err != nil {
+		panic(err)
+	}
+	resp := &membershipListResponse{
+		Data:    make([]*membershipResponse, len(memberships)),
+		Page:    page.Number,
+		PerPage: page.Size,
+		Total:   total,
+	}
+	for i, m := range memberships {
+		resp.Data[i] = &membershipResponse{
+			orgResponse: newOrgResponse(m.Organization),
+			Role:        m.Role,
+			JoinedAt:    m.CreatedAt,
+		}
+	}
+	httpx.ResponseJSON(w, http.StatusOK, resp)
+}
+
+// Switch re-issues the token pair of the current user scoped to the
+// organization, so downstream services get the tenant from the token and
+// refreshing keeps it. The tokens keep the authentication methods of the
+// current one.
+func (handler *orgHandler) Switch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	claims, err := handler.GetCurrentClaims(r)
+	if err != nil {
+		panic(err)
+	}
+	pair, err := handler.GenerateTokenPair(ctx, handler.currentUser(r), authx.WithOrganization(org.ID),
+		authx.WithAuthMethods(claims.AuthMethods...))
+	if err != nil {
+		if errors.Is(err, errorx.ErrUnauthorized) {
+			httpx.ResponseJSONError(w, r, http.StatusForbidden, "not a member of the organization", err)
+			return
+		}
+		panic(err)
+	}
+	role, _ := ctx.Value(roleKey).(*models.Role)
+	httpx.ResponseJSON(w, http.StatusOK, &switchResponse{
+		Token:          pair.AccessToken,
+		RefreshToken:   pair.RefreshToken,
+		ExpiresIn:      pair.ExpiresIn,
+		OrganizationID: org.ID,
+		Role:           role.Name,
+	})
+}
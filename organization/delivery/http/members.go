@@ -0,0 +1,193 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/httpx"
+	param "github.com/oceanicdev/chi-param"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+type memberPayload struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (mp *memberPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"email": []string{"required", "min:4", "max:100", "email"},
+	}
+	opts := govalidator.Options{
+		Data:  mp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type memberResponse struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func newMemberResponse(m *models.UserOrganization) *memberResponse {
+	return &memberResponse{
+		ID:       m.UserId,
+		Name:     m.User.Name,
+		Email:    m.User.Email,
+		Role:     m.Role,
+		JoinedAt: m.CreatedAt,
+	}
+}
+
+// ListMembers lists the members of the organization with their roles
+func (handler *orgHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	members, err := handler.useCase.FindMembers(ctx, org.ID)
+	if err != nil {
+		panic(err)
+	}
+	resp := make([]*memberResponse, len(members))
+	for i, m := range members {
+		resp[i] = newMemberResponse(m)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, resp)
+}
+
+// AddMember adds an existing user to the organization, as member unless
+// another role is given. Users without an account are invited instead.
+func (handler *orgHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	data := &memberPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	if data.Role == "" {
+		data.Role = models.RoleMember
+	}
+	if _, err := handler.useCase.FindRole(ctx, org.ID, data.Role); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			validationErrors.Add("role", organization.ErrUnknownRole.Error())
+			httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+			return
+		}
+		panic(err)
+	}
+	if data.Role == models.RoleOwner && !handler.can(r, organization.PermOwnersWrite) {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+organization.PermOwnersWrite)
+		return
+	}
+
+	u, err := handler.userUseCase.FindByEmail(ctx, data.Email)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "user not found, invite the email instead", err)
+			return
+		}
+		panic(err)
+	}
+	if handler.userUseCase.IsMember(ctx, u, org) {
+		validationErrors.Add("email", "already a member of the organization")
+		httpx.ResponseJSONError(w, r, http.StatusConflict, "invalid request", validationErrors)
+		return
+	}
+	m := &models.UserOrganization{UserId: u.ID, User: u, OrganizationId: org.ID, Role: data.Role}
+	if err := handler.userUseCase.AddToOrganization(ctx, u, org, m.Role); err != nil {
+		panic(err)
+	}
+	m.CreatedAt = time.Now().UTC()
+	httpx.ResponseJSON(w, http.StatusCreated, newMemberResponse(m))
+}
+
+// RemoveMember removes a member from the organization, only roles granting
+// org:owners:write can remove owners
+func (handler *orgHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	userID, err := param.Int(r, "userID")
+	if err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+		return
+	}
+	role, ok := handler.memberRole(w, r, org, userID)
+	if !ok {
+		return
+	}
+	if role.Name == models.RoleOwner && !handler.can(r, organization.PermOwnersWrite) {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+organization.PermOwnersWrite)
+		return
+	}
+	handler.removeMember(w, r, org, userID)
+}
+
+// Leave removes the current user from the organization, the last owner can
+// not leave
+func (handler *orgHandler) Leave(w http.ResponseWriter, r *http.Request) {
+	org, ok := r.Context().Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	handler.removeMember(w, r, org, handler.currentUser(r).ID)
+}
+
+func (handler *orgHandler) removeMember(w http.ResponseWriter, r *http.Request, org *models.Organization, userID int) {
+	if err := handler.useCase.RemoveMember(r.Context(), org.ID, userID); err != nil {
+		if errors.Is(err, organization.ErrLastOwner) {
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+			return
+		}
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "member not found", err)
+			return
+		}
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+func (handler *orgHandler) currentUser(r *http.Request) *models.User {
+	au, err := handler.GetCurrentUser(r)
+	u, ok := au.(*models.User)
+	if err != nil || !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	return u
+}
@@ -0,0 +1,411 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/httpx"
+	param "github.com/oceanicdev/chi-param"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+const roleKey contextKey = "role"
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// RequirePermission rejects users whose role in the organization of the
+// {id} URL parameter does not grant permission, any member passes when
+// permission is empty. Organizations requiring MFA also reject tokens issued
+// without a second factor.
+func RequirePermission(aux *authx.Authx, useCase organization.UseCase, permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			id, err := param.Int(r, "id")
+			if err != nil {
+				httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+				return
+			}
+			u, err := aux.GetCurrentUser(r)
+			if err != nil {
+				panic(err)
+			}
+			role, err := useCase.MemberRole(ctx, id, u.GetId())
+			if err != nil {
+				if errors.Is(err, errorx.ErrorNotFound) {
+					httpx.ResponseJSONError(w, r, http.StatusForbidden, "not a member of the organization")
+					return
+				}
+				panic(err)
+			}
+			if permission != "" && !role.Can(permission) {
+				httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+permission)
+				return
+			}
+			org, ok := ctx.Value(orgKey).(*models.Organization)
+			if !ok {
+				if org, err = useCase.FindByID(ctx, id); err != nil {
+					if errors.Is(err, errorx.ErrorNotFound) {
+						httpx.ResponseJSONError(w, r, http.StatusNotFound, "organization not found", err)
+						return
+					}
+					panic(err)
+				}
+			}
+			if org.RequireMFA {
+				claims, err := aux.GetCurrentClaims(r)
+				if err != nil {
+					panic(err)
+				}
+				if !claims.HasAuthMethod(authx.AuthMethodMFA) {
+					httpx.ResponseJSONError(w, r, http.StatusForbidden, "the organization requires two-factor authentication")
+					return
+				}
+			}
+			ctx = context.WithValue(ctx, roleKey, role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequirePermission rejects users whose role in the organization of the
+// request does not grant permission
+func (handler *orgHandler) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return RequirePermission(handler.Authx, handler.useCase, permission)
+}
+
+// MemberOnly rejects users who do not belong to the organization of the request
+func (handler *orgHandler) MemberOnly(next http.Handler) http.Handler {
+	return handler.RequirePermission("")(next)
+}
+
+type rolePayload struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+func (rp *rolePayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"name": []string{"required", "min:2", "max:50"},
+	}
+	opts := govalidator.Options{
+		Data:  rp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	if rp.Name != "" && !roleNamePattern.MatchString(rp.Name) {
+		e.Add("name", "name must start with a lowercase letter followed by lowercase letters, digits, _ or -")
+	}
+	validatePermissions(e, rp.Permissions)
+	return e
+}
+
+type rolePermissionsPayload struct {
+	Permissions []string `json:"permissions"`
+}
+
+func (rp *rolePermissionsPayload) validate() url.Values {
+	e := make(url.Values)
+	validatePermissions(e, rp.Permissions)
+	return e
+}
+
+func validatePermissions(e url.Values, permissions []string) {
+	for _, p := range permissions {
+		if !organization.IsPermission(p) {
+			e.Add("permissions", "unknown permission "+p)
+		}
+	}
+}
+
+type memberRolePayload struct {
+	Role string `json:"role"`
+}
+
+func (mp *memberRolePayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"role": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  mp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type roleResponse struct {
+	Name        string    `json:"name"`
+	Permissions []string  `json:"permissions"`
+	Builtin     bool      `json:"builtin"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+func newRoleResponse(role *models.Role) *roleResponse {
+	permissions := role.Permissions
+	if permissions == nil {
+		permissions = make([]string, 0)
+	}
+	return &roleResponse{
+		Name:        role.Name,
+		Permissions: permissions,
+		Builtin:     role.Builtin,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+// ListPermissions returns the catalogue of the permissions roles can grant
+func (handler *orgHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	httpx.ResponseJSON(w, http.StatusOK, organization.Permissions)
+}
+
+// ListRoles lists the built-in and the custom roles of the organization
+func (handler *orgHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	roles, err := handler.useCase.Roles(ctx, org.ID)
+	if err != nil {
+		panic(err)
+	}
+	resp := make([]*roleResponse, len(roles))
+	for i, role := range roles {
+		resp[i] = newRoleResponse(role)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, resp)
+}
+
+// CreateRole creates a custom role
+func (handler *orgHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	data := &rolePayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	if permission, missing := handler.missingPermission(r, data.Permissions); missing {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+permission)
+		return
+	}
+	role := &models.Role{OrganizationID: org.ID, Name: data.Name, Permissions: data.Permissions}
+	if role.Permissions == nil {
+		role.Permissions = make([]string, 0)
+	}
+	if err := handler.useCase.SaveRole(ctx, role); err != nil {
+		if errors.Is(err, organization.ErrRoleExists) {
+			validationErrors.Add("name", err.Error())
+			httpx.ResponseJSONError(w, r, http.StatusConflict, "invalid request", validationErrors)
+			return
+		}
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusCreated, newRoleResponse(role))
+}
+
+// UpdateRole replaces the permissions of a custom role
+func (handler *orgHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	role, ok := handler.customRole(w, r)
+	if !ok {
+		return
+	}
+	data := &rolePermissionsPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	// the role may be held by members with more permissions than the user
+	if permission, missing := handler.missingPermission(r, append(role.Permissions, data.Permissions...)); missing {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+permission)
+		return
+	}
+	role.Permissions = data.Permissions
+	if role.Permissions == nil {
+		role.Permissions = make([]string, 0)
+	}
+	if err := handler.useCase.UpdateRole(ctx, role); err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, newRoleResponse(role))
+}
+
+// DeleteRole deletes a custom role no member has
+func (handler *orgHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := handler.customRole(w, r)
+	if !ok {
+		return
+	}
+	if err := handler.useCase.DeleteRole(r.Context(), role); err != nil {
+		if errors.Is(err, organization.ErrRoleInUse) {
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+			return
+		}
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// customRole loads the custom role of the {role} URL parameter, built-in
+// roles can not be changed
+func (handler *orgHandler) customRole(w http.ResponseWriter, r *http.Request) (*models.Role, bool) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return nil, false
+	}
+	role, err := handler.useCase.FindRole(ctx, org.ID, chi.URLParam(r, "role"))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "role not found", err)
+			return nil, false
+		}
+		panic(err)
+	}
+	if role.Builtin {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "built-in roles can not be changed")
+		return nil, false
+	}
+	return role, true
+}
+
+// AssignRole changes the role of a member, only roles granting
+// org:owners:write can grant or revoke the owner role. Users can only move
+// members between roles granting no more than their own role.
+func (handler *orgHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	userID, err := param.Int(r, "userID")
+	if err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+		return
+	}
+	data := &memberRolePayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	current, ok := handler.memberRole(w, r, org, userID)
+	if !ok {
+		return
+	}
+	if (current.Name == models.RoleOwner || data.Role == models.RoleOwner) && !handler.can(r, organization.PermOwnersWrite) {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+organization.PermOwnersWrite)
+		return
+	}
+	role, err := handler.useCase.FindRole(ctx, org.ID, data.Role)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			validationErrors.Add("role", organization.ErrUnknownRole.Error())
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request", validationErrors)
+			return
+		}
+		panic(err)
+	}
+	if permission, missing := handler.missingPermission(r, append(current.Permissions, role.Permissions...)); missing {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+permission)
+		return
+	}
+	if err := handler.useCase.AssignRole(ctx, org.ID, userID, data.Role); err != nil {
+		switch {
+		case errors.Is(err, organization.ErrUnknownRole):
+			validationErrors.Add("role", err.Error())
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request", validationErrors)
+		case errors.Is(err, organization.ErrLastOwner):
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+		case errors.Is(err, errorx.ErrorNotFound):
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "member not found", err)
+		default:
+			panic(err)
+		}
+		return
+	}
+	httpx.NoContent(w)
+}
+
+// memberRole writes the error response and returns false when userID is not
+// a member of org
+func (handler *orgHandler) memberRole(w http.ResponseWriter, r *http.Request, org *models.Organization, userID int) (*models.Role, bool) {
+	role, err := handler.useCase.MemberRole(r.Context(), org.ID, userID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "member not found", err)
+			return nil, false
+		}
+		panic(err)
+	}
+	return role, true
+}
+
+// can reports whether the role of the current user, loaded by
+// RequirePermission, grants permission
+func (handler *orgHandler) can(r *http.Request, permission string) bool {
+	role, ok := r.Context().Value(roleKey).(*models.Role)
+	return ok && role.Can(permission)
+}
+
+// missingPermission returns a permission of permissions the role of the
+// current user does not grant, roles can't hand out more than they grant
+func (handler *orgHandler) missingPermission(r *http.Request, permissions []string) (string, bool) {
+	for _, p := range permissions {
+		if !handler.can(r, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/imtanmoy/authn/events"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/httpx"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+type transferPayload struct {
+	UserID int `json:"user_id"`
+}
+
+func (tp *transferPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"user_id": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  tp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+// RequestTransfer nominates a member as the new owner, the ownership moves
+// once the member accepts
+func (handler *orgHandler) RequestTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	data := &transferPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	if err := handler.useCase.RequestTransfer(ctx, org, data.UserID); err != nil {
+		switch {
+		case errors.Is(err, organization.ErrAlreadyOwner):
+			validationErrors.Add("user_id", err.Error())
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request", validationErrors)
+		case errors.Is(err, errorx.ErrorNotFound):
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "member not found", err)
+		default:
+			panic(err)
+		}
+		return
+	}
+	handler.emitTransfer(r, events.OrganizationTransferRequestedEvent, org, org.OwnerID, org.PendingOwnerID)
+	httpx.ResponseJSON(w, http.StatusAccepted, newOrgResponse(org))
+}
+
+// AcceptTransfer makes the nominated member the owner
+func (handler *orgHandler) AcceptTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	previousOwnerID := org.OwnerID
+	if err := handler.useCase.AcceptTransfer(ctx, org, handler.currentUser(r).ID); err != nil {
+		if errors.Is(err, organization.ErrNoTransfer) {
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+			return
+		}
+		panic(err)
+	}
+	handler.emitTransfer(r, events.OrganizationTransferredEvent, org, previousOwnerID, org.OwnerID)
+	httpx.ResponseJSON(w, http.StatusOK, newOrgResponse(org))
+}
+
+// CancelTransfer drops the pending transfer, the nominated member declines
+// the same way
+func (handler *orgHandler) CancelTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	org, ok := ctx.Value(orgKey).(*models.Organization)
+	if !ok {
+		httpx.ResponseJSONError(w, r, http.StatusInternalServerError, httpx.ErrInternalServerError)
+		return
+	}
+	nomineeID := org.PendingOwnerID
+	if nomineeID != handler.currentUser(r).ID && !handler.can(r, organization.PermOwnersWrite) {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "missing permission "+organization.PermOwnersWrite)
+		return
+	}
+	if err := handler.useCase.CancelTransfer(ctx, org); err != nil {
+		if errors.Is(err, organization.ErrNoTransfer) {
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+			return
+		}
+		panic(err)
+	}
+	handler.emitTransfer(r, events.OrganizationTransferCanceledEvent, org, org.OwnerID, nomineeID)
+	httpx.NoContent(w)
+}
+
+func (handler *orgHandler) emitTransfer(r *http.Request, topic string, org *models.Organization, from, to int) {
+	handler.event.Emit(r.Context(), topic, events.OrganizationTransfer{
+		OrganizationID: org.ID,
+		FromUserID:     from,
+		ToUserID:       to,
+		ActorID:        handler.currentUser(r).ID,
+		ChangedAt:      org.UpdatedAt,
+	})
+}
@@ -0,0 +1,78 @@
+package organization
+
+import (
+	"github.com/imtanmoy/authn/models"
+)
+
+// Permissions roles can grant within an organization
+const (
+	PermOrgRead          = "org:read"
+	PermOrgWrite         = "org:write"
+	PermOrgDelete        = "org:delete"
+	PermMembersRead      = "org:members:read"
+	PermMembersWrite     = "org:members:write"
+	PermOwnersWrite      = "org:owners:write"
+	PermRolesWrite       = "org:roles:write"
+	PermInvitationsRead  = "org:invitations:read"
+	PermInvitationsWrite = "org:invitations:write"
+)
+
+// Permission describes an entry of the permission catalogue
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions is the catalogue of the permissions roles can grant
+var Permissions = []*Permission{
+	{PermOrgRead, "view the organization and its roles"},
+	{PermOrgWrite, "change the organization and its password policy"},
+	{PermOrgDelete, "delete the organization"},
+	{PermMembersRead, "list the members"},
+	{PermMembersWrite, "add and remove members and assign them roles other than owner"},
+	{PermOwnersWrite, "grant and revoke the owner role"},
+	{PermRolesWrite, "create, change and delete custom roles"},
+	{PermInvitationsRead, "list the pending invitations"},
+	{PermInvitationsWrite, "invite, resend and cancel invitations"},
+}
+
+// IsPermission reports whether name is in the catalogue
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltinRole returns the built-in role called name, nil for custom roles
+func BuiltinRole(name string) *models.Role {
+	var permissions []string
+	switch name {
+	case models.RoleOwner:
+		for _, p := range Permissions {
+			permissions = append(permissions, p.Name)
+		}
+	case models.RoleAdmin:
+		for _, p := range Permissions {
+			if p.Name != PermOrgDelete && p.Name != PermOwnersWrite {
+				permissions = append(permissions, p.Name)
+			}
+		}
+	case models.RoleMember:
+		permissions = []string{PermOrgRead, PermMembersRead}
+	default:
+		return nil
+	}
+	return &models.Role{Name: name, Permissions: permissions, Builtin: true}
+}
+
+// BuiltinRoles returns the roles every organization has
+func BuiltinRoles() []*models.Role {
+	return []*models.Role{
+		BuiltinRole(models.RoleOwner),
+		BuiltinRole(models.RoleAdmin),
+		BuiltinRole(models.RoleMember),
+	}
+}
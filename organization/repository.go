@@ -2,10 +2,51 @@ package organization
 
 import (
 	"context"
+	"github.com/imtanmoy/authn/internal/authx"
 	"github.com/imtanmoy/authn/models"
 )
 
 type Repository interface {
 	Save(ctx context.Context, org *models.Organization) error
 	FindByID(ctx context.Context, id int) (*models.Organization, error)
+	FindAllByUserID(ctx context.Context, userID int) ([]*models.Organization, error)
+	// FindMemberships lists the organizations the user belongs to, by id,
+	// with the user's role in each
+	FindMemberships(ctx context.Context, userID, limit, offset int) ([]*models.UserOrganization, error)
+	CountMemberships(ctx context.Context, userID int) (int, error)
+	// FindDeletedByID returns a soft deleted organization
+	FindDeletedByID(ctx context.Context, id int) (*models.Organization, error)
+	// Update changes the name and the MFA requirement of the organization
+	Update(ctx context.Context, org *models.Organization) error
+	// Delete soft deletes the organization and drops its pending transfer
+	Delete(ctx context.Context, org *models.Organization) error
+	Restore(ctx context.Context, org *models.Organization) error
+	// UpdatePendingOwner nominates org.PendingOwnerID as the new owner, 0
+	// drops the pending transfer
+	UpdatePendingOwner(ctx context.Context, org *models.Organization) error
+	// Transfer makes the pending owner the owner at once, the previous owner
+	// becomes admin. It must return errorx.ErrorNotFound when
+	// org.PendingOwnerID is no longer the nominee or no longer a member.
+	Transfer(ctx context.Context, org *models.Organization) error
+	// PasswordPolicy returns nil when the organization has no policy of its own
+	PasswordPolicy(ctx context.Context, id int) (*authx.PasswordPolicy, error)
+	SavePasswordPolicy(ctx context.Context, id int, policy *authx.PasswordPolicy) error
+	// FindMembers lists the memberships of the organization with their users
+	FindMembers(ctx context.Context, id int) ([]*models.UserOrganization, error)
+	// FindMember must return errorx.ErrorNotFound when the user is not a member
+	FindMember(ctx context.Context, id, userID int) (*models.UserOrganization, error)
+	// UpdateMemberRole changes the role of a member. It must return
+	// errorx.ErrorNotFound for non members and ErrLastOwner, changing nothing,
+	// when the organization would be left without an owner.
+	UpdateMemberRole(ctx context.Context, orgUser *models.UserOrganization) error
+	// DeleteMember removes a member, with the same errors as UpdateMemberRole
+	DeleteMember(ctx context.Context, orgUser *models.UserOrganization) error
+	// FindRoles lists the custom roles of the organization by name
+	FindRoles(ctx context.Context, id int) ([]*models.Role, error)
+	FindRole(ctx context.Context, id int, name string) (*models.Role, error)
+	// SaveRole must return ErrRoleExists when the name is taken
+	SaveRole(ctx context.Context, role *models.Role) error
+	UpdateRole(ctx context.Context, role *models.Role) error
+	// DeleteRole must return ErrRoleInUse when members have the role
+	DeleteRole(ctx context.Context, role *models.Role) error
 }
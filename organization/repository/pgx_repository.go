@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/dbx"
 	"github.com/imtanmoy/authn/internal/errorx"
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/organization"
@@ -12,7 +15,7 @@ import (
 )
 
 type pgxRepository struct {
-	conn *pgx.Conn
+	conn dbx.DB
 }
 
 func (repo *pgxRepository) Save(ctx context.Context, org *models.Organization) error {
@@ -37,24 +40,439 @@ func (repo *pgxRepository) Save(ctx context.Context, org *models.Organization) e
 	return nil
 }
 
+const selectOrganization = "SELECT id, name, owner_id, pending_owner_id, transfer_requested_at, " +
+	"require_mfa, created_at, updated_at, deleted_at FROM organizations "
+
 func (repo *pgxRepository) FindByID(ctx context.Context, id int) (*models.Organization, error) {
-	var org models.Organization
-	err := repo.conn.QueryRow(ctx, "SELECT id, name, owner_id, created_at, updated_at "+
-		"FROM organizations WHERE id = $1 "+
+	org, err := scanOrganization(repo.conn.QueryRow(ctx, selectOrganization+"WHERE id = $1 "+
+		"AND deleted_at IS NULL", id))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	return org, nil
+}
+
+func (repo *pgxRepository) FindDeletedByID(ctx context.Context, id int) (*models.Organization, error) {
+	org, err := scanOrganization(repo.conn.QueryRow(ctx, selectOrganization+"WHERE id = $1 "+
+		"AND deleted_at IS NOT NULL", id))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	return org, nil
+}
+
+func (repo *pgxRepository) FindAllByUserID(ctx context.Context, userID int) ([]*models.Organization, error) {
+	rows, err := repo.conn.Query(ctx, selectOrganization+"WHERE owner_id = $1 "+
+		"AND deleted_at IS NULL ORDER BY id", userID)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	orgs := make([]*models.Organization, 0)
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+func (repo *pgxRepository) FindMemberships(ctx context.Context, userID, limit, offset int) ([]*models.UserOrganization, error) {
+	rows, err := repo.conn.Query(ctx, "SELECT o.id, o.name, o.owner_id, o.pending_owner_id, o.transfer_requested_at, "+
+		"o.require_mfa, o.created_at, o.updated_at, o.deleted_at, uo.role, uo.created_at "+
+		"FROM users_organizations uo JOIN organizations o ON o.id = uo.organization_id "+
+		"WHERE uo.user_id = $1 AND o.deleted_at IS NULL ORDER BY o.id LIMIT $2 OFFSET $3", userID, limit, offset)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	memberships := make([]*models.UserOrganization, 0)
+	for rows.Next() {
+		m := &models.UserOrganization{UserId: userID}
+		org, err := scanOrganization(membershipRow{rows, m})
+		if err != nil {
+			return nil, err
+		}
+		m.OrganizationId = org.ID
+		m.Organization = org
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+func (repo *pgxRepository) CountMemberships(ctx context.Context, userID int) (int, error) {
+	count := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) FROM users_organizations uo "+
+		"JOIN organizations o ON o.id = uo.organization_id "+
+		"WHERE uo.user_id = $1 AND o.deleted_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, errorx.ErrInternalDB
+	}
+	return count, nil
+}
+
+// membershipRow scans the membership columns following the organization ones
+type membershipRow struct {
+	pgx.Row
+	m *models.UserOrganization
+}
+
+func (r membershipRow) Scan(dest ...interface{}) error {
+	return r.Row.Scan(append(dest, &r.m.Role, &r.m.CreatedAt)...)
+}
+
+func (repo *pgxRepository) Update(ctx context.Context, org *models.Organization) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE organizations SET name = $1, require_mfa = $2, updated_at = $3 "+
+		"WHERE id = $4 AND deleted_at IS NULL", org.Name, org.RequireMFA, now, org.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	org.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) Delete(ctx context.Context, org *models.Organization) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE organizations SET deleted_at = $1, pending_owner_id = NULL, "+
+		"transfer_requested_at = NULL WHERE id = $2 AND deleted_at IS NULL", now, org.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	org.DeletedAt = now
+	org.PendingOwnerID = 0
+	org.TransferRequestedAt = time.Time{}
+	return nil
+}
+
+func (repo *pgxRepository) Restore(ctx context.Context, org *models.Organization) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE organizations SET deleted_at = NULL, updated_at = $1 "+
+		"WHERE id = $2 AND deleted_at IS NOT NULL", now, org.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	org.DeletedAt = time.Time{}
+	org.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) UpdatePendingOwner(ctx context.Context, org *models.Organization) error {
+	var pendingOwnerID *int
+	var requestedAt *time.Time
+	if org.PendingOwnerID != 0 {
+		now := time.Now().UTC()
+		pendingOwnerID = &org.PendingOwnerID
+		requestedAt = &now
+	}
+	tag, err := repo.conn.Exec(ctx, "UPDATE organizations SET pending_owner_id = $1, transfer_requested_at = $2 "+
+		"WHERE id = $3 AND deleted_at IS NULL", pendingOwnerID, requestedAt, org.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	org.TransferRequestedAt = time.Time{}
+	if requestedAt != nil {
+		org.TransferRequestedAt = *requestedAt
+	}
+	return nil
+}
+
+func (repo *pgxRepository) Transfer(ctx context.Context, org *models.Organization) error {
+	now := time.Now().UTC()
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	previousOwnerID := org.OwnerID
+	tag, err := tx.Exec(ctx, "UPDATE organizations SET owner_id = pending_owner_id, pending_owner_id = NULL, "+
+		"transfer_requested_at = NULL, updated_at = $1 "+
+		"WHERE id = $2 AND pending_owner_id = $3 AND deleted_at IS NULL", now, org.ID, org.PendingOwnerID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	tag, err = tx.Exec(ctx, "UPDATE users_organizations SET role = $1 WHERE organization_id = $2 AND user_id = $3",
+		models.RoleOwner, org.ID, org.PendingOwnerID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		// the nominee left the organization in the meantime
+		return errorx.ErrorNotFound
+	}
+	_, err = tx.Exec(ctx, "UPDATE users_organizations SET role = $1 "+
+		"WHERE organization_id = $2 AND user_id = $3 AND role = $4",
+		models.RoleAdmin, org.ID, previousOwnerID, models.RoleOwner)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	org.OwnerID = org.PendingOwnerID
+	org.PendingOwnerID = 0
+	org.TransferRequestedAt = time.Time{}
+	org.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) PasswordPolicy(ctx context.Context, id int) (*authx.PasswordPolicy, error) {
+	var data []byte
+	err := repo.conn.QueryRow(ctx, "SELECT password_policy FROM organizations WHERE id = $1 "+
 		"AND deleted_at IS NULL", id).
-		Scan(&org.ID, &org.Name, &org.OwnerID, &org.CreatedAt, &org.UpdatedAt)
+		Scan(&data)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
 			return nil, errorx.ErrorNotFound
 		}
 		return nil, err
 	}
+	if data == nil {
+		return nil, nil
+	}
+	var policy authx.PasswordPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (repo *pgxRepository) SavePasswordPolicy(ctx context.Context, id int, policy *authx.PasswordPolicy) error {
+	var data *string
+	if policy != nil {
+		b, err := json.Marshal(policy)
+		if err != nil {
+			return err
+		}
+		s := string(b)
+		data = &s
+	}
+	_, err := repo.conn.Exec(ctx, "UPDATE organizations SET password_policy = $1::jsonb, updated_at = $2 WHERE id = $3",
+		data, time.Now().UTC(), id)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindMembers(ctx context.Context, id int) ([]*models.UserOrganization, error) {
+	rows, err := repo.conn.Query(ctx, "SELECT uo.user_id, uo.organization_id, uo.role, uo.created_at, "+
+		"u.name, u.email, u.created_at, u.updated_at "+
+		"FROM users_organizations uo JOIN users u ON u.id = uo.user_id "+
+		"WHERE uo.organization_id = $1 AND u.deleted_at IS NULL ORDER BY uo.created_at, uo.user_id", id)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	members := make([]*models.UserOrganization, 0)
+	for rows.Next() {
+		var m models.UserOrganization
+		var u models.User
+		err := rows.Scan(&m.UserId, &m.OrganizationId, &m.Role, &m.CreatedAt,
+			&u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		u.ID = m.UserId
+		m.User = &u
+		members = append(members, &m)
+	}
+	return members, rows.Err()
+}
+
+func (repo *pgxRepository) FindMember(ctx context.Context, id, userID int) (*models.UserOrganization, error) {
+	var m models.UserOrganization
+	err := repo.conn.QueryRow(ctx, "SELECT uo.user_id, uo.organization_id, uo.role, uo.created_at "+
+		"FROM users_organizations uo JOIN organizations o ON o.id = uo.organization_id "+
+		"WHERE uo.organization_id = $1 AND uo.user_id = $2 AND o.deleted_at IS NULL", id, userID).
+		Scan(&m.UserId, &m.OrganizationId, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return &m, nil
+}
+
+func (repo *pgxRepository) UpdateMemberRole(ctx context.Context, orgUser *models.UserOrganization) error {
+	return repo.changeMember(ctx, orgUser, "UPDATE users_organizations SET role = $3 "+
+		"WHERE organization_id = $1 AND user_id = $2", orgUser.OrganizationId, orgUser.UserId, orgUser.Role)
+}
+
+func (repo *pgxRepository) DeleteMember(ctx context.Context, orgUser *models.UserOrganization) error {
+	return repo.changeMember(ctx, orgUser, "DELETE FROM users_organizations "+
+		"WHERE organization_id = $1 AND user_id = $2", orgUser.OrganizationId, orgUser.UserId)
+}
+
+// changeMember runs a change of a membership and rolls it back when it left
+// the organization without an owner
+func (repo *pgxRepository) changeMember(ctx context.Context, orgUser *models.UserOrganization, query string, args ...interface{}) error {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	// locking the owners serializes concurrent changes which could each
+	// leave one owner behind
+	_, err = tx.Exec(ctx, "SELECT 1 FROM users_organizations WHERE organization_id = $1 AND role = $2 FOR UPDATE",
+		orgUser.OrganizationId, models.RoleOwner)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	owners := 0
+	err = tx.QueryRow(ctx, "SELECT COUNT(*) FROM users_organizations WHERE organization_id = $1 AND role = $2",
+		orgUser.OrganizationId, models.RoleOwner).
+		Scan(&owners)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if owners == 0 {
+		return organization.ErrLastOwner
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindRoles(ctx context.Context, id int) ([]*models.Role, error) {
+	rows, err := repo.conn.Query(ctx, "SELECT id, organization_id, name, permissions, created_at, updated_at "+
+		"FROM organization_roles WHERE organization_id = $1 ORDER BY name", id)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	roles := make([]*models.Role, 0)
+	for rows.Next() {
+		var role models.Role
+		err := rows.Scan(&role.ID, &role.OrganizationID, &role.Name, &role.Permissions, &role.CreatedAt, &role.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	return roles, rows.Err()
+}
+
+func (repo *pgxRepository) FindRole(ctx context.Context, id int, name string) (*models.Role, error) {
+	var role models.Role
+	err := repo.conn.QueryRow(ctx, "SELECT id, organization_id, name, permissions, created_at, updated_at "+
+		"FROM organization_roles WHERE organization_id = $1 AND name = $2", id, name).
+		Scan(&role.ID, &role.OrganizationID, &role.Name, &role.Permissions, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return &role, nil
+}
+
+func (repo *pgxRepository) SaveRole(ctx context.Context, role *models.Role) error {
+	err := repo.conn.QueryRow(ctx, "INSERT INTO organization_roles(organization_id, name, permissions) "+
+		"VALUES ($1,$2,$3) "+
+		"RETURNING id, created_at, updated_at",
+		role.OrganizationID, role.Name, role.Permissions).
+		Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok {
+			if pgErr.Code == "23505" {
+				return organization.ErrRoleExists
+			}
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) UpdateRole(ctx context.Context, role *models.Role) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE organization_roles SET permissions = $1, updated_at = $2 WHERE id = $3",
+		role.Permissions, now, role.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	role.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) DeleteRole(ctx context.Context, role *models.Role) error {
+	tag, err := repo.conn.Exec(ctx, "DELETE FROM organization_roles r WHERE r.id = $1 AND NOT EXISTS "+
+		"(SELECT 1 FROM users_organizations uo WHERE uo.organization_id = r.organization_id AND uo.role = r.name)",
+		role.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := repo.FindRole(ctx, role.OrganizationID, role.Name); err != nil {
+			return err
+		}
+		return organization.ErrRoleInUse
+	}
+	return nil
+}
+
+func scanOrganization(row pgx.Row) (*models.Organization, error) {
+	var org models.Organization
+	var pendingOwnerID *int
+	var requestedAt, deletedAt *time.Time
+	err := row.Scan(&org.ID, &org.Name, &org.OwnerID, &pendingOwnerID, &requestedAt,
+		&org.RequireMFA, &org.CreatedAt, &org.UpdatedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if pendingOwnerID != nil {
+		org.PendingOwnerID = *pendingOwnerID
+	}
+	if requestedAt != nil {
+		org.TransferRequestedAt = *requestedAt
+	}
+	if deletedAt != nil {
+		org.DeletedAt = *deletedAt
+	}
 	return &org, nil
 }
 
 var _ organization.Repository = (*pgxRepository)(nil)
 
 // NewRepository will create an object that represent the organization.Repository interface
-func NewPgxRepository(conn *pgx.Conn) organization.Repository {
+func NewPgxRepository(conn dbx.DB) organization.Repository {
 	return &pgxRepository{conn: conn}
 }
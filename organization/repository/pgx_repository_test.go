@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"github.com/imtanmoy/authn/internal/authx"
 	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/organization"
 	"github.com/imtanmoy/authn/tests"
 	"github.com/jackc/pgx/v4"
@@ -93,3 +95,149 @@ func TestPgxRepository_FindByID(t *testing.T) {
 		}
 	}
 }
+
+func TestPgxRepository_FindAllByUserID(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	tests.SeedUser(db)
+
+	orgs := tests.FakeOrgs(3)
+	err := tests.InsertTestOrgs(db, orgs)
+	require.NoError(t, err)
+
+	got, err := repo.FindAllByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+
+	got, err = repo.FindAllByUserID(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, got, 0)
+}
+
+func TestPgxRepository_FindMemberships(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	tests.SeedUser(db)
+	err := tests.InsertTestOrgs(db, tests.FakeOrgs(3))
+	require.NoError(t, err)
+
+	count, err := repo.CountMemberships(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	got, err := repo.FindMemberships(ctx, 1, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, 2, got[0].OrganizationId)
+	assert.Equal(t, 2, got[0].Organization.ID)
+	assert.Equal(t, models.RoleOwner, got[0].Role)
+	assert.Equal(t, 3, got[1].OrganizationId)
+
+	require.NoError(t, repo.Delete(ctx, got[0].Organization))
+	count, err = repo.CountMemberships(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	got, err = repo.FindMemberships(ctx, 2, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, got, 0)
+}
+
+func TestPgxRepository_PasswordPolicy(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	tests.SeedUser(db)
+	err := tests.InsertTestOrgs(db, tests.FakeOrgs(1))
+	require.NoError(t, err)
+
+	policy, err := repo.PasswordPolicy(ctx, 1)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	err = repo.SavePasswordPolicy(ctx, 1, &authx.PasswordPolicy{MinLength: 12, RequireDigit: true})
+	require.NoError(t, err)
+	policy, err = repo.PasswordPolicy(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &authx.PasswordPolicy{MinLength: 12, RequireDigit: true}, policy)
+
+	err = repo.SavePasswordPolicy(ctx, 1, nil)
+	require.NoError(t, err)
+	policy, err = repo.PasswordPolicy(ctx, 1)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	_, err = repo.PasswordPolicy(ctx, 2)
+	assert.Equal(t, errorx.ErrorNotFound, err)
+}
+
+func TestPgxRepository_Members(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	require.NoError(t, tests.InsertTestUsers(db, tests.FakeUsers(2)))
+	require.NoError(t, tests.InsertTestOrgs(db, tests.FakeOrgs(1)))
+	_, err := db.Exec("INSERT INTO users_organizations(user_id, organization_id) VALUES (2, 1)")
+	require.NoError(t, err)
+
+	members, err := repo.FindMembers(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, models.RoleOwner, members[0].Role)
+	assert.Equal(t, "test0@test.com", members[0].User.Email)
+
+	m, err := repo.FindMember(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleMember, m.Role)
+	_, err = repo.FindMember(ctx, 1, 3)
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	owner := &models.UserOrganization{OrganizationId: 1, UserId: 1, Role: models.RoleAdmin}
+	assert.Equal(t, organization.ErrLastOwner, repo.UpdateMemberRole(ctx, owner))
+	assert.Equal(t, organization.ErrLastOwner, repo.DeleteMember(ctx, owner))
+	m, err = repo.FindMember(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleOwner, m.Role, "the change is rolled back")
+
+	require.NoError(t, repo.UpdateMemberRole(ctx, &models.UserOrganization{OrganizationId: 1, UserId: 2, Role: models.RoleOwner}))
+	require.NoError(t, repo.DeleteMember(ctx, owner))
+	assert.Equal(t, errorx.ErrorNotFound, repo.DeleteMember(ctx, owner))
+}
+
+func TestPgxRepository_Roles(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	tests.SeedUser(db)
+	require.NoError(t, tests.InsertTestOrgs(db, tests.FakeOrgs(1)))
+
+	role := &models.Role{OrganizationID: 1, Name: "billing", Permissions: []string{organization.PermOrgRead}}
+	require.NoError(t, repo.SaveRole(ctx, role))
+	assert.NotZero(t, role.ID)
+	assert.Equal(t, organization.ErrRoleExists, repo.SaveRole(ctx, &models.Role{OrganizationID: 1, Name: "billing"}))
+
+	role.Permissions = []string{organization.PermOrgRead, organization.PermMembersRead}
+	require.NoError(t, repo.UpdateRole(ctx, role))
+	found, err := repo.FindRole(ctx, 1, "billing")
+	require.NoError(t, err)
+	assert.Equal(t, role.Permissions, found.Permissions)
+
+	_, err = db.Exec("UPDATE users_organizations SET role = 'billing'")
+	require.NoError(t, err)
+	assert.Equal(t, organization.ErrRoleInUse, repo.DeleteRole(ctx, role))
+	_, err = db.Exec("UPDATE users_organizations SET role = 'owner'")
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteRole(ctx, role))
+
+	roles, err := repo.FindRoles(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, roles, 0)
+	assert.Equal(t, errorx.ErrorNotFound, repo.DeleteRole(ctx, role))
+}
@@ -2,6 +2,7 @@ package organization
 
 import (
 	"context"
+	"github.com/imtanmoy/authn/internal/authx"
 	"github.com/imtanmoy/authn/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -23,11 +24,111 @@ func (r *repoMock) FindByID(ctx context.Context, id int) (*models.Organization,
 	return args.Get(0).(*models.Organization), args.Error(1)
 }
 
+func (r *repoMock) FindAllByUserID(ctx context.Context, userID int) ([]*models.Organization, error) {
+	args := r.Called(ctx, userID)
+	return args.Get(0).([]*models.Organization), args.Error(1)
+}
+
+func (r *repoMock) FindMemberships(ctx context.Context, userID, limit, offset int) ([]*models.UserOrganization, error) {
+	args := r.Called(ctx, userID, limit, offset)
+	return args.Get(0).([]*models.UserOrganization), args.Error(1)
+}
+
+func (r *repoMock) CountMemberships(ctx context.Context, userID int) (int, error) {
+	args := r.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (r *repoMock) PasswordPolicy(ctx context.Context, id int) (*authx.PasswordPolicy, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).(*authx.PasswordPolicy), args.Error(1)
+}
+
+func (r *repoMock) SavePasswordPolicy(ctx context.Context, id int, policy *authx.PasswordPolicy) error {
+	args := r.Called(ctx, id, policy)
+	return args.Error(0)
+}
+
 func (r *repoMock) Save(ctx context.Context, org *models.Organization) error {
 	args := r.Called(ctx, org)
 	return args.Error(0)
 }
 
+func (r *repoMock) FindMembers(ctx context.Context, id int) ([]*models.UserOrganization, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).([]*models.UserOrganization), args.Error(1)
+}
+
+func (r *repoMock) FindMember(ctx context.Context, id, userID int) (*models.UserOrganization, error) {
+	args := r.Called(ctx, id, userID)
+	return args.Get(0).(*models.UserOrganization), args.Error(1)
+}
+
+func (r *repoMock) UpdateMemberRole(ctx context.Context, orgUser *models.UserOrganization) error {
+	args := r.Called(ctx, orgUser)
+	return args.Error(0)
+}
+
+func (r *repoMock) DeleteMember(ctx context.Context, orgUser *models.UserOrganization) error {
+	args := r.Called(ctx, orgUser)
+	return args.Error(0)
+}
+
+func (r *repoMock) FindRoles(ctx context.Context, id int) ([]*models.Role, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).([]*models.Role), args.Error(1)
+}
+
+func (r *repoMock) FindRole(ctx context.Context, id int, name string) (*models.Role, error) {
+	args := r.Called(ctx, id, name)
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (r *repoMock) SaveRole(ctx context.Context, role *models.Role) error {
+	args := r.Called(ctx, role)
+	return args.Error(0)
+}
+
+func (r *repoMock) UpdateRole(ctx context.Context, role *models.Role) error {
+	args := r.Called(ctx, role)
+	return args.Error(0)
+}
+
+func (r *repoMock) DeleteRole(ctx context.Context, role *models.Role) error {
+	args := r.Called(ctx, role)
+	return args.Error(0)
+}
+
+func (r *repoMock) FindDeletedByID(ctx context.Context, id int) (*models.Organization, error) {
+	args := r.Called(ctx, id)
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+func (r *repoMock) Update(ctx context.Context, org *models.Organization) error {
+	args := r.Called(ctx, org)
+	return args.Error(0)
+}
+
+func (r *repoMock) Delete(ctx context.Context, org *models.Organization) error {
+	args := r.Called(ctx, org)
+	return args.Error(0)
+}
+
+func (r *repoMock) Restore(ctx context.Context, org *models.Organization) error {
+	args := r.Called(ctx, org)
+	return args.Error(0)
+}
+
+func (r *repoMock) UpdatePendingOwner(ctx context.Context, org *models.Organization) error {
+	args := r.Called(ctx, org)
+	return args.Error(0)
+}
+
+func (r *repoMock) Transfer(ctx context.Context, org *models.Organization) error {
+	args := r.Called(ctx, org)
+	return args.Error(0)
+}
+
 var _ Repository = (*repoMock)(nil)
 
 func Test_Save(t *testing.T) {
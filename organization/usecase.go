@@ -2,10 +2,89 @@ package organization
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
 	"github.com/imtanmoy/authn/models"
 )
 
+var (
+	// ErrLastOwner the change would leave the organization without an owner
+	ErrLastOwner = errors.New("the organization must keep at least one owner")
+	// ErrRoleExists a built-in or custom role has the name already
+	ErrRoleExists = errors.New("role already exists")
+	// ErrRoleInUse members still have the role
+	ErrRoleInUse = errors.New("role is assigned to members")
+	// ErrUnknownRole the organization has no such role
+	ErrUnknownRole = errors.New("unknown role")
+	// ErrRetentionExpired the organization was deleted too long ago to be restored
+	ErrRetentionExpired = errors.New("organization can no longer be restored")
+	// ErrAlreadyOwner the user owns the organization already
+	ErrAlreadyOwner = errors.New("already the owner of the organization")
+	// ErrNoTransfer the user is not nominated as the new owner
+	ErrNoTransfer = errors.New("no pending ownership transfer")
+)
+
+// Config of the organization use cases
+type Config struct {
+	// RetentionPeriod is how long deleted organizations can be restored
+	RetentionPeriod time.Duration
+}
+
+// Page selects a page of a listing, pages start at 1
+type Page struct {
+	Number int
+	Size   int
+}
+
+// Offset of the first item of the page
+func (p *Page) Offset() int {
+	return (p.Number - 1) * p.Size
+}
+
 type UseCase interface {
 	Save(ctx context.Context, org *models.Organization) error
 	FindByID(ctx context.Context, id int) (*models.Organization, error)
+	FindAllByUserID(ctx context.Context, userID int) ([]*models.Organization, error)
+	// Memberships returns a page of the organizations the user owns or
+	// belongs to, with the user's role in each, and their total count
+	Memberships(ctx context.Context, userID int, page *Page) ([]*models.UserOrganization, int, error)
+	Update(ctx context.Context, org *models.Organization) error
+	// Delete soft deletes the organization, it can be restored during the
+	// retention period
+	Delete(ctx context.Context, org *models.Organization) error
+	// Restore returns errorx.ErrorNotFound unless the organization is deleted
+	// and ErrRetentionExpired once the retention period is over
+	Restore(ctx context.Context, id int) (*models.Organization, error)
+	// RequestTransfer nominates a member as the new owner, who has to accept.
+	// It returns errorx.ErrorNotFound for non members and ErrAlreadyOwner.
+	RequestTransfer(ctx context.Context, org *models.Organization, userID int) error
+	// AcceptTransfer makes the nominated user the owner, the previous owner
+	// becomes admin. It returns ErrNoTransfer when userID is not nominated.
+	AcceptTransfer(ctx context.Context, org *models.Organization, userID int) error
+	// CancelTransfer returns ErrNoTransfer when no transfer is pending
+	CancelTransfer(ctx context.Context, org *models.Organization) error
+	// PasswordPolicy returns nil when the organization has no policy of its own
+	PasswordPolicy(ctx context.Context, id int) (*authx.PasswordPolicy, error)
+	SavePasswordPolicy(ctx context.Context, id int, policy *authx.PasswordPolicy) error
+	FindMembers(ctx context.Context, id int) ([]*models.UserOrganization, error)
+	// MemberRole returns the role of the user in the organization, it returns
+	// errorx.ErrorNotFound when the user is not a member
+	MemberRole(ctx context.Context, id, userID int) (*models.Role, error)
+	// AssignRole returns ErrUnknownRole, errorx.ErrorNotFound for non members
+	// and ErrLastOwner when no owner would be left
+	AssignRole(ctx context.Context, id, userID int, role string) error
+	// RemoveMember returns errorx.ErrorNotFound for non members and
+	// ErrLastOwner when no owner would be left
+	RemoveMember(ctx context.Context, id, userID int) error
+	// Roles lists the built-in roles followed by the custom ones
+	Roles(ctx context.Context, id int) ([]*models.Role, error)
+	// FindRole returns built-in and custom roles, errorx.ErrorNotFound otherwise
+	FindRole(ctx context.Context, id int, name string) (*models.Role, error)
+	// SaveRole returns ErrRoleExists when a built-in or custom role has the name
+	SaveRole(ctx context.Context, role *models.Role) error
+	UpdateRole(ctx context.Context, role *models.Role) error
+	// DeleteRole returns ErrRoleInUse when members have the role
+	DeleteRole(ctx context.Context, role *models.Role) error
 }
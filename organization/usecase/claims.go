@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/organization"
+)
+
+type claimsEnricher struct {
+	useCase organization.UseCase
+}
+
+var _ authx.ClaimsEnricher = (*claimsEnricher)(nil)
+
+// NewClaimsEnricher adds the current organization and the user's roles in it
+// to access tokens. Tokens not scoped to an organization get the first one the
+// user belongs to. Tokens without a second factor are never scoped to an
+// organization which requires MFA.
+func NewClaimsEnricher(useCase organization.UseCase) authx.ClaimsEnricher {
+	return &claimsEnricher{useCase: useCase}
+}
+
+func (e *claimsEnricher) Enrich(ctx context.Context, u authx.AuthUser, claims *authx.Claims) error {
+	mfa := claims.HasAuthMethod(authx.AuthMethodMFA)
+	if claims.OrganizationID == 0 {
+		id, err := e.defaultOrganization(ctx, u.GetId(), mfa)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			return nil
+		}
+		claims.OrganizationID = id
+	} else if !mfa {
+		org, err := e.useCase.FindByID(ctx, claims.OrganizationID)
+		if err != nil {
+			if errors.Is(err, errorx.ErrorNotFound) {
+				return errorx.ErrUnauthorized
+			}
+			return err
+		}
+		if org.RequireMFA {
+			return errorx.ErrUnauthorized
+		}
+	}
+	role, err := e.useCase.MemberRole(ctx, claims.OrganizationID, u.GetId())
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return errorx.ErrUnauthorized
+		}
+		return err
+	}
+	claims.Roles = []string{role.Name}
+	return nil
+}
+
+// defaultOrganization is the first organization of userID the token may be
+// scoped to, 0 when there is none
+func (e *claimsEnricher) defaultOrganization(ctx context.Context, userID int, mfa bool) (int, error) {
+	page := &organization.Page{Number: 1, Size: 20}
+	for {
+		memberships, total, err := e.useCase.Memberships(ctx, userID, page)
+		if err != nil {
+			return 0, err
+		}
+		for _, m := range memberships {
+			if mfa || !m.Organization.RequireMFA {
+				return m.OrganizationId, nil
+			}
+		}
+		if page.Offset()+page.Size >= total {
+			return 0, nil
+		}
+		page.Number++
+	}
+}
@@ -2,6 +2,9 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/organization"
 	"time"
@@ -9,6 +12,7 @@ import (
 
 type useCase struct {
 	repo           organization.Repository
+	config         *organization.Config
 	contextTimeout time.Duration
 }
 
@@ -16,16 +20,158 @@ func (u *useCase) FindByID(ctx context.Context, id int) (*models.Organization, e
 	return u.repo.FindByID(ctx, id)
 }
 
+func (u *useCase) FindAllByUserID(ctx context.Context, userID int) ([]*models.Organization, error) {
+	return u.repo.FindAllByUserID(ctx, userID)
+}
+
+func (u *useCase) Memberships(ctx context.Context, userID int, page *organization.Page) ([]*models.UserOrganization, int, error) {
+	total, err := u.repo.CountMemberships(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total <= page.Offset() {
+		return []*models.UserOrganization{}, total, nil
+	}
+	memberships, err := u.repo.FindMemberships(ctx, userID, page.Size, page.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	return memberships, total, nil
+}
+
+func (u *useCase) Update(ctx context.Context, org *models.Organization) error {
+	return u.repo.Update(ctx, org)
+}
+
+func (u *useCase) Delete(ctx context.Context, org *models.Organization) error {
+	return u.repo.Delete(ctx, org)
+}
+
+func (u *useCase) Restore(ctx context.Context, id int) (*models.Organization, error) {
+	org, err := u.repo.FindDeletedByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(org.DeletedAt) > u.config.RetentionPeriod {
+		return nil, organization.ErrRetentionExpired
+	}
+	if err := u.repo.Restore(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (u *useCase) RequestTransfer(ctx context.Context, org *models.Organization, userID int) error {
+	if userID == org.OwnerID {
+		return organization.ErrAlreadyOwner
+	}
+	if _, err := u.repo.FindMember(ctx, org.ID, userID); err != nil {
+		return err
+	}
+	org.PendingOwnerID = userID
+	return u.repo.UpdatePendingOwner(ctx, org)
+}
+
+func (u *useCase) AcceptTransfer(ctx context.Context, org *models.Organization, userID int) error {
+	if org.PendingOwnerID == 0 || org.PendingOwnerID != userID {
+		return organization.ErrNoTransfer
+	}
+	err := u.repo.Transfer(ctx, org)
+	if errors.Is(err, errorx.ErrorNotFound) {
+		return organization.ErrNoTransfer
+	}
+	return err
+}
+
+func (u *useCase) CancelTransfer(ctx context.Context, org *models.Organization) error {
+	if org.PendingOwnerID == 0 {
+		return organization.ErrNoTransfer
+	}
+	org.PendingOwnerID = 0
+	return u.repo.UpdatePendingOwner(ctx, org)
+}
+
+func (u *useCase) PasswordPolicy(ctx context.Context, id int) (*authx.PasswordPolicy, error) {
+	return u.repo.PasswordPolicy(ctx, id)
+}
+
+func (u *useCase) SavePasswordPolicy(ctx context.Context, id int, policy *authx.PasswordPolicy) error {
+	return u.repo.SavePasswordPolicy(ctx, id, policy)
+}
+
 func (u *useCase) Save(ctx context.Context, org *models.Organization) error {
 	return u.repo.Save(ctx, org)
 }
 
+func (u *useCase) FindMembers(ctx context.Context, id int) ([]*models.UserOrganization, error) {
+	return u.repo.FindMembers(ctx, id)
+}
+
+func (u *useCase) MemberRole(ctx context.Context, id, userID int) (*models.Role, error) {
+	m, err := u.repo.FindMember(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := u.FindRole(ctx, id, m.Role)
+	if errors.Is(err, errorx.ErrorNotFound) {
+		// a role deleted under the member grants nothing
+		return &models.Role{OrganizationID: id, Name: m.Role}, nil
+	}
+	return role, err
+}
+
+func (u *useCase) AssignRole(ctx context.Context, id, userID int, role string) error {
+	if _, err := u.FindRole(ctx, id, role); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return organization.ErrUnknownRole
+		}
+		return err
+	}
+	return u.repo.UpdateMemberRole(ctx, &models.UserOrganization{OrganizationId: id, UserId: userID, Role: role})
+}
+
+func (u *useCase) RemoveMember(ctx context.Context, id, userID int) error {
+	return u.repo.DeleteMember(ctx, &models.UserOrganization{OrganizationId: id, UserId: userID})
+}
+
+func (u *useCase) Roles(ctx context.Context, id int) ([]*models.Role, error) {
+	custom, err := u.repo.FindRoles(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(organization.BuiltinRoles(), custom...), nil
+}
+
+func (u *useCase) FindRole(ctx context.Context, id int, name string) (*models.Role, error) {
+	if role := organization.BuiltinRole(name); role != nil {
+		role.OrganizationID = id
+		return role, nil
+	}
+	return u.repo.FindRole(ctx, id, name)
+}
+
+func (u *useCase) SaveRole(ctx context.Context, role *models.Role) error {
+	if organization.BuiltinRole(role.Name) != nil {
+		return organization.ErrRoleExists
+	}
+	return u.repo.SaveRole(ctx, role)
+}
+
+func (u *useCase) UpdateRole(ctx context.Context, role *models.Role) error {
+	return u.repo.UpdateRole(ctx, role)
+}
+
+func (u *useCase) DeleteRole(ctx context.Context, role *models.Role) error {
+	return u.repo.DeleteRole(ctx, role)
+}
+
 var _ organization.UseCase = (*useCase)(nil)
 
 // NewUseCase will create new an useCase object representation of user.UseCase interface
-func NewUseCase(repo organization.Repository, timeout time.Duration) organization.UseCase {
+func NewUseCase(repo organization.Repository, config *organization.Config, timeout time.Duration) organization.UseCase {
 	return &useCase{
 		repo:           repo,
+		config:         config,
 		contextTimeout: timeout,
 	}
 }
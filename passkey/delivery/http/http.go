@@ -0,0 +1,385 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/events"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/webauthn"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passkey"
+	"github.com/imtanmoy/authn/user"
+	"github.com/imtanmoy/httpx"
+	param "github.com/oceanicdev/chi-param"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+type registerPayload struct {
+	Name       string                       `json:"name"`
+	Credential *webauthn.CredentialCreation `json:"credential"`
+}
+
+func (rp *registerPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"name": []string{"required", "min:1", "max:100"},
+	}
+	opts := govalidator.Options{
+		Data:  rp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	if rp.Credential == nil {
+		e.Add("credential", "The credential field is required")
+	}
+	return e
+}
+
+// loginOptionsPayload starts a login, MFAToken is set when the passkey is the
+// second factor after the password
+type loginOptionsPayload struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type loginPayload struct {
+	MFAToken   string                        `json:"mfa_token"`
+	Credential *webauthn.CredentialAssertion `json:"credential"`
+}
+
+type passkeyResponse struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	CredentialID   string     `json:"credential_id"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackupState    bool       `json:"backup_state"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newPasskeyResponse(p *models.Passkey) *passkeyResponse {
+	res := &passkeyResponse{
+		ID:             p.ID,
+		Name:           p.Name,
+		CredentialID:   webauthn.URLEncodedBytes(p.CredentialID).String(),
+		Transports:     p.Transports,
+		BackupEligible: p.BackupEligible,
+		BackupState:    p.BackupState,
+		CreatedAt:      p.CreatedAt,
+	}
+	if !p.LastUsedAt.IsZero() {
+		res.LastUsedAt = &p.LastUsedAt
+	}
+	return res
+}
+
+type loginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// passkeyHandler represent the http handler for passkeys
+type passkeyHandler struct {
+	useCase     passkey.UseCase
+	userUseCase user.UseCase
+	*authx.Authx
+	event events.EventEmitter
+}
+
+// List returns the passkeys of the current user
+func (handler *passkeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	passkeys, err := handler.useCase.FindAllByUser(r.Context(), handler.currentUser(r))
+	if err != nil {
+		panic(err)
+	}
+	res := make([]*passkeyResponse, len(passkeys))
+	for i, p := range passkeys {
+		res[i] = newPasskeyResponse(p)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, res)
+}
+
+// RegistrationOptions returns the options for navigator.credentials.create()
+func (handler *passkeyHandler) RegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	options, err := handler.useCase.RegistrationOptions(r.Context(), handler.currentUser(r))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, options)
+}
+
+// Register saves the passkey created with the RegistrationOptions
+func (handler *passkeyHandler) Register(w http.ResponseWriter, r *http.Request) {
+	data := &registerPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	u := handler.currentUser(r)
+	p, err := handler.useCase.Register(r.Context(), u, data.Name, data.Credential)
+	if err != nil {
+		switch {
+		case errors.Is(err, passkey.ErrInvalidChallenge), errors.Is(err, passkey.ErrInvalidCredential):
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, passkey.ErrAlreadyRegistered):
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+		default:
+			panic(err)
+		}
+		return
+	}
+	handler.emit(r, events.PasskeyRegisteredEvent, u, p)
+	httpx.ResponseJSON(w, http.StatusCreated, newPasskeyResponse(p))
+}
+
+// Revoke deletes a passkey of the current user
+func (handler *passkeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := param.Int(r, "id")
+	if err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+		return
+	}
+	u := handler.currentUser(r)
+	p, err := handler.useCase.Revoke(r.Context(), u, id)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "passkey not found", err)
+			return
+		}
+		panic(err)
+	}
+	handler.emit(r, events.PasskeyRevokedEvent, u, p)
+	httpx.NoContent(w)
+}
+
+// LoginOptions returns the options for navigator.credentials.get(). With an
+// MFA pending token the user's passkeys are allowed, otherwise the login is
+// passwordless and any discoverable passkey may answer.
+func (handler *passkeyHandler) LoginOptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &loginOptionsPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	var u *models.User
+	if data.MFAToken != "" {
+		var ok bool
+		if u, _, ok = handler.pendingUser(w, r, data.MFAToken); !ok {
+			return
+		}
+	}
+	options, err := handler.useCase.LoginOptions(ctx, u)
+	if err != nil {
+		if errors.Is(err, passkey.ErrNoPasskey) {
+			httpx.ResponseJSONError(w, r, http.StatusConflict, err.Error(), err)
+			return
+		}
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, options)
+}
+
+// Login exchanges the assertion of a passkey for the token pair. With an MFA
+// pending token the passkey is the second factor and wrong assertions count
+// as failed logins, otherwise it is the only factor.
+func (handler *passkeyHandler) Login(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &loginPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	if data.Credential == nil {
+		validationErrors := url.Values{}
+		validationErrors.Add("credential", "The credential field is required")
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+	if data.MFAToken == "" {
+		handler.passwordlessLogin(w, r, data.Credential)
+		return
+	}
+
+	u, claims, ok := handler.pendingUser(w, r, data.MFAToken)
+	if !ok {
+		return
+	}
+	ip := authx.ClientIP(r)
+	if err := handler.CheckLogin(ctx, u.Email, ip); err != nil {
+		var be *authx.LoginBlockedError
+		if !errors.As(err, &be) {
+			panic(err)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+		httpx.ResponseJSONError(w, r, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return
+	}
+	if _, err := handler.useCase.Login(ctx, u, data.Credential); err != nil {
+		if errors.Is(err, passkey.ErrInvalidCredential) {
+			handler.loginFailed(ctx, u, ip)
+		}
+		handler.loginError(w, r, err)
+		return
+	}
+	if err := handler.LoginSucceeded(ctx, u.Email); err != nil {
+		panic(err)
+	}
+	// the pending token is spent, it can't be exchanged for another pair
+	if err := handler.RevokeToken(ctx, u, claims); err != nil {
+		panic(err)
+	}
+	// the first factor recorded in the pending token, then the second
+	methods := append(append([]string(nil), claims.AuthMethods...), authx.AuthMethodHardwareKey, authx.AuthMethodMFA)
+	handler.issueTokens(w, r, u, methods...)
+}
+
+// passwordlessLogin logs in the owner of the passkey. The authenticator
+// verified the user, so possession and the PIN or biometrics are two factors.
+func (handler *passkeyHandler) passwordlessLogin(w http.ResponseWriter, r *http.Request, c *webauthn.CredentialAssertion) {
+	ctx := r.Context()
+	p, err := handler.useCase.Login(ctx, nil, c)
+	if err != nil {
+		handler.loginError(w, r, err)
+		return
+	}
+	u, err := handler.userUseCase.FindByID(ctx, p.UserID)
+	if err != nil {
+		panic(err)
+	}
+	if err := handler.CheckEmailVerified(u); err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusForbidden, "email address is not verified", err)
+		return
+	}
+	handler.issueTokens(w, r, u, authx.AuthMethodHardwareKey, authx.AuthMethodMFA)
+}
+
+func (handler *passkeyHandler) issueTokens(w http.ResponseWriter, r *http.Request, u *models.User, methods ...string) {
+	pair, err := handler.GenerateTokenPair(r.Context(), u, authx.WithAuthMethods(methods...))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, &loginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+// pendingUser returns the user of a valid MFA pending token, answering 401
+// to any other token
+func (handler *passkeyHandler) pendingUser(w http.ResponseWriter, r *http.Request, token string) (*models.User, *authx.Claims, bool) {
+	au, claims, err := handler.VerifyMFAToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, errorx.ErrInvalidToken) {
+			httpx.ResponseJSONError(w, r, http.StatusUnauthorized, "invalid or expired mfa token", err)
+			return nil, nil, false
+		}
+		panic(err)
+	}
+	u, ok := au.(*models.User)
+	if !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	return u, claims, true
+}
+
+// loginFailed counts a wrong assertion like a wrong password, locking the
+// account is announced on the bus
+func (handler *passkeyHandler) loginFailed(ctx context.Context, u *models.User, ip string) {
+	lockedUntil, err := handler.LoginFailed(ctx, u.Email, ip)
+	if err != nil {
+		panic(err)
+	}
+	if lockedUntil.IsZero() {
+		return
+	}
+	handler.event.Emit(ctx, events.UserLockedEvent, events.UserLocked{
+		UserID:      u.ID,
+		Email:       u.Email,
+		IP:          ip,
+		LockedUntil: lockedUntil,
+	})
+}
+
+func (handler *passkeyHandler) loginError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, passkey.ErrInvalidChallenge) || errors.Is(err, passkey.ErrInvalidCredential) {
+		httpx.ResponseJSONError(w, r, http.StatusUnauthorized, "invalid passkey", err)
+		return
+	}
+	panic(err)
+}
+
+func (handler *passkeyHandler) emit(r *http.Request, topic string, u *models.User, p *models.Passkey) {
+	handler.event.Emit(r.Context(), topic, events.PasskeyChanged{
+		UserID:    u.ID,
+		Email:     u.Email,
+		PasskeyID: p.ID,
+		Name:      p.Name,
+		IP:        authx.ClientIP(r),
+		ChangedAt: time.Now().UTC(),
+	})
+}
+
+func (handler *passkeyHandler) currentUser(r *http.Request) *models.User {
+	au, err := handler.GetCurrentUser(r)
+	u, ok := au.(*models.User)
+	if err != nil || !ok {
+		panic(fmt.Sprintf("could not upgrade user to an authable user, type: %T", au))
+	}
+	return u
+}
+
+// NewHandler will initialize the passkey endpoints
+func NewHandler(r *chi.Mux, aux *authx.Authx, useCase passkey.UseCase, userUseCase user.UseCase, event events.EventEmitter) {
+	handler := &passkeyHandler{
+		useCase:     useCase,
+		userUseCase: userUseCase,
+		Authx:       aux,
+		event:       event,
+	}
+	r.Post("/login/webauthn/options", handler.LoginOptions)
+	r.Post("/login/webauthn", handler.Login)
+	r.Route("/me/credentials", func(r chi.Router) {
+		r.Use(handler.AuthMiddleware)
+		r.Get("/", handler.List)
+		r.Post("/options", handler.RegistrationOptions)
+		r.Post("/", handler.Register)
+		r.Delete("/{id}", handler.Revoke)
+	})
+}
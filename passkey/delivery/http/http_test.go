@@ -0,0 +1,222 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi"
+	_authDeliveryHttp "github.com/imtanmoy/authn/auth/delivery/http"
+	_authUseCase "github.com/imtanmoy/authn/auth/usecase"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/webauthn"
+	_passkeyRepo "github.com/imtanmoy/authn/passkey/repository"
+	_passkeyUseCase "github.com/imtanmoy/authn/passkey/usecase"
+	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	_userUseCase "github.com/imtanmoy/authn/user/usecase"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const origin = "http://localhost:3000"
+
+var (
+	r    = chi.NewRouter()
+	db   *sql.DB
+	conn *pgx.Conn
+	aux  *authx.Authx
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err = stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setup()
+}
+
+func setup() {
+	timeoutContext := 30 * time.Millisecond * time.Second
+	userRepo := _userRepo.NewPgxRepository(conn)
+	tokenRepo := _tokenRepo.NewPgxRepository(conn)
+
+	useCase := _passkeyUseCase.NewUseCase(_passkeyRepo.NewPgxRepository(conn), &webauthn.Config{
+		RPID:    "localhost",
+		RPName:  "Authn",
+		Origins: []string{origin},
+		Timeout: time.Minute,
+	}, timeoutContext)
+	aux = authx.New(userRepo, &authx.AuthxConfig{
+		SecretKey:              "test",
+		AccessTokenExpireTime:  1,
+		RefreshTokenExpireTime: 5,
+	}, authx.WithRefreshTokenRepo(tokenRepo), authx.WithRevocationRepo(tokenRepo), authx.WithSecondFactor(useCase))
+
+	evt := tests.NewMockEventEmitter()
+	userUseCase := _userUseCase.NewUseCase(userRepo, timeoutContext)
+	_authDeliveryHttp.NewHandler(r, aux, _authUseCase.NewUseCase(userRepo, timeoutContext), userUseCase, evt)
+	NewHandler(r, aux, useCase, userUseCase, evt)
+}
+
+func request(t *testing.T, method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		body = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, path, body)
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+type passwordLogin struct {
+	Token      string   `json:"token"`
+	MFA        bool     `json:"mfa_required"`
+	MFAToken   string   `json:"mfa_token"`
+	MFAMethods []string `json:"mfa_methods"`
+}
+
+func login(t *testing.T) *passwordLogin {
+	w := request(t, "POST", "/login", "", map[string]string{"email": "test@test.com", "password": "password"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got passwordLogin
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	return &got
+}
+
+func loginOptions(t *testing.T, mfaToken string) *webauthn.RequestOptions {
+	w := request(t, "POST", "/login/webauthn/options", "", &loginOptionsPayload{MFAToken: mfaToken})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var options webauthn.RequestOptions
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+	return &options
+}
+
+func TestPasskeyHandler(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+	authenticator := webauthn.NewAuthenticator(origin)
+
+	first := login(t)
+	require.False(t, first.MFA)
+	token := first.Token
+
+	var registered passkeyResponse
+	t.Run("Register", func(t *testing.T) {
+		w := request(t, "POST", "/me/credentials/options", token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var options webauthn.CreationOptions
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+		assert.Equal(t, "localhost", options.RP.ID)
+		assert.Equal(t, "test@test.com", options.User.Name)
+		assert.Empty(t, options.ExcludeCredentials)
+
+		c, err := authenticator.Create(&options)
+		require.NoError(t, err)
+		w = request(t, "POST", "/me/credentials", token, &registerPayload{Name: "Laptop", Credential: c})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+		assert.Equal(t, "Laptop", registered.Name)
+		assert.Equal(t, c.ID, registered.CredentialID)
+
+		w = request(t, "POST", "/me/credentials", token, &registerPayload{Name: "Laptop", Credential: c})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "the challenge is single use")
+
+		w = request(t, "POST", "/me/credentials/options", token, nil)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+		require.Len(t, options.ExcludeCredentials, 1, "registered passkeys are excluded")
+		assert.Equal(t, c.ID, options.ExcludeCredentials[0].ID.String())
+	})
+
+	t.Run("Second factor", func(t *testing.T) {
+		pending := login(t)
+		require.True(t, pending.MFA)
+		assert.Equal(t, []string{authx.AuthMethodHardwareKey}, pending.MFAMethods)
+
+		w := request(t, "POST", "/login/webauthn/options", "", &loginOptionsPayload{MFAToken: token})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "an access token is no pending token")
+
+		options := loginOptions(t, pending.MFAToken)
+		require.Len(t, options.AllowCredentials, 1)
+		c, err := authenticator.Get(options)
+		require.NoError(t, err)
+		w = request(t, "POST", "/login/webauthn", "", &loginPayload{MFAToken: pending.MFAToken, Credential: c})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.NotEmpty(t, got.RefreshToken)
+		info, err := aux.Introspect(ctx, got.Token, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{authx.AuthMethodPassword, authx.AuthMethodHardwareKey, authx.AuthMethodMFA}, info.AuthMethods)
+
+		w = request(t, "POST", "/login/webauthn", "", &loginPayload{MFAToken: pending.MFAToken, Credential: c})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the pending token is single use")
+	})
+
+	t.Run("Passwordless", func(t *testing.T) {
+		options := loginOptions(t, "")
+		assert.Empty(t, options.AllowCredentials)
+		assert.Equal(t, webauthn.UserVerificationRequired, options.UserVerification)
+		c, err := authenticator.Get(options)
+		require.NoError(t, err)
+		w := request(t, "POST", "/login/webauthn", "", &loginPayload{Credential: c})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got loginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		info, err := aux.Introspect(ctx, got.Token, "")
+		require.NoError(t, err)
+		assert.Equal(t, "test@test.com", info.Username)
+		assert.Equal(t, []string{authx.AuthMethodHardwareKey, authx.AuthMethodMFA}, info.AuthMethods)
+
+		w = request(t, "POST", "/login/webauthn", "", &loginPayload{Credential: c})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "assertions can't be replayed")
+
+		stranger := webauthn.NewAuthenticator(origin)
+		_, err = stranger.Get(loginOptions(t, ""))
+		assert.Equal(t, webauthn.ErrNoCredential, err)
+	})
+
+	t.Run("List and revoke", func(t *testing.T) {
+		w := request(t, "GET", "/me/credentials", token, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got []passkeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		require.Len(t, got, 1)
+		assert.Equal(t, registered.ID, got[0].ID)
+		assert.NotNil(t, got[0].LastUsedAt)
+
+		path := fmt.Sprintf("/me/credentials/%d", registered.ID)
+		assert.Equal(t, http.StatusNotFound, request(t, "DELETE", "/me/credentials/999", token, nil).Code)
+		assert.Equal(t, http.StatusNoContent, request(t, "DELETE", path, token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, request(t, "DELETE", path, token, nil).Code)
+		assert.False(t, login(t).MFA, "no second factor is left")
+
+		c, err := authenticator.Get(loginOptions(t, ""))
+		require.NoError(t, err)
+		w = request(t, "POST", "/login/webauthn", "", &loginPayload{Credential: c})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "revoked passkeys can't log in")
+	})
+}
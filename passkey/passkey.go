@@ -0,0 +1,57 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/webauthn"
+	"github.com/imtanmoy/authn/models"
+)
+
+var (
+	// ErrInvalidChallenge the response answers no pending ceremony, or one
+	// which expired
+	ErrInvalidChallenge = errors.New("unknown or expired webauthn challenge")
+	// ErrInvalidCredential the authenticator response does not verify, or the
+	// passkey is unknown
+	ErrInvalidCredential = errors.New("invalid passkey")
+	// ErrAlreadyRegistered the credential is registered already
+	ErrAlreadyRegistered = errors.New("the passkey is already registered")
+	// ErrNoPasskey the user has no passkey to log in with
+	ErrNoPasskey = errors.New("the user has no passkey")
+)
+
+// Ceremonies a challenge is handed out for
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+// UseCase represent the passkey's use cases
+type UseCase interface {
+	// SecondFactorRequired reports whether the user registered a passkey,
+	// which makes the use case an authx.SecondFactor
+	SecondFactorRequired(ctx context.Context, u authx.AuthUser) (bool, error)
+	// AuthMethod is authx.AuthMethodHardwareKey
+	AuthMethod() string
+	// RegistrationOptions starts registering a passkey for u
+	RegistrationOptions(ctx context.Context, u *models.User) (*webauthn.CreationOptions, error)
+	// Register verifies the response to RegistrationOptions and saves the
+	// passkey. It returns ErrInvalidChallenge, ErrInvalidCredential and
+	// ErrAlreadyRegistered.
+	Register(ctx context.Context, u *models.User, name string, c *webauthn.CredentialCreation) (*models.Passkey, error)
+	// LoginOptions starts a login with a passkey of u, it returns ErrNoPasskey
+	// when u has none. With u nil the login is passwordless, the
+	// authenticator offers any discoverable passkey.
+	LoginOptions(ctx context.Context, u *models.User) (*webauthn.RequestOptions, error)
+	// Login verifies the response to LoginOptions of the same u and returns
+	// the passkey used, its UserID is the user logging in. Passwordless
+	// logins require user verification. It returns ErrInvalidChallenge and
+	// ErrInvalidCredential.
+	Login(ctx context.Context, u *models.User, c *webauthn.CredentialAssertion) (*models.Passkey, error)
+	FindAllByUser(ctx context.Context, u *models.User) ([]*models.Passkey, error)
+	// Revoke deletes the passkey with id of u, it returns
+	// errorx.ErrorNotFound when u has no such passkey
+	Revoke(ctx context.Context, u *models.User, id int) (*models.Passkey, error)
+}
@@ -0,0 +1,27 @@
+package passkey
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the passkey's repository contract
+type Repository interface {
+	// SaveChallenge stores a challenge and drops the expired ones
+	SaveChallenge(ctx context.Context, c *models.WebAuthnChallenge) error
+	// ConsumeChallenge deletes and returns the challenge handed out for
+	// ceremony, it must return errorx.ErrorNotFound when there is none
+	ConsumeChallenge(ctx context.Context, challenge []byte, ceremony string) (*models.WebAuthnChallenge, error)
+	// Save must return ErrAlreadyRegistered when the credential ID is taken
+	Save(ctx context.Context, p *models.Passkey) error
+	FindByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error)
+	FindAllByUserID(ctx context.Context, userID int) ([]*models.Passkey, error)
+	ExistsByUserID(ctx context.Context, userID int) (bool, error)
+	// UpdateUsage saves the signature counter, the backup state and the
+	// last use of p
+	UpdateUsage(ctx context.Context, p *models.Passkey) error
+	// Delete returns the deleted passkey, errorx.ErrorNotFound when the user
+	// has no passkey with id
+	Delete(ctx context.Context, userID, id int) (*models.Passkey, error)
+}
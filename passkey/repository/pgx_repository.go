@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passkey"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const selectPasskey = "SELECT id, user_id, name, credential_id, public_key, sign_count, aaguid, transports, " +
+	"backup_eligible, backup_state, last_used_at, created_at, updated_at FROM webauthn_credentials "
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ passkey.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the passkey.Repository interface
+func NewPgxRepository(conn dbx.DB) passkey.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) SaveChallenge(ctx context.Context, c *models.WebAuthnChallenge) error {
+	if _, err := repo.conn.Exec(ctx, "DELETE FROM webauthn_challenges WHERE expires_at < $1", time.Now().UTC()); err != nil {
+		return errorx.ErrInternalDB
+	}
+	var userID *int
+	if c.UserID != 0 {
+		userID = &c.UserID
+	}
+	err := repo.conn.QueryRow(ctx, "INSERT INTO webauthn_challenges(challenge, user_id, ceremony, expires_at) "+
+		"VALUES ($1,$2,$3,$4) RETURNING id, created_at",
+		c.Challenge, userID, c.Ceremony, c.ExpiresAt).
+		Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) ConsumeChallenge(ctx context.Context, challenge []byte, ceremony string) (*models.WebAuthnChallenge, error) {
+	var c models.WebAuthnChallenge
+	var userID *int
+	err := repo.conn.QueryRow(ctx, "DELETE FROM webauthn_challenges WHERE challenge = $1 AND ceremony = $2 "+
+		"RETURNING id, challenge, user_id, ceremony, expires_at, created_at", challenge, ceremony).
+		Scan(&c.ID, &c.Challenge, &userID, &c.Ceremony, &c.ExpiresAt, &c.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	if userID != nil {
+		c.UserID = *userID
+	}
+	return &c, nil
+}
+
+func (repo *pgxRepository) Save(ctx context.Context, p *models.Passkey) error {
+	if p.Transports == nil {
+		p.Transports = []string{}
+	}
+	err := repo.conn.QueryRow(ctx, "INSERT INTO webauthn_credentials(user_id, name, credential_id, public_key, "+
+		"sign_count, aaguid, transports, backup_eligible, backup_state) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id, created_at, updated_at",
+		p.UserID, p.Name, p.CredentialID, p.PublicKey, int64(p.SignCount), p.AAGUID, p.Transports,
+		p.BackupEligible, p.BackupState).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok {
+			if pgErr.Code == "23505" {
+				return passkey.ErrAlreadyRegistered
+			}
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	p, err := scanPasskey(repo.conn.QueryRow(ctx, selectPasskey+"WHERE credential_id = $1", credentialID))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return p, nil
+}
+
+func (repo *pgxRepository) FindAllByUserID(ctx context.Context, userID int) ([]*models.Passkey, error) {
+	rows, err := repo.conn.Query(ctx, selectPasskey+"WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	passkeys := make([]*models.Passkey, 0)
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+	return passkeys, rows.Err()
+}
+
+func (repo *pgxRepository) ExistsByUserID(ctx context.Context, userID int) (bool, error) {
+	var exists bool
+	err := repo.conn.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM webauthn_credentials WHERE user_id = $1)", userID).
+		Scan(&exists)
+	if err != nil {
+		return false, errorx.ErrInternalDB
+	}
+	return exists, nil
+}
+
+func (repo *pgxRepository) UpdateUsage(ctx context.Context, p *models.Passkey) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE webauthn_credentials SET sign_count = $1, backup_state = $2, "+
+		"last_used_at = $3, updated_at = $3 WHERE id = $4", int64(p.SignCount), p.BackupState, now, p.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrorNotFound
+	}
+	p.LastUsedAt = now
+	p.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) Delete(ctx context.Context, userID, id int) (*models.Passkey, error) {
+	p, err := scanPasskey(repo.conn.QueryRow(ctx, "DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2 "+
+		"RETURNING id, user_id, name, credential_id, public_key, sign_count, aaguid, transports, "+
+		"backup_eligible, backup_state, last_used_at, created_at, updated_at", id, userID))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return p, nil
+}
+
+func scanPasskey(row pgx.Row) (*models.Passkey, error) {
+	var p models.Passkey
+	var signCount int64
+	var lastUsedAt *time.Time
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.CredentialID, &p.PublicKey, &signCount, &p.AAGUID,
+		&p.Transports, &p.BackupEligible, &p.BackupState, &lastUsedAt, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.SignCount = uint32(signCount)
+	if lastUsedAt != nil {
+		p.LastUsedAt = *lastUsedAt
+	}
+	return &p, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passkey"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo passkey.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func TestPgxRepository_Challenges(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	expired := &models.WebAuthnChallenge{Challenge: []byte("expired"), Ceremony: passkey.CeremonyLogin,
+		ExpiresAt: time.Now().Add(-time.Minute).UTC()}
+	require.NoError(t, repo.SaveChallenge(ctx, expired))
+	c := &models.WebAuthnChallenge{Challenge: []byte("challenge"), UserID: 1, Ceremony: passkey.CeremonyRegistration,
+		ExpiresAt: time.Now().Add(time.Minute).UTC()}
+	require.NoError(t, repo.SaveChallenge(ctx, c))
+	assert.NotZero(t, c.ID)
+
+	_, err := repo.ConsumeChallenge(ctx, []byte("expired"), passkey.CeremonyLogin)
+	assert.Equal(t, errorx.ErrorNotFound, err, "expired challenges are dropped")
+	_, err = repo.ConsumeChallenge(ctx, []byte("challenge"), passkey.CeremonyLogin)
+	assert.Equal(t, errorx.ErrorNotFound, err, "the ceremony must match")
+
+	found, err := repo.ConsumeChallenge(ctx, []byte("challenge"), passkey.CeremonyRegistration)
+	require.NoError(t, err)
+	assert.Equal(t, 1, found.UserID)
+	assert.False(t, found.IsExpired())
+	_, err = repo.ConsumeChallenge(ctx, []byte("challenge"), passkey.CeremonyRegistration)
+	assert.Equal(t, errorx.ErrorNotFound, err, "challenges are single use")
+}
+
+func TestPgxRepository_Passkeys(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	exists, err := repo.ExistsByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	p := &models.Passkey{UserID: 1, Name: "Laptop", CredentialID: []byte{1, 2, 3}, PublicKey: []byte{4},
+		Transports: []string{"internal", "hybrid"}, BackupEligible: true}
+	require.NoError(t, repo.Save(ctx, p))
+	assert.NotZero(t, p.ID)
+	dup := &models.Passkey{UserID: 1, Name: "Copy", CredentialID: []byte{1, 2, 3}, PublicKey: []byte{4}}
+	assert.Equal(t, passkey.ErrAlreadyRegistered, repo.Save(ctx, dup))
+	require.NoError(t, repo.Save(ctx, &models.Passkey{UserID: 1, Name: "Key", CredentialID: []byte{9}, PublicKey: []byte{4}}))
+
+	exists, err = repo.ExistsByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	found, err := repo.FindByCredentialID(ctx, []byte{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop", found.Name)
+	assert.Equal(t, []string{"internal", "hybrid"}, found.Transports)
+	assert.True(t, found.LastUsedAt.IsZero())
+	_, err = repo.FindByCredentialID(ctx, []byte{7})
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	found.SignCount = 42
+	found.BackupState = true
+	require.NoError(t, repo.UpdateUsage(ctx, found))
+	all, err := repo.FindAllByUserID(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, uint32(42), all[0].SignCount)
+	assert.True(t, all[0].BackupState)
+	assert.False(t, all[0].LastUsedAt.IsZero())
+	assert.Equal(t, []string{}, all[1].Transports)
+
+	_, err = repo.Delete(ctx, 2, p.ID)
+	assert.Equal(t, errorx.ErrorNotFound, err, "only the owner revokes a passkey")
+	deleted, err := repo.Delete(ctx, 1, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop", deleted.Name)
+	all, err = repo.FindAllByUserID(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
@@ -0,0 +1,219 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/webauthn"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passkey"
+)
+
+// defaultTimeout is the time to complete a ceremony when the relying party
+// sets none
+const defaultTimeout = 5 * time.Minute
+
+type useCase struct {
+	repo           passkey.Repository
+	rp             *webauthn.RelyingParty
+	timeout        time.Duration
+	contextTimeout time.Duration
+}
+
+var _ passkey.UseCase = (*useCase)(nil)
+
+// NewUseCase will create new an useCase object representation of passkey.UseCase interface
+func NewUseCase(repo passkey.Repository, config *webauthn.Config, timeout time.Duration) passkey.UseCase {
+	ceremonyTimeout := config.Timeout
+	if ceremonyTimeout <= 0 {
+		ceremonyTimeout = defaultTimeout
+	}
+	return &useCase{
+		repo:           repo,
+		rp:             webauthn.New(config),
+		timeout:        ceremonyTimeout,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) SecondFactorRequired(ctx context.Context, au authx.AuthUser) (bool, error) {
+	return u.repo.ExistsByUserID(ctx, au.GetId())
+}
+
+func (u *useCase) AuthMethod() string {
+	return authx.AuthMethodHardwareKey
+}
+
+func (u *useCase) RegistrationOptions(ctx context.Context, us *models.User) (*webauthn.CreationOptions, error) {
+	registered, err := u.repo.FindAllByUserID(ctx, us.ID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := u.newChallenge(ctx, us.ID, passkey.CeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	user := webauthn.UserEntity{ID: userHandle(us.ID), Name: us.Email, DisplayName: us.Name}
+	return u.rp.CreationOptions(challenge, user, descriptors(registered)), nil
+}
+
+func (u *useCase) Register(ctx context.Context, us *models.User, name string, c *webauthn.CredentialCreation) (*models.Passkey, error) {
+	clientChallenge, err := c.Challenge()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", passkey.ErrInvalidCredential, err)
+	}
+	challenge, err := u.consumeChallenge(ctx, clientChallenge, passkey.CeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID != us.ID {
+		return nil, passkey.ErrInvalidChallenge
+	}
+	ad, err := u.rp.VerifyRegistration(challenge.Challenge, c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", passkey.ErrInvalidCredential, err)
+	}
+	p := &models.Passkey{
+		UserID:         us.ID,
+		Name:           name,
+		CredentialID:   ad.CredentialID,
+		PublicKey:      ad.PublicKey,
+		SignCount:      ad.SignCount,
+		AAGUID:         ad.AAGUID,
+		Transports:     c.Response.Transports,
+		BackupEligible: ad.BackupEligible(),
+		BackupState:    ad.BackupState(),
+	}
+	if err := u.repo.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (u *useCase) LoginOptions(ctx context.Context, us *models.User) (*webauthn.RequestOptions, error) {
+	userID := 0
+	var allow []webauthn.CredentialDescriptor
+	if us != nil {
+		registered, err := u.repo.FindAllByUserID(ctx, us.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(registered) == 0 {
+			return nil, passkey.ErrNoPasskey
+		}
+		userID = us.ID
+		allow = descriptors(registered)
+	}
+	challenge, err := u.newChallenge(ctx, userID, passkey.CeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+	return u.rp.RequestOptions(challenge, allow), nil
+}
+
+func (u *useCase) Login(ctx context.Context, us *models.User, c *webauthn.CredentialAssertion) (*models.Passkey, error) {
+	clientChallenge, err := c.Challenge()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", passkey.ErrInvalidCredential, err)
+	}
+	challenge, err := u.consumeChallenge(ctx, clientChallenge, passkey.CeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+	userID := 0
+	if us != nil {
+		userID = us.ID
+	}
+	if challenge.UserID != userID {
+		return nil, passkey.ErrInvalidChallenge
+	}
+
+	p, err := u.repo.FindByCredentialID(ctx, c.RawID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, passkey.ErrInvalidCredential
+		}
+		return nil, err
+	}
+	if us != nil && p.UserID != us.ID {
+		return nil, passkey.ErrInvalidCredential
+	}
+	// discoverable credentials name their user, it must be the owner
+	if len(c.Response.UserHandle) > 0 && !bytes.Equal(c.Response.UserHandle, userHandle(p.UserID)) {
+		return nil, passkey.ErrInvalidCredential
+	}
+
+	ad, err := u.rp.VerifyAssertion(challenge.Challenge, c, p.PublicKey, p.SignCount, us == nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", passkey.ErrInvalidCredential, err)
+	}
+	p.SignCount = ad.SignCount
+	p.BackupState = ad.BackupState()
+	if err := u.repo.UpdateUsage(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (u *useCase) FindAllByUser(ctx context.Context, us *models.User) ([]*models.Passkey, error) {
+	return u.repo.FindAllByUserID(ctx, us.ID)
+}
+
+func (u *useCase) Revoke(ctx context.Context, us *models.User, id int) (*models.Passkey, error) {
+	return u.repo.Delete(ctx, us.ID, id)
+}
+
+func (u *useCase) newChallenge(ctx context.Context, userID int, ceremony string) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	err = u.repo.SaveChallenge(ctx, &models.WebAuthnChallenge{
+		Challenge: challenge,
+		UserID:    userID,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(u.timeout).UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge spends the challenge a response signed, so the response
+// can't be replayed
+func (u *useCase) consumeChallenge(ctx context.Context, challenge []byte, ceremony string) (*models.WebAuthnChallenge, error) {
+	c, err := u.repo.ConsumeChallenge(ctx, challenge, ceremony)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, passkey.ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	if c.IsExpired() {
+		return nil, passkey.ErrInvalidChallenge
+	}
+	return c, nil
+}
+
+// userHandle is the WebAuthn user handle of the user with id, the ID itself
+// as it identifies no person outside of this service
+func userHandle(id int) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(id))
+	return handle
+}
+
+func descriptors(passkeys []*models.Passkey) []webauthn.CredentialDescriptor {
+	d := make([]webauthn.CredentialDescriptor, len(passkeys))
+	for i, p := range passkeys {
+		d[i] = webauthn.CredentialDescriptor{Type: "public-key", ID: p.CredentialID, Transports: p.Transports}
+	}
+	return d
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/events"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordless"
+	"github.com/imtanmoy/httpx"
+	"github.com/imtanmoy/logx"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+// sendTimeout bounds the lookup and the email sent after responding
+const sendTimeout = 30 * time.Second
+
+type sendPayload struct {
+	Email string `json:"email"`
+}
+
+func (sp *sendPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"email": []string{"required", "min:4", "max:100", "email"},
+	}
+	opts := govalidator.Options{
+		Data:  sp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type magicLinkPayload struct {
+	Token string `json:"token"`
+}
+
+func (mp *magicLinkPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"token": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  mp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type otpPayload struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+func (op *otpPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"email": []string{"required", "min:4", "max:100", "email"},
+		"code":  []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  op,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type loginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// mfaRequiredResponse answers a login of a user with a second factor like
+// the password login does
+type mfaRequiredResponse struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	MFAMethods  []string `json:"mfa_methods"`
+	ExpiresIn   int      `json:"expires_in"`
+}
+
+// passwordlessHandler represent the http handler for passwordless logins
+type passwordlessHandler struct {
+	useCase passwordless.UseCase
+	*authx.Authx
+	event events.EventEmitter
+}
+
+// SendMagicLink emails a login link. The response is the same whether or not
+// the email can log in, the lookup and the email happen after it.
+func (handler *passwordlessHandler) SendMagicLink(w http.ResponseWriter, r *http.Request) {
+	handler.send(w, r, handler.useCase.SendMagicLink, "if the email address can log in, a login link has been sent to it")
+}
+
+// SendOTP emails a login code like SendMagicLink
+func (handler *passwordlessHandler) SendOTP(w http.ResponseWriter, r *http.Request) {
+	handler.send(w, r, handler.useCase.SendOTP, "if the email address can log in, a login code has been sent to it")
+}
+
+// VerifyMagicLink logs in with the token of a magic link
+func (handler *passwordlessHandler) VerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &magicLinkPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	u, created, err := handler.useCase.VerifyMagicLink(ctx, data.Token)
+	if err != nil {
+		if errors.Is(err, errorx.ErrInvalidToken) ||
+			errors.Is(err, errorx.ErrTokenExpired) ||
+			errors.Is(err, errorx.ErrTokenReused) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return
+		}
+		panic(err)
+	}
+	handler.completeLogin(w, r, u, created)
+}
+
+// VerifyOTP logs in with an emailed code. Wrong codes count as failed logins.
+func (handler *passwordlessHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &otpPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	ip := authx.ClientIP(r)
+	if err := handler.CheckLogin(ctx, data.Email, ip); err != nil {
+		var be *authx.LoginBlockedError
+		if !errors.As(err, &be) {
+			panic(err)
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(be.RetryAfter.Seconds()))))
+		httpx.ResponseJSONError(w, r, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return
+	}
+
+	u, created, err := handler.useCase.VerifyOTP(ctx, data.Email, data.Code)
+	if err != nil {
+		if errors.Is(err, passwordless.ErrInvalidCode) {
+			handler.loginFailed(ctx, data.Email, ip)
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired code", err)
+			return
+		}
+		panic(err)
+	}
+	if err := handler.LoginSucceeded(ctx, data.Email); err != nil {
+		panic(err)
+	}
+	handler.completeLogin(w, r, u, created)
+}
+
+func (handler *passwordlessHandler) send(w http.ResponseWriter, r *http.Request, send func(ctx context.Context, email string) error, message string) {
+	data := &sendPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	go func(email, locale string) {
+		ctx, cancel := context.WithTimeout(mailer.WithLocale(context.Background(), locale), sendTimeout)
+		defer cancel()
+		if err := send(ctx, email); err != nil {
+			logx.Errorf("could not send passwordless login: %s", err)
+		}
+	}(data.Email, mailer.RequestLocale(r))
+
+	httpx.ResponseJSON(w, http.StatusAccepted, &messageResponse{Message: message})
+}
+
+// completeLogin answers like the password login, the access to the inbox
+// takes the place of the password
+func (handler *passwordlessHandler) completeLogin(w http.ResponseWriter, r *http.Request, u *models.User, created bool) {
+	ctx := r.Context()
+	if created {
+		handler.event.EmitWithDelay(ctx, events.UserCreateEvent, *u)
+	}
+	methods, err := handler.SecondFactorMethods(ctx, u)
+	if err != nil {
+		panic(err)
+	}
+	if len(methods) > 0 {
+		token, err := handler.GenerateMFAToken(u, authx.WithAuthMethods(authx.AuthMethodEmail))
+		if err != nil {
+			panic(err)
+		}
+		httpx.ResponseJSON(w, http.StatusOK, &mfaRequiredResponse{
+			MFARequired: true,
+			MFAToken:    token,
+			MFAMethods:  methods,
+			ExpiresIn:   handler.MFATokenExpiresIn(),
+		})
+		return
+	}
+	pair, err := handler.GenerateTokenPair(ctx, u, authx.WithAuthMethods(authx.AuthMethodEmail))
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, &loginResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	})
+}
+
+// loginFailed counts a wrong code like a wrong password, whether or not the
+// email has an account. The user is unknown here, so locking is not
+// announced on the bus.
+func (handler *passwordlessHandler) loginFailed(ctx context.Context, email, ip string) {
+	if _, err := handler.LoginFailed(ctx, email, ip); err != nil {
+		panic(err)
+	}
+}
+
+// NewHandler will initialize the passwordless login endpoints
+func NewHandler(r *chi.Mux, aux *authx.Authx, useCase passwordless.UseCase, event events.EventEmitter) {
+	handler := &passwordlessHandler{
+		useCase: useCase,
+		Authx:   aux,
+		event:   event,
+	}
+	r.Post("/login/magic-link", handler.SendMagicLink)
+	r.Post("/login/magic-link/verify", handler.VerifyMagicLink)
+	r.Post("/login/otp", handler.SendOTP)
+	r.Post("/login/otp/verify", handler.VerifyOTP)
+}
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/passwordless"
+	_passwordlessRepo "github.com/imtanmoy/authn/passwordless/repository"
+	_passwordlessUseCase "github.com/imtanmoy/authn/passwordless/usecase"
+	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	r      = chi.NewRouter()
+	db     *sql.DB
+	conn   *pgx.Conn
+	aux    *authx.Authx
+	mail   = mailer.NewMemoryMailer()
+	config = &passwordless.Config{
+		MagicLinkURL: "http://localhost:3000/login/magic-link",
+		MagicLinkTTL: 15 * time.Minute,
+		OTPTTL:       10 * time.Minute,
+		OTPAttempts:  3,
+	}
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err = stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setup()
+}
+
+func setup() {
+	timeoutContext := 30 * time.Millisecond * time.Second
+	userRepo := _userRepo.NewPgxRepository(conn)
+	tokenRepo := _tokenRepo.NewPgxRepository(conn)
+
+	aux = authx.New(userRepo, &authx.AuthxConfig{
+		SecretKey:              "test",
+		AccessTokenExpireTime:  1,
+		RefreshTokenExpireTime: 5,
+	}, authx.WithRefreshTokenRepo(tokenRepo), authx.WithRevocationRepo(tokenRepo))
+
+	useCase := _passwordlessUseCase.NewUseCase(_passwordlessRepo.NewPgxRepository(conn), userRepo, mail,
+		mailer.NewTemplates("en"), config, timeoutContext)
+	NewHandler(r, aux, useCase, tests.NewMockEventEmitter())
+}
+
+func post(t *testing.T, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// waitMail waits for the email number after+1 to email
+func waitMail(t *testing.T, email string, after int) *mailer.Message {
+	var msg *mailer.Message
+	require.Eventually(t, func() bool {
+		msg = mail.Last(email)
+		return msg != nil && len(mail.Messages()) > after
+	}, 5*time.Second, 10*time.Millisecond)
+	return msg
+}
+
+func magicLinkToken(t *testing.T, email string, after int) string {
+	msg := waitMail(t, email, after)
+	i := strings.Index(msg.Text, config.MagicLinkURL+"?")
+	require.True(t, i >= 0, msg.Text)
+	link, err := url.Parse(strings.Fields(msg.Text[i:])[0])
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+func otp(t *testing.T, email string, after int) string {
+	msg := waitMail(t, email, after)
+	fields := strings.Fields(msg.Subject)
+	return fields[len(fields)-1]
+}
+
+func assertLoggedIn(t *testing.T, w *httptest.ResponseRecorder, email string) {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got loginResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.NotEmpty(t, got.RefreshToken)
+	info, err := aux.Introspect(context.Background(), got.Token, "")
+	require.NoError(t, err)
+	assert.Equal(t, email, info.Username)
+	assert.Equal(t, []string{authx.AuthMethodEmail}, info.AuthMethods)
+}
+
+func TestPasswordlessHandler(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+
+	t.Run("Magic link", func(t *testing.T) {
+		known := post(t, "/login/magic-link", &sendPayload{Email: "test@test.com"})
+		unknown := post(t, "/login/magic-link", &sendPayload{Email: "nobody@test.com"})
+		assert.Equal(t, http.StatusAccepted, known.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+		token := magicLinkToken(t, "test@test.com", 0)
+		time.Sleep(50 * time.Millisecond)
+		assert.Nil(t, mail.Last("nobody@test.com"), "unknown emails can't sign up")
+
+		assertLoggedIn(t, post(t, "/login/magic-link/verify", &magicLinkPayload{Token: token}), "test@test.com")
+		w := post(t, "/login/magic-link/verify", &magicLinkPayload{Token: token})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "links are single use")
+		w = post(t, "/login/magic-link/verify", &magicLinkPayload{Token: "unknown"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("A new link supersedes the previous one", func(t *testing.T) {
+		sent := len(mail.Messages())
+		post(t, "/login/magic-link", &sendPayload{Email: "test@test.com"})
+		first := magicLinkToken(t, "test@test.com", sent)
+		post(t, "/login/magic-link", &sendPayload{Email: "test@test.com"})
+		second := magicLinkToken(t, "test@test.com", sent+1)
+
+		w := post(t, "/login/magic-link/verify", &magicLinkPayload{Token: first})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assertLoggedIn(t, post(t, "/login/magic-link/verify", &magicLinkPayload{Token: second}), "test@test.com")
+	})
+
+	t.Run("OTP", func(t *testing.T) {
+		sent := len(mail.Messages())
+		assert.Equal(t, http.StatusAccepted, post(t, "/login/otp", &sendPayload{Email: "test@test.com"}).Code)
+		code := otp(t, "test@test.com", sent)
+		assert.Len(t, code, 6)
+
+		w := post(t, "/login/otp/verify", &otpPayload{Email: "test@test.com", Code: "wrong"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = post(t, "/login/otp/verify", &otpPayload{Email: "nobody@test.com", Code: code})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "codes belong to their email")
+
+		assertLoggedIn(t, post(t, "/login/otp/verify", &otpPayload{Email: "test@test.com", Code: code}), "test@test.com")
+		w = post(t, "/login/otp/verify", &otpPayload{Email: "test@test.com", Code: code})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "codes are single use")
+	})
+
+	t.Run("OTP attempts are limited", func(t *testing.T) {
+		sent := len(mail.Messages())
+		post(t, "/login/otp", &sendPayload{Email: "test@test.com"})
+		code := otp(t, "test@test.com", sent)
+		for i := 0; i < config.OTPAttempts; i++ {
+			w := post(t, "/login/otp/verify", &otpPayload{Email: "test@test.com", Code: "wrong"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+		w := post(t, "/login/otp/verify", &otpPayload{Email: "test@test.com", Code: code})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "the right code comes too late")
+	})
+
+	t.Run("Unknown emails sign up when allowed", func(t *testing.T) {
+		config.CreateUsers = true
+		defer func() { config.CreateUsers = false }()
+
+		sent := len(mail.Messages())
+		post(t, "/login/otp", &sendPayload{Email: "new@test.com"})
+		code := otp(t, "new@test.com", sent)
+		assertLoggedIn(t, post(t, "/login/otp/verify", &otpPayload{Email: "new@test.com", Code: code}), "new@test.com")
+
+		u, err := _userRepo.NewPgxRepository(conn).FindByEmail(context.Background(), "new@test.com")
+		require.NoError(t, err)
+		assert.Equal(t, "new", u.Name)
+		assert.True(t, u.IsEmailVerified())
+		assert.False(t, aux.VerifyPassword(u, ""), "the empty password matches nothing")
+	})
+}
@@ -0,0 +1,55 @@
+package passwordless
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+var (
+	// ErrInvalidCode the code is wrong, expired, used already or ran out of
+	// attempts
+	ErrInvalidCode = errors.New("invalid or expired login code")
+	// ErrNoAttemptsLeft the code was verified as often as it allows
+	ErrNoAttemptsLeft = errors.New("the login code has no attempts left")
+)
+
+// Kinds of login tokens
+const (
+	KindMagicLink = "magic_link"
+	KindOTP       = "otp"
+)
+
+// Config of the passwordless logins
+type Config struct {
+	// MagicLinkURL of the page which completes the login, the token is
+	// appended as the token query parameter
+	MagicLinkURL string
+	MagicLinkTTL time.Duration
+	OTPTTL       time.Duration
+	// OTPAttempts is the number of verifications a code allows, right or wrong
+	OTPAttempts int
+	// CreateUsers signs up unknown email addresses on their first login,
+	// otherwise nothing is sent to them
+	CreateUsers bool
+}
+
+// UseCase represent the passwordless login's use cases
+type UseCase interface {
+	// SendMagicLink emails a login link to email, superseding the previous
+	// ones. Emails which can't log in are ignored without an error, so
+	// callers can't tell them apart.
+	SendMagicLink(ctx context.Context, email string) error
+	// VerifyMagicLink redeems the token of a magic link and returns the user
+	// it logs in, created reports whether the user was signed up by it. It
+	// returns errorx.ErrInvalidToken, errorx.ErrTokenExpired or
+	// errorx.ErrTokenReused for tokens which can't be redeemed.
+	VerifyMagicLink(ctx context.Context, token string) (u *models.User, created bool, err error)
+	// SendOTP emails a one-time login code to email like SendMagicLink
+	SendOTP(ctx context.Context, email string) error
+	// VerifyOTP redeems the latest code sent to email like VerifyMagicLink,
+	// it returns ErrInvalidCode for codes which can't be redeemed
+	VerifyOTP(ctx context.Context, email, code string) (u *models.User, created bool, err error)
+}
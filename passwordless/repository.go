@@ -0,0 +1,23 @@
+package passwordless
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the passwordless login's repository contract
+type Repository interface {
+	Save(ctx context.Context, lt *models.LoginToken) error
+	FindByTokenHash(ctx context.Context, kind, hash string) (*models.LoginToken, error)
+	// FindPending returns the newest unused token of the kind sent to email
+	FindPending(ctx context.Context, email, kind string) (*models.LoginToken, error)
+	// CountAttempt counts a verification of lt, it must return
+	// ErrNoAttemptsLeft when lt was verified max times already
+	CountAttempt(ctx context.Context, lt *models.LoginToken, max int) error
+	// MarkUsed redeems lt, it must return errorx.ErrTokenReused when lt was
+	// already used
+	MarkUsed(ctx context.Context, lt *models.LoginToken) error
+	// Invalidate marks every unused token of the kind sent to email as used
+	Invalidate(ctx context.Context, email, kind string) error
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordless"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const selectLoginToken = "SELECT id, email, kind, token_hash, attempts, expires_at, used_at, created_at FROM login_tokens "
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ passwordless.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the passwordless.Repository interface
+func NewPgxRepository(conn dbx.DB) passwordless.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) Save(ctx context.Context, lt *models.LoginToken) error {
+	if _, err := repo.conn.Exec(ctx, "DELETE FROM login_tokens WHERE expires_at < $1", time.Now().UTC()); err != nil {
+		return errorx.ErrInternalDB
+	}
+	err := repo.conn.QueryRow(ctx, "INSERT INTO login_tokens(email, kind, token_hash, expires_at) "+
+		"VALUES ($1,$2,$3,$4) "+
+		"RETURNING id, created_at",
+		lt.Email, lt.Kind, lt.TokenHash, lt.ExpiresAt).
+		Scan(&lt.ID, &lt.CreatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindByTokenHash(ctx context.Context, kind, hash string) (*models.LoginToken, error) {
+	lt, err := scanLoginToken(repo.conn.QueryRow(ctx, selectLoginToken+"WHERE kind = $1 AND token_hash = $2", kind, hash))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return lt, nil
+}
+
+func (repo *pgxRepository) FindPending(ctx context.Context, email, kind string) (*models.LoginToken, error) {
+	lt, err := scanLoginToken(repo.conn.QueryRow(ctx, selectLoginToken+
+		"WHERE email = $1 AND kind = $2 AND used_at IS NULL ORDER BY id DESC LIMIT 1", email, kind))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, errorx.ErrInternalDB
+	}
+	return lt, nil
+}
+
+func (repo *pgxRepository) CountAttempt(ctx context.Context, lt *models.LoginToken, max int) error {
+	err := repo.conn.QueryRow(ctx, "UPDATE login_tokens SET attempts = attempts + 1 "+
+		"WHERE id = $1 AND attempts < $2 RETURNING attempts", lt.ID, max).
+		Scan(&lt.Attempts)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return passwordless.ErrNoAttemptsLeft
+		}
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
+
+func (repo *pgxRepository) MarkUsed(ctx context.Context, lt *models.LoginToken) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE login_tokens SET used_at = $1 "+
+		"WHERE id = $2 AND used_at IS NULL", now, lt.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrTokenReused
+	}
+	lt.UsedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) Invalidate(ctx context.Context, email, kind string) error {
+	_, err := repo.conn.Exec(ctx, "UPDATE login_tokens SET used_at = $1 "+
+		"WHERE email = $2 AND kind = $3 AND used_at IS NULL", time.Now().UTC(), email, kind)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	return nil
+}
+
+func scanLoginToken(row pgx.Row) (*models.LoginToken, error) {
+	var lt models.LoginToken
+	var usedAt *time.Time
+	err := row.Scan(&lt.ID, &lt.Email, &lt.Kind, &lt.TokenHash, &lt.Attempts, &lt.ExpiresAt, &usedAt, &lt.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if usedAt != nil {
+		lt.UsedAt = *usedAt
+	}
+	return &lt, nil
+}
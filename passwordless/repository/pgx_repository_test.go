@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordless"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo passwordless.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func fakeToken(kind, hash string) *models.LoginToken {
+	return &models.LoginToken{
+		Email:     "test@test.com",
+		Kind:      kind,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}
+}
+
+func TestPgxRepository_MagicLinks(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	lt := fakeToken(passwordless.KindMagicLink, "hash")
+	require.NoError(t, repo.Save(ctx, lt))
+	assert.NotZero(t, lt.ID)
+
+	_, err := repo.FindByTokenHash(ctx, passwordless.KindOTP, "hash")
+	assert.Equal(t, errorx.ErrorNotFound, err, "the kind must match")
+	found, err := repo.FindByTokenHash(ctx, passwordless.KindMagicLink, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, "test@test.com", found.Email)
+	assert.False(t, found.IsUsed())
+
+	require.NoError(t, repo.MarkUsed(ctx, found))
+	assert.Equal(t, errorx.ErrTokenReused, repo.MarkUsed(ctx, found))
+	found, err = repo.FindByTokenHash(ctx, passwordless.KindMagicLink, "hash")
+	require.NoError(t, err)
+	assert.True(t, found.IsUsed())
+}
+
+func TestPgxRepository_OTPs(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	_, err := repo.FindPending(ctx, "test@test.com", passwordless.KindOTP)
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	first := fakeToken(passwordless.KindOTP, "first")
+	require.NoError(t, repo.Save(ctx, first))
+	require.NoError(t, repo.Invalidate(ctx, "test@test.com", passwordless.KindOTP))
+	second := fakeToken(passwordless.KindOTP, "second")
+	require.NoError(t, repo.Save(ctx, second))
+	require.NoError(t, repo.Save(ctx, fakeToken(passwordless.KindMagicLink, "link")))
+
+	found, err := repo.FindPending(ctx, "test@test.com", passwordless.KindOTP)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, found.ID, "invalidated codes are not pending")
+
+	require.NoError(t, repo.CountAttempt(ctx, found, 2))
+	require.NoError(t, repo.CountAttempt(ctx, found, 2))
+	assert.Equal(t, 2, found.Attempts)
+	assert.Equal(t, passwordless.ErrNoAttemptsLeft, repo.CountAttempt(ctx, found, 2))
+	assert.Equal(t, 2, found.Attempts)
+}
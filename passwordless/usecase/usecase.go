@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/confirmation"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordless"
+	"github.com/imtanmoy/authn/user"
+)
+
+// otpDigits is the length of the emailed login codes
+const otpDigits = 6
+
+// defaultOTPAttempts is the number of verifications a code allows when the
+// config sets none
+const defaultOTPAttempts = 5
+
+// maxNameLength is the length of users.name, new users are named after
+// their email address
+const maxNameLength = 100
+
+type useCase struct {
+	repo           passwordless.Repository
+	userRepo       user.Repository
+	mailer         mailer.Mailer
+	templates      *mailer.Templates
+	config         *passwordless.Config
+	otpAttempts    int
+	contextTimeout time.Duration
+}
+
+var _ passwordless.UseCase = (*useCase)(nil)
+
+// NewUseCase will create new an useCase object representation of passwordless.UseCase interface
+func NewUseCase(repo passwordless.Repository, userRepo user.Repository, m mailer.Mailer, t *mailer.Templates, config *passwordless.Config, timeout time.Duration) passwordless.UseCase {
+	attempts := config.OTPAttempts
+	if attempts <= 0 {
+		attempts = defaultOTPAttempts
+	}
+	return &useCase{
+		repo:           repo,
+		userRepo:       userRepo,
+		mailer:         m,
+		templates:      t,
+		config:         config,
+		otpAttempts:    attempts,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) SendMagicLink(ctx context.Context, email string) error {
+	name, ok, err := u.recipient(ctx, email)
+	if err != nil || !ok {
+		return err
+	}
+	token := confirmation.GenerateConfirmationToken()
+	if err := u.issue(ctx, email, passwordless.KindMagicLink, token, u.config.MagicLinkTTL); err != nil {
+		return err
+	}
+	msg, err := u.templates.Render(ctx, "magic_link", struct {
+		Name string
+		Link string
+		TTL  time.Duration
+	}{name, confirmation.TokenLink(u.config.MagicLinkURL, token), u.config.MagicLinkTTL})
+	if err != nil {
+		return err
+	}
+	msg.To = email
+	return u.mailer.Send(ctx, msg)
+}
+
+func (u *useCase) VerifyMagicLink(ctx context.Context, token string) (*models.User, bool, error) {
+	lt, err := u.repo.FindByTokenHash(ctx, passwordless.KindMagicLink, confirmation.HashToken(token))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, false, errorx.ErrInvalidToken
+		}
+		return nil, false, err
+	}
+	if lt.IsUsed() {
+		return nil, false, errorx.ErrTokenReused
+	}
+	if lt.IsExpired() {
+		return nil, false, errorx.ErrTokenExpired
+	}
+	if err := u.repo.MarkUsed(ctx, lt); err != nil {
+		return nil, false, err
+	}
+	return u.login(ctx, lt.Email, errorx.ErrInvalidToken)
+}
+
+func (u *useCase) SendOTP(ctx context.Context, email string) error {
+	name, ok, err := u.recipient(ctx, email)
+	if err != nil || !ok {
+		return err
+	}
+	code, err := generateOTP()
+	if err != nil {
+		return err
+	}
+	if err := u.issue(ctx, email, passwordless.KindOTP, code, u.config.OTPTTL); err != nil {
+		return err
+	}
+	msg, err := u.templates.Render(ctx, "login_code", struct {
+		Name string
+		Code string
+		TTL  time.Duration
+	}{name, code, u.config.OTPTTL})
+	if err != nil {
+		return err
+	}
+	msg.To = email
+	return u.mailer.Send(ctx, msg)
+}
+
+func (u *useCase) VerifyOTP(ctx context.Context, email, code string) (*models.User, bool, error) {
+	lt, err := u.repo.FindPending(ctx, email, passwordless.KindOTP)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, false, passwordless.ErrInvalidCode
+		}
+		return nil, false, err
+	}
+	if lt.IsExpired() {
+		return nil, false, passwordless.ErrInvalidCode
+	}
+	// the attempt is counted before comparing, concurrent guesses can't
+	// exceed the limit
+	if err := u.repo.CountAttempt(ctx, lt, u.otpAttempts); err != nil {
+		if errors.Is(err, passwordless.ErrNoAttemptsLeft) {
+			return nil, false, passwordless.ErrInvalidCode
+		}
+		return nil, false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(lt.TokenHash), []byte(confirmation.HashToken(code))) != 1 {
+		return nil, false, passwordless.ErrInvalidCode
+	}
+	if err := u.repo.MarkUsed(ctx, lt); err != nil {
+		if errors.Is(err, errorx.ErrTokenReused) {
+			return nil, false, passwordless.ErrInvalidCode
+		}
+		return nil, false, err
+	}
+	return u.login(ctx, email, passwordless.ErrInvalidCode)
+}
+
+// recipient returns the name to greet email with, ok is false when email
+// can't log in and nothing must be sent
+func (u *useCase) recipient(ctx context.Context, email string) (name string, ok bool, err error) {
+	us, err := u.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return "", u.config.CreateUsers, nil
+		}
+		return "", false, err
+	}
+	return us.Name, true, nil
+}
+
+// issue saves the hash of token, superseding the unused tokens of the kind
+// sent to email before
+func (u *useCase) issue(ctx context.Context, email, kind, token string, ttl time.Duration) error {
+	if err := u.repo.Invalidate(ctx, email, kind); err != nil {
+		return err
+	}
+	return u.repo.Save(ctx, &models.LoginToken{
+		Email:     email,
+		Kind:      kind,
+		TokenHash: confirmation.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+}
+
+// login returns the user with email, signing it up when allowed. Redeeming
+// the token proved access to the inbox, so the address counts as verified.
+// invalid is returned when the user is gone and can't be created.
+func (u *useCase) login(ctx context.Context, email string, invalid error) (*models.User, bool, error) {
+	created := false
+	us, err := u.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, errorx.ErrorNotFound) {
+			return nil, false, err
+		}
+		if !u.config.CreateUsers {
+			return nil, false, invalid
+		}
+		// an empty password matches no hash, the user sets one with a
+		// password reset
+		us = &models.User{Name: nameOf(email), Email: email}
+		if err := u.userRepo.Save(ctx, us); err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+	if !us.IsEmailVerified() {
+		if err := u.userRepo.MarkEmailVerified(ctx, us); err != nil {
+			return nil, false, err
+		}
+	}
+	return us, created, nil
+}
+
+// nameOf derives the name of a new user from the local part of email
+func nameOf(email string) string {
+	name := email
+	if i := strings.LastIndexByte(email, '@'); i > 0 {
+		name = email[:i]
+	}
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
+
+func generateOTP() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/organization"
+	"github.com/imtanmoy/authn/passwordreset"
+	"github.com/imtanmoy/httpx"
+	"github.com/imtanmoy/logx"
+	"gopkg.in/thedevsaddam/govalidator.v1"
+)
+
+// forgotTimeout bounds the lookup and the email sent after responding
+const forgotTimeout = 30 * time.Second
+
+type forgotPayload struct {
+	Email string `json:"email"`
+}
+
+func (fp *forgotPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"email": []string{"required", "min:4", "max:100", "email"},
+	}
+	opts := govalidator.Options{
+		Data:  fp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	return e
+}
+
+type resetPayload struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+func (rp *resetPayload) validate() url.Values {
+	rules := govalidator.MapData{
+		"token":            []string{"required"},
+		"password":         []string{"required"},
+		"confirm_password": []string{"required"},
+	}
+	opts := govalidator.Options{
+		Data:  rp,
+		Rules: rules,
+	}
+
+	v := govalidator.New(opts)
+	e := v.ValidateStruct()
+	if rp.Password != "" && rp.ConfirmPassword != "" && rp.Password != rp.ConfirmPassword {
+		e.Add("password", "password and confirmation password do not match")
+		e.Add("confirm_password", "password and confirmation password do not match")
+	}
+	return e
+}
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// passwordResetHandler represent the http handler for password resets
+type passwordResetHandler struct {
+	useCase       passwordreset.UseCase
+	organizations organization.UseCase
+	*authx.Authx
+}
+
+// Forgot starts a password reset. The response is the same whether or not
+// the email belongs to an account, the lookup and the email happen after it.
+func (handler *passwordResetHandler) Forgot(w http.ResponseWriter, r *http.Request) {
+	data := &forgotPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	go func(email, locale string) {
+		ctx, cancel := context.WithTimeout(mailer.WithLocale(context.Background(), locale), forgotTimeout)
+		defer cancel()
+		if err := handler.useCase.Forgot(ctx, email); err != nil {
+			logx.Errorf("could not start password reset: %s", err)
+		}
+	}(data.Email, mailer.RequestLocale(r))
+
+	httpx.ResponseJSON(w, http.StatusAccepted, &messageResponse{
+		Message: "if an account with this email exists, a link to reset its password has been sent",
+	})
+}
+
+// Reset sets a new password with the token of a reset email and logs the
+// user out everywhere
+func (handler *passwordResetHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data := &resetPayload{}
+	if err := httpx.DecodeJSON(r, data); err != nil {
+		var mr *httpx.MalformedRequest
+		if errors.As(err, &mr) {
+			httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+			return
+		}
+		panic(err)
+	}
+	validationErrors := data.validate()
+	if len(validationErrors) > 0 {
+		httpx.ResponseJSONError(w, r, 400, "invalid request", validationErrors)
+		return
+	}
+
+	pr, u, err := handler.useCase.Verify(ctx, data.Token)
+	if err != nil {
+		if isTokenError(err) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return
+		}
+		panic(err)
+	}
+	// the user is not signed in to any organization, the password has to
+	// satisfy the policies of all of them
+	organizationIDs, err := handler.organizationIDs(ctx, u.ID)
+	if err != nil {
+		panic(err)
+	}
+	policy, err := handler.PasswordPolicy(ctx, organizationIDs...)
+	if err != nil {
+		panic(err)
+	}
+	if err := policy.Validate(data.Password, u.Email, u.Name); err != nil {
+		var pe *authx.PolicyError
+		if !errors.As(err, &pe) {
+			panic(err)
+		}
+		httpx.ResponseJSONError(w, r, 400, "invalid request", pe.Values("password"))
+		return
+	}
+	hash, err := handler.HashPassword(data.Password)
+	if err != nil {
+		panic(err)
+	}
+	u.PutPassword(hash)
+	if err := handler.useCase.Reset(ctx, pr, u); err != nil {
+		if isTokenError(err) {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid or expired token", err)
+			return
+		}
+		panic(err)
+	}
+
+	if err := handler.RevokeAllTokens(ctx, u, time.Now()); err != nil {
+		panic(err)
+	}
+	// whoever reads the inbox owns the account, failed logins no longer matter
+	if err := handler.UnlockLogin(ctx, u.Email); err != nil {
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// organizationIDs lists the organizations userID owns or belongs to
+func (handler *passwordResetHandler) organizationIDs(ctx context.Context, userID int) ([]int, error) {
+	var ids []int
+	page := &organization.Page{Number: 1, Size: 100}
+	for {
+		memberships, total, err := handler.organizations.Memberships(ctx, userID, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range memberships {
+			ids = append(ids, m.OrganizationId)
+		}
+		if page.Offset()+page.Size >= total {
+			return ids, nil
+		}
+		page.Number++
+	}
+}
+
+func isTokenError(err error) bool {
+	return errors.Is(err, errorx.ErrInvalidToken) ||
+		errors.Is(err, errorx.ErrTokenExpired) ||
+		errors.Is(err, errorx.ErrTokenReused)
+}
+
+// NewHandler will initialize the password reset endpoints
+func NewHandler(r *chi.Mux, aux *authx.Authx, useCase passwordreset.UseCase, organizations organization.UseCase) {
+	handler := &passwordResetHandler{
+		useCase:       useCase,
+		organizations: organizations,
+		Authx:         aux,
+	}
+	r.Route("/password", func(r chi.Router) {
+		r.Post("/forgot", handler.Forgot)
+		r.Post("/reset", handler.Reset)
+	})
+}
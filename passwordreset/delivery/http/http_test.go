@@ -0,0 +1,168 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/organization"
+	_orgRepo "github.com/imtanmoy/authn/organization/repository"
+	_orgUseCase "github.com/imtanmoy/authn/organization/usecase"
+	"github.com/imtanmoy/authn/passwordreset"
+	_resetRepo "github.com/imtanmoy/authn/passwordreset/repository"
+	_resetUseCase "github.com/imtanmoy/authn/passwordreset/usecase"
+	"github.com/imtanmoy/authn/tests"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	_userRepo "github.com/imtanmoy/authn/user/repository"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	r    = chi.NewRouter()
+	db   *sql.DB
+	conn *pgx.Conn
+	aux  *authx.Authx
+	orgs organization.UseCase
+	mail = mailer.NewMemoryMailer()
+)
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err = stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	setup()
+}
+
+func setup() {
+	timeoutContext := 30 * time.Millisecond * time.Second
+	userRepo := _userRepo.NewPgxRepository(conn)
+	tokenRepo := _tokenRepo.NewPgxRepository(conn)
+
+	orgs = _orgUseCase.NewUseCase(_orgRepo.NewPgxRepository(conn), &organization.Config{}, timeoutContext)
+
+	aux = authx.New(userRepo, &authx.AuthxConfig{
+		SecretKey:              "test",
+		AccessTokenExpireTime:  1,
+		RefreshTokenExpireTime: 5,
+	}, authx.WithRefreshTokenRepo(tokenRepo), authx.WithRevocationRepo(tokenRepo),
+		authx.WithPasswordPolicy(authx.DefaultPasswordPolicy(), orgs))
+
+	useCase := _resetUseCase.NewUseCase(_resetRepo.NewPgxRepository(conn), userRepo, mail, mailer.NewTemplates("en"),
+		&passwordreset.Config{URL: "http://localhost:3000/reset", TTL: time.Hour}, timeoutContext)
+	NewHandler(r, aux, useCase, orgs)
+}
+
+func post(t *testing.T, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// resetToken waits for the reset email to email and returns its token
+func resetToken(t *testing.T, email string, after int) string {
+	var msg *mailer.Message
+	require.Eventually(t, func() bool {
+		msg = mail.Last(email)
+		return msg != nil && len(mail.Messages()) > after
+	}, 5*time.Second, 10*time.Millisecond)
+	i := strings.Index(msg.Text, "http://localhost:3000/reset?")
+	require.True(t, i >= 0, msg.Text)
+	link, err := url.Parse(strings.Fields(msg.Text[i:])[0])
+	require.NoError(t, err)
+	return link.Query().Get("token")
+}
+
+func TestPasswordResetHandler(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+
+	t.Run("Forgot responds the same for unknown emails", func(t *testing.T) {
+		known := post(t, "/password/forgot", &forgotPayload{Email: "test@test.com"})
+		unknown := post(t, "/password/forgot", &forgotPayload{Email: "nobody@test.com"})
+		assert.Equal(t, http.StatusAccepted, known.Code)
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+		resetToken(t, "test@test.com", 0)
+		time.Sleep(50 * time.Millisecond)
+		assert.Nil(t, mail.Last("nobody@test.com"))
+	})
+
+	t.Run("Reset sets the password once and revokes sessions", func(t *testing.T) {
+		sent := len(mail.Messages())
+		post(t, "/password/forgot", &forgotPayload{Email: "test@test.com"})
+		token := resetToken(t, "test@test.com", sent)
+
+		u, err := _userRepo.NewPgxRepository(conn).FindByEmail(context.Background(), "test@test.com")
+		require.NoError(t, err)
+		pair, err := aux.GenerateTokenPair(context.Background(), u)
+		require.NoError(t, err)
+
+		w := post(t, "/password/reset", &resetPayload{Token: token, Password: "test1234", ConfirmPassword: "test1234"})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "the policy applies")
+		assert.Contains(t, w.Body.String(), "password.user_info")
+
+		password := "correct horse battery staple"
+		w = post(t, "/password/reset", &resetPayload{Token: token, Password: password, ConfirmPassword: password})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		u, err = _userRepo.NewPgxRepository(conn).FindByEmail(context.Background(), "test@test.com")
+		require.NoError(t, err)
+		assert.True(t, aux.VerifyPassword(u, password))
+		_, err = aux.RefreshTokenPair(context.Background(), pair.RefreshToken)
+		assert.Error(t, err, "existing sessions are revoked")
+
+		w = post(t, "/password/reset", &resetPayload{Token: token, Password: password + "!", ConfirmPassword: password + "!"})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "tokens are single use")
+		assert.Contains(t, w.Body.String(), "invalid or expired token")
+	})
+
+	t.Run("Reset applies the policies of the user's organizations", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO organizations(name, owner_id) VALUES ('Strict', 1)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO users_organizations(user_id, organization_id, role) VALUES (1, 1, 'owner')")
+		require.NoError(t, err)
+		require.NoError(t, orgs.SavePasswordPolicy(context.Background(), 1, &authx.PasswordPolicy{MinLength: 40}))
+
+		sent := len(mail.Messages())
+		post(t, "/password/forgot", &forgotPayload{Email: "test@test.com"})
+		token := resetToken(t, "test@test.com", sent)
+
+		password := "another correct horse battery staple"
+		w := post(t, "/password/reset", &resetPayload{Token: token, Password: password, ConfirmPassword: password})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "password.min_length")
+
+		password += " and more"
+		w = post(t, "/password/reset", &resetPayload{Token: token, Password: password, ConfirmPassword: password})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Reset rejects unknown tokens", func(t *testing.T) {
+		w := post(t, "/password/reset", &resetPayload{Token: "unknown", Password: "x", ConfirmPassword: "x"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,18 @@
+package passwordreset
+
+import (
+	"context"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Repository represent the password reset's repository contract
+type Repository interface {
+	Save(ctx context.Context, pr *models.PasswordReset) error
+	FindByTokenHash(ctx context.Context, hash string) (*models.PasswordReset, error)
+	// MarkUsed redeems pr, it must return errorx.ErrTokenReused when pr was
+	// already used
+	MarkUsed(ctx context.Context, pr *models.PasswordReset) error
+	// InvalidateUserResets marks every unused reset of the user as used
+	InvalidateUserResets(ctx context.Context, userID int) error
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordreset"
+	"github.com/jackc/pgconn"
+)
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ passwordreset.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the passwordreset.Repository interface
+func NewPgxRepository(conn dbx.DB) passwordreset.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) Save(ctx context.Context, pr *models.PasswordReset) error {
+	err := repo.conn.QueryRow(ctx, "INSERT INTO password_resets(user_id, token_hash, expires_at) "+
+		"VALUES ($1,$2,$3) "+
+		"RETURNING id, created_at",
+		pr.UserID, pr.TokenHash, pr.ExpiresAt).
+		Scan(&pr.ID, &pr.CreatedAt)
+	if err != nil {
+		if _, ok := err.(*pgconn.PgError); ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
+
+func (repo *pgxRepository) FindByTokenHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var pr models.PasswordReset
+	var usedAt *time.Time
+	err := repo.conn.QueryRow(ctx, "SELECT id, user_id, token_hash, expires_at, used_at, created_at "+
+		"FROM password_resets WHERE token_hash = $1", hash).
+		Scan(&pr.ID, &pr.UserID, &pr.TokenHash, &pr.ExpiresAt, &usedAt, &pr.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	if usedAt != nil {
+		pr.UsedAt = *usedAt
+	}
+	return &pr, nil
+}
+
+func (repo *pgxRepository) MarkUsed(ctx context.Context, pr *models.PasswordReset) error {
+	now := time.Now().UTC()
+	tag, err := repo.conn.Exec(ctx, "UPDATE password_resets SET used_at = $1 "+
+		"WHERE id = $2 AND used_at IS NULL", now, pr.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrTokenReused
+	}
+	pr.UsedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) InvalidateUserResets(ctx context.Context, userID int) error {
+	_, err := repo.conn.Exec(ctx, "UPDATE password_resets SET used_at = $1 "+
+		"WHERE user_id = $2 AND used_at IS NULL", time.Now().UTC(), userID)
+	return err
+}
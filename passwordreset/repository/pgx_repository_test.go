@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordreset"
+	"github.com/imtanmoy/authn/tests"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log"
+	"testing"
+	"time"
+)
+
+var db *sql.DB
+var repo passwordreset.Repository
+
+func init() {
+	var err error
+	db, err = tests.ConnectTestDB("localhost", 5432, "admin", "password", "authn")
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repo = NewPgxRepository(conn)
+}
+
+func fakeReset(hash string) *models.PasswordReset {
+	return &models.PasswordReset{
+		UserID:    1,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	}
+}
+
+func TestPgxRepository_PasswordResets(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	tests.SeedUser(db)
+	ctx := context.Background()
+
+	first := fakeReset("hash-1")
+	require.NoError(t, repo.Save(ctx, first))
+	assert.NotZero(t, first.ID)
+	second := fakeReset("hash-2")
+	require.NoError(t, repo.Save(ctx, second))
+
+	found, err := repo.FindByTokenHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, found.ID)
+	assert.False(t, found.IsUsed())
+
+	_, err = repo.FindByTokenHash(ctx, "unknown")
+	assert.Equal(t, errorx.ErrorNotFound, err)
+
+	t.Run("single use", func(t *testing.T) {
+		require.NoError(t, repo.MarkUsed(ctx, found))
+		assert.True(t, found.IsUsed())
+		assert.Equal(t, errorx.ErrTokenReused, repo.MarkUsed(ctx, first))
+	})
+
+	t.Run("invalidate the others", func(t *testing.T) {
+		require.NoError(t, repo.InvalidateUserResets(ctx, 1))
+		found, err := repo.FindByTokenHash(ctx, "hash-2")
+		require.NoError(t, err)
+		assert.True(t, found.IsUsed())
+	})
+}
@@ -0,0 +1,30 @@
+package passwordreset
+
+import (
+	"context"
+	"time"
+
+	"github.com/imtanmoy/authn/models"
+)
+
+// Config of the reset emails
+type Config struct {
+	// URL of the page which lets the user choose the new password, the token
+	// is appended as the token query parameter
+	URL string
+	TTL time.Duration
+}
+
+// UseCase represent the password reset's use cases
+type UseCase interface {
+	// Forgot emails a reset link to the user with email. Unknown emails are
+	// ignored without an error, so callers can't tell them apart.
+	Forgot(ctx context.Context, email string) error
+	// Verify returns the reset of a token and its user. It returns
+	// errorx.ErrInvalidToken, errorx.ErrTokenExpired or errorx.ErrTokenReused
+	// for tokens which can't be redeemed.
+	Verify(ctx context.Context, token string) (*models.PasswordReset, *models.User, error)
+	// Reset redeems pr, saves the new password of u and invalidates the other
+	// resets of u
+	Reset(ctx context.Context, pr *models.PasswordReset, u *models.User) error
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/imtanmoy/authn/confirmation"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/passwordreset"
+	"github.com/imtanmoy/authn/user"
+)
+
+type useCase struct {
+	repo           passwordreset.Repository
+	userRepo       user.Repository
+	mailer         mailer.Mailer
+	templates      *mailer.Templates
+	config         *passwordreset.Config
+	contextTimeout time.Duration
+}
+
+var _ passwordreset.UseCase = (*useCase)(nil)
+
+// NewUseCase will create new an useCase object representation of passwordreset.UseCase interface
+func NewUseCase(repo passwordreset.Repository, userRepo user.Repository, m mailer.Mailer, t *mailer.Templates, config *passwordreset.Config, timeout time.Duration) passwordreset.UseCase {
+	return &useCase{
+		repo:           repo,
+		userRepo:       userRepo,
+		mailer:         m,
+		templates:      t,
+		config:         config,
+		contextTimeout: timeout,
+	}
+}
+
+func (u *useCase) Forgot(ctx context.Context, email string) error {
+	us, err := u.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil
+		}
+		return err
+	}
+	token := confirmation.GenerateConfirmationToken()
+	pr := &models.PasswordReset{
+		UserID:    us.ID,
+		TokenHash: confirmation.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(u.config.TTL),
+	}
+	if err := u.repo.Save(ctx, pr); err != nil {
+		return err
+	}
+	msg, err := u.templates.Render(ctx, "password_reset", struct {
+		Name string
+		Link string
+		TTL  time.Duration
+	}{us.Name, confirmation.TokenLink(u.config.URL, token), u.config.TTL})
+	if err != nil {
+		return err
+	}
+	msg.To = us.Email
+	return u.mailer.Send(ctx, msg)
+}
+
+func (u *useCase) Verify(ctx context.Context, token string) (*models.PasswordReset, *models.User, error) {
+	pr, err := u.repo.FindByTokenHash(ctx, confirmation.HashToken(token))
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, nil, errorx.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	if pr.IsUsed() {
+		return nil, nil, errorx.ErrTokenReused
+	}
+	if pr.IsExpired() {
+		return nil, nil, errorx.ErrTokenExpired
+	}
+	us, err := u.userRepo.FindByID(ctx, pr.UserID)
+	if err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			return nil, nil, errorx.ErrInvalidToken
+		}
+		return nil, nil, err
+	}
+	return pr, us, nil
+}
+
+func (u *useCase) Reset(ctx context.Context, pr *models.PasswordReset, us *models.User) error {
+	if err := u.repo.MarkUsed(ctx, pr); err != nil {
+		return err
+	}
+	if err := u.userRepo.UpdatePassword(ctx, us); err != nil {
+		return err
+	}
+	return u.repo.InvalidateUserResets(ctx, us.ID)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/imtanmoy/authn/events"
 	"github.com/imtanmoy/logx"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/pgx/v4/stdlib"
 )
 
@@ -16,15 +17,17 @@ type Registry interface {
 	Config() config.Config
 	Bus() events.EventBus
 	DB() *sql.DB
+	Pool() *pgxpool.Pool
 	Close()
 }
 
 var _ Registry = (*registry)(nil)
 
 type registry struct {
-	c  config.Config
-	b  events.EventBus
-	db *sql.DB
+	c    config.Config
+	b    events.EventBus
+	db   *sql.DB
+	pool *pgxpool.Pool
 }
 
 func (r *registry) Config() config.Config {
@@ -50,6 +53,18 @@ func (r *registry) DB() *sql.DB {
 	return r.db
 }
 
+// Pool returns the pgx connection pool the repositories share
+func (r *registry) Pool() *pgxpool.Pool {
+	if r.pool == nil {
+		pool, err := connectPool(r.c.DB.HOST, r.c.DB.PORT, r.c.DB.USERNAME, r.c.DB.PASSWORD, r.c.DB.DBNAME)
+		if err != nil {
+			logx.Fatalf("%s : %s", "Database pool could not be initiated", err)
+		}
+		r.pool = pool
+	}
+	return r.pool
+}
+
 func NewRegistry(c config.Config) Registry {
 	return &registry{c: c}
 }
@@ -66,6 +81,9 @@ func (r *registry) Init() error {
 }
 
 func (r *registry) Close() {
+	if r.pool != nil {
+		r.pool.Close()
+	}
 	err := r.db.Close()
 	if err != nil {
 		logx.Errorf("%s : %s", "Database shutdown failed", err)
@@ -78,6 +96,11 @@ func connectDB(host string, port int, username, password, database string) (*sql
 	return db, nil
 }
 
+func connectPool(host string, port int, username, password, database string) (*pgxpool.Pool, error) {
+	connString := fmt.Sprintf("postgresql://%s:%s@%s:%d/%s", username, password, host, port, database)
+	return pgxpool.Connect(context.Background(), connString)
+}
+
 func ConnectPgx(connString string) (*pgx.Conn, error) {
 	connConfig, err := pgx.ParseConfig(connString)
 	if err != nil {
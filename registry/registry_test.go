@@ -10,10 +10,11 @@ import (
 
 func testConfig() *config.Config {
 	con := &config.Config{
-		ENVIRONMENT:           "test",
-		DEBUG:                 false,
-		JwtSecretKey:          "test",
-		JwtAccessTokenExpires: 1,
+		ENVIRONMENT:            "test",
+		DEBUG:                  false,
+		JwtSecretKey:           "test",
+		JwtAccessTokenExpires:  1,
+		JwtRefreshTokenExpires: 60,
 		SERVER: config.Server{
 			HOST: "0.0.0.0",
 			PORT: 8080,
@@ -1,18 +1,56 @@
 package http
 
 import (
+	"database/sql"
+	"fmt"
 	_authDeliveryHttp "github.com/imtanmoy/authn/auth/delivery/http"
 	_authUseCase "github.com/imtanmoy/authn/auth/usecase"
 	"github.com/imtanmoy/authn/config"
+	"github.com/imtanmoy/authn/confirmation"
+	_confirmationDeliveryHttp "github.com/imtanmoy/authn/confirmation/delivery/http"
+	_confirmationRepo "github.com/imtanmoy/authn/confirmation/repository"
+	_confirmationUseCase "github.com/imtanmoy/authn/confirmation/usecase"
+	_userEventHandler "github.com/imtanmoy/authn/events/handlers/user"
 	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/mailer"
+	"github.com/imtanmoy/authn/internal/ratelimit"
+	_rateLimitRepo "github.com/imtanmoy/authn/internal/ratelimit/repository"
+	"github.com/imtanmoy/authn/internal/webauthn"
+	"github.com/imtanmoy/authn/invitation"
+	_invitationDeliveryHttp "github.com/imtanmoy/authn/invitation/delivery/http"
+	_inviteRepo "github.com/imtanmoy/authn/invitation/repository"
+	_inviteUseCase "github.com/imtanmoy/authn/invitation/usecase"
+	_lockoutRepo "github.com/imtanmoy/authn/lockout/repository"
+	"github.com/imtanmoy/authn/mfa"
+	_mfaDeliveryHttp "github.com/imtanmoy/authn/mfa/delivery/http"
+	_mfaRepo "github.com/imtanmoy/authn/mfa/repository"
+	_mfaUseCase "github.com/imtanmoy/authn/mfa/usecase"
+	"github.com/imtanmoy/authn/oauth"
+	_oauthDeliveryHttp "github.com/imtanmoy/authn/oauth/delivery/http"
+	_oauthRepo "github.com/imtanmoy/authn/oauth/repository"
+	_oauthUseCase "github.com/imtanmoy/authn/oauth/usecase"
+	"github.com/imtanmoy/authn/organization"
 	_orgDeliveryHttp "github.com/imtanmoy/authn/organization/delivery/http"
 	_orgRepo "github.com/imtanmoy/authn/organization/repository"
 	_orgUseCase "github.com/imtanmoy/authn/organization/usecase"
+	_passkeyDeliveryHttp "github.com/imtanmoy/authn/passkey/delivery/http"
+	_passkeyRepo "github.com/imtanmoy/authn/passkey/repository"
+	_passkeyUseCase "github.com/imtanmoy/authn/passkey/usecase"
+	"github.com/imtanmoy/authn/passwordless"
+	_passwordlessDeliveryHttp "github.com/imtanmoy/authn/passwordless/delivery/http"
+	_passwordlessRepo "github.com/imtanmoy/authn/passwordless/repository"
+	_passwordlessUseCase "github.com/imtanmoy/authn/passwordless/usecase"
+	"github.com/imtanmoy/authn/passwordreset"
+	_resetDeliveryHttp "github.com/imtanmoy/authn/passwordreset/delivery/http"
+	_resetRepo "github.com/imtanmoy/authn/passwordreset/repository"
+	_resetUseCase "github.com/imtanmoy/authn/passwordreset/usecase"
 	"github.com/imtanmoy/authn/registry"
+	_tokenRepo "github.com/imtanmoy/authn/token/repository"
+	_userDeliveryHttp "github.com/imtanmoy/authn/user/delivery/http"
 	_userRepo "github.com/imtanmoy/authn/user/repository"
 	_userUseCase "github.com/imtanmoy/authn/user/usecase"
-	"github.com/jackc/pgx/v4/stdlib"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -25,32 +63,266 @@ func RegisterHandler(r *chi.Mux, rg registry.Registry) {
 
 	timeoutContext := 30 * time.Millisecond * time.Second //TODO it will come from config
 
-	conn, err := stdlib.AcquireConn(rg.DB())
+	pool := rg.Pool()
+
+	orgRepo := _orgRepo.NewPgxRepository(pool)
+	userRepo := _userRepo.NewPgxRepository(pool)
+	tokenRepo := _tokenRepo.NewPgxRepository(pool)
+	oauthRepo := _oauthRepo.NewPgxRepository(pool)
+	lockoutRepo := _lockoutRepo.NewPgxRepository(pool)
+	resetRepo := _resetRepo.NewPgxRepository(pool)
+	confirmationRepo := _confirmationRepo.NewPgxRepository(pool)
+	inviteRepo := _inviteRepo.NewPgxRepository(pool)
+	mfaRepo := _mfaRepo.NewPgxRepository(pool)
+	passkeyRepo := _passkeyRepo.NewPgxRepository(pool)
+	passwordlessRepo := _passwordlessRepo.NewPgxRepository(pool)
+
+	authxConfig := authx.AuthxConfig{
+		SecretKey:              config.Conf.JwtSecretKey,
+		AccessTokenExpireTime:  config.Conf.JwtAccessTokenExpires,
+		RefreshTokenExpireTime: config.Conf.JwtRefreshTokenExpires,
+		Issuer:                 config.Conf.JwtIssuer,
+		Audience:               config.Conf.JwtAudience,
+		AdminEmails:            config.Conf.AdminEmails,
+		RequireVerifiedEmail:   config.Conf.CONFIRMATION.RequireVerified,
+		MFATokenExpireTime:     config.Conf.MFA.TokenTTL,
+	}
+
+	orgUseCase := _orgUseCase.NewUseCase(orgRepo, &organization.Config{
+		RetentionPeriod: time.Duration(config.Conf.ORGANIZATION.RetentionDays) * 24 * time.Hour,
+	}, timeoutContext)
+
+	mfaUseCase := _mfaUseCase.NewUseCase(mfaRepo, &mfa.Config{
+		Issuer:        config.Conf.MFA.Issuer,
+		EncryptionKey: []byte(config.Conf.MFA.EncryptionKey),
+		RecoveryCodes: config.Conf.MFA.RecoveryCodes,
+	}, timeoutContext)
+
+	passkeyUseCase := _passkeyUseCase.NewUseCase(passkeyRepo, &webauthn.Config{
+		RPID:             config.Conf.WEBAUTHN.RPID,
+		RPName:           config.Conf.WEBAUTHN.RPName,
+		Origins:          config.Conf.WEBAUTHN.Origins,
+		Timeout:          time.Duration(config.Conf.WEBAUTHN.Timeout) * time.Second,
+		UserVerification: config.Conf.WEBAUTHN.UserVerification,
+	}, timeoutContext)
+
+	authxOptions := []authx.Option{
+		authx.WithRefreshTokenRepo(tokenRepo),
+		authx.WithRevocationRepo(tokenRepo),
+		authx.WithClaimsEnricher(_orgUseCase.NewClaimsEnricher(orgUseCase)),
+		authx.WithSecondFactor(mfaUseCase, passkeyUseCase),
+	}
+	if config.Conf.JwtKeyringDir != "" {
+		reload := time.Duration(config.Conf.JwtKeyringReload) * time.Second
+		keyring, err := authx.OpenKeyring(config.Conf.JwtKeyringDir, reload)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authxOptions = append(authxOptions, authx.WithKeyProvider(keyring))
+	} else if config.Conf.JwtSigningKeyFile != "" {
+		key, err := authx.LoadSigningKey(config.Conf.JwtSigningKeyID, config.Conf.JwtSigningKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authxOptions = append(authxOptions, authx.WithKeyProvider(authx.NewStaticKeys(key)))
+	}
+
+	if config.Conf.LOCKOUT.Enabled {
+		account, ip := lockoutPolicies(config.Conf.LOCKOUT)
+		authxOptions = append(authxOptions, authx.WithLoginLockout(lockoutRepo, account, ip))
+	}
+
+	hashers, err := authx.NewHasherRegistry(config.Conf.PASSWORD.Hasher, append([]authx.PasswordHasher{
+		authx.NewArgon2idHasher(config.Conf.PASSWORD.Argon2Memory, config.Conf.PASSWORD.Argon2Iterations, config.Conf.PASSWORD.Argon2Parallelism),
+		authx.NewBcryptHasher(config.Conf.PASSWORD.BcryptCost),
+		authx.NewScryptHasher(config.Conf.PASSWORD.ScryptN, config.Conf.PASSWORD.ScryptR, config.Conf.PASSWORD.ScryptP),
+	}, authx.ImportedHashers()...)...)
 	if err != nil {
 		log.Fatal(err)
 	}
+	authxOptions = append(authxOptions, authx.WithPasswordHashers(hashers))
 
-	orgRepo := _orgRepo.NewPgxRepository(conn)
-	userRepo := _userRepo.NewPgxRepository(conn)
-	//inviteRepo := _inviteRepo.NewRepository(rg.DB())
-
-	authxConfig := authx.AuthxConfig{
-		SecretKey:             config.Conf.JwtSecretKey,
-		AccessTokenExpireTime: config.Conf.JwtAccessTokenExpires,
+	policy := &authx.PasswordPolicy{
+		MinLength:     config.Conf.PASSWORD.MinLength,
+		MaxLength:     config.Conf.PASSWORD.MaxLength,
+		RequireUpper:  config.Conf.PASSWORD.RequireUpper,
+		RequireLower:  config.Conf.PASSWORD.RequireLower,
+		RequireDigit:  config.Conf.PASSWORD.RequireDigit,
+		RequireSymbol: config.Conf.PASSWORD.RequireSymbol,
+		CheckUserInfo: config.Conf.PASSWORD.CheckUserInfo,
+		MinScore:      config.Conf.PASSWORD.MinScore,
 	}
+	if config.Conf.PASSWORD.DenylistFile != "" {
+		policy.Denylist, err = authx.LoadDenylist(config.Conf.PASSWORD.DenylistFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if config.Conf.PASSWORD.BreachFile != "" || config.Conf.PASSWORD.BreachIndex != "" {
+		policy.Breaches, err = newBreachCorpus(config.Conf.PASSWORD)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	authxOptions = append(authxOptions, authx.WithPasswordPolicy(policy, orgUseCase))
 
-	au := authx.New(userRepo, &authxConfig)
+	au := authx.New(userRepo, &authxConfig, authxOptions...)
 
-	orgUseCase := _orgUseCase.NewUseCase(orgRepo, timeoutContext)
 	userUseCase := _userUseCase.NewUseCase(userRepo, timeoutContext)
 	authUseCase := _authUseCase.NewUseCase(userRepo, timeoutContext)
-	//invitationUseCase := _inviteUseCase.NewUseCase(inviteRepo, timeoutContext)
-	//confirmationUseCase := _confirmationUseCase.NewUseCase(timeoutContext)
+	oauthUseCase := _oauthUseCase.NewUseCase(oauthRepo, &oauth.Config{
+		Scopes:  config.Conf.OAUTH.Scopes,
+		CodeTTL: time.Duration(config.Conf.OAUTH.CodeTTL) * time.Second,
+	}, timeoutContext)
+	mail, err := newMailer(config.Conf.MAIL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	templates := mailer.NewTemplates(config.Conf.MAIL.DefaultLocale)
+	if config.Conf.MAIL.TemplatesDir != "" {
+		if templates, err = mailer.LoadTemplates(config.Conf.MAIL.TemplatesDir, config.Conf.MAIL.DefaultLocale); err != nil {
+			log.Fatal(err)
+		}
+	}
+	resetUseCase := _resetUseCase.NewUseCase(resetRepo, userRepo, mail, templates, &passwordreset.Config{
+		URL: config.Conf.PASSWORDRESET.URL,
+		TTL: time.Duration(config.Conf.PASSWORDRESET.TokenTTL) * time.Minute,
+	}, timeoutContext)
+	confirmationUseCase := _confirmationUseCase.NewUseCase(confirmationRepo, userRepo, mail, templates, &confirmation.Config{
+		URL:            config.Conf.CONFIRMATION.URL,
+		TTL:            time.Duration(config.Conf.CONFIRMATION.TokenTTL) * time.Minute,
+		ResendInterval: time.Duration(config.Conf.CONFIRMATION.ResendInterval) * time.Second,
+	}, timeoutContext)
+	_userEventHandler.SetConfirmationSender(confirmationUseCase)
+	invitationUseCase := _inviteUseCase.NewUseCase(inviteRepo, userRepo, mail, templates, &invitation.Config{
+		URL: config.Conf.INVITATION.URL,
+		TTL: time.Duration(config.Conf.INVITATION.TokenTTL) * time.Minute,
+	}, timeoutContext)
+	passwordlessUseCase := _passwordlessUseCase.NewUseCase(passwordlessRepo, userRepo, mail, templates, &passwordless.Config{
+		MagicLinkURL: config.Conf.PASSWORDLESS.MagicLinkURL,
+		MagicLinkTTL: time.Duration(config.Conf.PASSWORDLESS.MagicLinkTTL) * time.Minute,
+		OTPTTL:       time.Duration(config.Conf.PASSWORDLESS.OTPTTL) * time.Minute,
+		OTPAttempts:  config.Conf.PASSWORDLESS.OTPAttempts,
+		CreateUsers:  config.Conf.PASSWORDLESS.CreateUsers,
+	}, timeoutContext)
+
+	if config.Conf.RATELIMIT.Enabled {
+		limiter, err := newRateLimiter(config.Conf.RATELIMIT, au, rg.DB())
+		if err != nil {
+			log.Fatal(err)
+		}
+		// middlewares must be set up before the first route
+		r.Use(limiter.Handler)
+	}
 
-	_orgDeliveryHttp.NewHandler(r, au, orgUseCase, b)
+	_orgDeliveryHttp.NewHandler(r, au, orgUseCase, userUseCase, b)
 	//_userDeliveryHttp.NewHandler(r, userUseCase, orgUseCase, au)
 	//_authDeliveryHttp.NewHandler(r, authUseCase, userUseCase, au, b)
 	_authDeliveryHttp.NewHandler(r, au, authUseCase, userUseCase, b)
-	//_invitationDeliveryHttp.NewHandler(r, invitationUseCase, userUseCase, orgUseCase, au)
-	//_confirmationDeliveryHttp.NewHandler(r, confirmationUseCase)
+	_oauthDeliveryHttp.NewHandler(r, au, oauthUseCase, userUseCase, config.Conf.OAUTH.ConsentURL)
+	_userDeliveryHttp.NewAdminHandler(r, userUseCase, au)
+	_orgDeliveryHttp.NewAdminHandler(r, au, orgUseCase, b)
+	_resetDeliveryHttp.NewHandler(r, au, resetUseCase, orgUseCase)
+	_invitationDeliveryHttp.NewHandler(r, au, invitationUseCase, userUseCase, orgUseCase, b)
+	_confirmationDeliveryHttp.NewHandler(r, confirmationUseCase)
+	_mfaDeliveryHttp.NewHandler(r, au, mfaUseCase, b)
+	_passkeyDeliveryHttp.NewHandler(r, au, passkeyUseCase, userUseCase, b)
+	_passwordlessDeliveryHttp.NewHandler(r, au, passwordlessUseCase, b)
+}
+
+func newBreachCorpus(conf config.Password) (*authx.BreachCorpus, error) {
+	var file *authx.HIBPFile
+	var index *authx.BloomIndex
+	var err error
+	if conf.BreachFile != "" {
+		if file, err = authx.OpenHIBPFile(conf.BreachFile); err != nil {
+			return nil, err
+		}
+	}
+	if conf.BreachIndex != "" {
+		if index, err = authx.LoadBloomIndex(conf.BreachIndex); err != nil {
+			return nil, err
+		}
+	}
+	return authx.NewBreachCorpus(file, index, conf.BreachThreshold)
+}
+
+func newMailer(conf config.Mail) (mailer.Mailer, error) {
+	switch conf.Transport {
+	case "", "log":
+		return mailer.NewLogMailer(), nil
+	case "smtp":
+		return mailer.NewSMTPMailer(&mailer.SMTPConfig{
+			Host:     conf.SMTP.Host,
+			Port:     conf.SMTP.Port,
+			Username: conf.SMTP.Username,
+			Password: conf.SMTP.Password,
+			TLS:      conf.SMTP.TLS,
+			From:     conf.From,
+		}), nil
+	case "maildir":
+		return mailer.NewMaildirMailer(conf.MaildirDir, conf.From)
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", conf.Transport)
+	}
+}
+
+func lockoutPolicies(conf config.Lockout) (authx.LockoutPolicy, authx.LockoutPolicy) {
+	seconds := func(s int) time.Duration {
+		return time.Duration(s) * time.Second
+	}
+	account := authx.LockoutPolicy{
+		FreeAttempts: conf.AccountFreeAttempts,
+		BaseDelay:    seconds(conf.BaseDelay),
+		MaxDelay:     seconds(conf.MaxDelay),
+		LockAfter:    conf.AccountLockAfter,
+		LockDuration: seconds(conf.LockDuration),
+		Window:       seconds(conf.Window),
+	}
+	ip := account
+	ip.FreeAttempts = conf.IPFreeAttempts
+	ip.LockAfter = conf.IPLockAfter
+	return account, ip
+}
+
+func newRateLimiter(conf config.RateLimit, au *authx.Authx, db *sql.DB) (*ratelimit.Limiter, error) {
+	var store ratelimit.Store
+	switch conf.Backend {
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = _rateLimitRepo.NewPgxRepository(db)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", conf.Backend)
+	}
+
+	policies := make([]*ratelimit.Policy, len(conf.Policies))
+	for i, p := range conf.Policies {
+		if p.Limit < 1 || p.Period < 1 {
+			return nil, fmt.Errorf("rate limit policy %q needs a positive limit and period", p.Name)
+		}
+		var keys []ratelimit.KeyFunc
+		for _, name := range strings.Split(p.Key, ",") {
+			switch strings.TrimSpace(name) {
+			case "ip":
+				keys = append(keys, ratelimit.ByIP)
+			case "user":
+				keys = append(keys, ratelimit.ByUser(au))
+			case "client":
+				keys = append(keys, ratelimit.ByClient)
+			default:
+				return nil, fmt.Errorf("rate limit policy %q has unknown key %q", p.Name, name)
+			}
+		}
+		policies[i] = &ratelimit.Policy{
+			Name:   p.Name,
+			Method: p.Method,
+			Path:   p.Path,
+			Rate:   ratelimit.Rate{Limit: p.Limit, Period: time.Duration(p.Period) * time.Second},
+			Key:    ratelimit.FirstKey(keys...),
+		}
+	}
+	return ratelimit.New(store, policies...), nil
 }
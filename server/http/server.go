@@ -26,7 +26,7 @@ func newRouter() (*chi.Mux, error) {
 	r.Use(_chiMiddleware.DefaultCompress)
 	r.Use(_chiMiddleware.Timeout(15 * time.Second))
 	r.Use(_chiMiddleware.Logger)
-	r.Use(_chiMiddleware.AllowContentType("application/json"))
+	r.Use(_chiMiddleware.AllowContentType("application/json", "application/x-www-form-urlencoded", "text/csv"))
 	r.Use(_chiMiddleware.Heartbeat("/heartbeat"))
 	//r.Use(render.SetContentType(3)) //render.ContentTypeJSON resolve value 3
 	return r, nil
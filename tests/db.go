@@ -18,7 +18,7 @@ func ConnectTestDB(host string, port int, username, password, database string) (
 }
 
 func TruncateTestDB(db *sql.DB) {
-	_, err := db.Exec("TRUNCATE TABLE users, organizations, invitations, users_organizations RESTART IDENTITY;")
+	_, err := db.Exec("TRUNCATE TABLE users, organizations, invitations, users_organizations, refresh_tokens, revoked_tokens, user_token_revocations, oauth_clients, login_attempts, rate_limit_buckets, password_resets, email_confirmations, organization_roles, totp_factors, recovery_codes, webauthn_credentials, webauthn_challenges, login_tokens, oauth_authorization_codes, oauth_consents RESTART IDENTITY;")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -113,5 +113,12 @@ func InsertTestOrgs(db *sql.DB, orgs []*models.Organization) error {
 		_ = tx.Rollback()
 		return err
 	}
+	// owners are members of their organizations
+	_, err = tx.Exec("INSERT INTO users_organizations(user_id, organization_id, role) " +
+		"SELECT owner_id, id, 'owner' FROM organizations ON CONFLICT DO NOTHING")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
 	return tx.Commit()
 }
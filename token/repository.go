@@ -0,0 +1,11 @@
+package token
+
+import (
+	"github.com/imtanmoy/authn/internal/authx"
+)
+
+// Repository persists the server side state of issued tokens
+type Repository interface {
+	authx.RefreshTokenRepo
+	authx.RevocationRepo
+}
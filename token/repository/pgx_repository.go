@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/dbx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/token"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type pgxRepository struct {
+	conn dbx.DB
+}
+
+var _ token.Repository = (*pgxRepository)(nil)
+
+// NewPgxRepository will create an object that represent the token.Repository interface
+func NewPgxRepository(conn dbx.DB) token.Repository {
+	return &pgxRepository{conn: conn}
+}
+
+func (repo *pgxRepository) SaveRefreshToken(ctx context.Context, rt *authx.RefreshToken) error {
+	return saveRefreshToken(ctx, repo.conn, rt)
+}
+
+func (repo *pgxRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*authx.RefreshToken, error) {
+	var rt authx.RefreshToken
+	var revokedAt *time.Time
+	var replacedBy *int
+	var clientID *string
+	var organizationID *int
+	err := repo.conn.QueryRow(ctx, "SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, "+
+		"auth_methods, scope, client_id, organization_id, created_at FROM refresh_tokens WHERE token_hash = $1", hash).
+		Scan(&rt.ID, &rt.UserID, &rt.FamilyID, &rt.TokenHash, &rt.ExpiresAt, &revokedAt, &replacedBy,
+			&rt.AuthMethods, &rt.Scope, &clientID, &organizationID, &rt.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return nil, errorx.ErrorNotFound
+		}
+		return nil, err
+	}
+	if revokedAt != nil {
+		rt.RevokedAt = *revokedAt
+	}
+	if replacedBy != nil {
+		rt.ReplacedBy = *replacedBy
+	}
+	if clientID != nil {
+		rt.ClientID = *clientID
+	}
+	if organizationID != nil {
+		rt.OrganizationID = *organizationID
+	}
+	return &rt, nil
+}
+
+func (repo *pgxRepository) RotateRefreshToken(ctx context.Context, old, next *authx.RefreshToken) error {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	tag, err := tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = $1 "+
+		"WHERE id = $2 AND revoked_at IS NULL", now, old.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if tag.RowsAffected() == 0 {
+		return errorx.ErrTokenReused
+	}
+	if err := saveRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET replaced_by = $1 WHERE id = $2", next.ID, old.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errorx.ErrInternalDB
+	}
+	old.RevokedAt = now
+	old.ReplacedBy = next.ID
+	return nil
+}
+
+func (repo *pgxRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := repo.conn.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = $1 "+
+		"WHERE family_id = $2 AND revoked_at IS NULL", time.Now().UTC(), familyID)
+	return err
+}
+
+func (repo *pgxRepository) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	_, err := repo.conn.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = $1 "+
+		"WHERE user_id = $2 AND revoked_at IS NULL", time.Now().UTC(), userID)
+	return err
+}
+
+func (repo *pgxRepository) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	_, err := repo.conn.Exec(ctx, "INSERT INTO revoked_tokens(jti, user_id, expires_at) "+
+		"VALUES ($1,$2,$3) "+
+		"ON CONFLICT (jti) DO NOTHING", jti, userID, expiresAt)
+	return err
+}
+
+func (repo *pgxRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	found := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) AS found FROM revoked_tokens WHERE jti = $1", jti).
+		Scan(&found)
+	if err != nil {
+		return false, err
+	}
+	return found > 0, nil
+}
+
+func (repo *pgxRepository) RevokeUserTokens(ctx context.Context, userID int, before time.Time) error {
+	_, err := repo.conn.Exec(ctx, "INSERT INTO user_token_revocations(user_id, revoked_before) "+
+		"VALUES ($1,$2) "+
+		"ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before", userID, before)
+	return err
+}
+
+func (repo *pgxRepository) UserTokensRevokedBefore(ctx context.Context, userID int) (time.Time, error) {
+	var before time.Time
+	err := repo.conn.QueryRow(ctx, "SELECT revoked_before FROM user_token_revocations WHERE user_id = $1", userID).
+		Scan(&before)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return before, nil
+}
+
+func (repo *pgxRepository) PurgeExpiredRevocations(ctx context.Context, now time.Time) error {
+	_, err := repo.conn.Exec(ctx, "DELETE FROM revoked_tokens WHERE expires_at < $1", now)
+	return err
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func saveRefreshToken(ctx context.Context, q queryRower, rt *authx.RefreshToken) error {
+	methods := rt.AuthMethods
+	if methods == nil {
+		methods = []string{}
+	}
+	var clientID *string
+	if rt.ClientID != "" {
+		clientID = &rt.ClientID
+	}
+	var organizationID *int
+	if rt.OrganizationID != 0 {
+		organizationID = &rt.OrganizationID
+	}
+	err := q.QueryRow(ctx, "INSERT INTO refresh_tokens(user_id, family_id, token_hash, expires_at, auth_methods, "+
+		"scope, client_id, organization_id) "+
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8) "+
+		"RETURNING id, created_at",
+		rt.UserID, rt.FamilyID, rt.TokenHash, rt.ExpiresAt, methods, rt.Scope, clientID, organizationID).
+		Scan(&rt.ID, &rt.CreatedAt)
+	if err != nil {
+		_, ok := err.(*pgconn.PgError)
+		if ok {
+			return errorx.ErrInternalDB
+		}
+		return errorx.ErrInternalServer
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/user"
+	"github.com/imtanmoy/authn/user/importer"
+	"github.com/imtanmoy/httpx"
+	param "github.com/oceanicdev/chi-param"
+)
+
+// maxImportSize bounds the body of an import request
+const maxImportSize = 32 << 20
+
+type importPayload struct {
+	Options *importer.Options  `json:"options"`
+	Users   []*importer.Record `json:"users"`
+}
+
+// AdminHandler represent the http handler for user administration
+type AdminHandler struct {
+	useCase user.UseCase
+	*authx.Authx
+}
+
+// Import bulk-loads users with their original password hashes. JSON bodies
+// carry the options next to the users, CSV bodies take them from the query.
+func (handler *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var records []*importer.Record
+	opts := &importer.Options{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		var err error
+		records, err = importer.ParseCSV(r.Body)
+		if err != nil {
+			httpx.ResponseJSONError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts = importOptionsFromQuery(r)
+	} else {
+		data := &importPayload{}
+		if err := httpx.DecodeJSON(r, data); err != nil {
+			var mr *httpx.MalformedRequest
+			if errors.As(err, &mr) {
+				httpx.ResponseJSONError(w, r, mr.Status, mr.Status, mr.Msg)
+				return
+			}
+			panic(err)
+		}
+		records = data.Users
+		if data.Options != nil {
+			opts = data.Options
+		}
+	}
+	if len(records) == 0 {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "no users to import")
+		return
+	}
+
+	result, err := handler.useCase.Import(ctx, records, opts)
+	if err != nil {
+		panic(err)
+	}
+	httpx.ResponseJSON(w, http.StatusOK, result)
+}
+
+func importOptionsFromQuery(r *http.Request) *importer.Options {
+	q := r.URL.Query()
+	rounds, _ := strconv.Atoi(q.Get("firebase_rounds"))
+	memCost, _ := strconv.Atoi(q.Get("firebase_mem_cost"))
+	suffix, _ := strconv.ParseBool(q.Get("salt_suffix"))
+	return &importer.Options{
+		FirebaseSignerKey:     q.Get("firebase_signer_key"),
+		FirebaseSaltSeparator: q.Get("firebase_salt_separator"),
+		FirebaseRounds:        rounds,
+		FirebaseMemCost:       memCost,
+		SaltSuffix:            suffix,
+	}
+}
+
+// Unlock lifts the lockout of a user after failed logins
+func (handler *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	id, err := param.Int(r, "id")
+	if err != nil {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter", err)
+		return
+	}
+	if _, err := handler.UnlockUser(ctx, id); err != nil {
+		if errors.Is(err, errorx.ErrorNotFound) {
+			httpx.ResponseJSONError(w, r, http.StatusNotFound, "user not found", err)
+			return
+		}
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// UnlockIP lifts the lockout of a source IP after failed logins
+func (handler *AdminHandler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ip := net.ParseIP(chi.URLParam(r, "ip"))
+	if ip == nil {
+		httpx.ResponseJSONError(w, r, http.StatusBadRequest, "invalid request parameter")
+		return
+	}
+	if err := handler.Authx.UnlockIP(ctx, ip.String()); err != nil {
+		panic(err)
+	}
+	httpx.NoContent(w)
+}
+
+// NewAdminHandler will initialize the user administration endpoints
+func NewAdminHandler(r *chi.Mux, useCase user.UseCase, au *authx.Authx) {
+	handler := &AdminHandler{
+		useCase: useCase,
+		Authx:   au,
+	}
+	r.With(handler.AuthMiddleware, handler.AdminOnly).Post("/admin/users/import", handler.Import)
+	r.With(handler.AuthMiddleware, handler.AdminOnly).Post("/admin/users/{id}/unlock", handler.Unlock)
+	r.With(handler.AuthMiddleware, handler.AdminOnly).Post("/admin/ips/{ip}/unlock", handler.UnlockIP)
+}
@@ -0,0 +1,192 @@
+// Package importer reads users exported from other systems, together with
+// their original password hashes, so they can be bulk-loaded and upgraded to
+// the current hash algorithm on their first login.
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/imtanmoy/authn/internal/authx"
+)
+
+// Supported values of Record.Algorithm
+const (
+	AlgorithmBcrypt         = "bcrypt"
+	AlgorithmArgon2id       = "argon2id"
+	AlgorithmScrypt         = "scrypt"
+	AlgorithmDjangoPBKDF2   = "pbkdf2_sha256"
+	AlgorithmDjangoPBKDF2S1 = "pbkdf2_sha1"
+	AlgorithmFirebaseScrypt = "firebase_scrypt"
+	AlgorithmSaltedSHA256   = "salted_sha256"
+)
+
+// Record is one user of an import file
+type Record struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	// Algorithm tells how Hash, and Salt where needed, are to be read:
+	//  bcrypt, argon2id, scrypt: the encoded hash as produced by authn
+	//  pbkdf2_sha256, pbkdf2_sha1: the complete Django password field
+	//  firebase_scrypt: base64 passwordHash and salt of a Firebase export
+	//  salted_sha256: hex encoded hash, Salt is the raw salt
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+	Salt      string `json:"salt"`
+}
+
+// Options holds the hash parameters shared by all records of an import
+type Options struct {
+	// hash parameters of the Firebase project, as shown in its console
+	FirebaseSignerKey     string `json:"firebase_signer_key"`
+	FirebaseSaltSeparator string `json:"firebase_salt_separator"`
+	FirebaseRounds        int    `json:"firebase_rounds"`
+	FirebaseMemCost       int    `json:"firebase_mem_cost"`
+	// SaltSuffix is set when salted_sha256 hashes were computed over
+	// password+salt instead of salt+password
+	SaltSuffix bool `json:"salt_suffix"`
+}
+
+// Result summarises an import
+type Result struct {
+	Imported int         `json:"imported"`
+	Failed   []*RowError `json:"failed"`
+}
+
+// RowError tells why a record was not imported, Row starts at 1
+type RowError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+// Fail records that the record at index i was not imported
+func (res *Result) Fail(i int, rec *Record, err error) {
+	res.Failed = append(res.Failed, &RowError{Row: i + 1, Email: rec.Email, Error: err.Error()})
+}
+
+// ParseJSON reads a JSON array of records
+func ParseJSON(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("invalid JSON import: %v", err)
+	}
+	return records, nil
+}
+
+// ParseCSV reads records from CSV with a header row naming the columns, the
+// email, algorithm and hash columns are required
+func ParseCSV(r io.Reader) ([]*Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV import: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"email", "algorithm", "hash"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("invalid CSV import: missing column %q", name)
+		}
+	}
+	column := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var records []*Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV import: %v", err)
+		}
+		records = append(records, &Record{
+			Email:     column(row, "email"),
+			Name:      column(row, "name"),
+			Algorithm: column(row, "algorithm"),
+			Hash:      column(row, "hash"),
+			Salt:      column(row, "salt"),
+		})
+	}
+	return records, nil
+}
+
+// hashers checks the encoded hashes of an import, the bounds of the hash
+// parameters don't depend on the configured hashers
+var hashers = authx.DefaultHasherRegistry()
+
+// EncodedHash converts the hash of rec into the encoded form authx verifies.
+// Hashes with parameters out of bounds are rejected, they could take down the
+// server on the first login of the user.
+func (rec *Record) EncodedHash(opts *Options) (string, error) {
+	encoded, err := rec.encodedHash(opts)
+	if err != nil {
+		return "", err
+	}
+	if err := hashers.Check(encoded); err != nil {
+		return "", errors.New("hash is malformed or its parameters are out of bounds")
+	}
+	return encoded, nil
+}
+
+func (rec *Record) encodedHash(opts *Options) (string, error) {
+	switch rec.Algorithm {
+	case AlgorithmBcrypt:
+		return passThrough(rec.Hash, "$2a$", "$2b$", "$2y$")
+	case AlgorithmArgon2id:
+		return passThrough(rec.Hash, "$argon2id$")
+	case AlgorithmScrypt:
+		return passThrough(rec.Hash, "$scrypt$")
+	case AlgorithmDjangoPBKDF2, AlgorithmDjangoPBKDF2S1:
+		return passThrough(rec.Hash, rec.Algorithm+"$")
+	case AlgorithmFirebaseScrypt:
+		return rec.firebaseHash(opts)
+	case AlgorithmSaltedSHA256:
+		hash, err := hex.DecodeString(rec.Hash)
+		if err != nil || len(hash) != 32 {
+			return "", errors.New("hash is not a hex encoded SHA-256 digest")
+		}
+		return authx.EncodeSaltedSHA256(hash, []byte(rec.Salt), opts.SaltSuffix), nil
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q", rec.Algorithm)
+	}
+}
+
+func (rec *Record) firebaseHash(opts *Options) (string, error) {
+	if opts.FirebaseSignerKey == "" || opts.FirebaseRounds == 0 || opts.FirebaseMemCost == 0 {
+		return "", errors.New("firebase hash parameters are missing")
+	}
+	var decoded [4][]byte
+	for i, s := range []string{rec.Hash, rec.Salt, opts.FirebaseSaltSeparator, opts.FirebaseSignerKey} {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 in firebase hash parameters: %v", err)
+		}
+		decoded[i] = b
+	}
+	return authx.EncodeFirebaseScrypt(decoded[0], decoded[1], decoded[2], decoded[3],
+		opts.FirebaseMemCost, opts.FirebaseRounds), nil
+}
+
+func passThrough(hash string, prefixes ...string) (string, error) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(hash, prefix) {
+			return hash, nil
+		}
+	}
+	return "", errors.New("hash does not match its algorithm")
+}
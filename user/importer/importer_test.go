@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	records, err := ParseCSV(strings.NewReader("email, algorithm, hash, salt\n" +
+		"a@test.com,salted_sha256,00ff,pepper\n" +
+		"b@test.com,bcrypt,$2a$10$abc\n"))
+	require.Error(t, err, "rows must have as many fields as the header")
+	assert.Nil(t, records)
+
+	records, err = ParseCSV(strings.NewReader("email,name,algorithm,hash\n" +
+		"a@test.com,A,pbkdf2_sha256,pbkdf2_sha256$1$salt$aGFzaA==\n"))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, &Record{Email: "a@test.com", Name: "A", Algorithm: "pbkdf2_sha256", Hash: "pbkdf2_sha256$1$salt$aGFzaA=="}, records[0])
+
+	_, err = ParseCSV(strings.NewReader("email,hash\na@test.com,x\n"))
+	assert.Error(t, err, "algorithm column is required")
+}
+
+func TestParseJSON(t *testing.T) {
+	records, err := ParseJSON(strings.NewReader(`[{"email":"a@test.com","algorithm":"bcrypt","hash":"$2a$10$abc"}]`))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "bcrypt", records[0].Algorithm)
+
+	_, err = ParseJSON(strings.NewReader(`{"email":"a@test.com"}`))
+	assert.Error(t, err)
+}
+
+func TestRecord_EncodedHash(t *testing.T) {
+	hr := authx.DefaultHasherRegistry()
+	opts := &Options{
+		FirebaseSignerKey:     "jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==",
+		FirebaseSaltSeparator: "Bw==",
+		FirebaseRounds:        8,
+		FirebaseMemCost:       14,
+	}
+	valid := map[string]*Record{
+		"user1password": {Algorithm: AlgorithmFirebaseScrypt, Salt: "42xEC+ixf3L2lw==",
+			Hash: "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="},
+		// sha256("pepper" + "password")
+		"password": {Algorithm: AlgorithmSaltedSHA256, Salt: "pepper",
+			Hash: "4b65d30b048d9eab292a2ea50fd60423d3d5d581a6ed85169b8a0c4f7dd10c00"},
+	}
+	for password, rec := range valid {
+		encoded, err := rec.EncodedHash(opts)
+		require.NoError(t, err, rec.Algorithm)
+		ok, err := hr.Verify(password, encoded)
+		require.NoError(t, err, rec.Algorithm)
+		assert.True(t, ok, rec.Algorithm)
+	}
+
+	invalid := []*Record{
+		{Algorithm: "md5", Hash: "abc"},
+		{Algorithm: AlgorithmBcrypt, Hash: "$argon2id$v=19$..."},
+		{Algorithm: AlgorithmSaltedSHA256, Hash: "not hex"},
+		{Algorithm: AlgorithmFirebaseScrypt, Hash: "aGFzaA==", Salt: "c2FsdA=="},
+		{Algorithm: AlgorithmArgon2id, Hash: "$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"},
+		{Algorithm: AlgorithmArgon2id, Hash: "$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"},
+		{Algorithm: AlgorithmScrypt, Hash: "$scrypt$ln=29,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"},
+		{Algorithm: AlgorithmDjangoPBKDF2, Hash: "pbkdf2_sha256$2000000000$seasalt$ftMWvEdczZQK5azuap2CQYKRjHLa1wOuMrfMiYEswYQ="},
+	}
+	for _, rec := range invalid {
+		_, err := rec.EncodedHash(&Options{})
+		assert.Error(t, err, rec.Algorithm)
+	}
+}
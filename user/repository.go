@@ -8,14 +8,21 @@ import (
 
 type Repository interface {
 	FindAll(ctx context.Context) ([]*models.User, error)
-	//FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error)
+	// FindAllByOrganizationId lists the members of the organization
+	FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error)
 	Save(ctx context.Context, u *models.User) error
-	//SaveUserOrganization(ctx context.Context, orgUser *models.UserOrganization) error
+	// SaveUserOrganization adds the user to the organization with orgUser.Role,
+	// member when empty. Saving an existing membership again does nothing.
+	SaveUserOrganization(ctx context.Context, orgUser *models.UserOrganization) error
+	ExistsUserOrganization(ctx context.Context, userID, organizationID int) bool
 	ExistsByID(ctx context.Context, id int) bool
 	ExistsByEmail(ctx context.Context, email string) bool
 	Delete(ctx context.Context, u *models.User) error
 	Update(ctx context.Context, u *models.User) error
+	UpdatePassword(ctx context.Context, u *models.User) error
+	MarkEmailVerified(ctx context.Context, u *models.User) error
 	FindByID(ctx context.Context, id int) (*models.User, error)
 	FindByEmail(ctx context.Context, email string) (*models.User, error)
 	GetByEmail(ctx context.Context, identity string) (authx.AuthUser, error)
+	GetByID(ctx context.Context, id int) (authx.AuthUser, error)
 }
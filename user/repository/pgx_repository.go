@@ -4,24 +4,24 @@ import (
 	"context"
 	"fmt"
 	"github.com/imtanmoy/authn/internal/authx"
+	"github.com/imtanmoy/authn/internal/dbx"
 	"github.com/imtanmoy/authn/internal/errorx"
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/user"
 	"github.com/imtanmoy/logx"
 	"github.com/jackc/pgconn"
-	"github.com/jackc/pgx/v4"
 	"strings"
 	"time"
 )
 
 type pgxRepository struct {
-	conn *pgx.Conn
+	conn dbx.DB
 }
 
 var _ user.Repository = (*pgxRepository)(nil)
 
 // NewRepository will create an object that represent the user.Repository interface
-func NewPgxRepository(conn *pgx.Conn) user.Repository {
+func NewPgxRepository(conn dbx.DB) user.Repository {
 	return &pgxRepository{conn: conn}
 }
 
@@ -43,13 +43,26 @@ func (repo *pgxRepository) FindAll(ctx context.Context) ([]*models.User, error)
 	return users, nil
 }
 
-//func (repo *repository) FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error) {
-//	db := repo.db.WithContext(ctx)
-//	var users []*models.User
-//	err := db.Model(&users).Where("organization_id = ?", id).Select()
-//	err = godbx.ParsePgError(err)
-//	return users, err
-//}
+func (repo *pgxRepository) FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error) {
+	rows, err := repo.conn.Query(ctx, "SELECT u.id, u.name, u.email, u.created_at, u.updated_at "+
+		"FROM users u JOIN users_organizations uo ON uo.user_id = u.id "+
+		"WHERE uo.organization_id = $1 AND u.deleted_at IS NULL "+
+		"ORDER BY u.id", id)
+	if err != nil {
+		return nil, errorx.ErrInternalDB
+	}
+	defer rows.Close()
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &u)
+	}
+	return users, rows.Err()
+}
 
 func (repo *pgxRepository) Save(ctx context.Context, u *models.User) error {
 	lastInsertedID := 0
@@ -74,39 +87,67 @@ func (repo *pgxRepository) Save(ctx context.Context, u *models.User) error {
 	return err
 }
 
-//func (repo *repository) SaveUserOrganization(ctx context.Context, orgUser *models.UserOrganization) error {
-//	db := repo.db.WithContext(ctx)
-//	err := db.Insert(orgUser)
-//	return err
-//}
+func (repo *pgxRepository) SaveUserOrganization(ctx context.Context, orgUser *models.UserOrganization) error {
+	now := time.Now().UTC()
+	if orgUser.Role == "" {
+		orgUser.Role = models.RoleMember
+	}
+	_, err := repo.conn.Exec(ctx, "INSERT INTO users_organizations(user_id, organization_id, role, created_at) "+
+		"VALUES ($1,$2,$3,$4) ON CONFLICT (user_id, organization_id) DO NOTHING",
+		orgUser.UserId, orgUser.OrganizationId, orgUser.Role, now)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	orgUser.CreatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) ExistsUserOrganization(ctx context.Context, userID, organizationID int) bool {
+	found := 0
+	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) AS found FROM users_organizations "+
+		"WHERE user_id = $1 AND organization_id = $2", userID, organizationID).
+		Scan(&found)
+	if err != nil {
+		logx.Fatal(err)
+	}
+	return found > 0
+}
 
 func (repo *pgxRepository) FindByID(ctx context.Context, id int) (*models.User, error) {
 	var u models.User
-	err := repo.conn.QueryRow(ctx, "SELECT id, name, email, created_at, updated_at "+
+	var verifiedAt *time.Time
+	err := repo.conn.QueryRow(ctx, "SELECT id, name, email, email_verified_at, created_at, updated_at "+
 		"FROM users WHERE id = $1 "+
 		"AND deleted_at IS NULL", id).
-		Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+		Scan(&u.ID, &u.Name, &u.Email, &verifiedAt, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
 			return nil, errorx.ErrorNotFound
 		}
 		return nil, err
 	}
+	if verifiedAt != nil {
+		u.EmailVerifiedAt = *verifiedAt
+	}
 	return &u, nil
 }
 
 func (repo *pgxRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var u models.User
-	err := repo.conn.QueryRow(ctx, "SELECT id, name, email, password, created_at, updated_at "+
+	var verifiedAt *time.Time
+	err := repo.conn.QueryRow(ctx, "SELECT id, name, email, password, email_verified_at, created_at, updated_at "+
 		"FROM users WHERE email = $1 "+
 		"AND deleted_at IS NULL", email).
-		Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt)
+		Scan(&u.ID, &u.Name, &u.Email, &u.Password, &verifiedAt, &u.CreatedAt, &u.UpdatedAt)
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
 			return nil, errorx.ErrorNotFound
 		}
 		return nil, err
 	}
+	if verifiedAt != nil {
+		u.EmailVerifiedAt = *verifiedAt
+	}
 	return &u, err
 }
 
@@ -114,6 +155,10 @@ func (repo *pgxRepository) GetByEmail(ctx context.Context, identity string) (aut
 	return repo.FindByEmail(ctx, identity)
 }
 
+func (repo *pgxRepository) GetByID(ctx context.Context, id int) (authx.AuthUser, error) {
+	return repo.FindByID(ctx, id)
+}
+
 func (repo *pgxRepository) ExistsByID(ctx context.Context, id int) bool {
 	found := 0
 	err := repo.conn.QueryRow(ctx, "SELECT COUNT(*) AS found FROM users WHERE id = $1 AND deleted_at IS NULL", id).
@@ -141,6 +186,27 @@ func (repo *pgxRepository) Delete(ctx context.Context, u *models.User) error {
 	return err
 }
 
+func (repo *pgxRepository) UpdatePassword(ctx context.Context, u *models.User) error {
+	now := time.Now().UTC()
+	_, err := repo.conn.Exec(ctx, "UPDATE users SET password = $1, updated_at = $2 WHERE id = $3", u.Password, now, u.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	u.UpdatedAt = now
+	return nil
+}
+
+func (repo *pgxRepository) MarkEmailVerified(ctx context.Context, u *models.User) error {
+	now := time.Now().UTC()
+	_, err := repo.conn.Exec(ctx, "UPDATE users SET email_verified_at = $1, updated_at = $1 WHERE id = $2", now, u.ID)
+	if err != nil {
+		return errorx.ErrInternalDB
+	}
+	u.EmailVerifiedAt = now
+	u.UpdatedAt = now
+	return nil
+}
+
 func (repo *pgxRepository) Update(ctx context.Context, u *models.User) error {
 	now := time.Now().UTC()
 	_, err := repo.conn.Exec(ctx, "UPDATE users SET name = $1, updated_at= $2 WHERE id = $3", u.Name, now, u.ID)
@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"github.com/imtanmoy/authn/internal/errorx"
+	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/tests"
 	"github.com/imtanmoy/authn/user"
 	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"log"
 	"testing"
 )
@@ -264,3 +266,31 @@ func reverse(s string) string {
 	}
 	return result
 }
+
+func TestRepository_UserOrganizations(t *testing.T) {
+	tests.TruncateTestDB(db)
+	defer tests.TruncateTestDB(db)
+	ctx := context.Background()
+
+	users := tests.FakeUsers(3)
+	require.NoError(t, tests.InsertTestUsers(db, users))
+	_, err := db.Exec("INSERT INTO organizations(name, owner_id) VALUES ('Test Org', 1)")
+	require.NoError(t, err)
+
+	members, err := repo.FindAllByOrganizationId(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, members, 0)
+
+	owner := &models.UserOrganization{UserId: 1, OrganizationId: 1, Role: models.RoleOwner}
+	require.NoError(t, repo.SaveUserOrganization(ctx, owner))
+	orgUser := &models.UserOrganization{UserId: 2, OrganizationId: 1}
+	require.NoError(t, repo.SaveUserOrganization(ctx, orgUser))
+	assert.Equal(t, models.RoleMember, orgUser.Role)
+	require.NoError(t, repo.SaveUserOrganization(ctx, orgUser), "saving a membership again does nothing")
+	assert.True(t, repo.ExistsUserOrganization(ctx, 2, 1))
+	assert.False(t, repo.ExistsUserOrganization(ctx, 3, 1))
+
+	members, err = repo.FindAllByOrganizationId(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+}
@@ -30,6 +30,14 @@ func (o *userRepoMock) Delete(ctx context.Context, u *models.User) error {
 	panic("implement me")
 }
 
+func (o *userRepoMock) UpdatePassword(ctx context.Context, u *models.User) error {
+	panic("implement me")
+}
+
+func (o *userRepoMock) MarkEmailVerified(ctx context.Context, u *models.User) error {
+	panic("implement me")
+}
+
 func (o *userRepoMock) Update(ctx context.Context, u *models.User) error {
 	panic("implement me")
 }
@@ -47,11 +55,27 @@ func (o *userRepoMock) GetByEmail(ctx context.Context, identity string) (authx.A
 	panic("implement me")
 }
 
+func (o *userRepoMock) GetByID(ctx context.Context, id int) (authx.AuthUser, error) {
+	panic("implement me")
+}
+
 func (o *userRepoMock) ExistsByID(ctx context.Context, id int) bool {
 	args := o.Called(ctx, id)
 	return args.Bool(0)
 }
 
+func (o *userRepoMock) FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error) {
+	panic("implement me")
+}
+
+func (o *userRepoMock) SaveUserOrganization(ctx context.Context, orgUser *models.UserOrganization) error {
+	panic("implement me")
+}
+
+func (o *userRepoMock) ExistsUserOrganization(ctx context.Context, userID, organizationID int) bool {
+	panic("implement me")
+}
+
 var _ Repository = (*userRepoMock)(nil)
 
 func (o *userRepoMock) Find(ctx context.Context, id int) (*models.User, error) {
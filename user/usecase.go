@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/imtanmoy/authn/models"
+	"github.com/imtanmoy/authn/user/importer"
 )
 
 // UseCase represent the user's use cases
@@ -16,4 +17,16 @@ type UseCase interface {
 	////Delete(ctx context.Context, u *models.User) error
 	//Exists(ctx context.Context, id int) bool
 	ExistsByEmail(ctx context.Context, email string) bool
+	FindByID(ctx context.Context, id int) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// FindAllByOrganizationId lists the members of the organization
+	FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error)
+	// AddToOrganization makes u a member of org with role, adding a member
+	// again does nothing
+	AddToOrganization(ctx context.Context, u *models.User, org *models.Organization, role string) error
+	// IsMember reports whether u belongs to org
+	IsMember(ctx context.Context, u *models.User, org *models.Organization) bool
+	// Import creates users with password hashes taken over from another
+	// system. Records which can not be imported are reported in the result.
+	Import(ctx context.Context, records []*importer.Record, opts *importer.Options) (*importer.Result, error)
 }
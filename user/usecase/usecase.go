@@ -2,10 +2,14 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/imtanmoy/authn/models"
 	"github.com/imtanmoy/authn/user"
+	"github.com/imtanmoy/authn/user/importer"
 )
 
 type useCase struct {
@@ -13,18 +17,6 @@ type useCase struct {
 	contextTimeout time.Duration
 }
 
-//func (uc *useCase) StoreWithOrg(ctx context.Context, u *models.User, org *models.Organization) error {
-//	err := uc.userRepo.Save(ctx, u)
-//	if err != nil {
-//		return err
-//	}
-//	var ou models.UserOrganization
-//	ou.UserId = u.ID
-//	ou.OrganizationId = org.ID
-//	err = uc.userRepo.SaveUserOrganization(ctx, &ou)
-//	return err
-//}
-
 var _ user.UseCase = (*useCase)(nil)
 
 // NewUseCase will create new an useCase object representation of user.UseCase interface
@@ -43,6 +35,63 @@ func (uc *useCase) Save(ctx context.Context, u *models.User) error {
 	return uc.userRepo.Save(ctx, u)
 }
 
+func (uc *useCase) FindByID(ctx context.Context, id int) (*models.User, error) {
+	return uc.userRepo.FindByID(ctx, id)
+}
+
+func (uc *useCase) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return uc.userRepo.FindByEmail(ctx, email)
+}
+
+func (uc *useCase) FindAllByOrganizationId(ctx context.Context, id int) ([]*models.User, error) {
+	return uc.userRepo.FindAllByOrganizationId(ctx, id)
+}
+
+func (uc *useCase) AddToOrganization(ctx context.Context, u *models.User, org *models.Organization, role string) error {
+	return uc.userRepo.SaveUserOrganization(ctx, &models.UserOrganization{UserId: u.ID, OrganizationId: org.ID, Role: role})
+}
+
+func (uc *useCase) IsMember(ctx context.Context, u *models.User, org *models.Organization) bool {
+	return uc.userRepo.ExistsUserOrganization(ctx, u.ID, org.ID)
+}
+
+func (uc *useCase) Import(ctx context.Context, records []*importer.Record, opts *importer.Options) (*importer.Result, error) {
+	result := &importer.Result{Failed: make([]*importer.RowError, 0)}
+	for i, rec := range records {
+		u, err := newImportedUser(rec, opts)
+		if err != nil {
+			result.Fail(i, rec, err)
+			continue
+		}
+		if uc.userRepo.ExistsByEmail(ctx, u.Email) {
+			result.Fail(i, rec, errors.New("user with this email already exists"))
+			continue
+		}
+		if err := uc.userRepo.Save(ctx, u); err != nil {
+			result.Fail(i, rec, err)
+			continue
+		}
+		result.Imported++
+	}
+	return result, nil
+}
+
+func newImportedUser(rec *importer.Record, opts *importer.Options) (*models.User, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(rec.Email))
+	if err != nil {
+		return nil, errors.New("invalid email")
+	}
+	hash, err := rec.EncodedHash(opts)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(rec.Name)
+	if name == "" {
+		name = address.Address[:strings.Index(address.Address, "@")]
+	}
+	return &models.User{Name: name, Email: address.Address, Password: hash}, nil
+}
+
 //func (uc *useCase) GetByID(ctx context.Context, id int) (*models.User, error) {
 //	if !uc.Exists(ctx, id) {
 //		return nil, errorx.ErrorNotFound